@@ -0,0 +1,89 @@
+package account
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+
+	errs "github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// RuleSchema is the rule reported by the validation errors of the context information which doesn't match the JSON schema of its namespace
+const RuleSchema = "schema"
+
+// ContextInformationConfiguration represents the configuration of the namespaces of the user context information
+type ContextInformationConfiguration interface {
+	GetUserContextNamespaces() map[string]string
+}
+
+// ContextInformationPolicy protects the namespaces of the user context information.
+// A namespace is a top level key of the context information named after the ID of the API client which owns it:
+// only this client can modify it and, if the configuration provides a JSON schema for the namespace, the namespace
+// must match the schema once modified. The namespaces of the registered OAuth clients are always protected,
+// the configuration is only needed to protect the namespaces of the other clients or to add a schema.
+// The other top level keys are shared by all the clients.
+type ContextInformationPolicy struct {
+	namespaces map[string]*gojsonschema.Schema
+}
+
+// NewContextInformationPolicy creates a context information policy from the given configuration,
+// which maps the namespaces to their JSON schema (or to an empty string if the namespace has no schema),
+// and the IDs of the registered clients whose namespaces are protected without a schema unless configured
+// returns an error if a schema of the configuration is invalid
+func NewContextInformationPolicy(config ContextInformationConfiguration, clientIDs ...string) (*ContextInformationPolicy, error) {
+	namespaces := map[string]*gojsonschema.Schema{}
+	for _, clientID := range clientIDs {
+		namespaces[clientID] = nil
+	}
+	for namespace, schema := range config.GetUserContextNamespaces() {
+		if strings.TrimSpace(schema) == "" {
+			namespaces[namespace] = nil
+			continue
+		}
+		compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid JSON schema of the '%s' context namespace", namespace)
+		}
+		namespaces[namespace] = compiled
+	}
+	return &ContextInformationPolicy{namespaces: namespaces}, nil
+}
+
+// IsNamespace returns true if the given top level key of the context information is the namespace of an API client
+func (p *ContextInformationPolicy) IsNamespace(key string) bool {
+	_, found := p.namespaces[key]
+	return found
+}
+
+// Apply applies the JSON merge patch (RFC 7396) sent by the given API client to the context information and returns the result.
+// returns ForbiddenError if the patch modifies the namespace of another client
+// and ValidationError if a modified namespace doesn't match its schema anymore
+func (p *ContextInformationPolicy) Apply(clientID string, contextInformation ContextInformation, patch map[string]interface{}) (ContextInformation, error) {
+	for key := range patch {
+		if p.IsNamespace(key) && key != clientID {
+			return nil, errors.NewForbiddenError(fmt.Sprintf("the '%s' context namespace can't be modified by the '%s' client", key, clientID))
+		}
+	}
+	result := contextInformation.MergePatch(patch)
+	for key := range patch {
+		schema := p.namespaces[key]
+		value, found := result[key]
+		if schema == nil || !found {
+			continue
+		}
+		validation, err := schema.Validate(gojsonschema.NewGoLoader(value))
+		if err != nil {
+			return nil, errors.NewValidationError(key, value, RuleSchema, err.Error())
+		}
+		if !validation.Valid() {
+			messages := make([]string, len(validation.Errors()))
+			for i, validationErr := range validation.Errors() {
+				messages[i] = validationErr.String()
+			}
+			return nil, errors.NewValidationError(key, value, RuleSchema, strings.Join(messages, "; "))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package account_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type contextInformationConfig map[string]string
+
+func (c contextInformationConfig) GetUserContextNamespaces() map[string]string { return c }
+
+func TestContextInformationMergePatch(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	contextInformation := account.ContextInformation{
+		"last_visited": "yesterday",
+		"recent": map[string]interface{}{
+			"spaces":   []interface{}{"a", "b"},
+			"projects": "x",
+		},
+	}
+
+	result := contextInformation.MergePatch(map[string]interface{}{
+		"last_visited": nil,
+		"recent": map[string]interface{}{
+			"projects": nil,
+			"pipelines": map[string]interface{}{
+				"count": 1,
+			},
+		},
+		"count": 2,
+	})
+
+	assert.Equal(t, account.ContextInformation{
+		"recent": map[string]interface{}{
+			"spaces": []interface{}{"a", "b"},
+			"pipelines": map[string]interface{}{
+				"count": 1,
+			},
+		},
+		"count": 2,
+	}, result)
+	// the patched context information is left untouched
+	assert.Equal(t, "yesterday", contextInformation["last_visited"])
+	assert.Equal(t, "x", contextInformation["recent"].(map[string]interface{})["projects"])
+}
+
+func TestContextInformationPolicy(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	policy, err := account.NewContextInformationPolicy(contextInformationConfig{
+		"fabric8-ui": `{"type": "object", "properties": {"theme": {"type": "string"}}}`,
+		"che":        "",
+	})
+	require.Nil(t, err)
+	contextInformation := account.ContextInformation{
+		"shared":     "value",
+		"fabric8-ui": map[string]interface{}{"theme": "dark"},
+	}
+
+	t.Run("own namespace", func(t *testing.T) {
+		result, err := policy.Apply("fabric8-ui", contextInformation, map[string]interface{}{
+			"fabric8-ui": map[string]interface{}{"theme": "light", "layout": "grid"},
+		})
+		require.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"theme": "light", "layout": "grid"}, result["fabric8-ui"])
+		assert.Equal(t, "value", result["shared"])
+	})
+
+	t.Run("shared keys", func(t *testing.T) {
+		result, err := policy.Apply("", contextInformation, map[string]interface{}{"shared": nil})
+		require.Nil(t, err)
+		_, found := result["shared"]
+		assert.False(t, found)
+	})
+
+	t.Run("namespace of another client", func(t *testing.T) {
+		_, err := policy.Apply("che", contextInformation, map[string]interface{}{
+			"fabric8-ui": nil,
+		})
+		require.NotNil(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, err)
+	})
+
+	t.Run("schema mismatch", func(t *testing.T) {
+		_, err := policy.Apply("fabric8-ui", contextInformation, map[string]interface{}{
+			"fabric8-ui": map[string]interface{}{"theme": 1},
+		})
+		assertValidationRule(t, account.RuleSchema, err)
+	})
+
+	t.Run("namespace of a registered client", func(t *testing.T) {
+		policy, err := account.NewContextInformationPolicy(contextInformationConfig{}, "vscode")
+		require.Nil(t, err)
+		assert.True(t, policy.IsNamespace("vscode"))
+		_, err = policy.Apply("fabric8-ui", contextInformation, map[string]interface{}{
+			"vscode": map[string]interface{}{"theme": "dark"},
+		})
+		require.NotNil(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, err)
+		_, err = policy.Apply("vscode", contextInformation, map[string]interface{}{
+			"vscode": map[string]interface{}{"theme": "dark"},
+		})
+		require.Nil(t, err)
+	})
+
+	t.Run("invalid schema", func(t *testing.T) {
+		_, err := account.NewContextInformationPolicy(contextInformationConfig{"che": "{"})
+		assert.NotNil(t, err)
+	})
+}
@@ -3,6 +3,7 @@ package account
 import (
 	"context"
 	"database/sql/driver"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +22,11 @@ import (
 const (
 	// KeycloakIDP is the name of the main Keycloak Identity Provider
 	KeycloakIDP string = "kc"
+	// GitHubIDP is the provider type of the linked GitHub accounts
+	GitHubIDP string = "github"
+	// OpenShiftIDPPrefix is the prefix of the provider type of the linked OpenShift accounts.
+	// The provider type is the prefix followed by the cluster name.
+	OpenShiftIDPPrefix string = "openshift-"
 )
 
 // NullUUID can be used with the standard sql package to represent a
@@ -74,6 +80,8 @@ type Identity struct {
 	// Link to User
 	UserID NullUUID `sql:"type:uuid"`
 	User   User
+	// The version of the identity, incremented on every save
+	Version int
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -84,8 +92,8 @@ func (m Identity) TableName() string {
 
 // GetETagData returns the field values to use to generate the ETag
 func (m Identity) GetETagData() []interface{} {
-	// using the 'ID' and 'UpdatedAt' (converted to number of seconds since epoch) fields
-	return []interface{}{m.ID, strconv.FormatInt(m.UpdatedAt.Unix(), 10)}
+	// using the 'ID', 'UpdatedAt' (converted to number of seconds since epoch) and 'Version' fields
+	return []interface{}{m.ID, strconv.FormatInt(m.UpdatedAt.Unix(), 10), m.Version}
 }
 
 // GetLastModified returns the last modification time
@@ -109,13 +117,14 @@ type IdentityRepository interface {
 	repository.Exister
 	Load(ctx context.Context, id uuid.UUID) (*Identity, error)
 	Create(ctx context.Context, identity *Identity) error
-	Lookup(ctx context.Context, username, profileURL, providerType string) (*Identity, error)
+	Lookup(ctx context.Context, providerType string, username string) (*Identity, error)
 	Save(ctx context.Context, identity *Identity) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	Query(funcs ...func(*gorm.DB) *gorm.DB) ([]Identity, error)
 	List(ctx context.Context) ([]Identity, error)
 	IsValid(context.Context, uuid.UUID) bool
-	Search(ctx context.Context, q string, start int, limit int) ([]Identity, int, error)
+	Count(funcs ...func(*gorm.DB) *gorm.DB) (int, error)
+	Search(ctx context.Context, q string, filter IdentitySearchFilter, start int, limit int) ([]IdentitySearchResult, int, error)
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -148,12 +157,16 @@ func (m *GormIdentityRepository) CheckExists(ctx context.Context, id string) err
 }
 
 // Create creates a new record.
+// Returns VersionConflictError if the account is already recorded as an identity of the identity provider.
 func (m *GormIdentityRepository) Create(ctx context.Context, model *Identity) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "create"}, time.Now())
 	if model.ID == uuid.Nil {
 		model.ID = uuid.NewV4()
 	}
 	err := m.db.Create(model).Error
+	if gormsupport.IsUniqueViolation(err, "idx_identities_provider_type_username") {
+		return errors.NewVersionConflictError(fmt.Sprintf("account %s of %s is already recorded as an identity", model.Username, model.ProviderType))
+	}
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"identity_id": model.ID,
@@ -167,48 +180,58 @@ func (m *GormIdentityRepository) Create(ctx context.Context, model *Identity) er
 	return nil
 }
 
-// Lookup looks for an existing identity with the given `profileURL` or creates a new one
-func (m *GormIdentityRepository) Lookup(ctx context.Context, username, profileURL, providerType string) (*Identity, error) {
-	if username == "" || profileURL == "" || providerType == "" {
-		return nil, errs.New("Cannot lookup identity with empty username, profile URL or provider type")
+// Lookup returns the identity of the account with the given username in the given identity provider, along with
+// the user the account is linked to. The username is case insensitive.
+// Returns NotFoundError if the account is not linked to any user.
+func (m *GormIdentityRepository) Lookup(ctx context.Context, providerType string, username string) (*Identity, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "lookup"}, time.Now())
+	var native Identity
+	err := m.db.Scopes(IdentityWithUser()).
+		Where("provider_type = ? AND lower(username) = lower(?) AND user_id IS NOT NULL", providerType, username).
+		Order("created_at").First(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("identity", providerType+"/"+username)
 	}
-	log.Debug(nil, nil, "Looking for identity of user with profile URL=%s\n", profileURL)
-	// bind the assignee to an existing identity, or create a new one
-	identity, err := m.First(IdentityFilterByProfileURL(profileURL))
 	if err != nil {
-		return nil, errs.Wrapf(err, "failed to lookup identity by profileURL '%s'", profileURL)
-	}
-	if identity == nil {
-		// create the identity if it does not exist yet
-		log.Debug(nil, nil, "Creating an identity for username '%s' with profile '%s' on '%s'\n", username, profileURL, providerType)
-		identity = &Identity{
-			ProviderType: providerType,
-			Username:     username,
-			ProfileURL:   &profileURL,
-		}
-		err = m.Create(context.Background(), identity)
-		if err != nil {
-			return nil, errs.Wrap(err, "failed to create identity during lookup")
-		}
-	} else {
-		// use existing identity
-		log.Debug(nil, nil, "Using existing identity with ID: %v", identity.ID.String())
+		return nil, errors.NewInternalError(ctx, err)
 	}
-	log.Debug(nil, nil, "Found identity of user with profile URL=%s: %s", profileURL, identity.ID)
-	return identity, nil
+	return &native, nil
 }
 
 // Save modifies a single record.
+// The changes of the username of the identities of a user are appended to the user profile history.
+// Returns a stale VersionConflictError if the identity has been saved since the model was loaded
+// or a VersionConflictError if the account is already recorded as another identity of the identity provider.
 func (m *GormIdentityRepository) Save(ctx context.Context, model *Identity) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "save"}, time.Now())
 
+	var changes []UserProfileChange
+	var stored Identity
+	if err := m.db.Where("id = ?", model.ID).First(&stored).Error; err == nil {
+		changes = identityProfileChanges(stored, *model)
+		if err := incrementVersion(m.db, m.TableName(), "identity", model.ID, model.Version); err != nil {
+			return err
+		}
+		model.Version++
+	} else if err != gorm.ErrRecordNotFound {
+		return errs.WithStack(err)
+	}
 	err := m.db.Save(model).Error
+	if gormsupport.IsUniqueViolation(err, "idx_identities_provider_type_username") {
+		return errors.NewVersionConflictError(fmt.Sprintf("account %s of %s is already recorded as an identity", model.Username, model.ProviderType))
+	}
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	if err := recordProfileChanges(ctx, m.db, changes); err != nil {
+		return err
+	}
 
 	log.Debug(ctx, map[string]interface{}{
 		"identity_id": model.ID,
 	}, "Identity saved!")
 
-	return errs.WithStack(err)
+	return nil
 }
 
 // Delete removes a single record.
@@ -251,6 +274,17 @@ func (m *GormIdentityRepository) Query(funcs ...func(*gorm.DB) *gorm.DB) ([]Iden
 	return identities, nil
 }
 
+// Count returns the number of identities that match the given criteria
+func (m *GormIdentityRepository) Count(funcs ...func(*gorm.DB) *gorm.DB) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "count"}, time.Now())
+	var count int
+	err := m.db.Scopes(funcs...).Model(&Identity{}).Count(&count).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
+
 // First returns the first Identity element that matches the given criteria
 func (m *GormIdentityRepository) First(funcs ...func(*gorm.DB) *gorm.DB) (*Identity, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "first"}, time.Now())
@@ -280,6 +314,13 @@ func IdentityFilterByUserID(userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// IdentityFilterByIDs is a gorm filter on the identities with the given IDs
+func IdentityFilterByIDs(identityIDs []uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("identities.id IN (?)", identityIDs)
+	}
+}
+
 // IdentityFilterByUsername is a gorm filter by 'username'
 func IdentityFilterByUsername(username string) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -287,6 +328,13 @@ func IdentityFilterByUsername(username string) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// IdentityFilterByUsernameIgnoreCase is a gorm filter by 'username' which ignores the case
+func IdentityFilterByUsernameIgnoreCase(username string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("lower(username) = lower(?)", username)
+	}
+}
+
 // IdentityFilterByProfileURL is a gorm filter by 'profile_url'
 func IdentityFilterByProfileURL(profileURL string) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -308,6 +356,13 @@ func IdentityWithUser() func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// IdentityWithDeleted is a gorm filter which includes the deleted identities
+func IdentityWithDeleted() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}
+}
+
 // IdentityFilterByProviderType is a gorm filter by 'provider_type'
 func IdentityFilterByProviderType(providerType string) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -322,6 +377,67 @@ func IdentityFilterByRegistrationCompleted(registrationCompleted bool) func(db *
 	}
 }
 
+// IdentityJoinUser is a gorm filter joining the (not deleted) user of the identities,
+// so that the identities can be filtered or sorted on the 'users' columns.
+func IdentityJoinUser() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("identities.*").Joins("JOIN users ON users.id = identities.user_id AND users.deleted_at IS NULL")
+	}
+}
+
+// IdentityFilterByUserEmail is a gorm filter by 'users.email'. It requires IdentityJoinUser
+func IdentityFilterByUserEmail(email string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.email = ?", email)
+	}
+}
+
+// IdentityFilterByUserCluster is a gorm filter by 'users.cluster'. It requires IdentityJoinUser
+func IdentityFilterByUserCluster(cluster string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.cluster = ?", cluster)
+	}
+}
+
+// IdentityFilterByUserCreatedAfter is a gorm filter on the users created at or after the given date. It requires IdentityJoinUser
+func IdentityFilterByUserCreatedAfter(date time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.created_at >= ?", date)
+	}
+}
+
+// IdentityFilterByUserCreatedBefore is a gorm filter on the users created before the given date. It requires IdentityJoinUser
+func IdentityFilterByUserCreatedBefore(date time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.created_at < ?", date)
+	}
+}
+
+// IdentityFilterByApprovalStatus is a gorm filter on the identities whose pending approval has the given status
+func IdentityFilterByApprovalStatus(status string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`EXISTS (SELECT 1 FROM pending_approvals WHERE pending_approvals.keycloak_user_id = identities.id
+			AND pending_approvals.status = ? AND pending_approvals.deleted_at IS NULL)`, status)
+	}
+}
+
+// IdentityOrderBy is a gorm filter sorting the identities by the given ORDER BY clauses
+func IdentityOrderBy(orders ...string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, order := range orders {
+			db = db.Order(order)
+		}
+		return db
+	}
+}
+
+// IdentityPage is a gorm filter returning the page of identities starting at 'start'
+func IdentityPage(start int, limit int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Offset(start).Limit(limit)
+	}
+}
+
 // List return all user identities
 func (m *GormIdentityRepository) List(ctx context.Context) ([]Identity, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "list"}, time.Now())
@@ -348,38 +464,87 @@ func (m *GormIdentityRepository) IsValid(ctx context.Context, id uuid.UUID) bool
 	return true
 }
 
-// Search searches for Identites where FullName like %q% or users.email like %q% or users.username like %q%
-func (m *GormIdentityRepository) Search(ctx context.Context, q string, start int, limit int) ([]Identity, int, error) {
+// IdentitySearchFilter holds the optional criteria which narrow down the results of a search
+type IdentitySearchFilter struct {
+	// Only return the identities of the given provider type. Defaults to the Keycloak identities
+	// so that a user with linked accounts is returned only once.
+	ProviderType          *string
+	Cluster               *string
+	RegistrationCompleted *bool
+	// Only return the members of the team
+	TeamID *uuid.UUID
+}
+
+// IdentitySearchResult is an identity matching a search, along with its relevance
+type IdentitySearchResult struct {
+	Identity
+	Rank float64
+}
+
+// searchLikeEscaper escapes the wildcard characters of a LIKE pattern
+var searchLikeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Search searches for Identites matching every term of q on users.full_name, users.email or identities.username,
+// either by substring or by trigram similarity. Results are ordered by rank, i.e. the best trigram similarity
+// of the query plus its full text rank on users.full_name.
+func (m *GormIdentityRepository) Search(ctx context.Context, q string, filter IdentitySearchFilter, start int, limit int) ([]IdentitySearchResult, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "search"}, time.Now())
+	terms := strings.Fields(strings.ToLower(q))
+	if len(terms) == 0 {
+		return []IdentitySearchResult{}, 0, nil
+	}
+	query := strings.Join(terms, " ")
 
 	db := m.db.Model(&Identity{})
 	db = db.Offset(start)
 	db = db.Limit(limit)
 	// FIXME : returning the identities.id just for the sake of consistency with the other User APIs.
-	db = db.Select("count(*) over () as cnt2 ,identities.id as identity_id,identities.username,users.*")
-	db = db.Joins("LEFT JOIN users ON identities.user_id = users.id")
-	db = db.Where("LOWER(users.full_name) like ?", "%"+strings.ToLower(q)+"%")
-	db = db.Or("users.email like ?", "%"+strings.ToLower(q)+"%")
-	db = db.Or("identities.username like ?", "%"+strings.ToLower(q)+"%")
-	db = db.Group("identities.id,identities.username,users.id")
-	//db = db.Preload("user")
+	db = db.Select(`count(*) over () as cnt2, identities.id as identity_id, identities.username as identity_username,
+		identities.provider_type as identity_provider_type, identities.registration_completed as identity_registration_completed,
+		greatest(similarity(lower(users.full_name), ?), similarity(lower(users.email), ?), similarity(lower(identities.username), ?))
+		+ ts_rank(to_tsvector('simple', coalesce(users.full_name, '')), plainto_tsquery('simple', ?)) as search_rank,
+		users.*`, query, query, query, query)
+	db = db.Joins("JOIN users ON identities.user_id = users.id AND users.deleted_at IS NULL")
+	for _, term := range terms {
+		pattern := "%" + searchLikeEscaper.Replace(term) + "%"
+		db = db.Where(`(lower(users.full_name) LIKE ? OR lower(users.email) LIKE ? OR lower(identities.username) LIKE ?
+			OR lower(users.full_name) % ? OR lower(identities.username) % ?)`, pattern, pattern, pattern, term, term)
+	}
+	providerType := KeycloakIDP
+	if filter.ProviderType != nil {
+		providerType = *filter.ProviderType
+	}
+	db = db.Where("identities.provider_type = ?", providerType)
+	if filter.Cluster != nil {
+		db = db.Where("users.cluster = ?", *filter.Cluster)
+	}
+	if filter.RegistrationCompleted != nil {
+		db = db.Where("identities.registration_completed = ?", *filter.RegistrationCompleted)
+	}
+	if filter.TeamID != nil {
+		db = db.Where("EXISTS (SELECT 1 FROM team_members WHERE team_members.identity_id = identities.id AND team_members.team_id = ?)", *filter.TeamID)
+	}
+	db = db.Order("search_rank desc, identities.username")
 
 	rows, err := db.Rows()
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, errors.NewInternalError(ctx, err)
 	}
 	defer rows.Close()
 
-	result := []Identity{}
-	value := Identity{}
+	result := []IdentitySearchResult{}
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, 0, errors.NewInternalError(ctx, err)
 	}
 
-	// need to set up a result for Scan() in order to extract total count.
+	// need to set up a result for Scan() in order to extract total count and rank.
 	var count int
 	var identityID string
 	var identityUsername string
+	var identityProviderType string
+	var identityRegistrationCompleted bool
+	var rank float64
 	var ignore interface{}
 	columnValues := make([]interface{}, len(columns))
 
@@ -387,12 +552,16 @@ func (m *GormIdentityRepository) Search(ctx context.Context, q string, start int
 		columnValues[index] = &ignore
 	}
 	columnValues[0] = &count
+	columnValues[1] = &identityID
+	columnValues[2] = &identityUsername
+	columnValues[3] = &identityProviderType
+	columnValues[4] = &identityRegistrationCompleted
+	columnValues[5] = &rank
 	// FIXME When our User Profile endpoints start giving "user" response
 	// instead of "identity" response, the identity.ID would be less relevant.
 
 	for rows.Next() {
-		columnValues[1] = &identityID
-		columnValues[2] = &identityUsername
+		value := IdentitySearchResult{}
 		db.ScanRows(rows, &value.User)
 
 		if err = rows.Scan(columnValues...); err != nil {
@@ -403,11 +572,17 @@ func (m *GormIdentityRepository) Search(ctx context.Context, q string, start int
 		if err != nil {
 			return nil, 0, errors.NewInternalError(ctx, err)
 		}
-
 		value.Username = identityUsername
+		value.ProviderType = identityProviderType
+		value.RegistrationCompleted = identityRegistrationCompleted
+		value.UserID = NullUUID{UUID: value.User.ID, Valid: true}
+		value.Rank = rank
 
 		result = append(result, value)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
 
 	return result, count, nil
 }
@@ -1,6 +1,7 @@
 package account_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/fabric8-services/fabric8-auth/account"
@@ -93,6 +94,93 @@ func (s *identityBlackBoxTest) TestOKToSave() {
 	require.Nil(s.T(), err, "Could not update identity")
 }
 
+func (s *identityBlackBoxTest) TestLookup() {
+	// given a GitHub account linked to a user
+	user := account.User{
+		Email:    "TestLookup" + uuid.NewV4().String() + "@example.com",
+		FullName: "TestLookup",
+		Cluster:  "https://api.starter-us-east-2.openshift.com",
+	}
+	require.Nil(s.T(), account.NewUserRepository(s.DB).Create(s.Ctx, &user))
+	githubLogin := "TestLookup" + uuid.NewV4().String()
+	identity := &account.Identity{
+		Username:     githubLogin,
+		ProviderType: account.GitHubIDP,
+		UserID:       account.NullUUID{UUID: user.ID, Valid: true},
+	}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, identity))
+
+	s.T().Run("ignoring case", func(t *testing.T) {
+		// when
+		found, err := s.repo.Lookup(s.Ctx, account.GitHubIDP, strings.ToLower(githubLogin))
+		// then
+		require.Nil(t, err)
+		assert.Equal(t, identity.ID, found.ID)
+		assert.Equal(t, user.ID, found.User.ID)
+	})
+
+	s.T().Run("other provider", func(t *testing.T) {
+		_, err := s.repo.Lookup(s.Ctx, account.KeycloakIDP, githubLogin)
+		notFound, _ := errors.IsNotFoundError(err)
+		assert.True(t, notFound)
+	})
+
+	s.T().Run("account not linked to any user", func(t *testing.T) {
+		unlinked := &account.Identity{
+			Username:     "TestLookup" + uuid.NewV4().String(),
+			ProviderType: account.GitHubIDP,
+		}
+		require.Nil(t, s.repo.Create(s.Ctx, unlinked))
+		_, err := s.repo.Lookup(s.Ctx, account.GitHubIDP, unlinked.Username)
+		notFound, _ := errors.IsNotFoundError(err)
+		assert.True(t, notFound)
+	})
+}
+
+func (s *identityBlackBoxTest) TestRecordAccountOnce() {
+	// given a GitHub account recorded as an identity
+	githubLogin := "TestRecordAccountOnce" + uuid.NewV4().String()
+	recorded := &account.Identity{
+		Username:     githubLogin,
+		ProviderType: account.GitHubIDP,
+	}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, recorded))
+
+	s.T().Run("create conflicts", func(t *testing.T) {
+		// when
+		err := s.repo.Create(s.Ctx, &account.Identity{
+			Username:     strings.ToUpper(githubLogin),
+			ProviderType: account.GitHubIDP,
+		})
+		// then
+		conflict, _ := errors.IsVersionConflictError(err)
+		assert.True(t, conflict)
+	})
+
+	s.T().Run("save conflicts", func(t *testing.T) {
+		// given
+		other := &account.Identity{
+			Username:     "TestRecordAccountOnce" + uuid.NewV4().String(),
+			ProviderType: account.GitHubIDP,
+		}
+		require.Nil(t, s.repo.Create(s.Ctx, other))
+		// when
+		other.Username = githubLogin
+		err := s.repo.Save(s.Ctx, other)
+		// then
+		conflict, _ := errors.IsVersionConflictError(err)
+		assert.True(t, conflict)
+	})
+
+	s.T().Run("other provider ok", func(t *testing.T) {
+		err := s.repo.Create(s.Ctx, &account.Identity{
+			Username:     githubLogin,
+			ProviderType: account.OpenShiftIDPPrefix + "starter-us-east-2",
+		})
+		assert.Nil(t, err)
+	})
+}
+
 func createAndLoad(s *identityBlackBoxTest) *account.Identity {
 	identity := &account.Identity{
 		ID:           uuid.NewV4(),
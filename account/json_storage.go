@@ -25,6 +25,37 @@ func (f ContextInformation) Equal(u convert.Equaler) bool {
 	return reflect.DeepEqual(f, other)
 }
 
+// MergePatch returns the result of the JSON merge patch (RFC 7396) applied to the context information:
+// the patch values replace the existing ones, except the objects which are merged recursively
+// and the null values which remove the existing keys.
+func (f ContextInformation) MergePatch(patch map[string]interface{}) ContextInformation {
+	result, _ := mergePatch(map[string]interface{}(f), patch).(map[string]interface{})
+	return ContextInformation(result)
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	// the target is copied so that the merge doesn't modify it
+	result := make(map[string]interface{}, len(targetObject)+len(patchObject))
+	if ok {
+		for key, value := range targetObject {
+			result[key] = value
+		}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(result, key)
+		} else {
+			result[key] = mergePatch(result[key], value)
+		}
+	}
+	return result
+}
+
 func (j ContextInformation) Value() (driver.Value, error) {
 	return toBytes(j)
 }
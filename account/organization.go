@@ -0,0 +1,158 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const organizationTableName = "organizations"
+
+// Organization represents a company or a community which manages the access of its users by team
+type Organization struct {
+	gormsupport.Lifecycle
+	ID          uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	Name        string
+	Description string
+	// The identity which created the organization and manages its teams
+	OwnerID uuid.UUID `sql:"type:uuid"`
+}
+
+// TableName implements gorm.tabler
+func (m Organization) TableName() string {
+	return organizationTableName
+}
+
+// GetLastModified returns the last modification time
+func (m Organization) GetLastModified() time.Time {
+	return m.UpdatedAt
+}
+
+// OrganizationRepository encapsulate storage & retrieval of organizations
+type OrganizationRepository interface {
+	Create(ctx context.Context, organization *Organization) error
+	Save(ctx context.Context, organization *Organization) error
+	Load(ctx context.Context, ID uuid.UUID) (*Organization, error)
+	Delete(ctx context.Context, ID uuid.UUID) error
+	List(ctx context.Context) ([]Organization, error)
+	TransferOwnership(ctx context.Context, fromOwnerID uuid.UUID, toOwnerID uuid.UUID) error
+}
+
+// NewOrganizationRepository creates a new organization repo
+func NewOrganizationRepository(db *gorm.DB) *GormOrganizationRepository {
+	return &GormOrganizationRepository{db}
+}
+
+// GormOrganizationRepository implements OrganizationRepository using gorm
+type GormOrganizationRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new organization in the DB
+// returns VersionConflictError if an organization with the same name already exists or InternalError
+func (r *GormOrganizationRepository) Create(ctx context.Context, organization *Organization) error {
+	defer goa.MeasureSince([]string{"goa", "db", "organization", "create"}, time.Now())
+	if organization.ID == uuid.Nil {
+		organization.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(organization).Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_organizations_name") {
+			return errors.NewVersionConflictError(fmt.Sprintf("organization %s already exists", organization.Name))
+		}
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"organization_id": organization.ID,
+		"name":            organization.Name,
+		"owner_id":        organization.OwnerID,
+	}, "Organization created successfully")
+	return nil
+}
+
+// Save modifies a single organization in the DB
+// returns NotFoundError, VersionConflictError or InternalError
+func (r *GormOrganizationRepository) Save(ctx context.Context, organization *Organization) error {
+	defer goa.MeasureSince([]string{"goa", "db", "organization", "save"}, time.Now())
+	tx := r.db.Save(organization)
+	if err := tx.Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_organizations_name") {
+			return errors.NewVersionConflictError(fmt.Sprintf("organization %s already exists", organization.Name))
+		}
+		log.Error(ctx, map[string]interface{}{
+			"organization_id": organization.ID,
+			"err":             err,
+		}, "unable to update the organization")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("organization", organization.ID.String())
+	}
+	return nil
+}
+
+// Load returns the organization for the given id
+// returns NotFoundError or InternalError
+func (r *GormOrganizationRepository) Load(ctx context.Context, ID uuid.UUID) (*Organization, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "organization", "load"}, time.Now())
+	organization := Organization{}
+	tx := r.db.Where("id = ?", ID).First(&organization)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("organization", ID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &organization, nil
+}
+
+// Delete deletes the organization with the given id
+// returns NotFoundError or InternalError
+func (r *GormOrganizationRepository) Delete(ctx context.Context, ID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "organization", "delete"}, time.Now())
+	tx := r.db.Delete(&Organization{ID: ID})
+	if tx.Error != nil {
+		return errors.NewInternalError(ctx, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("organization", ID.String())
+	}
+	log.Info(ctx, map[string]interface{}{
+		"organization_id": ID,
+	}, "Organization deleted successfully")
+	return nil
+}
+
+// List returns all the organizations ordered by name
+// returns InternalError
+func (r *GormOrganizationRepository) List(ctx context.Context) ([]Organization, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "organization", "list"}, time.Now())
+	var organizations []Organization
+	if err := r.db.Order("name").Find(&organizations).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return organizations, nil
+}
+
+// TransferOwnership makes the given identity the owner of all the organizations owned by another identity
+// returns InternalError
+func (r *GormOrganizationRepository) TransferOwnership(ctx context.Context, fromOwnerID uuid.UUID, toOwnerID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "organization", "transferOwnership"}, time.Now())
+	tx := r.db.Model(&Organization{}).Where("owner_id = ?", fromOwnerID).Update("owner_id", toOwnerID)
+	if tx.Error != nil {
+		return errors.NewInternalError(ctx, tx.Error)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"from_owner_id": fromOwnerID,
+		"to_owner_id":   toOwnerID,
+		"transferred":   tx.RowsAffected,
+	}, "Organizations ownership transferred!")
+	return nil
+}
@@ -0,0 +1,154 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	pendingApprovalTableName = "pending_approvals"
+
+	// ApprovalStatusPending means the user is waiting for approval
+	ApprovalStatusPending = "pending"
+	// ApprovalStatusApproved means the user has been approved
+	ApprovalStatusApproved = "approved"
+	// ApprovalStatusRejected means the user has been rejected
+	ApprovalStatusRejected = "rejected"
+)
+
+// PendingApproval represents a user who signed up but has not been approved yet, or the decision made about the user.
+// Unapproved users don't have an identity in auth so the entry references the Keycloak user ID.
+type PendingApproval struct {
+	gormsupport.Lifecycle
+	ID             uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	KeycloakUserID uuid.UUID `sql:"type:uuid"`
+	Username       string
+	Email          string
+	FullName       string
+	Status         string
+	// Reason is the reason of the approval or rejection given by the admin
+	Reason    string
+	DecidedBy string
+	DecidedAt *time.Time
+	// InitTenant is true if the tenant should be initialized once the approved user checks the approval status
+	InitTenant bool
+}
+
+// TableName implements gorm.tabler
+func (a PendingApproval) TableName() string {
+	return pendingApprovalTableName
+}
+
+// PendingApprovalRepository encapsulate storage & retrieval of pending approvals
+type PendingApprovalRepository interface {
+	Create(ctx context.Context, approval *PendingApproval) error
+	Save(ctx context.Context, approval *PendingApproval) error
+	Load(ctx context.Context, ID uuid.UUID) (*PendingApproval, error)
+	LoadByKeycloakUserID(ctx context.Context, keycloakUserID uuid.UUID) (*PendingApproval, error)
+	List(ctx context.Context, status *string, start int, limit int) ([]PendingApproval, int, error)
+}
+
+// NewPendingApprovalRepository creates a new pending approval repo
+func NewPendingApprovalRepository(db *gorm.DB) *GormPendingApprovalRepository {
+	return &GormPendingApprovalRepository{db}
+}
+
+// GormPendingApprovalRepository implements PendingApprovalRepository using gorm
+type GormPendingApprovalRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new pending approval in the DB
+// returns InternalError
+func (r *GormPendingApprovalRepository) Create(ctx context.Context, approval *PendingApproval) error {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_approval", "create"}, time.Now())
+	if approval.ID == uuid.Nil {
+		approval.ID = uuid.NewV4()
+	}
+	if approval.Status == "" {
+		approval.Status = ApprovalStatusPending
+	}
+	if err := r.db.Create(approval).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"pending_approval_id": approval.ID,
+		"keycloak_user_id":    approval.KeycloakUserID,
+	}, "Pending approval created successfully")
+	return nil
+}
+
+// Save modifies a single pending approval in the DB
+// returns NotFoundError or InternalError
+func (r *GormPendingApprovalRepository) Save(ctx context.Context, approval *PendingApproval) error {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_approval", "save"}, time.Now())
+	tx := r.db.Save(approval)
+	if err := tx.Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"pending_approval_id": approval.ID,
+			"err":                 err,
+		}, "unable to update the pending approval")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("pending approval", approval.ID.String())
+	}
+	return nil
+}
+
+// Load returns the pending approval for the given id
+// returns NotFoundError or InternalError
+func (r *GormPendingApprovalRepository) Load(ctx context.Context, ID uuid.UUID) (*PendingApproval, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_approval", "load"}, time.Now())
+	approval := PendingApproval{}
+	tx := r.db.Where("id = ?", ID).First(&approval)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("pending approval", ID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &approval, nil
+}
+
+// LoadByKeycloakUserID returns the pending approval of the given Keycloak user
+// returns NotFoundError or InternalError
+func (r *GormPendingApprovalRepository) LoadByKeycloakUserID(ctx context.Context, keycloakUserID uuid.UUID) (*PendingApproval, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_approval", "loadByKeycloakUserID"}, time.Now())
+	approval := PendingApproval{}
+	tx := r.db.Where("keycloak_user_id = ?", keycloakUserID).First(&approval)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("pending approval", keycloakUserID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &approval, nil
+}
+
+// List returns the page of pending approvals with the given status (all if nil), oldest first, and the total count
+// returns InternalError
+func (r *GormPendingApprovalRepository) List(ctx context.Context, status *string, start int, limit int) ([]PendingApproval, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_approval", "list"}, time.Now())
+	db := r.db.Model(&PendingApproval{})
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	var count int
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
+	var approvals []PendingApproval
+	if err := db.Order("created_at").Offset(start).Limit(limit).Find(&approvals).Error; err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
+	return approvals, count, nil
+}
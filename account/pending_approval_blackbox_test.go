@@ -0,0 +1,82 @@
+package account_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type pendingApprovalBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo account.PendingApprovalRepository
+}
+
+func TestRunPendingApprovalBlackBoxTest(t *testing.T) {
+	suite.Run(t, &pendingApprovalBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *pendingApprovalBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = account.NewPendingApprovalRepository(s.DB)
+}
+
+func (s *pendingApprovalBlackBoxTest) TestCreateAndLoad() {
+	// given
+	approval := &account.PendingApproval{
+		KeycloakUserID: uuid.NewV4(),
+		Username:       "pending-" + uuid.NewV4().String(),
+		Email:          "pending@example.com",
+	}
+	// when
+	err := s.repo.Create(s.Ctx, approval)
+	// then
+	require.Nil(s.T(), err)
+	loaded, err := s.repo.LoadByKeycloakUserID(s.Ctx, approval.KeycloakUserID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), approval.ID, loaded.ID)
+	assert.Equal(s.T(), account.ApprovalStatusPending, loaded.Status)
+	assert.Equal(s.T(), approval.Username, loaded.Username)
+
+	_, err = s.repo.LoadByKeycloakUserID(s.Ctx, uuid.NewV4())
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
+
+func (s *pendingApprovalBlackBoxTest) TestListByStatus() {
+	// given
+	pending := &account.PendingApproval{KeycloakUserID: uuid.NewV4()}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, pending))
+	rejected := &account.PendingApproval{KeycloakUserID: uuid.NewV4()}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, rejected))
+	now := time.Now()
+	rejected.Status = account.ApprovalStatusRejected
+	rejected.Reason = "spam"
+	rejected.DecidedBy = "fabric8-auth-admin"
+	rejected.DecidedAt = &now
+	require.Nil(s.T(), s.repo.Save(s.Ctx, rejected))
+	// when
+	status := account.ApprovalStatusRejected
+	approvals, count, err := s.repo.List(s.Ctx, &status, 0, 100)
+	// then
+	require.Nil(s.T(), err)
+	assert.True(s.T(), count >= 1)
+	var found bool
+	for _, a := range approvals {
+		assert.Equal(s.T(), account.ApprovalStatusRejected, a.Status)
+		if a.ID == rejected.ID {
+			found = true
+			assert.Equal(s.T(), "spam", a.Reason)
+			assert.NotNil(s.T(), a.DecidedAt)
+		}
+		assert.NotEqual(s.T(), pending.ID, a.ID)
+	}
+	assert.True(s.T(), found)
+}
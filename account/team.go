@@ -0,0 +1,252 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	teamTableName       = "teams"
+	teamMemberTableName = "team_members"
+)
+
+// Team represents a group of users of an organization.
+// The roles held by the team on the resources apply to all its members.
+type Team struct {
+	gormsupport.Lifecycle
+	ID             uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	OrganizationID uuid.UUID `sql:"type:uuid"`
+	Name           string
+	Description    string
+}
+
+// TableName implements gorm.tabler
+func (m Team) TableName() string {
+	return teamTableName
+}
+
+// GetLastModified returns the last modification time
+func (m Team) GetLastModified() time.Time {
+	return m.UpdatedAt
+}
+
+// TeamMember represents the membership of an identity in a team.
+// The memberships are hard deleted so the access granted through the team ends as soon as the member leaves it.
+type TeamMember struct {
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	TeamID     uuid.UUID `sql:"type:uuid" gorm:"primary_key"`
+	IdentityID uuid.UUID `sql:"type:uuid" gorm:"primary_key"`
+}
+
+// TableName implements gorm.tabler
+func (m TeamMember) TableName() string {
+	return teamMemberTableName
+}
+
+// TeamRepository encapsulate storage & retrieval of teams and of their members
+type TeamRepository interface {
+	Create(ctx context.Context, team *Team) error
+	Save(ctx context.Context, team *Team) error
+	Load(ctx context.Context, ID uuid.UUID) (*Team, error)
+	Delete(ctx context.Context, ID uuid.UUID) error
+	ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]Team, error)
+	ListByMember(ctx context.Context, identityID uuid.UUID) ([]Team, error)
+	AddMember(ctx context.Context, teamID uuid.UUID, identityID uuid.UUID) error
+	RemoveMember(ctx context.Context, teamID uuid.UUID, identityID uuid.UUID) error
+	ListMembers(ctx context.Context, teamID uuid.UUID) ([]Identity, error)
+	RemoveMemberships(ctx context.Context, identityID uuid.UUID) error
+	ReassignMemberships(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error
+}
+
+// NewTeamRepository creates a new team repo
+func NewTeamRepository(db *gorm.DB) *GormTeamRepository {
+	return &GormTeamRepository{db}
+}
+
+// GormTeamRepository implements TeamRepository using gorm
+type GormTeamRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new team in the DB
+// returns VersionConflictError if the organization already has a team with the same name or InternalError
+func (r *GormTeamRepository) Create(ctx context.Context, team *Team) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "create"}, time.Now())
+	if team.ID == uuid.Nil {
+		team.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(team).Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_teams_organization_name") {
+			return errors.NewVersionConflictError(fmt.Sprintf("team %s already exists in the organization", team.Name))
+		}
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"team_id":         team.ID,
+		"organization_id": team.OrganizationID,
+		"name":            team.Name,
+	}, "Team created successfully")
+	return nil
+}
+
+// Save modifies a single team in the DB
+// returns NotFoundError, VersionConflictError or InternalError
+func (r *GormTeamRepository) Save(ctx context.Context, team *Team) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "save"}, time.Now())
+	tx := r.db.Save(team)
+	if err := tx.Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_teams_organization_name") {
+			return errors.NewVersionConflictError(fmt.Sprintf("team %s already exists in the organization", team.Name))
+		}
+		log.Error(ctx, map[string]interface{}{
+			"team_id": team.ID,
+			"err":     err,
+		}, "unable to update the team")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("team", team.ID.String())
+	}
+	return nil
+}
+
+// Load returns the team for the given id
+// returns NotFoundError or InternalError
+func (r *GormTeamRepository) Load(ctx context.Context, ID uuid.UUID) (*Team, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "load"}, time.Now())
+	team := Team{}
+	tx := r.db.Where("id = ?", ID).First(&team)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("team", ID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &team, nil
+}
+
+// Delete deletes the team with the given id and removes all its members
+// returns NotFoundError or InternalError
+func (r *GormTeamRepository) Delete(ctx context.Context, ID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "delete"}, time.Now())
+	if err := r.db.Where("team_id = ?", ID).Delete(TeamMember{}).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	tx := r.db.Delete(&Team{ID: ID})
+	if tx.Error != nil {
+		return errors.NewInternalError(ctx, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("team", ID.String())
+	}
+	log.Info(ctx, map[string]interface{}{
+		"team_id": ID,
+	}, "Team deleted successfully")
+	return nil
+}
+
+// ListByOrganization returns the teams of the given organization ordered by name
+// returns InternalError
+func (r *GormTeamRepository) ListByOrganization(ctx context.Context, organizationID uuid.UUID) ([]Team, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "listByOrganization"}, time.Now())
+	var teams []Team
+	if err := r.db.Where("organization_id = ?", organizationID).Order("name").Find(&teams).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return teams, nil
+}
+
+// ListByMember returns the teams the given identity belongs to ordered by name
+// returns InternalError
+func (r *GormTeamRepository) ListByMember(ctx context.Context, identityID uuid.UUID) ([]Team, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "listByMember"}, time.Now())
+	var teams []Team
+	err := r.db.Select("teams.*").Joins("JOIN team_members ON team_members.team_id = teams.id").
+		Where("team_members.identity_id = ?", identityID).Order("teams.name").Find(&teams).Error
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return teams, nil
+}
+
+// AddMember adds the given identity to the members of the team. Adding an existing member does nothing.
+// returns InternalError
+func (r *GormTeamRepository) AddMember(ctx context.Context, teamID uuid.UUID, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "addMember"}, time.Now())
+	now := time.Now()
+	err := r.db.Exec(`INSERT INTO team_members (created_at, updated_at, team_id, identity_id) VALUES (?, ?, ?, ?)
+		ON CONFLICT (team_id, identity_id) DO NOTHING`, now, now, teamID, identityID).Error
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"team_id":     teamID,
+		"identity_id": identityID,
+	}, "Team member added successfully")
+	return nil
+}
+
+// RemoveMember removes the given identity from the members of the team
+// returns NotFoundError if the identity is not a member of the team or InternalError
+func (r *GormTeamRepository) RemoveMember(ctx context.Context, teamID uuid.UUID, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "removeMember"}, time.Now())
+	tx := r.db.Where("team_id = ? AND identity_id = ?", teamID, identityID).Delete(TeamMember{})
+	if tx.Error != nil {
+		return errors.NewInternalError(ctx, tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("team member", identityID.String())
+	}
+	log.Info(ctx, map[string]interface{}{
+		"team_id":     teamID,
+		"identity_id": identityID,
+	}, "Team member removed successfully")
+	return nil
+}
+
+// ListMembers returns the identities of the members of the team with their user, ordered by username
+// returns InternalError
+func (r *GormTeamRepository) ListMembers(ctx context.Context, teamID uuid.UUID) ([]Identity, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "listMembers"}, time.Now())
+	var identities []Identity
+	err := r.db.Select("identities.*").Preload("User").Joins("JOIN team_members ON team_members.identity_id = identities.id").
+		Where("team_members.team_id = ?", teamID).Order("identities.username").Find(&identities).Error
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return identities, nil
+}
+
+// RemoveMemberships removes the given identity from all its teams
+// returns InternalError
+func (r *GormTeamRepository) RemoveMemberships(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "removeMemberships"}, time.Now())
+	if err := r.db.Where("identity_id = ?", identityID).Delete(TeamMember{}).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// ReassignMemberships moves the memberships of the given identity to another identity.
+// The memberships of the teams the other identity already belongs to are dropped.
+// returns InternalError
+func (r *GormTeamRepository) ReassignMemberships(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team", "reassignMemberships"}, time.Now())
+	err := r.db.Exec(`UPDATE team_members SET identity_id = ?, updated_at = ? WHERE identity_id = ?
+		AND team_id NOT IN (SELECT team_id FROM team_members WHERE identity_id = ?)`,
+		toIdentityID, time.Now(), fromIdentityID, toIdentityID).Error
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return r.RemoveMemberships(ctx, fromIdentityID)
+}
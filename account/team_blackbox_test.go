@@ -0,0 +1,129 @@
+package account_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type teamBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo             account.TeamRepository
+	organizationRepo account.OrganizationRepository
+	organization     account.Organization
+}
+
+func TestRunTeamBlackBoxTest(t *testing.T) {
+	suite.Run(t, &teamBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *teamBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = account.NewTeamRepository(s.DB)
+	s.organizationRepo = account.NewOrganizationRepository(s.DB)
+	s.organization = account.Organization{
+		Name:    "team_blackbox_test" + uuid.NewV4().String(),
+		OwnerID: s.createIdentity().ID,
+	}
+	require.Nil(s.T(), s.organizationRepo.Create(s.Ctx, &s.organization))
+}
+
+func (s *teamBlackBoxTest) createIdentity() account.Identity {
+	identity := account.Identity{
+		Username:     "team_blackbox_test" + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+	}
+	require.Nil(s.T(), account.NewIdentityRepository(s.DB).Create(s.Ctx, &identity))
+	return identity
+}
+
+func (s *teamBlackBoxTest) createTeam(name string) account.Team {
+	team := account.Team{
+		OrganizationID: s.organization.ID,
+		Name:           name,
+	}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, &team))
+	return team
+}
+
+func (s *teamBlackBoxTest) TestCreateAndLoad() {
+	// given
+	team := s.createTeam("developers")
+	// when
+	loaded, err := s.repo.Load(s.Ctx, team.ID)
+	// then
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "developers", loaded.Name)
+	assert.Equal(s.T(), s.organization.ID, loaded.OrganizationID)
+
+	teams, err := s.repo.ListByOrganization(s.Ctx, s.organization.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), teams, 1)
+	assert.Equal(s.T(), team.ID, teams[0].ID)
+}
+
+func (s *teamBlackBoxTest) TestCreateDuplicateName() {
+	s.createTeam("developers")
+	err := s.repo.Create(s.Ctx, &account.Team{OrganizationID: s.organization.ID, Name: "Developers"})
+	assert.IsType(s.T(), errors.VersionConflictError{}, err)
+}
+
+func (s *teamBlackBoxTest) TestMembers() {
+	// given
+	team := s.createTeam("developers")
+	other := s.createTeam("testers")
+	member := s.createIdentity()
+	// when
+	require.Nil(s.T(), s.repo.AddMember(s.Ctx, team.ID, member.ID))
+	require.Nil(s.T(), s.repo.AddMember(s.Ctx, team.ID, member.ID))
+	require.Nil(s.T(), s.repo.AddMember(s.Ctx, other.ID, member.ID))
+	// then
+	members, err := s.repo.ListMembers(s.Ctx, team.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), members, 1)
+	assert.Equal(s.T(), member.ID, members[0].ID)
+	teams, err := s.repo.ListByMember(s.Ctx, member.ID)
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), teams, 2)
+
+	require.Nil(s.T(), s.repo.RemoveMember(s.Ctx, team.ID, member.ID))
+	members, err = s.repo.ListMembers(s.Ctx, team.ID)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), members)
+	err = s.repo.RemoveMember(s.Ctx, team.ID, member.ID)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+
+	// deleting the team removes its members
+	require.Nil(s.T(), s.repo.Delete(s.Ctx, other.ID))
+	teams, err = s.repo.ListByMember(s.Ctx, member.ID)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), teams)
+}
+
+func (s *teamBlackBoxTest) TestReassignMemberships() {
+	// given
+	developers := s.createTeam("developers")
+	testers := s.createTeam("testers")
+	source := s.createIdentity()
+	target := s.createIdentity()
+	require.Nil(s.T(), s.repo.AddMember(s.Ctx, developers.ID, source.ID))
+	require.Nil(s.T(), s.repo.AddMember(s.Ctx, testers.ID, source.ID))
+	require.Nil(s.T(), s.repo.AddMember(s.Ctx, testers.ID, target.ID))
+	// when
+	err := s.repo.ReassignMemberships(s.Ctx, source.ID, target.ID)
+	// then
+	require.Nil(s.T(), err)
+	teams, err := s.repo.ListByMember(s.Ctx, target.ID)
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), teams, 2)
+	teams, err = s.repo.ListByMember(s.Ctx, source.ID)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), teams)
+}
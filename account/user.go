@@ -33,6 +33,9 @@ type User struct {
 	Cluster            string             // The OpenShift cluster allocted to the user.
 	Identities         []Identity         // has many Identities from different IDPs
 	ContextInformation ContextInformation `sql:"type:jsonb"` // context information of the user activity
+	TermsAcceptedAt    *time.Time         // The date the user accepted the terms of service during the registration
+	DeactivatedAt      *time.Time         // The date the user has been deactivated. Deactivated users can't log in nor retrieve tokens
+	Version            int                // The version of the user, incremented on every save
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -43,8 +46,8 @@ func (m User) TableName() string {
 
 // GetETagData returns the field values to use to generate the ETag
 func (m User) GetETagData() []interface{} {
-	// using the 'ID' and 'UpdatedAt' (converted to number of seconds since epoch) fields
-	return []interface{}{m.ID, strconv.FormatInt(m.UpdatedAt.Unix(), 10)}
+	// using the 'ID', 'UpdatedAt' (converted to number of seconds since epoch) and 'Version' fields
+	return []interface{}{m.ID, strconv.FormatInt(m.UpdatedAt.Unix(), 10), m.Version}
 }
 
 // GetLastModified returns the last modification time
@@ -69,6 +72,7 @@ type UserRepository interface {
 	Create(ctx context.Context, u *User) error
 	Save(ctx context.Context, u *User) error
 	List(ctx context.Context) ([]User, error)
+	CountByCluster(ctx context.Context) (map[string]int, error)
 	Delete(ctx context.Context, ID uuid.UUID) error
 	Query(funcs ...func(*gorm.DB) *gorm.DB) ([]User, error)
 }
@@ -121,13 +125,29 @@ func (m *GormUserRepository) Create(ctx context.Context, u *User) error {
 }
 
 // Save modifies a single record
+// The changes of the email, full name and cluster are appended to the user profile history.
+// Returns a stale VersionConflictError if the user has been saved since the model was loaded.
 func (m *GormUserRepository) Save(ctx context.Context, model *User) error {
 	defer goa.MeasureSince([]string{"goa", "db", "user", "save"}, time.Now())
 
+	var changes []UserProfileChange
+	var stored User
+	if err := m.db.Where("id = ?", model.ID).First(&stored).Error; err == nil {
+		changes = userProfileChanges(stored, *model)
+		if err := incrementVersion(m.db, m.TableName(), "user", model.ID, model.Version); err != nil {
+			return err
+		}
+		model.Version++
+	} else if err != gorm.ErrRecordNotFound {
+		return errs.WithStack(err)
+	}
 	err := m.db.Save(model).Error
 	if err != nil {
 		return errs.WithStack(err)
 	}
+	if err := recordProfileChanges(ctx, m.db, changes); err != nil {
+		return err
+	}
 
 	log.Debug(ctx, map[string]interface{}{
 		"user_id": model.ID,
@@ -135,6 +155,20 @@ func (m *GormUserRepository) Save(ctx context.Context, model *User) error {
 	return nil
 }
 
+// incrementVersion increments the version of the row with the given ID if it still has the given version.
+// The row stays locked until the end of the transaction so the concurrent saves of the same row are serialized.
+// Returns a stale VersionConflictError if the row has been saved since the given version was loaded.
+func incrementVersion(db *gorm.DB, tableName string, entity string, id uuid.UUID, version int) error {
+	tx := db.Table(tableName).Where("id = ? AND version = ?", id, version).UpdateColumn("version", gorm.Expr("version + 1"))
+	if tx.Error != nil {
+		return errs.WithStack(tx.Error)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewStaleVersionError(entity, id.String())
+	}
+	return nil
+}
+
 // Delete removes a single record.
 func (m *GormUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "user", "delete"}, time.Now())
@@ -170,6 +204,24 @@ func (m *GormUserRepository) List(ctx context.Context) ([]User, error) {
 	return rows, nil
 }
 
+// CountByCluster returns the number of users provisioned to each cluster by cluster API URL
+func (m *GormUserRepository) CountByCluster(ctx context.Context) (map[string]int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user", "countByCluster"}, time.Now())
+	var rows []struct {
+		Cluster string
+		Users   int
+	}
+	err := m.db.Model(&User{}).Select("cluster, count(*) AS users").Group("cluster").Scan(&rows).Error
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Cluster] = row.Users
+	}
+	return counts, nil
+}
+
 // Query expose an open ended Query model
 func (m *GormUserRepository) Query(funcs ...func(*gorm.DB) *gorm.DB) ([]User, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "user", "query"}, time.Now())
@@ -200,3 +252,10 @@ func UserFilterByEmail(email string) func(db *gorm.DB) *gorm.DB {
 		return db.Where("email = ?", email)
 	}
 }
+
+// UserFilterByEmailIgnoreCase is a gorm filter by 'email' which ignores the case
+func UserFilterByEmailIgnoreCase(email string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("lower(email) = lower(?)", email)
+	}
+}
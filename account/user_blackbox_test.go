@@ -102,6 +102,31 @@ func (s *userBlackBoxTest) TestOKToSave() {
 
 }
 
+func (s *userBlackBoxTest) TestSaveStaleVersionFails() {
+	t := s.T()
+	resource.Require(t, resource.Database)
+
+	user := createAndLoadUser(s)
+	stale := *user
+
+	user.FullName = "newusernameTestUser"
+	err := s.repo.Save(s.Ctx, user)
+	require.Nil(s.T(), err, "Could not update user")
+	updatedUser, err := s.repo.Load(s.Ctx, user.ID)
+	require.Nil(s.T(), err, "Could not load user")
+	assert.Equal(s.T(), stale.Version+1, updatedUser.Version)
+
+	stale.FullName = "staleusernameTestUser"
+	err = s.repo.Save(s.Ctx, &stale)
+	require.NotNil(s.T(), err)
+	isConflict, cause := errors.IsVersionConflictError(err)
+	require.True(s.T(), isConflict)
+	assert.True(s.T(), cause.(errors.VersionConflictError).Stale)
+	updatedUser, err = s.repo.Load(s.Ctx, user.ID)
+	require.Nil(s.T(), err, "Could not load user")
+	assert.Equal(s.T(), user.FullName, updatedUser.FullName)
+}
+
 func (s *userBlackBoxTest) TestCreateUserWithoutClusterFails() {
 	t := s.T()
 	resource.Require(t, resource.Database)
@@ -0,0 +1,193 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	userErasureTableName = "user_erasures"
+
+	// ErasureStatusPending means the user is deactivated and waiting for the erasure
+	ErasureStatusPending = "pending"
+	// ErasureStatusCompleted means the data of the user has been erased
+	ErasureStatusCompleted = "completed"
+	// ErasureStatusFailed means the last attempt to erase the user failed. The erasure is resumed on the next attempt.
+	ErasureStatusFailed = "failed"
+
+	// ErasureStepExternalTokens removes the tokens of the linked accounts
+	ErasureStepExternalTokens = "external_tokens"
+	// ErasureStepIdentityRoles removes the role assignments and the team memberships of the identities
+	ErasureStepIdentityRoles = "identity_roles"
+	// ErasureStepKeycloak deletes the user in Keycloak
+	ErasureStepKeycloak = "keycloak"
+	// ErasureStepWIT anonymizes the user in WIT
+	ErasureStepWIT = "wit"
+	// ErasureStepProfile anonymizes and deletes the user and its identities
+	ErasureStepProfile = "profile"
+	// ErasureStepExports removes the personal data exports of the identities
+	ErasureStepExports = "exports"
+)
+
+// ErasureSteps are the steps of the erasure in the order they are executed
+var ErasureSteps = []string{
+	ErasureStepExternalTokens,
+	ErasureStepIdentityRoles,
+	ErasureStepKeycloak,
+	ErasureStepWIT,
+	ErasureStepProfile,
+	ErasureStepExports,
+}
+
+// UserErasure represents the erasure job of a deactivated user.
+// Step is the last step completed successfully so the job can be resumed after a failure.
+type UserErasure struct {
+	gormsupport.Lifecycle
+	ID          uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	IdentityID  uuid.UUID `sql:"type:uuid"`
+	UserID      uuid.UUID `sql:"type:uuid"`
+	RequestedBy string
+	Status      string
+	Step        string
+	Attempts    int
+	LastError   string
+	// EraseAfter is the end of the grace period. The user is not erased before this date.
+	EraseAfter  time.Time
+	CompletedAt *time.Time
+}
+
+// TableName implements gorm.tabler
+func (e UserErasure) TableName() string {
+	return userErasureTableName
+}
+
+// NextStep returns the step to execute next or an empty string if all the steps have been completed
+func (e UserErasure) NextStep() string {
+	if e.Step == "" {
+		return ErasureSteps[0]
+	}
+	for i, step := range ErasureSteps {
+		if step == e.Step && i+1 < len(ErasureSteps) {
+			return ErasureSteps[i+1]
+		}
+	}
+	return ""
+}
+
+// UserErasureRepository encapsulate storage & retrieval of user erasures
+type UserErasureRepository interface {
+	Create(ctx context.Context, erasure *UserErasure) error
+	Save(ctx context.Context, erasure *UserErasure) error
+	Load(ctx context.Context, ID uuid.UUID) (*UserErasure, error)
+	LoadByIdentityID(ctx context.Context, identityID uuid.UUID) (*UserErasure, error)
+	List(ctx context.Context, status *string, due bool, start int, limit int) ([]UserErasure, int, error)
+}
+
+// NewUserErasureRepository creates a new user erasure repo
+func NewUserErasureRepository(db *gorm.DB) *GormUserErasureRepository {
+	return &GormUserErasureRepository{db}
+}
+
+// GormUserErasureRepository implements UserErasureRepository using gorm
+type GormUserErasureRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new user erasure in the DB
+// returns InternalError
+func (r *GormUserErasureRepository) Create(ctx context.Context, erasure *UserErasure) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_erasure", "create"}, time.Now())
+	if erasure.ID == uuid.Nil {
+		erasure.ID = uuid.NewV4()
+	}
+	if erasure.Status == "" {
+		erasure.Status = ErasureStatusPending
+	}
+	if err := r.db.Create(erasure).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"user_erasure_id": erasure.ID,
+		"identity_id":     erasure.IdentityID,
+	}, "User erasure created successfully")
+	return nil
+}
+
+// Save modifies a single user erasure in the DB
+// returns NotFoundError or InternalError
+func (r *GormUserErasureRepository) Save(ctx context.Context, erasure *UserErasure) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_erasure", "save"}, time.Now())
+	tx := r.db.Save(erasure)
+	if err := tx.Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_erasure_id": erasure.ID,
+			"err":             err,
+		}, "unable to update the user erasure")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("user erasure", erasure.ID.String())
+	}
+	return nil
+}
+
+// Load returns the user erasure for the given id
+// returns NotFoundError or InternalError
+func (r *GormUserErasureRepository) Load(ctx context.Context, ID uuid.UUID) (*UserErasure, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_erasure", "load"}, time.Now())
+	erasure := UserErasure{}
+	tx := r.db.Where("id = ?", ID).First(&erasure)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("user erasure", ID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &erasure, nil
+}
+
+// LoadByIdentityID returns the user erasure of the given identity
+// returns NotFoundError or InternalError
+func (r *GormUserErasureRepository) LoadByIdentityID(ctx context.Context, identityID uuid.UUID) (*UserErasure, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_erasure", "loadByIdentityID"}, time.Now())
+	erasure := UserErasure{}
+	tx := r.db.Where("identity_id = ?", identityID).First(&erasure)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("user erasure", identityID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &erasure, nil
+}
+
+// List returns the page of user erasures with the given status (all if nil), oldest first, and the total count.
+// If due is true then only the erasures which are not completed and whose grace period has elapsed are returned.
+// returns InternalError
+func (r *GormUserErasureRepository) List(ctx context.Context, status *string, due bool, start int, limit int) ([]UserErasure, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_erasure", "list"}, time.Now())
+	db := r.db.Model(&UserErasure{})
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	if due {
+		db = db.Where("status <> ? AND erase_after <= ?", ErasureStatusCompleted, time.Now())
+	}
+	var count int
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
+	var erasures []UserErasure
+	if err := db.Order("created_at").Offset(start).Limit(limit).Find(&erasures).Error; err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
+	return erasures, count, nil
+}
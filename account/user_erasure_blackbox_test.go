@@ -0,0 +1,114 @@
+package account_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type userErasureBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo account.UserErasureRepository
+}
+
+func TestRunUserErasureBlackBoxTest(t *testing.T) {
+	suite.Run(t, &userErasureBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *userErasureBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = account.NewUserErasureRepository(s.DB)
+}
+
+func (s *userErasureBlackBoxTest) createErasure(eraseAfter time.Time) *account.UserErasure {
+	identity := &account.Identity{
+		ID:           uuid.NewV4(),
+		Username:     "user_erasure_blackbox_test" + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+	}
+	require.Nil(s.T(), account.NewIdentityRepository(s.DB).Create(s.Ctx, identity))
+	erasure := &account.UserErasure{
+		IdentityID:  identity.ID,
+		UserID:      uuid.NewV4(),
+		RequestedBy: identity.ID.String(),
+		EraseAfter:  eraseAfter,
+	}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, erasure))
+	return erasure
+}
+
+func (s *userErasureBlackBoxTest) TestCreateAndLoad() {
+	// given
+	erasure := s.createErasure(time.Now())
+	// when
+	loaded, err := s.repo.Load(s.Ctx, erasure.ID)
+	// then
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), account.ErasureStatusPending, loaded.Status)
+	assert.Equal(s.T(), erasure.IdentityID, loaded.IdentityID)
+	byIdentity, err := s.repo.LoadByIdentityID(s.Ctx, erasure.IdentityID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), erasure.ID, byIdentity.ID)
+
+	_, err = s.repo.LoadByIdentityID(s.Ctx, uuid.NewV4())
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
+
+func (s *userErasureBlackBoxTest) TestSave() {
+	// given
+	erasure := s.createErasure(time.Now())
+	// when
+	erasure.Step = account.ErasureStepKeycloak
+	erasure.Status = account.ErasureStatusFailed
+	erasure.Attempts = 2
+	erasure.LastError = "wit: unavailable"
+	err := s.repo.Save(s.Ctx, erasure)
+	// then
+	require.Nil(s.T(), err)
+	loaded, err := s.repo.Load(s.Ctx, erasure.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), account.ErasureStepKeycloak, loaded.Step)
+	assert.Equal(s.T(), account.ErasureStatusFailed, loaded.Status)
+	assert.Equal(s.T(), 2, loaded.Attempts)
+	assert.Equal(s.T(), account.ErasureStepWIT, loaded.NextStep())
+}
+
+func (s *userErasureBlackBoxTest) TestListDue() {
+	// given
+	due := s.createErasure(time.Now().Add(-time.Minute))
+	notDue := s.createErasure(time.Now().Add(time.Hour))
+	// when
+	erasures, count, err := s.repo.List(s.Ctx, nil, true, 0, 1000)
+	// then
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), len(erasures), count)
+	var ids []uuid.UUID
+	for _, erasure := range erasures {
+		ids = append(ids, erasure.ID)
+	}
+	assert.Contains(s.T(), ids, due.ID)
+	assert.NotContains(s.T(), ids, notDue.ID)
+}
+
+func TestUserErasureNextStep(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	erasure := account.UserErasure{}
+	var steps []string
+	for step := erasure.NextStep(); step != ""; step = erasure.NextStep() {
+		steps = append(steps, step)
+		erasure.Step = step
+	}
+	assert.Equal(t, account.ErasureSteps, steps)
+}
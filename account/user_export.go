@@ -0,0 +1,163 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	userExportTableName = "user_exports"
+
+	// ExportStatusPending means the archive is being built
+	ExportStatusPending = "pending"
+	// ExportStatusCompleted means the archive can be downloaded
+	ExportStatusCompleted = "completed"
+	// ExportStatusFailed means the archive could not be built
+	ExportStatusFailed = "failed"
+)
+
+// UserExport represents the export of the personal data of a user
+type UserExport struct {
+	gormsupport.Lifecycle
+	ID          uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	IdentityID  uuid.UUID `sql:"type:uuid"`
+	RequestedBy string
+	Status      string
+	// Archive is the JSON document with the personal data of the user
+	Archive     string
+	LastError   string
+	CompletedAt *time.Time
+	// ExpiresAt is the date after which the archive can't be downloaded anymore
+	ExpiresAt *time.Time
+}
+
+// TableName implements gorm.tabler
+func (e UserExport) TableName() string {
+	return userExportTableName
+}
+
+// Available returns true if the archive is completed and has not expired yet
+func (e UserExport) Available() bool {
+	return e.Status == ExportStatusCompleted && e.ExpiresAt != nil && time.Now().Before(*e.ExpiresAt)
+}
+
+// UserExportRepository encapsulate storage & retrieval of user exports
+type UserExportRepository interface {
+	Create(ctx context.Context, export *UserExport) error
+	Save(ctx context.Context, export *UserExport) error
+	Load(ctx context.Context, ID uuid.UUID) (*UserExport, error)
+	LoadLatest(ctx context.Context, identityID uuid.UUID) (*UserExport, error)
+	List(ctx context.Context, identityID uuid.UUID) ([]UserExport, error)
+	DeleteByIdentityID(ctx context.Context, identityID uuid.UUID) error
+}
+
+// NewUserExportRepository creates a new user export repo
+func NewUserExportRepository(db *gorm.DB) *GormUserExportRepository {
+	return &GormUserExportRepository{db}
+}
+
+// GormUserExportRepository implements UserExportRepository using gorm
+type GormUserExportRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new user export in the DB
+// returns InternalError
+func (r *GormUserExportRepository) Create(ctx context.Context, export *UserExport) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_export", "create"}, time.Now())
+	if export.ID == uuid.Nil {
+		export.ID = uuid.NewV4()
+	}
+	if export.Status == "" {
+		export.Status = ExportStatusPending
+	}
+	if err := r.db.Create(export).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"user_export_id": export.ID,
+		"identity_id":    export.IdentityID,
+	}, "User export created successfully")
+	return nil
+}
+
+// Save modifies a single user export in the DB
+// returns NotFoundError or InternalError
+func (r *GormUserExportRepository) Save(ctx context.Context, export *UserExport) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_export", "save"}, time.Now())
+	tx := r.db.Save(export)
+	if err := tx.Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_export_id": export.ID,
+			"err":            err,
+		}, "unable to update the user export")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("user export", export.ID.String())
+	}
+	return nil
+}
+
+// Load returns the user export for the given id
+// returns NotFoundError or InternalError
+func (r *GormUserExportRepository) Load(ctx context.Context, ID uuid.UUID) (*UserExport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_export", "load"}, time.Now())
+	export := UserExport{}
+	tx := r.db.Where("id = ?", ID).First(&export)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("user export", ID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &export, nil
+}
+
+// LoadLatest returns the most recent user export of the given identity
+// returns NotFoundError or InternalError
+func (r *GormUserExportRepository) LoadLatest(ctx context.Context, identityID uuid.UUID) (*UserExport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_export", "loadLatest"}, time.Now())
+	export := UserExport{}
+	tx := r.db.Where("identity_id = ?", identityID).Order("created_at desc").First(&export)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("user export", identityID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &export, nil
+}
+
+// List returns the user exports of the given identity, oldest first
+// returns InternalError
+func (r *GormUserExportRepository) List(ctx context.Context, identityID uuid.UUID) ([]UserExport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_export", "list"}, time.Now())
+	var exports []UserExport
+	if err := r.db.Where("identity_id = ?", identityID).Order("created_at").Find(&exports).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return exports, nil
+}
+
+// DeleteByIdentityID removes all the exports of the given identity. This is a hard delete!
+// returns InternalError
+func (r *GormUserExportRepository) DeleteByIdentityID(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_export", "deleteByIdentityID"}, time.Now())
+	if err := r.db.Unscoped().Where("identity_id = ?", identityID).Delete(&UserExport{}).Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         err,
+		}, "unable to delete the user exports")
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package account_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type userExportBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo account.UserExportRepository
+}
+
+func TestRunUserExportBlackBoxTest(t *testing.T) {
+	suite.Run(t, &userExportBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *userExportBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = account.NewUserExportRepository(s.DB)
+}
+
+func (s *userExportBlackBoxTest) createIdentity() *account.Identity {
+	identity := &account.Identity{
+		ID:           uuid.NewV4(),
+		Username:     "user_export_blackbox_test" + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+	}
+	require.Nil(s.T(), account.NewIdentityRepository(s.DB).Create(s.Ctx, identity))
+	return identity
+}
+
+func (s *userExportBlackBoxTest) createExport(identityID uuid.UUID) *account.UserExport {
+	export := &account.UserExport{
+		IdentityID:  identityID,
+		RequestedBy: identityID.String(),
+	}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, export))
+	return export
+}
+
+func (s *userExportBlackBoxTest) TestCreateAndLoad() {
+	// given
+	identity := s.createIdentity()
+	export := s.createExport(identity.ID)
+	// when
+	loaded, err := s.repo.Load(s.Ctx, export.ID)
+	// then
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), account.ExportStatusPending, loaded.Status)
+	assert.Equal(s.T(), identity.ID, loaded.IdentityID)
+	assert.False(s.T(), loaded.Available())
+
+	_, err = s.repo.Load(s.Ctx, uuid.NewV4())
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
+
+func (s *userExportBlackBoxTest) TestSaveAndLoadLatest() {
+	// given
+	identity := s.createIdentity()
+	s.createExport(identity.ID)
+	latest := s.createExport(identity.ID)
+	// when
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	latest.Status = account.ExportStatusCompleted
+	latest.Archive = `{"user":{}}`
+	latest.CompletedAt = &now
+	latest.ExpiresAt = &expiresAt
+	require.Nil(s.T(), s.repo.Save(s.Ctx, latest))
+	// then
+	loaded, err := s.repo.LoadLatest(s.Ctx, identity.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), latest.ID, loaded.ID)
+	assert.Equal(s.T(), `{"user":{}}`, loaded.Archive)
+	assert.True(s.T(), loaded.Available())
+
+	_, err = s.repo.LoadLatest(s.Ctx, uuid.NewV4())
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
+
+func (s *userExportBlackBoxTest) TestListAndDeleteByIdentityID() {
+	// given
+	identity := s.createIdentity()
+	other := s.createIdentity()
+	s.createExport(identity.ID)
+	s.createExport(identity.ID)
+	otherExport := s.createExport(other.ID)
+	// when
+	exports, err := s.repo.List(s.Ctx, identity.ID)
+	// then
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), exports, 2)
+
+	// when
+	err = s.repo.DeleteByIdentityID(s.Ctx, identity.ID)
+	// then
+	require.Nil(s.T(), err)
+	exports, err = s.repo.List(s.Ctx, identity.ID)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), exports)
+	_, err = s.repo.Load(s.Ctx, otherExport.ID)
+	assert.Nil(s.T(), err)
+}
+
+func TestUserExportAvailable(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	assert.False(t, account.UserExport{Status: account.ExportStatusPending, ExpiresAt: &future}.Available())
+	assert.False(t, account.UserExport{Status: account.ExportStatusCompleted, ExpiresAt: &past}.Available())
+	assert.False(t, account.UserExport{Status: account.ExportStatusCompleted}.Available())
+	assert.True(t, account.UserExport{Status: account.ExportStatusCompleted, ExpiresAt: &future}.Available())
+}
@@ -0,0 +1,102 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const userMergeTableName = "user_merges"
+
+// UserMerge represents the merge of a duplicate user into another user.
+// It is the tombstone of the merged identity and user: their IDs are redirected to the target identity and user.
+type UserMerge struct {
+	gormsupport.Lifecycle
+	ID               uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	SourceIdentityID uuid.UUID `sql:"type:uuid"`
+	SourceUserID     uuid.UUID `sql:"type:uuid"`
+	TargetIdentityID uuid.UUID `sql:"type:uuid"`
+	TargetUserID     uuid.UUID `sql:"type:uuid"`
+	MergedBy         string
+}
+
+// TableName implements gorm.tabler
+func (m UserMerge) TableName() string {
+	return userMergeTableName
+}
+
+// UserMergeRepository encapsulate storage & retrieval of user merges
+type UserMergeRepository interface {
+	Create(ctx context.Context, merge *UserMerge) error
+	Load(ctx context.Context, ID uuid.UUID) (*UserMerge, error)
+	LoadBySourceIdentityID(ctx context.Context, sourceIdentityID uuid.UUID) (*UserMerge, error)
+	LoadBySourceUserID(ctx context.Context, sourceUserID uuid.UUID) (*UserMerge, error)
+}
+
+// NewUserMergeRepository creates a new user merge repo
+func NewUserMergeRepository(db *gorm.DB) *GormUserMergeRepository {
+	return &GormUserMergeRepository{db}
+}
+
+// GormUserMergeRepository implements UserMergeRepository using gorm
+type GormUserMergeRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new user merge in the DB
+// returns InternalError
+func (r *GormUserMergeRepository) Create(ctx context.Context, merge *UserMerge) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_merge", "create"}, time.Now())
+	if merge.ID == uuid.Nil {
+		merge.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(merge).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"user_merge_id":      merge.ID,
+		"source_identity_id": merge.SourceIdentityID,
+		"target_identity_id": merge.TargetIdentityID,
+	}, "User merge created successfully")
+	return nil
+}
+
+// Load returns the user merge for the given id
+// returns NotFoundError or InternalError
+func (r *GormUserMergeRepository) Load(ctx context.Context, ID uuid.UUID) (*UserMerge, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_merge", "load"}, time.Now())
+	return r.loadWhere(ctx, "id = ?", ID)
+}
+
+// LoadBySourceIdentityID returns the merge of the given merged identity
+// returns NotFoundError or InternalError
+func (r *GormUserMergeRepository) LoadBySourceIdentityID(ctx context.Context, sourceIdentityID uuid.UUID) (*UserMerge, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_merge", "loadBySourceIdentityID"}, time.Now())
+	return r.loadWhere(ctx, "source_identity_id = ?", sourceIdentityID)
+}
+
+// LoadBySourceUserID returns the merge of the given merged user
+// returns NotFoundError or InternalError
+func (r *GormUserMergeRepository) LoadBySourceUserID(ctx context.Context, sourceUserID uuid.UUID) (*UserMerge, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_merge", "loadBySourceUserID"}, time.Now())
+	return r.loadWhere(ctx, "source_user_id = ?", sourceUserID)
+}
+
+func (r *GormUserMergeRepository) loadWhere(ctx context.Context, query string, ID uuid.UUID) (*UserMerge, error) {
+	merge := UserMerge{}
+	tx := r.db.Where(query, ID).First(&merge)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("user merge", ID.String())
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &merge, nil
+}
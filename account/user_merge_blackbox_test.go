@@ -0,0 +1,76 @@
+package account_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type userMergeBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo account.UserMergeRepository
+}
+
+func TestRunUserMergeBlackBoxTest(t *testing.T) {
+	suite.Run(t, &userMergeBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *userMergeBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = account.NewUserMergeRepository(s.DB)
+}
+
+func (s *userMergeBlackBoxTest) createIdentity() account.Identity {
+	user := account.User{
+		Email:   "user_merge_blackbox_test" + uuid.NewV4().String() + "@example.com",
+		Cluster: "user_merge_blackbox_test cluster",
+	}
+	require.Nil(s.T(), account.NewUserRepository(s.DB).Create(s.Ctx, &user))
+	identity := account.Identity{
+		Username:     "user_merge_blackbox_test" + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+		User:         user,
+		UserID:       account.NullUUID{UUID: user.ID, Valid: true},
+	}
+	require.Nil(s.T(), account.NewIdentityRepository(s.DB).Create(s.Ctx, &identity))
+	return identity
+}
+
+func (s *userMergeBlackBoxTest) TestCreateAndLoad() {
+	// given
+	source := s.createIdentity()
+	target := s.createIdentity()
+	merge := &account.UserMerge{
+		SourceIdentityID: source.ID,
+		SourceUserID:     source.User.ID,
+		TargetIdentityID: target.ID,
+		TargetUserID:     target.User.ID,
+		MergedBy:         "fabric8-auth-admin",
+	}
+	// when
+	err := s.repo.Create(s.Ctx, merge)
+	// then
+	require.Nil(s.T(), err)
+	loaded, err := s.repo.Load(s.Ctx, merge.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), target.ID, loaded.TargetIdentityID)
+	assert.Equal(s.T(), "fabric8-auth-admin", loaded.MergedBy)
+
+	bySourceIdentity, err := s.repo.LoadBySourceIdentityID(s.Ctx, source.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), merge.ID, bySourceIdentity.ID)
+	bySourceUser, err := s.repo.LoadBySourceUserID(s.Ctx, source.User.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), merge.ID, bySourceUser.ID)
+
+	_, err = s.repo.LoadBySourceIdentityID(s.Ctx, target.ID)
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
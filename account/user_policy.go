@@ -0,0 +1,174 @@
+package account
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+)
+
+// The rules of the user policy reported by the validation errors
+const (
+	RuleRequired         = "required"
+	RuleMinLength        = "min_length"
+	RuleMaxLength        = "max_length"
+	RulePattern          = "pattern"
+	RuleReserved         = "reserved"
+	RuleUnique           = "unique"
+	RuleDomainNotAllowed = "domain_not_allowed"
+	RuleDomainBlocked    = "domain_blocked"
+)
+
+// UserPolicyConfiguration represents the configuration of the username and email policy
+type UserPolicyConfiguration interface {
+	GetUsernameMinLength() int
+	GetUsernameMaxLength() int
+	GetUsernamePattern() string
+	GetReservedUsernames() []string
+	IsUsernameCaseFoldingEnabled() bool
+	GetEmailPattern() string
+	GetAllowedEmailDomains() []string
+	GetBlockedEmailDomains() []string
+}
+
+// UserPolicy validates the usernames and the emails of the users.
+// The reserved usernames and the email domains are glob patterns such as "openshift-*" or "*.example.com"
+// and are matched ignoring the case.
+type UserPolicy struct {
+	usernameMinLength   int
+	usernameMaxLength   int
+	usernamePattern     *regexp.Regexp
+	reservedUsernames   []string
+	caseFolding         bool
+	emailPattern        *regexp.Regexp
+	allowedEmailDomains []string
+	blockedEmailDomains []string
+}
+
+// NewUserPolicy creates a user policy from the given configuration
+// returns an error if a pattern of the configuration is invalid
+func NewUserPolicy(config UserPolicyConfiguration) (*UserPolicy, error) {
+	usernamePattern, err := regexp.Compile(config.GetUsernamePattern())
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid username pattern")
+	}
+	emailPattern, err := regexp.Compile(config.GetEmailPattern())
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid email pattern")
+	}
+	reservedUsernames, err := lowerGlobPatterns(config.GetReservedUsernames())
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid reserved username")
+	}
+	allowedEmailDomains, err := lowerGlobPatterns(config.GetAllowedEmailDomains())
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid allowed email domain")
+	}
+	blockedEmailDomains, err := lowerGlobPatterns(config.GetBlockedEmailDomains())
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid blocked email domain")
+	}
+	return &UserPolicy{
+		usernameMinLength:   config.GetUsernameMinLength(),
+		usernameMaxLength:   config.GetUsernameMaxLength(),
+		usernamePattern:     usernamePattern,
+		reservedUsernames:   reservedUsernames,
+		caseFolding:         config.IsUsernameCaseFoldingEnabled(),
+		emailPattern:        emailPattern,
+		allowedEmailDomains: allowedEmailDomains,
+		blockedEmailDomains: blockedEmailDomains,
+	}, nil
+}
+
+// lowerGlobPatterns returns the given glob patterns in lower case
+// returns an error if a pattern is malformed
+func lowerGlobPatterns(patterns []string) ([]string, error) {
+	result := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errs.Wrapf(err, "malformed pattern '%s'", pattern)
+		}
+		result = append(result, pattern)
+	}
+	return result, nil
+}
+
+// matchesAny returns true if the given value matches one of the given lower case glob patterns
+func matchesAny(patterns []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateUsername checks the length, the charset and the reserved words rules
+// returns a ValidationError if the username doesn't comply with the policy
+func (p *UserPolicy) ValidateUsername(username string) error {
+	if strings.TrimSpace(username) == "" {
+		return errors.NewValidationError("username", username, RuleRequired, "username is required")
+	}
+	length := utf8.RuneCountInString(username)
+	if length < p.usernameMinLength {
+		return errors.NewValidationError("username", username, RuleMinLength, fmt.Sprintf("username must have at least %d characters", p.usernameMinLength))
+	}
+	if p.usernameMaxLength > 0 && length > p.usernameMaxLength {
+		return errors.NewValidationError("username", username, RuleMaxLength, fmt.Sprintf("username must have at most %d characters", p.usernameMaxLength))
+	}
+	if !p.usernamePattern.MatchString(username) {
+		return errors.NewValidationError("username", username, RulePattern, fmt.Sprintf("username %s contains forbidden characters", username))
+	}
+	if matchesAny(p.reservedUsernames, username) {
+		return errors.NewValidationError("username", username, RuleReserved, fmt.Sprintf("username %s is reserved", username))
+	}
+	return nil
+}
+
+// ValidateEmail checks the format and the domain of the email
+// returns a ValidationError if the email doesn't comply with the policy
+func (p *UserPolicy) ValidateEmail(email string) error {
+	if strings.TrimSpace(email) == "" {
+		return errors.NewValidationError("email", email, RuleRequired, "email is required")
+	}
+	if !p.emailPattern.MatchString(email) {
+		return errors.NewValidationError("email", email, RulePattern, fmt.Sprintf("email %s is not valid", email))
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if len(p.allowedEmailDomains) > 0 && !matchesAny(p.allowedEmailDomains, domain) {
+		return errors.NewValidationError("email", email, RuleDomainNotAllowed, fmt.Sprintf("email domain %s is not allowed", domain))
+	}
+	if matchesAny(p.blockedEmailDomains, domain) {
+		return errors.NewValidationError("email", email, RuleDomainBlocked, fmt.Sprintf("email domain %s is blocked", domain))
+	}
+	return nil
+}
+
+// UsernameFilter returns the gorm filter to use when checking if a username is unique.
+// The filter ignores the case if the case folding is enabled.
+func (p *UserPolicy) UsernameFilter(username string) func(db *gorm.DB) *gorm.DB {
+	if p.caseFolding {
+		return IdentityFilterByUsernameIgnoreCase(username)
+	}
+	return IdentityFilterByUsername(username)
+}
+
+// EmailFilter returns the gorm filter to use when checking if an email is unique.
+// The filter ignores the case if the case folding is enabled.
+func (p *UserPolicy) EmailFilter(email string) func(db *gorm.DB) *gorm.DB {
+	if p.caseFolding {
+		return UserFilterByEmailIgnoreCase(email)
+	}
+	return UserFilterByEmail(email)
+}
@@ -0,0 +1,95 @@
+package account_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userPolicyConfig struct {
+	pattern        string
+	reserved       []string
+	allowedDomains []string
+	blockedDomains []string
+}
+
+func (c userPolicyConfig) GetUsernameMinLength() int          { return 3 }
+func (c userPolicyConfig) GetUsernameMaxLength() int          { return 10 }
+func (c userPolicyConfig) GetUsernamePattern() string         { return c.pattern }
+func (c userPolicyConfig) GetReservedUsernames() []string     { return c.reserved }
+func (c userPolicyConfig) IsUsernameCaseFoldingEnabled() bool { return true }
+func (c userPolicyConfig) GetEmailPattern() string            { return `^[^@\s]+@[^@\s]+$` }
+func (c userPolicyConfig) GetAllowedEmailDomains() []string   { return c.allowedDomains }
+func (c userPolicyConfig) GetBlockedEmailDomains() []string   { return c.blockedDomains }
+
+func newUserPolicyConfig() userPolicyConfig {
+	return userPolicyConfig{
+		pattern:  `^[a-z0-9][a-z0-9-]*$`,
+		reserved: []string{"admin", "openshift-*"},
+	}
+}
+
+func assertValidationRule(t *testing.T, rule string, err error) {
+	require.NotNil(t, err)
+	isValidationErr, cause := errors.IsValidationError(err)
+	require.True(t, isValidationErr)
+	assert.Equal(t, rule, cause.(errors.ValidationError).Rule)
+}
+
+func TestValidateUsername(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	policy, err := account.NewUserPolicy(newUserPolicyConfig())
+	require.Nil(t, err)
+
+	assert.Nil(t, policy.ValidateUsername("john-doe"))
+	assertValidationRule(t, account.RuleRequired, policy.ValidateUsername(" "))
+	assertValidationRule(t, account.RuleMinLength, policy.ValidateUsername("jd"))
+	assertValidationRule(t, account.RuleMaxLength, policy.ValidateUsername(strings.Repeat("j", 11)))
+	assertValidationRule(t, account.RulePattern, policy.ValidateUsername("john_doe"))
+	assertValidationRule(t, account.RuleReserved, policy.ValidateUsername("admin"))
+	assertValidationRule(t, account.RuleReserved, policy.ValidateUsername("openshift-x"))
+}
+
+func TestValidateEmail(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	config := newUserPolicyConfig()
+	config.blockedDomains = []string{"*.spam.io"}
+	policy, err := account.NewUserPolicy(config)
+	require.Nil(t, err)
+
+	assert.Nil(t, policy.ValidateEmail("john@example.com"))
+	assertValidationRule(t, account.RuleRequired, policy.ValidateEmail(""))
+	assertValidationRule(t, account.RulePattern, policy.ValidateEmail("john.example.com"))
+	assertValidationRule(t, account.RuleDomainBlocked, policy.ValidateEmail("john@mail.SPAM.io"))
+
+	t.Run("allowed domains", func(t *testing.T) {
+		config.allowedDomains = []string{"example.com"}
+		policy, err := account.NewUserPolicy(config)
+		require.Nil(t, err)
+		assert.Nil(t, policy.ValidateEmail("john@Example.com"))
+		assertValidationRule(t, account.RuleDomainNotAllowed, policy.ValidateEmail("john@example.org"))
+	})
+}
+
+func TestNewUserPolicyWithInvalidPatterns(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	config := newUserPolicyConfig()
+	config.pattern = "^[a-z"
+	_, err := account.NewUserPolicy(config)
+	assert.NotNil(t, err)
+
+	config = newUserPolicyConfig()
+	config.reserved = []string{"openshift-["}
+	_, err = account.NewUserPolicy(config)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,187 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	"github.com/goadesign/goa/client"
+	"github.com/goadesign/goa/middleware"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	userProfileHistoryTableName = "user_profile_history"
+
+	// ProfileFieldUsername is the username of an identity of the user
+	ProfileFieldUsername = "username"
+	// ProfileFieldEmail is the email of the user
+	ProfileFieldEmail = "email"
+	// ProfileFieldFullName is the full name of the user
+	ProfileFieldFullName = "full_name"
+	// ProfileFieldCluster is the OpenShift cluster the user is provisioned to
+	ProfileFieldCluster = "cluster"
+)
+
+// UserProfileChange records the change of a field of the profile of a user.
+// The history is append-only, the changes are never updated.
+type UserProfileChange struct {
+	ID        uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	CreatedAt time.Time
+	UserID    uuid.UUID `sql:"type:uuid"`
+	// IdentityID is the identity whose username changed. Not set for the fields of the user.
+	IdentityID NullUUID `sql:"type:uuid"`
+	Field      string
+	OldValue   string
+	NewValue   string
+	// Actor is the subject of the token of the request which made the change: the ID of the identity or of the service account.
+	// Empty if the change wasn't made on behalf of an authenticated request.
+	Actor     string
+	RequestID string
+}
+
+// TableName implements gorm.tabler
+func (c UserProfileChange) TableName() string {
+	return userProfileHistoryTableName
+}
+
+// UserProfileHistoryRepository encapsulate storage & retrieval of the changes of the user profiles
+type UserProfileHistoryRepository interface {
+	Create(ctx context.Context, change *UserProfileChange) error
+	List(ctx context.Context, userID uuid.UUID, start int, limit int) ([]UserProfileChange, int, error)
+	DeleteByUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// NewUserProfileHistoryRepository creates a new user profile history repo
+func NewUserProfileHistoryRepository(db *gorm.DB) *GormUserProfileHistoryRepository {
+	return &GormUserProfileHistoryRepository{db}
+}
+
+// GormUserProfileHistoryRepository implements UserProfileHistoryRepository using gorm
+type GormUserProfileHistoryRepository struct {
+	db *gorm.DB
+}
+
+// Create records a new change in the DB. The actor and the request ID are taken from the context if not set.
+// returns InternalError
+func (r *GormUserProfileHistoryRepository) Create(ctx context.Context, change *UserProfileChange) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_profile_history", "create"}, time.Now())
+	if change.ID == uuid.Nil {
+		change.ID = uuid.NewV4()
+	}
+	if change.Actor == "" {
+		change.Actor = profileChangeActor(ctx)
+	}
+	if change.RequestID == "" {
+		change.RequestID = profileChangeRequestID(ctx)
+	}
+	if err := r.db.Create(change).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"user_id": change.UserID,
+		"field":   change.Field,
+	}, "User profile change recorded")
+	return nil
+}
+
+// List returns the page of changes of the profile of the given user, most recent first, and the total count.
+// returns InternalError
+func (r *GormUserProfileHistoryRepository) List(ctx context.Context, userID uuid.UUID, start int, limit int) ([]UserProfileChange, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_profile_history", "list"}, time.Now())
+	db := r.db.Model(&UserProfileChange{}).Where("user_id = ?", userID)
+	var count int
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
+	var changes []UserProfileChange
+	if err := db.Order("created_at DESC").Offset(start).Limit(limit).Find(&changes).Error; err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
+	return changes, count, nil
+}
+
+// DeleteByUser removes the whole history of the given user. Only used when the user data is erased.
+// returns InternalError
+func (r *GormUserProfileHistoryRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_profile_history", "deleteByUser"}, time.Now())
+	if err := r.db.Where("user_id = ?", userID).Delete(&UserProfileChange{}).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// recordProfileChanges appends the given changes to the history
+func recordProfileChanges(ctx context.Context, db *gorm.DB, changes []UserProfileChange) error {
+	repo := NewUserProfileHistoryRepository(db)
+	for i := range changes {
+		if err := repo.Create(ctx, &changes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userProfileChanges returns the changes of the tracked fields between the stored and the updated user
+func userProfileChanges(old User, updated User) []UserProfileChange {
+	var changes []UserProfileChange
+	addChange := func(field string, oldValue string, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, UserProfileChange{UserID: updated.ID, Field: field, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	addChange(ProfileFieldEmail, old.Email, updated.Email)
+	addChange(ProfileFieldFullName, old.FullName, updated.FullName)
+	addChange(ProfileFieldCluster, old.Cluster, updated.Cluster)
+	return changes
+}
+
+// identityProfileChanges returns the changes of the tracked fields between the stored and the updated identity.
+// The identities which don't belong to a user have no history.
+func identityProfileChanges(old Identity, updated Identity) []UserProfileChange {
+	if !updated.UserID.Valid || old.Username == updated.Username {
+		return nil
+	}
+	return []UserProfileChange{{
+		UserID:     updated.UserID.UUID,
+		IdentityID: NullUUID{UUID: updated.ID, Valid: true},
+		Field:      ProfileFieldUsername,
+		OldValue:   old.Username,
+		NewValue:   updated.Username,
+	}}
+}
+
+// profileChangeActor returns the subject of the token of the request
+func profileChangeActor(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return ""
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// profileChangeRequestID returns the ID of the request either from a goa client or middleware
+func profileChangeRequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	reqID := middleware.ContextRequestID(ctx)
+	if reqID == "" {
+		return client.ContextRequestID(ctx)
+	}
+	return reqID
+}
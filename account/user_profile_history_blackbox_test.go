@@ -0,0 +1,134 @@
+package account_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa/client"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type userProfileHistoryBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo account.UserProfileHistoryRepository
+}
+
+func TestRunUserProfileHistoryBlackBoxTest(t *testing.T) {
+	resource.Require(t, resource.Database)
+	suite.Run(t, &userProfileHistoryBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *userProfileHistoryBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = account.NewUserProfileHistoryRepository(s.DB)
+}
+
+func (s *userProfileHistoryBlackBoxTest) createIdentity() account.Identity {
+	user := account.User{
+		Email:    "user_profile_history_blackbox_test" + uuid.NewV4().String() + "@example.com",
+		FullName: "Profile History",
+		Cluster:  "https://api.starter-us-east-2.openshift.com",
+	}
+	require.Nil(s.T(), account.NewUserRepository(s.DB).Create(s.Ctx, &user))
+	identity := account.Identity{
+		Username:     "user_profile_history_blackbox_test" + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+		User:         user,
+		UserID:       account.NullUUID{UUID: user.ID, Valid: true},
+	}
+	require.Nil(s.T(), account.NewIdentityRepository(s.DB).Create(s.Ctx, &identity))
+	return identity
+}
+
+func (s *userProfileHistoryBlackBoxTest) TestSaveRecordsChanges() {
+	// given
+	identity := s.createIdentity()
+	user := identity.User
+	actor := uuid.NewV4().String()
+	token := jwt.New(jwt.SigningMethodRS256)
+	token.Claims.(jwt.MapClaims)["sub"] = actor
+	ctx := client.SetContextRequestID(goajwt.WithJWT(context.Background(), token), "request-1")
+	oldEmail := user.Email
+	oldUsername := identity.Username
+
+	// when
+	user.Email = "user_profile_history_blackbox_test" + uuid.NewV4().String() + "@example.com"
+	user.Bio = "not tracked"
+	require.Nil(s.T(), account.NewUserRepository(s.DB).Save(ctx, &user))
+	identity.Username = "user_profile_history_blackbox_test" + uuid.NewV4().String()
+	identity.User = account.User{}
+	require.Nil(s.T(), account.NewIdentityRepository(s.DB).Save(ctx, &identity))
+
+	// then
+	changes, count, err := s.repo.List(s.Ctx, user.ID, 0, 10)
+	require.Nil(s.T(), err)
+	require.Equal(s.T(), 2, count)
+	require.Len(s.T(), changes, 2)
+	// most recent first
+	assert.Equal(s.T(), account.ProfileFieldUsername, changes[0].Field)
+	assert.Equal(s.T(), oldUsername, changes[0].OldValue)
+	assert.Equal(s.T(), identity.Username, changes[0].NewValue)
+	assert.Equal(s.T(), account.NullUUID{UUID: identity.ID, Valid: true}, changes[0].IdentityID)
+	assert.Equal(s.T(), account.ProfileFieldEmail, changes[1].Field)
+	assert.Equal(s.T(), oldEmail, changes[1].OldValue)
+	assert.Equal(s.T(), user.Email, changes[1].NewValue)
+	assert.False(s.T(), changes[1].IdentityID.Valid)
+	for _, change := range changes {
+		assert.Equal(s.T(), actor, change.Actor)
+		assert.Equal(s.T(), "request-1", change.RequestID)
+	}
+}
+
+func (s *userProfileHistoryBlackBoxTest) TestSaveWithoutChange() {
+	// given
+	identity := s.createIdentity()
+	user := identity.User
+	// when
+	require.Nil(s.T(), account.NewUserRepository(s.DB).Save(s.Ctx, &user))
+	// then
+	_, count, err := s.repo.List(s.Ctx, user.ID, 0, 10)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, count)
+}
+
+func (s *userProfileHistoryBlackBoxTest) TestListAndDeleteByUser() {
+	// given
+	identity := s.createIdentity()
+	other := s.createIdentity()
+	for _, cluster := range []string{"https://api.a.openshift.com", "https://api.b.openshift.com", "https://api.c.openshift.com"} {
+		require.Nil(s.T(), s.repo.Create(s.Ctx, &account.UserProfileChange{
+			UserID:   identity.User.ID,
+			Field:    account.ProfileFieldCluster,
+			OldValue: identity.User.Cluster,
+			NewValue: cluster,
+		}))
+	}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, &account.UserProfileChange{UserID: other.User.ID, Field: account.ProfileFieldFullName, NewValue: "Other"}))
+
+	// when
+	changes, count, err := s.repo.List(s.Ctx, identity.User.ID, 1, 1)
+	// then
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 3, count)
+	require.Len(s.T(), changes, 1)
+	assert.Equal(s.T(), "https://api.b.openshift.com", changes[0].NewValue)
+
+	// when
+	require.Nil(s.T(), s.repo.DeleteByUser(s.Ctx, identity.User.ID))
+	// then
+	_, count, err = s.repo.List(s.Ctx, identity.User.ID, 0, 10)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, count)
+	_, count, err = s.repo.List(s.Ctx, other.User.ID, 0, 10)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, count)
+}
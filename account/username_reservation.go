@@ -0,0 +1,108 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	usernameReservationTableName = "username_reservations"
+)
+
+// UsernameReservation represents a username held for a user who is completing the registration.
+// IdentityID is the Keycloak user ID which is also the ID of the identity once the user is registered.
+type UsernameReservation struct {
+	ID         uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	CreatedAt  time.Time
+	Username   string
+	IdentityID uuid.UUID `sql:"type:uuid"`
+	ExpiresAt  time.Time
+}
+
+// TableName implements gorm.tabler
+func (r UsernameReservation) TableName() string {
+	return usernameReservationTableName
+}
+
+// UsernameReservationRepository encapsulate storage & retrieval of username reservations
+type UsernameReservationRepository interface {
+	Reserve(ctx context.Context, username string, identityID uuid.UUID, expiresAt time.Time) (*UsernameReservation, error)
+	LoadActive(ctx context.Context, username string) (*UsernameReservation, error)
+	Release(ctx context.Context, identityID uuid.UUID) error
+}
+
+// NewUsernameReservationRepository creates a new username reservation repo
+func NewUsernameReservationRepository(db *gorm.DB) *GormUsernameReservationRepository {
+	return &GormUsernameReservationRepository{db}
+}
+
+// GormUsernameReservationRepository implements UsernameReservationRepository using gorm
+type GormUsernameReservationRepository struct {
+	db *gorm.DB
+}
+
+// Reserve holds the username for the given identity until the given expiration time.
+// The previous reservation of the identity and the expired reservation of the username, if any, are released.
+// Usernames are case insensitive.
+// returns VersionConflictError if the username is held for another identity or InternalError
+func (r *GormUsernameReservationRepository) Reserve(ctx context.Context, username string, identityID uuid.UUID, expiresAt time.Time) (*UsernameReservation, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "username_reservation", "reserve"}, time.Now())
+	err := r.db.Where("identity_id = ? OR (lower(username) = ? AND expires_at <= ?)", identityID, strings.ToLower(username), time.Now()).
+		Delete(UsernameReservation{}).Error
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	reservation := &UsernameReservation{
+		ID:         uuid.NewV4(),
+		Username:   username,
+		IdentityID: identityID,
+		ExpiresAt:  expiresAt,
+	}
+	if err := r.db.Create(reservation).Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_username_reservations_username") {
+			return nil, errors.NewVersionConflictError(fmt.Sprintf("username %s is reserved", username))
+		}
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+		"username":    username,
+		"expires_at":  expiresAt,
+	}, "Username reserved successfully")
+	return reservation, nil
+}
+
+// LoadActive returns the reservation of the given username which has not expired yet
+// returns NotFoundError or InternalError
+func (r *GormUsernameReservationRepository) LoadActive(ctx context.Context, username string) (*UsernameReservation, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "username_reservation", "loadActive"}, time.Now())
+	reservation := UsernameReservation{}
+	tx := r.db.Where("lower(username) = ? AND expires_at > ?", strings.ToLower(username), time.Now()).First(&reservation)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("username reservation", username)
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &reservation, nil
+}
+
+// Release releases the reservation held for the given identity if any
+// returns InternalError
+func (r *GormUsernameReservationRepository) Release(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "username_reservation", "release"}, time.Now())
+	if err := r.db.Where("identity_id = ?", identityID).Delete(UsernameReservation{}).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
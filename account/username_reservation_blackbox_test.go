@@ -0,0 +1,88 @@
+package account_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type usernameReservationBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo account.UsernameReservationRepository
+}
+
+func TestRunUsernameReservationBlackBoxTest(t *testing.T) {
+	suite.Run(t, &usernameReservationBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *usernameReservationBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = account.NewUsernameReservationRepository(s.DB)
+}
+
+func (s *usernameReservationBlackBoxTest) TestReserveAndLoadActive() {
+	// given
+	username := "Reserved-" + uuid.NewV4().String()
+	identityID := uuid.NewV4()
+	// when
+	reservation, err := s.repo.Reserve(s.Ctx, username, identityID, time.Now().Add(time.Minute))
+	// then
+	require.Nil(s.T(), err)
+	loaded, err := s.repo.LoadActive(s.Ctx, strings.ToLower(username))
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), reservation.ID, loaded.ID)
+	assert.Equal(s.T(), identityID, loaded.IdentityID)
+
+	// the username can't be reserved for another identity
+	_, err = s.repo.Reserve(s.Ctx, strings.ToUpper(username), uuid.NewV4(), time.Now().Add(time.Minute))
+	conflict, _ := errors.IsVersionConflictError(err)
+	assert.True(s.T(), conflict)
+}
+
+func (s *usernameReservationBlackBoxTest) TestExpiredReservation() {
+	// given
+	username := "expired-" + uuid.NewV4().String()
+	_, err := s.repo.Reserve(s.Ctx, username, uuid.NewV4(), time.Now().Add(-time.Minute))
+	require.Nil(s.T(), err)
+	// when
+	_, err = s.repo.LoadActive(s.Ctx, username)
+	// then
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+	// the expired reservation is replaced
+	_, err = s.repo.Reserve(s.Ctx, username, uuid.NewV4(), time.Now().Add(time.Minute))
+	assert.Nil(s.T(), err)
+}
+
+func (s *usernameReservationBlackBoxTest) TestRelease() {
+	// given
+	identityID := uuid.NewV4()
+	first := "first-" + uuid.NewV4().String()
+	second := "second-" + uuid.NewV4().String()
+	_, err := s.repo.Reserve(s.Ctx, first, identityID, time.Now().Add(time.Minute))
+	require.Nil(s.T(), err)
+	// when reserving another username
+	_, err = s.repo.Reserve(s.Ctx, second, identityID, time.Now().Add(time.Minute))
+	// then the previous reservation is released
+	require.Nil(s.T(), err)
+	_, err = s.repo.LoadActive(s.Ctx, first)
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+
+	// when
+	err = s.repo.Release(s.Ctx, identityID)
+	// then
+	require.Nil(s.T(), err)
+	_, err = s.repo.LoadActive(s.Ctx, second)
+	notFound, _ = errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
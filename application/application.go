@@ -3,6 +3,8 @@ package application
 import (
 	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/authorization/resource"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
 	"github.com/fabric8-services/fabric8-auth/space"
 	"github.com/fabric8-services/fabric8-auth/token/provider"
 )
@@ -14,6 +16,25 @@ type Application interface {
 	Users() account.UserRepository
 	OauthStates() auth.OauthStateReferenceRepository
 	ExternalTokens() provider.ExternalTokenRepository
+	DeviceCodes() auth.DeviceCodeRepository
+	RevokedSessions() auth.RevokedSessionRepository
+	RefreshTokens() auth.RefreshTokenRepository
+	OauthClients() auth.OauthClientRepository
+	PendingApprovals() account.PendingApprovalRepository
+	IdpMappings() auth.IdpMappingRepository
+	UsernameReservations() account.UsernameReservationRepository
+	UserErasures() account.UserErasureRepository
+	UserExports() account.UserExportRepository
+	UserMerges() account.UserMergeRepository
+	UserProfileHistory() account.UserProfileHistoryRepository
+	IdentityRoles() role.IdentityRoleRepository
+	Resources() resource.ResourceRepository
+	ResourceTypes() resource.ResourceTypeRepository
+	ResourceTypeScopes() resource.ResourceTypeScopeRepository
+	Roles() role.RoleRepository
+	Organizations() account.OrganizationRepository
+	Teams() account.TeamRepository
+	TeamRoles() role.TeamRoleRepository
 }
 
 // A Transaction abstracts a database transaction. The repositories created for the transaction object make changes inside the the transaction
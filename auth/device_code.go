@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	deviceCodeTableName = "device_codes"
+)
+
+// DeviceCode represents a pending or approved device authorization request (RFC 8628)
+type DeviceCode struct {
+	gormsupport.Lifecycle
+	ID              uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	DeviceCode      string
+	UserCode        string
+	ClientID        string
+	Scope           string
+	ExpiresAt       time.Time
+	PollingInterval int
+	LastPolledAt    *time.Time
+	// IdentityID is set once a user has logged in from the verification page
+	IdentityID *uuid.UUID `sql:"type:uuid"`
+	// Token is the JSON encoded token set issued for the device once a user has logged in from the verification page
+	Token string
+	// ConfirmationCode is the hash of the code given to the browser of the logged in user
+	// which must be sent back with the decision of the user to approve the user code
+	ConfirmationCode string
+	// Redirect is the URL the browser is redirected to once the user has confirmed the request of the device
+	Redirect string
+	// ApprovedAt is set once the logged in user has explicitly approved the user code
+	ApprovedAt *time.Time
+}
+
+// TableName implements gorm.tabler
+func (c DeviceCode) TableName() string {
+	return deviceCodeTableName
+}
+
+// IsExpired returns true if the device code can't be used anymore
+func (c DeviceCode) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// IsApproved returns true if the user code has been approved and a token is available for the device
+func (c DeviceCode) IsApproved() bool {
+	return c.ApprovedAt != nil && c.IdentityID != nil && c.Token != ""
+}
+
+// DeviceCodeRepository encapsulate storage & retrieval of device codes
+type DeviceCodeRepository interface {
+	Create(ctx context.Context, code *DeviceCode) error
+	Save(ctx context.Context, code *DeviceCode) error
+	Delete(ctx context.Context, ID uuid.UUID) error
+	LoadByDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error)
+	LoadByUserCode(ctx context.Context, userCode string) (*DeviceCode, error)
+}
+
+// NewDeviceCodeRepository creates a new device code repo
+func NewDeviceCodeRepository(db *gorm.DB) *GormDeviceCodeRepository {
+	return &GormDeviceCodeRepository{db}
+}
+
+// GormDeviceCodeRepository implements DeviceCodeRepository using gorm
+type GormDeviceCodeRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new device code in the DB
+// returns InternalError
+func (r *GormDeviceCodeRepository) Create(ctx context.Context, code *DeviceCode) error {
+	defer goa.MeasureSince([]string{"goa", "db", "device_code", "create"}, time.Now())
+	if code.ID == uuid.Nil {
+		code.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(code).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"device_code_id": code.ID,
+		"client_id":      code.ClientID,
+	}, "Device code created successfully")
+	return nil
+}
+
+// Save modifies a single device code in the DB
+// returns NotFoundError or InternalError
+func (r *GormDeviceCodeRepository) Save(ctx context.Context, code *DeviceCode) error {
+	defer goa.MeasureSince([]string{"goa", "db", "device_code", "save"}, time.Now())
+	tx := r.db.Save(code)
+	if err := tx.Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"device_code_id": code.ID,
+			"err":            err,
+		}, "unable to update the device code")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("device code", code.ID.String())
+	}
+	return nil
+}
+
+// Delete deletes the device code with the given id
+// returns NotFoundError or InternalError
+func (r *GormDeviceCodeRepository) Delete(ctx context.Context, ID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "device_code", "delete"}, time.Now())
+	if ID == uuid.Nil {
+		return errors.NewNotFoundError("device code", ID.String())
+	}
+	tx := r.db.Delete(DeviceCode{ID: ID})
+	if err := tx.Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"device_code_id": ID.String(),
+			"err":            err,
+		}, "unable to delete the device code")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("device code", ID.String())
+	}
+	return nil
+}
+
+// LoadByDeviceCode loads the device code entry by the code issued to the device
+func (r *GormDeviceCodeRepository) LoadByDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "device_code", "loadByDeviceCode"}, time.Now())
+	return r.load(ctx, "device_code = ?", deviceCode)
+}
+
+// LoadByUserCode loads the device code entry by the code displayed to the user
+func (r *GormDeviceCodeRepository) LoadByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "device_code", "loadByUserCode"}, time.Now())
+	return r.load(ctx, "user_code = ?", userCode)
+}
+
+func (r *GormDeviceCodeRepository) load(ctx context.Context, query string, value string) (*DeviceCode, error) {
+	code := DeviceCode{}
+	tx := r.db.Where(query, value).First(&code)
+	if tx.RecordNotFound() {
+		log.Error(ctx, map[string]interface{}{
+			"code": value,
+		}, "Could not find device code")
+		return nil, errors.NewNotFoundError("device code", value)
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &code, nil
+}
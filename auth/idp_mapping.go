@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	idpMappingTableName = "idp_mappings"
+)
+
+// IdpMapping maps an email domain to the Keycloak identity provider the users from the domain log in with (home-realm discovery).
+// IdpHint is the alias of the Keycloak identity provider which is passed to Keycloak as the kc_idp_hint param.
+type IdpMapping struct {
+	gormsupport.Lifecycle
+	ID      uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	Domain  string
+	IdpHint string
+}
+
+// TableName implements gorm.tabler
+func (m IdpMapping) TableName() string {
+	return idpMappingTableName
+}
+
+// IdpMappingRepository encapsulate storage & retrieval of home-realm discovery mappings
+type IdpMappingRepository interface {
+	Create(ctx context.Context, mapping *IdpMapping) error
+	Save(ctx context.Context, mapping *IdpMapping) error
+	Delete(ctx context.Context, ID uuid.UUID) error
+	Load(ctx context.Context, ID uuid.UUID) (*IdpMapping, error)
+	LoadByDomain(ctx context.Context, domain string) (*IdpMapping, error)
+	List(ctx context.Context) ([]IdpMapping, error)
+}
+
+// NewIdpMappingRepository creates a new idp mapping repo
+func NewIdpMappingRepository(db *gorm.DB) *GormIdpMappingRepository {
+	return &GormIdpMappingRepository{db}
+}
+
+// GormIdpMappingRepository implements IdpMappingRepository using gorm
+type GormIdpMappingRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new idp mapping in the DB
+// returns VersionConflictError or InternalError
+func (r *GormIdpMappingRepository) Create(ctx context.Context, mapping *IdpMapping) error {
+	defer goa.MeasureSince([]string{"goa", "db", "idp_mapping", "create"}, time.Now())
+	if mapping.ID == uuid.Nil {
+		mapping.ID = uuid.NewV4()
+	}
+	mapping.Domain = strings.ToLower(mapping.Domain)
+	if err := r.db.Create(mapping).Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_idp_mappings_domain") {
+			return errors.NewVersionConflictError(fmt.Sprintf("mapping for domain %s already exists", mapping.Domain))
+		}
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"idp_mapping_id": mapping.ID,
+		"domain":         mapping.Domain,
+		"idp_hint":       mapping.IdpHint,
+	}, "IdP mapping created successfully")
+	return nil
+}
+
+// Save modifies a single idp mapping in the DB
+// returns NotFoundError, VersionConflictError or InternalError
+func (r *GormIdpMappingRepository) Save(ctx context.Context, mapping *IdpMapping) error {
+	defer goa.MeasureSince([]string{"goa", "db", "idp_mapping", "save"}, time.Now())
+	mapping.Domain = strings.ToLower(mapping.Domain)
+	tx := r.db.Save(mapping)
+	if err := tx.Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_idp_mappings_domain") {
+			return errors.NewVersionConflictError(fmt.Sprintf("mapping for domain %s already exists", mapping.Domain))
+		}
+		log.Error(ctx, map[string]interface{}{
+			"idp_mapping_id": mapping.ID,
+			"err":            err,
+		}, "unable to update the idp mapping")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("idp mapping", mapping.ID.String())
+	}
+	return nil
+}
+
+// Delete deletes the idp mapping with the given id
+// returns NotFoundError or InternalError
+func (r *GormIdpMappingRepository) Delete(ctx context.Context, ID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "idp_mapping", "delete"}, time.Now())
+	if ID == uuid.Nil {
+		return errors.NewNotFoundError("idp mapping", ID.String())
+	}
+	tx := r.db.Delete(IdpMapping{ID: ID})
+	if err := tx.Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"idp_mapping_id": ID.String(),
+			"err":            err,
+		}, "unable to delete the idp mapping")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("idp mapping", ID.String())
+	}
+	return nil
+}
+
+// Load returns the idp mapping for the given id
+// returns NotFoundError or InternalError
+func (r *GormIdpMappingRepository) Load(ctx context.Context, ID uuid.UUID) (*IdpMapping, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "idp_mapping", "load"}, time.Now())
+	return r.load(ctx, "id = ?", ID)
+}
+
+// LoadByDomain returns the idp mapping for the given domain. Domains are case insensitive.
+// returns NotFoundError or InternalError
+func (r *GormIdpMappingRepository) LoadByDomain(ctx context.Context, domain string) (*IdpMapping, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "idp_mapping", "loadByDomain"}, time.Now())
+	return r.load(ctx, "lower(domain) = ?", strings.ToLower(domain))
+}
+
+// List returns all the idp mappings
+// returns InternalError
+func (r *GormIdpMappingRepository) List(ctx context.Context) ([]IdpMapping, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "idp_mapping", "list"}, time.Now())
+	var mappings []IdpMapping
+	if err := r.db.Order("domain").Find(&mappings).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return mappings, nil
+}
+
+func (r *GormIdpMappingRepository) load(ctx context.Context, query string, value interface{}) (*IdpMapping, error) {
+	mapping := IdpMapping{}
+	tx := r.db.Where(query, value).First(&mapping)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("idp mapping", fmt.Sprint(value))
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &mapping, nil
+}
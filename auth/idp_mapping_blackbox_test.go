@@ -0,0 +1,76 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type idpMappingBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo auth.IdpMappingRepository
+}
+
+func TestRunIdpMappingBlackBoxTest(t *testing.T) {
+	suite.Run(t, &idpMappingBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *idpMappingBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = auth.NewIdpMappingRepository(s.DB)
+}
+
+func (s *idpMappingBlackBoxTest) TestCreateAndLoadByDomain() {
+	// given
+	domain := uuid.NewV4().String() + ".Example.org"
+	mapping := &auth.IdpMapping{Domain: domain, IdpHint: "corporate-saml"}
+	// when
+	err := s.repo.Create(s.Ctx, mapping)
+	// then
+	require.Nil(s.T(), err)
+	loaded, err := s.repo.LoadByDomain(s.Ctx, domain)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), mapping.ID, loaded.ID)
+	assert.Equal(s.T(), "corporate-saml", loaded.IdpHint)
+
+	_, err = s.repo.LoadByDomain(s.Ctx, "unknown-"+domain)
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
+
+func (s *idpMappingBlackBoxTest) TestCreateDuplicateDomainFails() {
+	// given
+	domain := uuid.NewV4().String() + ".example.org"
+	require.Nil(s.T(), s.repo.Create(s.Ctx, &auth.IdpMapping{Domain: domain, IdpHint: "idp1"}))
+	// when
+	err := s.repo.Create(s.Ctx, &auth.IdpMapping{Domain: domain, IdpHint: "idp2"})
+	// then
+	conflict, _ := errors.IsVersionConflictError(err)
+	assert.True(s.T(), conflict)
+}
+
+func (s *idpMappingBlackBoxTest) TestSaveAndDelete() {
+	// given
+	mapping := &auth.IdpMapping{Domain: uuid.NewV4().String() + ".example.org", IdpHint: "idp1"}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, mapping))
+	// when
+	mapping.IdpHint = "idp2"
+	err := s.repo.Save(s.Ctx, mapping)
+	// then
+	require.Nil(s.T(), err)
+	loaded, err := s.repo.Load(s.Ctx, mapping.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "idp2", loaded.IdpHint)
+
+	require.Nil(s.T(), s.repo.Delete(s.Ctx, mapping.ID))
+	_, err = s.repo.Load(s.Ctx, mapping.ID)
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
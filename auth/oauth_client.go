@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	oauthClientTableName = "oauth_clients"
+)
+
+// StringList is a list of strings stored as a JSON array
+type StringList []string
+
+// Value implements the driver.Valuer interface
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
+// Scan implements the sql.Scanner interface
+func (l *StringList) Scan(src interface{}) error {
+	if src == nil {
+		*l = nil
+		return nil
+	}
+	s, ok := src.([]byte)
+	if !ok {
+		return errs.New("Scan source was not []byte")
+	}
+	return json.Unmarshal(s, l)
+}
+
+// Contains returns true if the list contains the given value
+func (l StringList) Contains(value string) bool {
+	for _, v := range l {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// OauthClient represents an API client registered in auth.
+// The ClientID is the value of the api_client param of the login endpoint and the client_id of the token endpoint.
+type OauthClient struct {
+	gormsupport.Lifecycle
+	ID       uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	ClientID string
+	Name     string
+	// RedirectURIs are the redirect URIs which are allowed for the client. The URIs are compared without query and fragment.
+	RedirectURIs StringList `sql:"type:jsonb"`
+	// RedirectURIPatterns are regular expressions matching the complete redirect URIs which are allowed for the client
+	RedirectURIPatterns StringList `sql:"type:jsonb"`
+	// GrantTypes are the OAuth 2.0 grant types the client is allowed to use
+	GrantTypes StringList `sql:"type:jsonb"`
+	// AccessTokenLifetime is the max lifetime in seconds of the access tokens returned to the client. Nil means the default lifetime.
+	AccessTokenLifetime *int
+	// RefreshTokenLifetime is the max lifetime in seconds of the refresh tokens returned to the client. Nil means the default lifetime.
+	RefreshTokenLifetime *int
+	// Claims are the token claims the client receives
+	Claims StringList `sql:"type:jsonb"`
+}
+
+// TableName implements gorm.tabler
+func (c OauthClient) TableName() string {
+	return oauthClientTableName
+}
+
+// IsGrantTypeAllowed returns true if the client is allowed to use the given grant type
+func (c OauthClient) IsGrantTypeAllowed(grantType string) bool {
+	return c.GrantTypes.Contains(grantType)
+}
+
+// HasRedirectURIs returns true if any exact redirect URI or redirect URI pattern is registered for the client
+func (c OauthClient) HasRedirectURIs() bool {
+	return len(c.RedirectURIs) > 0 || len(c.RedirectURIPatterns) > 0
+}
+
+// IsRedirectURIAllowed returns true if the redirect URI matches one of the exact redirect URIs
+// or one of the redirect URI patterns registered for the client.
+// The patterns are anchored so they must match the complete redirect URI.
+func (c OauthClient) IsRedirectURIAllowed(redirect string) (bool, error) {
+	redirectURL, err := url.Parse(redirect)
+	if err != nil {
+		return false, nil
+	}
+	redirectURL.RawQuery = ""
+	redirectURL.Fragment = ""
+	if c.RedirectURIs.Contains(redirectURL.String()) {
+		return true, nil
+	}
+	for _, pattern := range c.RedirectURIPatterns {
+		matched, err := regexp.MatchString("^(?:"+pattern+")$", redirect)
+		if err != nil {
+			return false, errs.Wrapf(err, "invalid redirect URI pattern %s", pattern)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// OauthClientRepository encapsulate storage & retrieval of registered API clients
+type OauthClientRepository interface {
+	Create(ctx context.Context, client *OauthClient) error
+	Save(ctx context.Context, client *OauthClient) error
+	Delete(ctx context.Context, ID uuid.UUID) error
+	Load(ctx context.Context, ID uuid.UUID) (*OauthClient, error)
+	LoadByClientID(ctx context.Context, clientID string) (*OauthClient, error)
+	List(ctx context.Context) ([]OauthClient, error)
+}
+
+// NewOauthClientRepository creates a new oauth client repo
+func NewOauthClientRepository(db *gorm.DB) *GormOauthClientRepository {
+	return &GormOauthClientRepository{db}
+}
+
+// GormOauthClientRepository implements OauthClientRepository using gorm
+type GormOauthClientRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new oauth client in the DB
+// returns VersionConflictError or InternalError
+func (r *GormOauthClientRepository) Create(ctx context.Context, client *OauthClient) error {
+	defer goa.MeasureSince([]string{"goa", "db", "oauth_client", "create"}, time.Now())
+	if client.ID == uuid.Nil {
+		client.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(client).Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_oauth_clients_client_id") {
+			return errors.NewVersionConflictError(fmt.Sprintf("client with client_id %s already exists", client.ClientID))
+		}
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"oauth_client_id": client.ID,
+		"client_id":       client.ClientID,
+	}, "OAuth client created successfully")
+	return nil
+}
+
+// Save modifies a single oauth client in the DB
+// returns NotFoundError, VersionConflictError or InternalError
+func (r *GormOauthClientRepository) Save(ctx context.Context, client *OauthClient) error {
+	defer goa.MeasureSince([]string{"goa", "db", "oauth_client", "save"}, time.Now())
+	tx := r.db.Save(client)
+	if err := tx.Error; err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_oauth_clients_client_id") {
+			return errors.NewVersionConflictError(fmt.Sprintf("client with client_id %s already exists", client.ClientID))
+		}
+		log.Error(ctx, map[string]interface{}{
+			"oauth_client_id": client.ID,
+			"err":             err,
+		}, "unable to update the oauth client")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("oauth client", client.ID.String())
+	}
+	return nil
+}
+
+// Delete deletes the oauth client with the given id
+// returns NotFoundError or InternalError
+func (r *GormOauthClientRepository) Delete(ctx context.Context, ID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "oauth_client", "delete"}, time.Now())
+	if ID == uuid.Nil {
+		return errors.NewNotFoundError("oauth client", ID.String())
+	}
+	tx := r.db.Delete(OauthClient{ID: ID})
+	if err := tx.Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"oauth_client_id": ID.String(),
+			"err":             err,
+		}, "unable to delete the oauth client")
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("oauth client", ID.String())
+	}
+	return nil
+}
+
+// Load returns the oauth client for the given id
+// returns NotFoundError or InternalError
+func (r *GormOauthClientRepository) Load(ctx context.Context, ID uuid.UUID) (*OauthClient, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "oauth_client", "load"}, time.Now())
+	return r.load(ctx, "id = ?", ID)
+}
+
+// LoadByClientID returns the oauth client for the given client_id
+// returns NotFoundError or InternalError
+func (r *GormOauthClientRepository) LoadByClientID(ctx context.Context, clientID string) (*OauthClient, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "oauth_client", "loadByClientID"}, time.Now())
+	return r.load(ctx, "client_id = ?", clientID)
+}
+
+// List returns all the registered oauth clients
+// returns InternalError
+func (r *GormOauthClientRepository) List(ctx context.Context) ([]OauthClient, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "oauth_client", "list"}, time.Now())
+	var clients []OauthClient
+	if err := r.db.Order("client_id").Find(&clients).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return clients, nil
+}
+
+func (r *GormOauthClientRepository) load(ctx context.Context, query string, value interface{}) (*OauthClient, error) {
+	client := OauthClient{}
+	tx := r.db.Where(query, value).First(&client)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("oauth client", fmt.Sprint(value))
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &client, nil
+}
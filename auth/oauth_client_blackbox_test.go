@@ -0,0 +1,135 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type oauthClientBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo auth.OauthClientRepository
+}
+
+func TestRunOauthClientBlackBoxTest(t *testing.T) {
+	suite.Run(t, &oauthClientBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *oauthClientBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = auth.NewOauthClientRepository(s.DB)
+}
+
+func (s *oauthClientBlackBoxTest) newClient() *auth.OauthClient {
+	lifetime := 300
+	return &auth.OauthClient{
+		ClientID:            "client-" + uuid.NewV4().String(),
+		Name:                "Test Client",
+		RedirectURIs:        auth.StringList{"https://client.example.org/callback"},
+		RedirectURIPatterns: auth.StringList{"^https://[a-z0-9-]+\\.apps\\.example\\.org/.*$"},
+		GrantTypes:          auth.StringList{"authorization_code"},
+		AccessTokenLifetime: &lifetime,
+		Claims:              auth.StringList{"email", "preferred_username"},
+	}
+}
+
+func (s *oauthClientBlackBoxTest) TestCreateAndLoad() {
+	// given
+	client := s.newClient()
+	// when
+	err := s.repo.Create(s.Ctx, client)
+	// then
+	require.Nil(s.T(), err)
+	loaded, err := s.repo.LoadByClientID(s.Ctx, client.ClientID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), client.ID, loaded.ID)
+	assert.Equal(s.T(), client.RedirectURIs, loaded.RedirectURIs)
+	assert.Equal(s.T(), client.RedirectURIPatterns, loaded.RedirectURIPatterns)
+	assert.Equal(s.T(), client.GrantTypes, loaded.GrantTypes)
+	assert.Equal(s.T(), client.Claims, loaded.Claims)
+	require.NotNil(s.T(), loaded.AccessTokenLifetime)
+	assert.Equal(s.T(), 300, *loaded.AccessTokenLifetime)
+	assert.Nil(s.T(), loaded.RefreshTokenLifetime)
+
+	loaded, err = s.repo.Load(s.Ctx, client.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), client.ClientID, loaded.ClientID)
+}
+
+func (s *oauthClientBlackBoxTest) TestCreateDuplicateFails() {
+	// given
+	client := s.newClient()
+	require.Nil(s.T(), s.repo.Create(s.Ctx, client))
+	// when
+	err := s.repo.Create(s.Ctx, &auth.OauthClient{ClientID: client.ClientID})
+	// then
+	require.NotNil(s.T(), err)
+	conflict, _ := errors.IsVersionConflictError(err)
+	assert.True(s.T(), conflict)
+}
+
+func (s *oauthClientBlackBoxTest) TestSaveListAndDelete() {
+	// given
+	client := s.newClient()
+	require.Nil(s.T(), s.repo.Create(s.Ctx, client))
+	// when
+	client.GrantTypes = auth.StringList{"authorization_code", "refresh_token"}
+	err := s.repo.Save(s.Ctx, client)
+	// then
+	require.Nil(s.T(), err)
+	clients, err := s.repo.List(s.Ctx)
+	require.Nil(s.T(), err)
+	var found bool
+	for _, c := range clients {
+		if c.ID == client.ID {
+			found = true
+			assert.Equal(s.T(), client.GrantTypes, c.GrantTypes)
+		}
+	}
+	assert.True(s.T(), found)
+
+	err = s.repo.Delete(s.Ctx, client.ID)
+	require.Nil(s.T(), err)
+	_, err = s.repo.LoadByClientID(s.Ctx, client.ClientID)
+	notFound, _ := errors.IsNotFoundError(err)
+	assert.True(s.T(), notFound)
+}
+
+func (s *oauthClientBlackBoxTest) TestIsRedirectURIAllowed() {
+	client := s.newClient()
+
+	allowed, err := client.IsRedirectURIAllowed("https://client.example.org/callback?api_client=test")
+	require.Nil(s.T(), err)
+	assert.True(s.T(), allowed)
+
+	allowed, err = client.IsRedirectURIAllowed("https://my-app.apps.example.org/somepath")
+	require.Nil(s.T(), err)
+	assert.True(s.T(), allowed)
+
+	allowed, err = client.IsRedirectURIAllowed("https://client.example.org/other")
+	require.Nil(s.T(), err)
+	assert.False(s.T(), allowed)
+
+	allowed, err = client.IsRedirectURIAllowed("https://evil.example.com/callback")
+	require.Nil(s.T(), err)
+	assert.False(s.T(), allowed)
+
+	// the patterns must match the complete redirect URI even if they are not anchored
+	client.RedirectURIPatterns = auth.StringList{"https://[a-z0-9-]+\\.apps\\.example\\.org/.*"}
+	allowed, err = client.IsRedirectURIAllowed("https://my-app.apps.example.org/somepath")
+	require.Nil(s.T(), err)
+	assert.True(s.T(), allowed)
+	allowed, err = client.IsRedirectURIAllowed("https://evil.example.com/?next=https://my-app.apps.example.org/somepath")
+	require.Nil(s.T(), err)
+	assert.False(s.T(), allowed)
+
+	assert.True(s.T(), client.IsGrantTypeAllowed("authorization_code"))
+	assert.False(s.T(), client.IsGrantTypeAllowed("refresh_token"))
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	refreshTokenTableName = "refresh_tokens"
+)
+
+// RefreshToken represents a refresh token issued by auth. Only the hash of the token is stored.
+// All the refresh tokens issued for the same session belong to the same token family.
+type RefreshToken struct {
+	gormsupport.Lifecycle
+	ID           uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	TokenHash    string
+	SessionState string
+	IdentityID   *uuid.UUID `sql:"type:uuid"`
+	// UsedAt is set when the token has been exchanged for a new one. A rotated token can't be used again.
+	UsedAt *time.Time
+	// RevokedAt is set when the whole token family has been revoked
+	RevokedAt *time.Time
+	// ClientID is the registered API client the token family has been issued to, if any
+	ClientID string
+	// ExpiresAt is the expiration of the token family limited by the settings of the API client, if any
+	ExpiresAt *time.Time
+}
+
+// TableName implements gorm.tabler
+func (t RefreshToken) TableName() string {
+	return refreshTokenTableName
+}
+
+// HashRefreshToken returns the hash of the refresh token which is used to look up the token
+func HashRefreshToken(refreshToken string) string {
+	h := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(h[:])
+}
+
+// RefreshTokenRepository encapsulate storage & retrieval of refresh tokens
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	Save(ctx context.Context, token *RefreshToken) error
+	LoadByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	MarkUsed(ctx context.Context, ID uuid.UUID) (bool, error)
+	RevokeFamily(ctx context.Context, sessionState string) error
+}
+
+// NewRefreshTokenRepository creates a new refresh token repo
+func NewRefreshTokenRepository(db *gorm.DB) *GormRefreshTokenRepository {
+	return &GormRefreshTokenRepository{db}
+}
+
+// GormRefreshTokenRepository implements RefreshTokenRepository using gorm
+type GormRefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// Create creates a new refresh token in the DB
+// returns InternalError
+func (r *GormRefreshTokenRepository) Create(ctx context.Context, token *RefreshToken) error {
+	defer goa.MeasureSince([]string{"goa", "db", "refresh_token", "create"}, time.Now())
+	if token.ID == uuid.Nil {
+		token.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(token).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// Save modifies a single refresh token in the DB
+// returns NotFoundError or InternalError
+func (r *GormRefreshTokenRepository) Save(ctx context.Context, token *RefreshToken) error {
+	defer goa.MeasureSince([]string{"goa", "db", "refresh_token", "save"}, time.Now())
+	tx := r.db.Save(token)
+	if err := tx.Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	if tx.RowsAffected == 0 {
+		return errors.NewNotFoundError("refresh token", token.ID.String())
+	}
+	return nil
+}
+
+// LoadByHash loads the refresh token by its hash
+// returns NotFoundError or InternalError
+func (r *GormRefreshTokenRepository) LoadByHash(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "refresh_token", "loadByHash"}, time.Now())
+	token := RefreshToken{}
+	tx := r.db.Where("token_hash = ?", tokenHash).First(&token)
+	if tx.RecordNotFound() {
+		return nil, errors.NewNotFoundError("refresh token", tokenHash)
+	}
+	if tx.Error != nil {
+		return nil, errors.NewInternalError(ctx, tx.Error)
+	}
+	return &token, nil
+}
+
+// MarkUsed marks the refresh token as used (rotated).
+// Returns false if the token had already been used, which means the token is being reused.
+// returns InternalError
+func (r *GormRefreshTokenRepository) MarkUsed(ctx context.Context, ID uuid.UUID) (bool, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "refresh_token", "markUsed"}, time.Now())
+	tx := r.db.Model(&RefreshToken{}).Where("id = ? AND used_at IS NULL", ID).UpdateColumn("used_at", time.Now())
+	if tx.Error != nil {
+		return false, errors.NewInternalError(ctx, tx.Error)
+	}
+	return tx.RowsAffected == 1, nil
+}
+
+// RevokeFamily revokes all the refresh tokens issued for the given session
+// returns InternalError
+func (r *GormRefreshTokenRepository) RevokeFamily(ctx context.Context, sessionState string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "refresh_token", "revokeFamily"}, time.Now())
+	tx := r.db.Model(&RefreshToken{}).Where("session_state = ? AND revoked_at IS NULL", sessionState).UpdateColumn("revoked_at", time.Now())
+	if tx.Error != nil {
+		return errors.NewInternalError(ctx, tx.Error)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"session_state": sessionState,
+		"revoked":       tx.RowsAffected,
+	}, "refresh token family revoked")
+	return nil
+}
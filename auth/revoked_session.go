@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	revokedSessionTableName = "revoked_sessions"
+)
+
+// RevokedSession represents a Keycloak session which has been logged out.
+// Tokens with the corresponding session_state claim are not valid anymore.
+type RevokedSession struct {
+	gormsupport.Lifecycle
+	ID           uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	SessionState string
+	IdentityID   *uuid.UUID `sql:"type:uuid"`
+}
+
+// TableName implements gorm.tabler
+func (s RevokedSession) TableName() string {
+	return revokedSessionTableName
+}
+
+// RevokedSessionRepository encapsulate storage & retrieval of revoked sessions
+type RevokedSessionRepository interface {
+	Create(ctx context.Context, session *RevokedSession) error
+	IsRevoked(ctx context.Context, sessionState string) (bool, error)
+}
+
+// NewRevokedSessionRepository creates a new revoked session repo
+func NewRevokedSessionRepository(db *gorm.DB) *GormRevokedSessionRepository {
+	return &GormRevokedSessionRepository{db}
+}
+
+// GormRevokedSessionRepository implements RevokedSessionRepository using gorm
+type GormRevokedSessionRepository struct {
+	db *gorm.DB
+}
+
+// Create records a revoked session in the DB. Revoking an already revoked session is a no-op.
+// returns InternalError
+func (r *GormRevokedSessionRepository) Create(ctx context.Context, session *RevokedSession) error {
+	defer goa.MeasureSince([]string{"goa", "db", "revoked_session", "create"}, time.Now())
+	revoked, err := r.IsRevoked(ctx, session.SessionState)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return nil
+	}
+	if session.ID == uuid.Nil {
+		session.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(session).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"session_state": session.SessionState,
+		"identity_id":   session.IdentityID,
+	}, "Session revoked")
+	return nil
+}
+
+// IsRevoked returns true if the session with the given session state has been revoked
+// returns InternalError
+func (r *GormRevokedSessionRepository) IsRevoked(ctx context.Context, sessionState string) (bool, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "revoked_session", "isRevoked"}, time.Now())
+	var count int
+	err := r.db.Model(&RevokedSession{}).Where("session_state = ?", sessionState).Count(&count).Error
+	if err != nil {
+		return false, errors.NewInternalError(ctx, err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,53 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type revokedSessionBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo auth.RevokedSessionRepository
+}
+
+func TestRunRevokedSessionBlackBoxTest(t *testing.T) {
+	suite.Run(t, &revokedSessionBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *revokedSessionBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = auth.NewRevokedSessionRepository(s.DB)
+}
+
+func (s *revokedSessionBlackBoxTest) TestCreateAndCheckRevoked() {
+	// given
+	identity, err := test.CreateTestIdentity(s.DB, "revoked-session-"+uuid.NewV4().String(), account.KeycloakIDP)
+	require.Nil(s.T(), err)
+	sessionState := uuid.NewV4().String()
+	revoked, err := s.repo.IsRevoked(s.Ctx, sessionState)
+	require.Nil(s.T(), err)
+	assert.False(s.T(), revoked)
+	// when
+	err = s.repo.Create(s.Ctx, &auth.RevokedSession{SessionState: sessionState, IdentityID: &identity.ID})
+	require.Nil(s.T(), err)
+	// then
+	revoked, err = s.repo.IsRevoked(s.Ctx, sessionState)
+	require.Nil(s.T(), err)
+	assert.True(s.T(), revoked)
+	// revoking the same session twice is fine
+	err = s.repo.Create(s.Ctx, &auth.RevokedSession{SessionState: sessionState})
+	require.Nil(s.T(), err)
+
+	revoked, err = s.repo.IsRevoked(s.Ctx, uuid.NewV4().String())
+	require.Nil(s.T(), err)
+	assert.False(s.T(), revoked)
+}
@@ -23,11 +23,19 @@ type Resource struct {
 	// This is the primary key value
 	ResourceID string `sql:"type:string" gorm:"primary_key" gorm:"column:resource_id"`
 	// The parent resource
-	ParentResource *Resource
+	ParentResource *Resource `gorm:"ForeignKey:ParentResourceID;AssociationForeignKey:ResourceID"`
+	// The foreign key value for ParentResource
+	ParentResourceID *string `sql:"type:uuid"`
 	// The owning identity
-	Owner account.Identity
+	Owner account.Identity `gorm:"ForeignKey:OwnerID;AssociationForeignKey:ID"`
+	// The foreign key value for Owner
+	OwnerID uuid.UUID `sql:"type:uuid"`
 	// The resource type
-	ResourceType ResourceType
+	ResourceType ResourceType `gorm:"ForeignKey:ResourceTypeID;AssociationForeignKey:ResourceTypeID"`
+	// The foreign key value for ResourceType
+	ResourceTypeID uuid.UUID `sql:"type:uuid"`
+	// Resource name
+	Name string
 	// Resource description
 	Description string
 }
@@ -60,6 +68,8 @@ type ResourceRepository interface {
 	Create(ctx context.Context, resource *Resource) error
 	Save(ctx context.Context, resource *Resource) error
 	Delete(ctx context.Context, id string) error
+	CountChildren(ctx context.Context, id string) (int, error)
+	TransferOwnership(ctx context.Context, fromOwnerID uuid.UUID, toOwnerID uuid.UUID) error
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -70,12 +80,12 @@ func (m *GormResourceRepository) TableName() string {
 
 // CRUD Functions
 
-// Load returns a single Resource as a Database Model
+// Load returns a single Resource with its type as a Database Model
 func (m *GormResourceRepository) Load(ctx context.Context, id string) (*Resource, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "resource", "load"}, time.Now())
 
 	var native Resource
-	err := m.db.Table(m.TableName()).Where("id = ?", id).Find(&native).Error
+	err := m.db.Table(m.TableName()).Preload("ResourceType").Where("resource_id = ?", id).Find(&native).Error
 	if err == gorm.ErrRecordNotFound {
 		return nil, errs.WithStack(errors.NewNotFoundError("resource", id))
 	}
@@ -107,6 +117,7 @@ func (m *GormResourceRepository) CheckExists(ctx context.Context, id string) err
 }
 
 // Create creates a new record.
+// returns VersionConflictError if a resource with the same identifier already exists
 func (m *GormResourceRepository) Create(ctx context.Context, resource *Resource) error {
 	defer goa.MeasureSince([]string{"goa", "db", "resource", "create"}, time.Now())
 
@@ -116,6 +127,9 @@ func (m *GormResourceRepository) Create(ctx context.Context, resource *Resource)
 	}
 	err := m.db.Create(resource).Error
 	if err != nil {
+		if gormsupport.IsUniqueViolation(err, "resource_pkey") {
+			return errors.NewVersionConflictError(fmt.Sprintf("resource %s already exists", resource.ResourceID))
+		}
 		log.Error(ctx, map[string]interface{}{
 			"resource_id": resource.ResourceID,
 			"err":         err,
@@ -173,3 +187,38 @@ func (m *GormResourceRepository) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+// CountChildren returns the number of resources whose parent is the given resource
+func (m *GormResourceRepository) CountChildren(ctx context.Context, id string) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "resource", "countChildren"}, time.Now())
+
+	var count int
+	err := m.db.Model(&Resource{}).Where("parent_resource_id = ?", id).Count(&count).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
+
+// TransferOwnership makes the given identity the owner of all the resources owned by another identity
+func (m *GormResourceRepository) TransferOwnership(ctx context.Context, fromOwnerID uuid.UUID, toOwnerID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "resource", "transferOwnership"}, time.Now())
+
+	db := m.db.Table(m.TableName()).Where("owner_id = ?", fromOwnerID).Update("owner_id", toOwnerID)
+	if db.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_owner_id": fromOwnerID,
+			"to_owner_id":   toOwnerID,
+			"err":           db.Error,
+		}, "unable to transfer the ownership of the resources")
+		return errs.WithStack(db.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"from_owner_id": fromOwnerID,
+		"to_owner_id":   toOwnerID,
+		"transferred":   db.RowsAffected,
+	}, "Resources ownership transferred!")
+
+	return nil
+}
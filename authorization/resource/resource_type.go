@@ -51,6 +51,7 @@ func NewResourceTypeRepository(db *gorm.DB) ResourceTypeRepository {
 type ResourceTypeRepository interface {
 	CheckExists(ctx context.Context, id string) (bool, error)
 	Load(ctx context.Context, ID uuid.UUID) (*ResourceType, error)
+	Lookup(ctx context.Context, name string) (*ResourceType, error)
 	Create(ctx context.Context, u *ResourceType) error
 	Save(ctx context.Context, u *ResourceType) error
 	List(ctx context.Context) ([]ResourceType, error)
@@ -77,6 +78,17 @@ func (m *GormResourceTypeRepository) Load(ctx context.Context, id uuid.UUID) (*R
 	return &native, errs.WithStack(err)
 }
 
+// Lookup returns the resource type with the given name
+func (m *GormResourceTypeRepository) Lookup(ctx context.Context, name string) (*ResourceType, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "resource_type", "lookup"}, time.Now())
+	var native ResourceType
+	err := m.db.Table(m.TableName()).Where("name = ?", name).First(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("resource_type", name)
+	}
+	return &native, errs.WithStack(err)
+}
+
 // CheckExists returns nil if the given ID exists otherwise returns an error
 func (m *GormResourceTypeRepository) CheckExists(ctx context.Context, id string) (bool, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "resource_type", "exists"}, time.Now())
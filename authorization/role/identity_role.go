@@ -2,6 +2,7 @@ package role
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/account"
@@ -24,11 +25,17 @@ type IdentityRole struct {
 	// This is the primary key value
 	IdentityRoleID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key" gorm:"column:identity_role_id"`
 	// The identity to which the role is assigned
-	Identity account.Identity
+	Identity account.Identity `gorm:"ForeignKey:IdentityID;AssociationForeignKey:ID"`
+	// The foreign key value for Identity
+	IdentityID uuid.UUID `sql:"type:uuid"`
 	// The resource to which the role is applied
-	Resource resource.Resource
+	Resource resource.Resource `gorm:"ForeignKey:ResourceID;AssociationForeignKey:ResourceID"`
+	// The foreign key value for Resource
+	ResourceID string
 	// The role that is assigned
-	Role Role
+	Role Role `gorm:"ForeignKey:RoleID;AssociationForeignKey:RoleID"`
+	// The foreign key value for Role
+	RoleID uuid.UUID `sql:"type:uuid"`
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -60,6 +67,12 @@ type IdentityRoleRepository interface {
 	Save(ctx context.Context, u *IdentityRole) error
 	List(ctx context.Context) ([]IdentityRole, error)
 	Delete(ctx context.Context, ID uuid.UUID) error
+	DeleteByIdentityID(ctx context.Context, identityID uuid.UUID) error
+	DeleteByResourceID(ctx context.Context, resourceID string) error
+	ReassignIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error
+	ListByIdentity(ctx context.Context, identityID uuid.UUID) ([]IdentityRole, error)
+	ListByResource(ctx context.Context, resourceID string) ([]IdentityRole, error)
+	FindForIdentityAndResource(ctx context.Context, identityID uuid.UUID, resourceID string) (*IdentityRole, error)
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -154,12 +167,117 @@ func (m *GormIdentityRoleRepository) Delete(ctx context.Context, id uuid.UUID) e
 	return nil
 }
 
+// DeleteByIdentityID removes all the role assignments of the given identity. This is a hard delete!
+func (m *GormIdentityRoleRepository) DeleteByIdentityID(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "deleteByIdentityID"}, time.Now())
+
+	db := m.db.Unscoped().Where("identity_id = ?", identityID).Delete(&IdentityRole{})
+	if db.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         db.Error,
+		}, "unable to delete the identity roles")
+		return errs.WithStack(db.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": identityID,
+		"deleted":     db.RowsAffected,
+	}, "Identity roles deleted!")
+
+	return nil
+}
+
+// DeleteByResourceID removes all the role assignments on the given resource. This is a hard delete!
+func (m *GormIdentityRoleRepository) DeleteByResourceID(ctx context.Context, resourceID string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "deleteByResourceID"}, time.Now())
+
+	db := m.db.Unscoped().Where("resource_id = ?", resourceID).Delete(&IdentityRole{})
+	if db.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": resourceID,
+			"err":         db.Error,
+		}, "unable to delete the identity roles")
+		return errs.WithStack(db.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"resource_id": resourceID,
+		"deleted":     db.RowsAffected,
+	}, "Identity roles deleted!")
+
+	return nil
+}
+
+// ReassignIdentity moves all the role assignments of the given identity to another identity
+func (m *GormIdentityRoleRepository) ReassignIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "reassignIdentity"}, time.Now())
+
+	db := m.db.Model(&IdentityRole{}).Where("identity_id = ?", fromIdentityID).Update("identity_id", toIdentityID)
+	if db.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_identity_id": fromIdentityID,
+			"to_identity_id":   toIdentityID,
+			"err":              db.Error,
+		}, "unable to reassign the identity roles")
+		return errs.WithStack(db.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"from_identity_id": fromIdentityID,
+		"to_identity_id":   toIdentityID,
+		"reassigned":       db.RowsAffected,
+	}, "Identity roles reassigned!")
+
+	return nil
+}
+
+// ListByIdentity returns the role assignments of the given identity with their role
+func (m *GormIdentityRoleRepository) ListByIdentity(ctx context.Context, identityID uuid.UUID) ([]IdentityRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "listByIdentity"}, time.Now())
+	var rows []IdentityRole
+
+	err := m.db.Where("identity_id = ?", identityID).Preload("Role").Order("created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// ListByResource returns the role assignments on the given resource with their role
+func (m *GormIdentityRoleRepository) ListByResource(ctx context.Context, resourceID string) ([]IdentityRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "listByResource"}, time.Now())
+	var rows []IdentityRole
+
+	err := m.db.Where("resource_id = ?", resourceID).Preload("Role").Order("created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// FindForIdentityAndResource returns the role assignment of the given identity on the given resource with its role
+// returns NotFoundError if no role is assigned to the identity on the resource
+func (m *GormIdentityRoleRepository) FindForIdentityAndResource(ctx context.Context, identityID uuid.UUID, resourceID string) (*IdentityRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "findForIdentityAndResource"}, time.Now())
+	var native IdentityRole
+
+	err := m.db.Where("identity_id = ? AND resource_id = ?", identityID, resourceID).Preload("Role").Order("created_at").First(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("identity_role", fmt.Sprintf("identity %s on resource %s", identityID, resourceID))
+	}
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return &native, nil
+}
+
 // List returns all identity roles
 func (m *GormIdentityRoleRepository) List(ctx context.Context) ([]IdentityRole, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "list"}, time.Now())
 	var rows []IdentityRole
 
-	err := m.db.Model(&resource.ResourceType{}).Find(&rows).Error
+	err := m.db.Model(&IdentityRole{}).Find(&rows).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, errs.WithStack(err)
 	}
@@ -79,6 +79,7 @@ type RoleRepository interface {
 	Save(ctx context.Context, u *Role) error
 	List(ctx context.Context) ([]Role, error)
 	Delete(ctx context.Context, ID uuid.UUID) error
+	LookupForResource(ctx context.Context, resourceID string, name string) (*Role, error)
 
 	ListScopes(ctx context.Context, u *Role) ([]resource.ResourceTypeScope, error)
 	AddScope(ctx context.Context, u *Role, s *resource.ResourceTypeScope) error
@@ -205,6 +206,19 @@ func (m *GormRoleRepository) List(ctx context.Context) ([]Role, error) {
 	return rows, nil
 }
 
+// LookupForResource returns the role with the given name defined for the type of the given resource
+func (m *GormRoleRepository) LookupForResource(ctx context.Context, resourceID string, name string) (*Role, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "lookupForResource"}, time.Now())
+	var native Role
+	err := m.db.Table(m.TableName()).Select("role.*").
+		Joins("JOIN resource ON resource.resource_type_id = role.resource_type_id AND resource.deleted_at IS NULL").
+		Where("resource.resource_id = ? AND role.name = ?", resourceID, name).First(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("role", name)
+	}
+	return &native, errs.WithStack(err)
+}
+
 func (m *GormRoleRepository) ListScopes(ctx context.Context, u *Role) ([]resource.ResourceTypeScope, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "role", "listscopes"}, time.Now())
 
@@ -0,0 +1,226 @@
+package role
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/authorization/resource"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	"github.com/satori/go.uuid"
+
+	errs "github.com/pkg/errors"
+)
+
+// TeamRole is a role held by a team on a resource.
+// The members of the team are collaborators of the resource for as long as they belong to the team.
+type TeamRole struct {
+	gormsupport.Lifecycle
+
+	// This is the primary key value
+	TeamRoleID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key" gorm:"column:team_role_id"`
+	// The team to which the role is assigned
+	Team account.Team `gorm:"ForeignKey:TeamID;AssociationForeignKey:ID"`
+	// The foreign key value for Team
+	TeamID uuid.UUID `sql:"type:uuid"`
+	// The resource to which the role is applied
+	Resource resource.Resource `gorm:"ForeignKey:ResourceID;AssociationForeignKey:ResourceID"`
+	// The foreign key value for Resource
+	ResourceID string
+	// The role that is assigned
+	Role Role `gorm:"ForeignKey:RoleID;AssociationForeignKey:RoleID"`
+	// The foreign key value for Role
+	RoleID uuid.UUID `sql:"type:uuid"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m TeamRole) TableName() string {
+	return "team_role"
+}
+
+// GetLastModified returns the last modification time
+func (m TeamRole) GetLastModified() time.Time {
+	return m.UpdatedAt
+}
+
+// GormTeamRoleRepository is the implementation of the storage interface for TeamRole.
+type GormTeamRoleRepository struct {
+	db *gorm.DB
+}
+
+// NewTeamRoleRepository creates a new storage type.
+func NewTeamRoleRepository(db *gorm.DB) TeamRoleRepository {
+	return &GormTeamRoleRepository{db: db}
+}
+
+// TeamRoleRepository represents the storage interface.
+type TeamRoleRepository interface {
+	Load(ctx context.Context, ID uuid.UUID) (*TeamRole, error)
+	Create(ctx context.Context, u *TeamRole) error
+	Delete(ctx context.Context, ID uuid.UUID) error
+	DeleteByResourceID(ctx context.Context, resourceID string) error
+	ListByTeam(ctx context.Context, teamID uuid.UUID) ([]TeamRole, error)
+	ListByResource(ctx context.Context, resourceID string) ([]TeamRole, error)
+	ListByMember(ctx context.Context, identityID uuid.UUID) ([]TeamRole, error)
+	ListMemberIDsByResource(ctx context.Context, resourceID string) ([]uuid.UUID, error)
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormTeamRoleRepository) TableName() string {
+	return "team_role"
+}
+
+// CRUD Functions
+
+// Load returns a single TeamRole with its role
+func (m *GormTeamRoleRepository) Load(ctx context.Context, id uuid.UUID) (*TeamRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team_role", "load"}, time.Now())
+	var native TeamRole
+	err := m.db.Where("team_role_id = ?", id).Preload("Role").Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("team_role", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+// returns VersionConflictError if the team already holds the role on the resource
+func (m *GormTeamRoleRepository) Create(ctx context.Context, u *TeamRole) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team_role", "create"}, time.Now())
+	if u.TeamRoleID == uuid.Nil {
+		u.TeamRoleID = uuid.NewV4()
+	}
+	err := m.db.Create(u).Error
+	if err != nil {
+		if gormsupport.IsUniqueViolation(err, "idx_team_role_team_resource_role") {
+			return errors.NewVersionConflictError(fmt.Sprintf("team %s already holds the role on resource %s", u.TeamID, u.ResourceID))
+		}
+		log.Error(ctx, map[string]interface{}{
+			"team_role_id": u.TeamRoleID,
+			"err":          err,
+		}, "unable to create the team role")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"team_role_id": u.TeamRoleID,
+		"team_id":      u.TeamID,
+		"resource_id":  u.ResourceID,
+	}, "Team Role created!")
+	return nil
+}
+
+// Delete removes a single record.
+func (m *GormTeamRoleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team_role", "delete"}, time.Now())
+
+	db := m.db.Delete(&TeamRole{TeamRoleID: id})
+	if db.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"team_role_id": id,
+			"err":          db.Error,
+		}, "unable to delete the team role")
+		return errs.WithStack(db.Error)
+	}
+	if db.RowsAffected == 0 {
+		return errors.NewNotFoundError("team_role", id.String())
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"team_role_id": id,
+	}, "Team role deleted!")
+
+	return nil
+}
+
+// DeleteByResourceID removes all the roles held by teams on the given resource. This is a hard delete!
+func (m *GormTeamRoleRepository) DeleteByResourceID(ctx context.Context, resourceID string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "team_role", "deleteByResourceID"}, time.Now())
+
+	db := m.db.Unscoped().Where("resource_id = ?", resourceID).Delete(&TeamRole{})
+	if db.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": resourceID,
+			"err":         db.Error,
+		}, "unable to delete the team roles")
+		return errs.WithStack(db.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"resource_id": resourceID,
+		"deleted":     db.RowsAffected,
+	}, "Team roles deleted!")
+
+	return nil
+}
+
+// ListByTeam returns the roles of the given team with their role
+func (m *GormTeamRoleRepository) ListByTeam(ctx context.Context, teamID uuid.UUID) ([]TeamRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team_role", "listByTeam"}, time.Now())
+	var rows []TeamRole
+
+	err := m.db.Where("team_id = ?", teamID).Preload("Role").Order("created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// ListByResource returns the roles held by the teams on the given resource with their role
+func (m *GormTeamRoleRepository) ListByResource(ctx context.Context, resourceID string) ([]TeamRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team_role", "listByResource"}, time.Now())
+	var rows []TeamRole
+
+	err := m.db.Where("resource_id = ?", resourceID).Preload("Role").Order("created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// ListByMember returns the roles the given identity holds through the teams it belongs to.
+// The roles are resolved from the current memberships so membership changes take effect straight away.
+func (m *GormTeamRoleRepository) ListByMember(ctx context.Context, identityID uuid.UUID) ([]TeamRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team_role", "listByMember"}, time.Now())
+	var rows []TeamRole
+
+	err := m.db.Select("team_role.*").Joins("JOIN teams ON teams.id = team_role.team_id AND teams.deleted_at IS NULL").
+		Joins("JOIN team_members ON team_members.team_id = team_role.team_id").
+		Where("team_members.identity_id = ?", identityID).
+		Preload("Role").Order("team_role.created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// ListMemberIDsByResource returns the IDs of the identities holding a role on the given resource through their teams
+func (m *GormTeamRoleRepository) ListMemberIDsByResource(ctx context.Context, resourceID string) ([]uuid.UUID, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "team_role", "listMemberIDsByResource"}, time.Now())
+	var ids []uuid.UUID
+
+	rows, err := m.db.Table("team_members").Select("DISTINCT team_members.identity_id").
+		Joins("JOIN teams ON teams.id = team_members.team_id AND teams.deleted_at IS NULL").
+		Joins("JOIN team_role ON team_role.team_id = team_members.team_id AND team_role.deleted_at IS NULL").
+		Joins("JOIN identities ON identities.id = team_members.identity_id AND identities.deleted_at IS NULL").
+		Where("team_role.resource_id = ?", resourceID).Rows()
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, errs.WithStack(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, errs.WithStack(rows.Err())
+}
@@ -0,0 +1,127 @@
+package role_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/authorization/resource"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type teamRoleBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo     role.TeamRoleRepository
+	teamRepo account.TeamRepository
+	owner    account.Identity
+	team     account.Team
+	role     role.Role
+	resource string
+}
+
+func TestRunTeamRoleBlackBoxTest(t *testing.T) {
+	suite.Run(t, &teamRoleBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *teamRoleBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = role.NewTeamRoleRepository(s.DB)
+	s.teamRepo = account.NewTeamRepository(s.DB)
+
+	s.owner = s.createIdentity()
+	organization := account.Organization{Name: "team_role_blackbox_test" + uuid.NewV4().String(), OwnerID: s.owner.ID}
+	require.Nil(s.T(), account.NewOrganizationRepository(s.DB).Create(s.Ctx, &organization))
+	s.team = account.Team{OrganizationID: organization.ID, Name: "developers"}
+	require.Nil(s.T(), s.teamRepo.Create(s.Ctx, &s.team))
+
+	resourceType := resource.ResourceType{
+		ResourceTypeID: uuid.NewV4(),
+		Name:           "team_role_blackbox_test_space" + uuid.NewV4().String(),
+	}
+	require.Nil(s.T(), resource.NewResourceTypeRepository(s.DB).Create(s.Ctx, &resourceType))
+	s.role = role.Role{
+		RoleID:         uuid.NewV4(),
+		ResourceTypeID: resourceType.ResourceTypeID,
+		Name:           "contributor",
+	}
+	require.Nil(s.T(), role.NewRoleRepository(s.DB).Create(s.Ctx, &s.role))
+	s.resource = uuid.NewV4().String()
+	err := s.DB.Exec("INSERT INTO resource (resource_id, owner_id, resource_type_id, name) VALUES (?, ?, ?, ?)",
+		s.resource, s.owner.ID, resourceType.ResourceTypeID, "team_role_blackbox_test").Error
+	require.Nil(s.T(), err)
+}
+
+func (s *teamRoleBlackBoxTest) createIdentity() account.Identity {
+	identity := account.Identity{
+		Username:     "team_role_blackbox_test" + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+	}
+	require.Nil(s.T(), account.NewIdentityRepository(s.DB).Create(s.Ctx, &identity))
+	return identity
+}
+
+func (s *teamRoleBlackBoxTest) TestCreateAndList() {
+	// given
+	teamRole := role.TeamRole{TeamID: s.team.ID, ResourceID: s.resource, RoleID: s.role.RoleID}
+	// when
+	err := s.repo.Create(s.Ctx, &teamRole)
+	// then
+	require.Nil(s.T(), err)
+	loaded, err := s.repo.Load(s.Ctx, teamRole.TeamRoleID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "contributor", loaded.Role.Name)
+	byTeam, err := s.repo.ListByTeam(s.Ctx, s.team.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), byTeam, 1)
+	byResource, err := s.repo.ListByResource(s.Ctx, s.resource)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), byResource, 1)
+	assert.Equal(s.T(), teamRole.TeamRoleID, byResource[0].TeamRoleID)
+
+	err = s.repo.Create(s.Ctx, &role.TeamRole{TeamID: s.team.ID, ResourceID: s.resource, RoleID: s.role.RoleID})
+	assert.IsType(s.T(), errors.VersionConflictError{}, err)
+}
+
+func (s *teamRoleBlackBoxTest) TestMembershipChangesTakeEffectStraightAway() {
+	// given
+	member := s.createIdentity()
+	teamRole := role.TeamRole{TeamID: s.team.ID, ResourceID: s.resource, RoleID: s.role.RoleID}
+	require.Nil(s.T(), s.repo.Create(s.Ctx, &teamRole))
+
+	// when the identity joins the team
+	require.Nil(s.T(), s.teamRepo.AddMember(s.Ctx, s.team.ID, member.ID))
+	// then
+	roles, err := s.repo.ListByMember(s.Ctx, member.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), roles, 1)
+	assert.Equal(s.T(), s.resource, roles[0].ResourceID)
+	assert.Equal(s.T(), "contributor", roles[0].Role.Name)
+	memberIDs, err := s.repo.ListMemberIDsByResource(s.Ctx, s.resource)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), []uuid.UUID{member.ID}, memberIDs)
+
+	// when the identity leaves the team
+	require.Nil(s.T(), s.teamRepo.RemoveMember(s.Ctx, s.team.ID, member.ID))
+	// then
+	roles, err = s.repo.ListByMember(s.Ctx, member.ID)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), roles)
+	memberIDs, err = s.repo.ListMemberIDsByResource(s.Ctx, s.resource)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), memberIDs)
+}
+
+func (s *teamRoleBlackBoxTest) TestLookupRoleForResource() {
+	r, err := role.NewRoleRepository(s.DB).LookupForResource(s.Ctx, s.resource, "contributor")
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), s.role.RoleID, r.RoleID)
+
+	_, err = role.NewRoleRepository(s.DB).LookupForResource(s.Ctx, s.resource, "admin")
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+}
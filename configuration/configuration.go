@@ -74,6 +74,7 @@ const (
 	varGitHubClientSecret                   = "github.client.secret"
 	varGitHubClientDefaultScopes            = "github.client.defaultscopes"
 	varOSOClientApiUrl                      = "oso.client.apiurl"
+	varClusterAllocationStrategy            = "cluster.allocation.strategy"
 	varTLSInsecureSkipVerify                = "tls.insecureskipverify"
 	varNotApprovedRedirect                  = "notapproved.redirect"
 	varHeaderMaxLength                      = "header.maxlength"
@@ -81,6 +82,28 @@ const (
 	varCacheControlCollaborators            = "cachecontrol.collaborators"
 	varCacheControlUser                     = "cachecontrol.user"
 	varUsersListLimit                       = "users.listlimit"
+	varDeviceCodeExpiresIn                  = "device.code.expiresin"
+	varDeviceCodePollingInterval            = "device.code.pollinginterval"
+	varBackChannelLogoutClients             = "logout.backchannel.clients"
+	varAdminServiceAccounts                 = "admin.serviceaccounts"
+	varRateLimits                           = "ratelimit.actions"
+	varRateLimitLockoutThreshold            = "ratelimit.lockout.threshold"
+	varRateLimitLockoutBase                 = "ratelimit.lockout.base"
+	varRateLimitLockoutMax                  = "ratelimit.lockout.max"
+	varRateLimitTrustedProxies              = "ratelimit.trustedproxies"
+	varStepUpPolicies                       = "stepup.actions"
+	varUsernameReservationTTL               = "registration.username.reservation.ttl"
+	varUsernameMinLength                    = "users.username.minlength"
+	varUsernameMaxLength                    = "users.username.maxlength"
+	varUsernamePattern                      = "users.username.pattern"
+	varReservedUsernames                    = "users.username.reserved"
+	varUsernameCaseFolding                  = "users.username.casefolding"
+	varEmailPattern                         = "users.email.pattern"
+	varAllowedEmailDomains                  = "users.email.domains.allowed"
+	varBlockedEmailDomains                  = "users.email.domains.blocked"
+	varUserErasureGracePeriod               = "users.erasure.graceperiod"
+	varUserExportTTL                        = "users.export.ttl"
+	varUserContextNamespaces                = "users.context.namespaces"
 	defaultConfigFile                       = "config.yaml"
 	varValidRedirectURLs                    = "redirect.valid"
 	varLogLevel                             = "log.level"
@@ -117,6 +140,9 @@ type OSOCluster struct {
 	AuthClientID           string `mapstructure:"auth-client-id"`
 	AuthClientSecret       string `mapstructure:"auth-client-secret"`
 	AuthClientDefaultScope string `mapstructure:"auth-client-default-scope"`
+	Capacity               int    `mapstructure:"capacity"` // The maximum number of users provisioned to the cluster. Unlimited if not set
+	Weight                 int    `mapstructure:"weight"`   // The relative share of the new users given to the cluster. 1 if not set
+	Region                 string `mapstructure:"region"`   // The region the cluster is located in
 }
 
 // ConfigurationData encapsulates the Viper configuration object which stores the configuration data in-memory.
@@ -394,11 +420,66 @@ func (c *ConfigurationData) setConfigDefaults() {
 	c.v.SetDefault(varGitHubClientSecret, defaultGitHubClientSecret)
 	c.v.SetDefault(varGitHubClientDefaultScopes, "admin:repo_hook read:org repo user gist")
 	c.v.SetDefault(varOSOClientApiUrl, "https://api.starter-us-east-2.openshift.com")
+	c.v.SetDefault(varClusterAllocationStrategy, "least-loaded")
 	c.v.SetDefault(varTLSInsecureSkipVerify, false) // Do not set to true in production! True can be used only for testing.
 
 	// Max number of users returned when searching users
 	c.v.SetDefault(varUsersListLimit, 50)
 
+	// Device authorization grant defaults
+	c.v.SetDefault(varDeviceCodeExpiresIn, time.Duration(10*time.Minute))
+	c.v.SetDefault(varDeviceCodePollingInterval, 5)
+
+	// Service accounts allowed to use the admin API
+	c.v.SetDefault(varAdminServiceAccounts, []string{"fabric8-auth-admin"})
+
+	// Rate limits of the login and token endpoints in "<requests>/<period>" format keyed by "<controller>.<action>"
+	c.v.SetDefault(varRateLimits, map[string]string{
+		"token.exchange": "20/1m",
+		"token.refresh":  "30/1m",
+		"login.login":    "30/1m",
+	})
+	// Lockout after failed client secret checks
+	c.v.SetDefault(varRateLimitLockoutThreshold, 3)
+	c.v.SetDefault(varRateLimitLockoutBase, time.Duration(5*time.Second))
+	c.v.SetDefault(varRateLimitLockoutMax, time.Duration(15*time.Minute))
+	// Number of proxies in front of the service which append the client address to the X-Forwarded-For header.
+	// The requests are not limited per client IP until it's configured.
+	c.v.SetDefault(varRateLimitTrustedProxies, 0)
+
+	// Step-up authentication required by the sensitive actions keyed by "<controller>.<action>"
+	c.v.SetDefault(varStepUpPolicies, map[string]string{
+		"token.delete":              "max_age=5m",
+		"users.update.email":        "max_age=5m",
+		"collaborators.remove":      "max_age=5m",
+		"collaborators.remove-many": "max_age=5m",
+		"erasure.create":            "max_age=5m",
+	})
+
+	// The time a username is held for the user completing the registration
+	c.v.SetDefault(varUsernameReservationTTL, time.Duration(15*time.Minute))
+
+	// Username and email policy. The max length is the size of the username column in Keycloak.
+	c.v.SetDefault(varUsernameMinLength, 2)
+	c.v.SetDefault(varUsernameMaxLength, 255)
+	c.v.SetDefault(varUsernamePattern, `^[a-zA-Z0-9][a-zA-Z0-9._@+-]*$`)
+	// Usernames which would clash with the system accounts and namespaces of the clusters
+	c.v.SetDefault(varReservedUsernames, []string{
+		"admin", "administrator", "root", "system", "default", "api", "auth", "support",
+		"openshift", "openshift-*", "kube-*", "kubernetes", "fabric8", "fabric8-*", "logging", "management-infra",
+	})
+	c.v.SetDefault(varUsernameCaseFolding, true)
+	c.v.SetDefault(varEmailPattern, `^[^@\s]+@[^@\s]+$`)
+	c.v.SetDefault(varAllowedEmailDomains, []string{})
+	c.v.SetDefault(varBlockedEmailDomains, []string{})
+
+	// The data of the deactivated users is erased once the grace period has elapsed
+	c.v.SetDefault(varUserErasureGracePeriod, time.Duration(30*24*time.Hour))
+	// The personal data exports can be downloaded for a day
+	c.v.SetDefault(varUserExportTTL, time.Duration(24*time.Hour))
+	// JSON schemas (or empty strings) of the namespaces of the user context information keyed by the ID of the API client owning the namespace
+	c.v.SetDefault(varUserContextNamespaces, map[string]string{})
+
 	// HTTP Cache-Control/max-age default
 	c.v.SetDefault(varCacheControlUsers, "max-age=2")
 	c.v.SetDefault(varCacheControlCollaborators, "max-age=2")
@@ -513,6 +594,131 @@ func (c *ConfigurationData) GetMaxUsersListLimit() int {
 	return c.v.GetInt(varUsersListLimit)
 }
 
+// GetDeviceCodeExpiresIn returns the lifetime of the device and user codes issued by the device authorization endpoint
+func (c *ConfigurationData) GetDeviceCodeExpiresIn() time.Duration {
+	return c.v.GetDuration(varDeviceCodeExpiresIn)
+}
+
+// GetDeviceCodePollingInterval returns the minimum amount of time in seconds that the device
+// should wait between polling requests to the token endpoint
+func (c *ConfigurationData) GetDeviceCodePollingInterval() int {
+	return c.v.GetInt(varDeviceCodePollingInterval)
+}
+
+// GetBackChannelLogoutClients returns the OpenID Connect Back-Channel Logout callback URLs of the registered clients.
+// The map is keyed by the client ID which is used as the audience of the logout token.
+func (c *ConfigurationData) GetBackChannelLogoutClients() map[string]string {
+	return c.v.GetStringMapString(varBackChannelLogoutClients)
+}
+
+// GetAdminServiceAccounts returns the names of the service accounts which are allowed to use the admin API
+func (c *ConfigurationData) GetAdminServiceAccounts() []string {
+	return c.v.GetStringSlice(varAdminServiceAccounts)
+}
+
+// GetRateLimits returns the rate limits keyed by "<controller>.<action>".
+// Every limit has the "<requests>/<period>" format, for example "20/1m".
+func (c *ConfigurationData) GetRateLimits() map[string]string {
+	return c.v.GetStringMapString(varRateLimits)
+}
+
+// GetRateLimitLockoutThreshold returns the number of consecutive failed client secret checks
+// after which the client is locked out
+func (c *ConfigurationData) GetRateLimitLockoutThreshold() int {
+	return c.v.GetInt(varRateLimitLockoutThreshold)
+}
+
+// GetRateLimitLockoutBase returns the duration of the first lockout.
+// The duration is doubled with every subsequent failed client secret check.
+func (c *ConfigurationData) GetRateLimitLockoutBase() time.Duration {
+	return c.v.GetDuration(varRateLimitLockoutBase)
+}
+
+// GetRateLimitLockoutMax returns the maximum duration of the lockout
+func (c *ConfigurationData) GetRateLimitLockoutMax() time.Duration {
+	return c.v.GetDuration(varRateLimitLockoutMax)
+}
+
+// GetRateLimitTrustedProxies returns the number of trusted proxies in front of the service.
+// The client IP is the address added to the X-Forwarded-For header by the outermost trusted proxy.
+// If zero then the header is ignored and the requests are not limited per client IP, only per client and identity,
+// since the remote address of the connection is shared by all the clients behind the router.
+func (c *ConfigurationData) GetRateLimitTrustedProxies() int {
+	return c.v.GetInt(varRateLimitTrustedProxies)
+}
+
+// GetStepUpPolicies returns the step-up authentication requirements keyed by "<controller>.<action>".
+// Every requirement has the "max_age=<duration>,acr=<values>" format where both settings are optional,
+// for example "max_age=5m" or "acr=1". The "users.update.email" requirement applies to email changes only.
+func (c *ConfigurationData) GetStepUpPolicies() map[string]string {
+	return c.v.GetStringMapString(varStepUpPolicies)
+}
+
+// GetUsernameReservationTTL returns the time a username is held for the user completing the registration
+func (c *ConfigurationData) GetUsernameReservationTTL() time.Duration {
+	return c.v.GetDuration(varUsernameReservationTTL)
+}
+
+// GetUsernameMinLength returns the min number of characters of a username
+func (c *ConfigurationData) GetUsernameMinLength() int {
+	return c.v.GetInt(varUsernameMinLength)
+}
+
+// GetUsernameMaxLength returns the max number of characters of a username
+func (c *ConfigurationData) GetUsernameMaxLength() int {
+	return c.v.GetInt(varUsernameMaxLength)
+}
+
+// GetUsernamePattern returns the regular expression a username must match
+func (c *ConfigurationData) GetUsernamePattern() string {
+	return c.v.GetString(varUsernamePattern)
+}
+
+// GetReservedUsernames returns the usernames which can't be used by the users.
+// Every entry is a glob pattern such as "openshift-*".
+func (c *ConfigurationData) GetReservedUsernames() []string {
+	return c.v.GetStringSlice(varReservedUsernames)
+}
+
+// IsUsernameCaseFoldingEnabled returns true if the usernames and the emails must be unique regardless of the case
+func (c *ConfigurationData) IsUsernameCaseFoldingEnabled() bool {
+	return c.v.GetBool(varUsernameCaseFolding)
+}
+
+// GetEmailPattern returns the regular expression an email must match
+func (c *ConfigurationData) GetEmailPattern() string {
+	return c.v.GetString(varEmailPattern)
+}
+
+// GetAllowedEmailDomains returns the email domains the users are allowed to use or an empty slice if all domains are allowed.
+// Every entry is a glob pattern such as "*.example.com".
+func (c *ConfigurationData) GetAllowedEmailDomains() []string {
+	return c.v.GetStringSlice(varAllowedEmailDomains)
+}
+
+// GetBlockedEmailDomains returns the email domains the users are not allowed to use.
+// Every entry is a glob pattern such as "*.example.com".
+func (c *ConfigurationData) GetBlockedEmailDomains() []string {
+	return c.v.GetStringSlice(varBlockedEmailDomains)
+}
+
+// GetUserErasureGracePeriod returns the time between the deactivation of a user and the erasure of the user data
+func (c *ConfigurationData) GetUserErasureGracePeriod() time.Duration {
+	return c.v.GetDuration(varUserErasureGracePeriod)
+}
+
+// GetUserExportTTL returns the time the personal data export of a user can be downloaded once completed
+func (c *ConfigurationData) GetUserExportTTL() time.Duration {
+	return c.v.GetDuration(varUserExportTTL)
+}
+
+// GetUserContextNamespaces returns the JSON schemas of the namespaces of the user context information
+// keyed by the ID of the API client owning the namespace. An empty schema means the namespace is not validated.
+// The namespaces of the registered OAuth clients are protected even if they are not configured.
+func (c *ConfigurationData) GetUserContextNamespaces() map[string]string {
+	return c.v.GetStringMapString(varUserContextNamespaces)
+}
+
 // GetCacheControlUsers returns the value to set in the "Cache-Control" HTTP response header
 // when returning users.
 func (c *ConfigurationData) GetCacheControlUsers() string {
@@ -563,6 +769,12 @@ func (c *ConfigurationData) GetOpenShiftClientApiUrl() string {
 	return c.v.GetString(varOSOClientApiUrl)
 }
 
+// GetClusterAllocationStrategy returns the strategy used to assign a cluster to the new users:
+// "least-loaded" or "region-affinity"
+func (c *ConfigurationData) GetClusterAllocationStrategy() string {
+	return c.v.GetString(varClusterAllocationStrategy)
+}
+
 // IsTLSInsecureSkipVerify returns true the client should not verify the
 // server's certificate chain and host name. This mode should be used only for testing.
 func (c *ConfigurationData) IsTLSInsecureSkipVerify() bool {
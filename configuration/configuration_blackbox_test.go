@@ -411,6 +411,9 @@ func checkClusterConfiguration(t *testing.T, clusters map[string]configuration.O
 		AuthClientID:           "autheast2",
 		AuthClientSecret:       "autheast2secret",
 		AuthClientDefaultScope: "user:full",
+		Capacity:               10000,
+		Weight:                 2,
+		Region:                 "us-east",
 	})
 	checkCluster(t, clusters, configuration.OSOCluster{
 		Name:                   "us-east-2a",
@@ -420,6 +423,9 @@ func checkClusterConfiguration(t *testing.T, clusters map[string]configuration.O
 		AuthClientID:           "autheast2a",
 		AuthClientSecret:       "autheast2asecret",
 		AuthClientDefaultScope: "user:full",
+		Capacity:               5000,
+		Weight:                 1,
+		Region:                 "us-east",
 	})
 }
 
@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token"
+)
+
+// AdminConfiguration represents configuration needed by the admin API
+type AdminConfiguration interface {
+	GetAdminServiceAccounts() []string
+}
+
+// checkAdmin returns ForbiddenError if the request is not done by one of the admin service accounts
+func checkAdmin(ctx context.Context, config AdminConfiguration) error {
+	if !token.IsSpecificServiceAccount(ctx, config.GetAdminServiceAccounts()) {
+		log.Error(ctx, nil, "The account is not an authorized admin service account")
+		return errors.NewForbiddenError("account not authorized to use the admin API")
+	}
+	return nil
+}
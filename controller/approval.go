@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/goasupport"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+)
+
+// ApprovalController implements the approval resource.
+type ApprovalController struct {
+	*goa.Controller
+	db              application.DB
+	config          AdminConfiguration
+	approvalService *login.KeycloakApprovalService
+	InitTenant      func(ctx context.Context) error
+}
+
+// NewApprovalController creates an approval controller.
+func NewApprovalController(service *goa.Service, db application.DB, config AdminConfiguration, approvalService *login.KeycloakApprovalService) *ApprovalController {
+	return &ApprovalController{
+		Controller:      service.NewController("ApprovalController"),
+		db:              db,
+		config:          config,
+		approvalService: approvalService,
+	}
+}
+
+// List runs the list action.
+func (c *ApprovalController) List(ctx *app.ListApprovalContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	offset, limit := computePagingLimits(ctx.PageOffset, ctx.PageLimit)
+	var approvals []account.PendingApproval
+	var count int
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		approvals, count, err = appl.PendingApprovals().List(ctx, ctx.Status, offset, limit)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.ApprovalData, len(approvals))
+	for i := range approvals {
+		data[i] = convertApproval(&approvals[i])
+	}
+	response := app.ApprovalList{
+		Data:  data,
+		Links: &app.PagingLinks{},
+		Meta:  &app.ApprovalListMeta{TotalCount: count},
+	}
+	var additionalQuery []string
+	if ctx.Status != nil {
+		additionalQuery = append(additionalQuery, "status="+*ctx.Status)
+	}
+	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(approvals), offset, limit, count, additionalQuery...)
+	return ctx.OK(&response)
+}
+
+// Approve runs the approve action.
+func (c *ApprovalController) Approve(ctx *app.ApproveApprovalContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var reason string
+	var initTenant bool
+	if ctx.Payload != nil {
+		if ctx.Payload.Reason != nil {
+			reason = *ctx.Payload.Reason
+		}
+		initTenant = ctx.Payload.InitTenant
+	}
+	adminName, _ := token.ServiceAccountName(ctx)
+	approval, err := c.approvalService.Approve(ctx, ctx.RequestData, ctx.ID, reason, adminName, initTenant)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.ApprovalSingle{Data: convertApproval(approval)})
+}
+
+// Reject runs the reject action.
+func (c *ApprovalController) Reject(ctx *app.RejectApprovalContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if ctx.Payload == nil || ctx.Payload.Reason == nil || *ctx.Payload.Reason == "" {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("reason", "").Expected("reason of the rejection"))
+	}
+	adminName, _ := token.ServiceAccountName(ctx)
+	approval, err := c.approvalService.Reject(ctx, ctx.RequestData, ctx.ID, *ctx.Payload.Reason, adminName)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.ApprovalSingle{Data: convertApproval(approval)})
+}
+
+// Status runs the status action. It returns the approval status of the authenticated user.
+func (c *ApprovalController) Status(ctx *app.StatusApprovalContext) error {
+	keycloakUserID, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	var approval *account.PendingApproval
+	var initTenant bool
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		approval, err = appl.PendingApprovals().LoadByKeycloakUserID(ctx, *keycloakUserID)
+		if err != nil {
+			return err
+		}
+		if approval.Status == account.ApprovalStatusApproved && approval.InitTenant {
+			// The tenant is initialized only once with the token of the approved user
+			initTenant = true
+			approval.InitTenant = false
+			return appl.PendingApprovals().Save(ctx, approval)
+		}
+		return nil
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if initTenant && c.InitTenant != nil {
+		// the tenant is initialized in the background with a context which is not cancelled once the response is sent
+		go func(ctx context.Context) {
+			err := c.InitTenant(ctx)
+			if err != nil {
+				log.Error(ctx, map[string]interface{}{
+					"keycloak_user_id": keycloakUserID,
+					"err":              err,
+				}, "unable to initialize the tenant of the approved user")
+			}
+		}(goasupport.DetachContext(ctx))
+	}
+	return ctx.OK(&app.ApprovalSingle{Data: convertApproval(approval)})
+}
+
+func convertApproval(approval *account.PendingApproval) *app.ApprovalData {
+	id := approval.ID.String()
+	return &app.ApprovalData{
+		ID:   id,
+		Type: "approvals",
+		Attributes: &app.ApprovalDataAttributes{
+			UserID:    approval.KeycloakUserID.String(),
+			Username:  &approval.Username,
+			Email:     &approval.Email,
+			FullName:  &approval.FullName,
+			Status:    approval.Status,
+			Reason:    &approval.Reason,
+			DecidedBy: &approval.DecidedBy,
+			DecidedAt: approval.DecidedAt,
+			CreatedAt: &approval.CreatedAt,
+		},
+	}
+}
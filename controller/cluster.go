@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+)
+
+// ClusterController implements the cluster resource.
+type ClusterController struct {
+	*goa.Controller
+	db             application.DB
+	config         AdminConfiguration
+	clusterService *login.ClusterService
+}
+
+// NewClusterController creates a cluster controller.
+func NewClusterController(service *goa.Service, db application.DB, config AdminConfiguration, clusterService *login.ClusterService) *ClusterController {
+	return &ClusterController{
+		Controller:     service.NewController("ClusterController"),
+		db:             db,
+		config:         config,
+		clusterService: clusterService,
+	}
+}
+
+// List runs the list action.
+func (c *ClusterController) List(ctx *app.ListClusterContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	usage, err := c.clusterService.Usage(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.ClusterData, len(usage))
+	for i := range usage {
+		data[i] = convertClusterUsage(usage[i])
+	}
+	return ctx.OK(&app.ClusterList{Data: data})
+}
+
+// MoveUser runs the move-user action.
+func (c *ClusterController) MoveUser(ctx *app.MoveUserClusterContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	movedBy, _ := token.ServiceAccountName(ctx)
+	identity, err := c.clusterService.Move(ctx, ctx.IdentityID, ctx.Payload.Cluster, movedBy)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": ctx.IdentityID,
+		"cluster":     ctx.Payload.Cluster,
+	}, "User moved to another cluster")
+	return ctx.OK(ConvertToAppUser(ctx.RequestData, &identity.User, identity))
+}
+
+func convertClusterUsage(usage login.ClusterUsage) *app.ClusterData {
+	capacity := usage.Capacity
+	weight := usage.Weight
+	region := usage.Region
+	return &app.ClusterData{
+		ID:   usage.Name,
+		Type: "clusters",
+		Attributes: &app.ClusterDataAttributes{
+			Name:     usage.Name,
+			APIURL:   usage.URL,
+			Region:   &region,
+			Capacity: &capacity,
+			Weight:   &weight,
+			Users:    usage.Users,
+			Full:     usage.Full(),
+		},
+	}
+}
@@ -0,0 +1,136 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/resource"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestClusterSuite struct {
+	gormtestsupport.DBTestSuite
+	events []login.ClusterMigration
+}
+
+func TestRunClusterSuite(t *testing.T) {
+	resource.Require(t, resource.Database)
+	suite.Run(t, &TestClusterSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *TestClusterSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.events = nil
+}
+
+func (s *TestClusterSuite) newController(svc *goa.Service) *ClusterController {
+	clusterService := login.NewClusterService(s.Application, s.Configuration)
+	clusterService.Notify = func(ctx context.Context, migration login.ClusterMigration) {
+		s.events = append(s.events, migration)
+	}
+	return NewClusterController(svc, s.Application, s.Configuration, clusterService)
+}
+
+func (s *TestClusterSuite) SecuredController(identity account.Identity) (*goa.Service, *ClusterController) {
+	svc := testsupport.ServiceAsUser("Cluster-Service", identity)
+	return svc, s.newController(svc)
+}
+
+func (s *TestClusterSuite) AdminController() (*goa.Service, *ClusterController) {
+	svc := testsupport.ServiceAsAdmin("Cluster-Admin-Service")
+	return svc, s.newController(svc)
+}
+
+func (s *TestClusterSuite) createIdentity(cluster string) account.Identity {
+	identity, err := testsupport.CreateTestIdentityAndUser(s.DB, "TestCluster", account.User{Cluster: cluster})
+	require.Nil(s.T(), err)
+	return identity
+}
+
+func (s *TestClusterSuite) usersOf(list *app.ClusterList, url string) int {
+	for _, cluster := range list.Data {
+		if cluster.Attributes.APIURL == url {
+			return cluster.Attributes.Users
+		}
+	}
+	require.Fail(s.T(), "cluster not found", url)
+	return 0
+}
+
+func (s *TestClusterSuite) TestMoveUserOK() {
+	// given
+	source := "https://api.starter-us-east-2.openshift.com"
+	target := "https://api.starter-us-east-2a.openshift.com"
+	identity := s.createIdentity(source)
+	svc, ctrl := s.AdminController()
+	_, before := test.ListClusterOK(s.T(), svc.Context, svc, ctrl)
+	require.Len(s.T(), before.Data, len(s.Configuration.GetOSOClusters()))
+
+	// when
+	_, result := test.MoveUserClusterOK(s.T(), svc.Context, svc, ctrl, identity.ID, &app.ClusterMoveRequest{Cluster: target})
+
+	// then
+	assert.Equal(s.T(), target, *result.Data.Attributes.Cluster)
+	user, err := s.Application.Users().Load(s.Ctx, identity.User.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), target, user.Cluster)
+	require.Len(s.T(), s.events, 1)
+	assert.Equal(s.T(), source, s.events[0].SourceCluster)
+	assert.Equal(s.T(), target, s.events[0].TargetCluster)
+	assert.Equal(s.T(), identity.ID, s.events[0].IdentityID)
+	_, after := test.ListClusterOK(s.T(), svc.Context, svc, ctrl)
+	assert.Equal(s.T(), s.usersOf(before, source)-1, s.usersOf(after, source))
+	assert.Equal(s.T(), s.usersOf(before, target)+1, s.usersOf(after, target))
+}
+
+func (s *TestClusterSuite) TestMoveUserWithTrailingSlashOK() {
+	// given
+	target := "https://api.starter-us-east-2a.openshift.com"
+	identity := s.createIdentity("https://api.starter-us-east-2.openshift.com")
+	svc, ctrl := s.AdminController()
+
+	// when
+	_, result := test.MoveUserClusterOK(s.T(), svc.Context, svc, ctrl, identity.ID, &app.ClusterMoveRequest{Cluster: target + "/"})
+
+	// then the URL of the configured cluster is stored
+	assert.Equal(s.T(), target, *result.Data.Attributes.Cluster)
+	user, err := s.Application.Users().Load(s.Ctx, identity.User.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), target, user.Cluster)
+}
+
+func (s *TestClusterSuite) TestMoveUserBadRequest() {
+	// given
+	identity := s.createIdentity("https://api.starter-us-east-2.openshift.com")
+	svc, ctrl := s.AdminController()
+
+	// unknown cluster
+	test.MoveUserClusterBadRequest(s.T(), svc.Context, svc, ctrl, identity.ID, &app.ClusterMoveRequest{Cluster: "https://api.unknown.openshift.com"})
+	// current cluster
+	test.MoveUserClusterBadRequest(s.T(), svc.Context, svc, ctrl, identity.ID, &app.ClusterMoveRequest{Cluster: identity.User.Cluster})
+	test.MoveUserClusterBadRequest(s.T(), svc.Context, svc, ctrl, identity.ID, &app.ClusterMoveRequest{Cluster: identity.User.Cluster + "/"})
+	// unknown identity
+	test.MoveUserClusterNotFound(s.T(), svc.Context, svc, ctrl, uuid.NewV4(), &app.ClusterMoveRequest{Cluster: "https://api.starter-us-east-2a.openshift.com"})
+	assert.Empty(s.T(), s.events)
+}
+
+func (s *TestClusterSuite) TestClustersForbiddenForUsers() {
+	identity := s.createIdentity("https://api.starter-us-east-2.openshift.com")
+	svc, ctrl := s.SecuredController(identity)
+
+	test.ListClusterForbidden(s.T(), svc.Context, svc, ctrl)
+	test.MoveUserClusterForbidden(s.T(), svc.Context, svc, ctrl, identity.ID, &app.ClusterMoveRequest{Cluster: "https://api.starter-us-east-2a.openshift.com"})
+	assert.Empty(s.T(), s.events)
+}
@@ -50,22 +50,8 @@ func (c *CollaboratorsController) List(ctx *app.ListCollaboratorsContext) error
 	userIDs := policy.Config.UserIDs
 	//UsersIDs format : "[\"<ID>\",\"<ID>\"]"
 	s := strings.Split(userIDs, ",")
-	count := len(s)
-
-	offset, limit := computePagingLimits(ctx.PageOffset, ctx.PageLimit)
-
-	pageOffset := offset
-	pageLimit := offset + limit
-	if offset > len(s) {
-		pageOffset = len(s)
-	}
-	if offset+limit > len(s) {
-		pageLimit = len(s)
-	}
-	page := s[pageOffset:pageLimit]
-	resultIdentities := make([]account.Identity, len(page))
-	resultUsers := make([]account.User, len(page))
-	for i, id := range page {
+	collaboratorIDs := make([]uuid.UUID, len(s))
+	for i, id := range s {
 		id = strings.Trim(id, "[]\"")
 		uID, err := uuid.FromString(id)
 		if err != nil {
@@ -75,23 +61,66 @@ func (c *CollaboratorsController) List(ctx *app.ListCollaboratorsContext) error
 			}, "unable to convert the identity ID to uuid v4")
 			return jsonapi.JSONErrorResponse(ctx, goa.ErrInternal(err.Error()))
 		}
+		collaboratorIDs[i] = uID
+	}
+	// the members of the teams holding a role on the space are collaborators for as long as they belong to the team,
+	// including the members added before the teams were synchronized with the space policy
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		memberIDs, err := appl.TeamRoles().ListMemberIDsByResource(ctx, ctx.SpaceID.String())
+		if err != nil {
+			return err
+		}
+		collaboratorIDs = appendMissingIDs(collaboratorIDs, memberIDs)
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"space_id": ctx.SpaceID,
+			"err":      err,
+		}, "unable to list the members of the teams of the space")
+		return jsonapi.JSONErrorResponse(ctx, goa.ErrInternal(err.Error()))
+	}
+	count := len(collaboratorIDs)
+
+	offset, limit := computePagingLimits(ctx.PageOffset, ctx.PageLimit)
+
+	pageOffset := offset
+	pageLimit := offset + limit
+	if offset > count {
+		pageOffset = count
+	}
+	if offset+limit > count {
+		pageLimit = count
+	}
+	ids := collaboratorIDs[pageOffset:pageLimit]
+	resultIdentities := make([]account.Identity, len(ids))
+	resultUsers := make([]account.User, len(ids))
+	if len(ids) > 0 {
 		err = application.Transactional(c.db, func(appl application.Application) error {
-			identities, err := appl.Identities().Query(account.IdentityFilterByID(uID), account.IdentityWithUser())
+			identities, err := appl.Identities().Query(account.IdentityFilterByIDs(ids), account.IdentityWithUser())
 			if err != nil {
 				log.Error(ctx, map[string]interface{}{
-					"identity_id": id,
-					"err":         err,
-				}, "unable to find the identity listed in the space policy")
+					"identity_ids": ids,
+					"err":          err,
+				}, "unable to find the identities listed in the space policy")
 				return err
 			}
-			if len(identities) == 0 {
-				log.Error(ctx, map[string]interface{}{
-					"identity_id": id,
-				}, "unable to find the identity listed in the space policy")
-				return errors.New("Identity listed in the space policy not found")
+			identitiesByID := make(map[uuid.UUID]account.Identity, len(identities))
+			for _, identity := range identities {
+				identitiesByID[identity.ID] = identity
+			}
+			// keep the order of the space policy
+			for i, id := range ids {
+				identity, found := identitiesByID[id]
+				if !found {
+					log.Error(ctx, map[string]interface{}{
+						"identity_id": id,
+					}, "unable to find the identity listed in the space policy")
+					return errors.New("Identity listed in the space policy not found")
+				}
+				resultIdentities[i] = identity
+				resultUsers[i] = identity.User
 			}
-			resultIdentities[i] = identities[0]
-			resultUsers[i] = identities[0].User
 			return nil
 		})
 		if err != nil {
@@ -100,7 +129,7 @@ func (c *CollaboratorsController) List(ctx *app.ListCollaboratorsContext) error
 	}
 
 	return ctx.ConditionalEntities(resultUsers, c.config.GetCacheControlCollaborators, func() error {
-		data := make([]*app.UserData, len(page))
+		data := make([]*app.UserData, len(ids))
 		for i := range resultUsers {
 			appUser := ConvertToAppUser(ctx.RequestData, &resultUsers[i], &resultIdentities[i])
 			data[i] = appUser.Data
@@ -110,7 +139,7 @@ func (c *CollaboratorsController) List(ctx *app.ListCollaboratorsContext) error
 			Meta:  &app.UserListMeta{TotalCount: count},
 			Data:  data,
 		}
-		setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(page), offset, limit, count)
+		setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(ids), offset, limit, count)
 		return ctx.OK(&response)
 	})
 }
@@ -271,3 +300,18 @@ func (c *CollaboratorsController) getPolicy(ctx collaboratorContext, req *goa.Re
 	}
 	return policy, pat, nil
 }
+
+// appendMissingIDs appends to the given IDs the other IDs they don't contain yet
+func appendMissingIDs(ids []uuid.UUID, others []uuid.UUID) []uuid.UUID {
+	found := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		found[id] = true
+	}
+	for _, id := range others {
+		if !found[id] {
+			found[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+)
+
+// ErasureController implements the erasure resource.
+type ErasureController struct {
+	*goa.Controller
+	db             application.DB
+	config         AdminConfiguration
+	erasureService *login.UserErasureService
+}
+
+// NewErasureController creates an erasure controller.
+func NewErasureController(service *goa.Service, db application.DB, config AdminConfiguration, erasureService *login.UserErasureService) *ErasureController {
+	return &ErasureController{
+		Controller:     service.NewController("ErasureController"),
+		db:             db,
+		config:         config,
+		erasureService: erasureService,
+	}
+}
+
+// Create runs the create action. The users can deactivate themselves, the admin service accounts can deactivate any user.
+func (c *ErasureController) Create(ctx *app.CreateErasureContext) error {
+	var identityID uuid.UUID
+	var requestedBy string
+	if ctx.Payload != nil && ctx.Payload.IdentityID != nil {
+		if err := checkAdmin(ctx, c.config); err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+		identityID = *ctx.Payload.IdentityID
+		requestedBy, _ = token.ServiceAccountName(ctx)
+	} else {
+		currentIdentity, err := login.ContextIdentity(ctx)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+		}
+		identityID = *currentIdentity
+		requestedBy = currentIdentity.String()
+	}
+	erasure, err := c.erasureService.Deactivate(ctx, ctx.RequestData, identityID, requestedBy)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.ErasureHref(erasure.ID)))
+	return ctx.Created(&app.ErasureSingle{Data: convertErasure(ctx.RequestData, erasure)})
+}
+
+// List runs the list action.
+func (c *ErasureController) List(ctx *app.ListErasureContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	offset, limit := computePagingLimits(ctx.PageOffset, ctx.PageLimit)
+	due := ctx.Due != nil && *ctx.Due
+	var erasures []account.UserErasure
+	var count int
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		erasures, count, err = appl.UserErasures().List(ctx, ctx.Status, due, offset, limit)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.ErasureData, len(erasures))
+	for i := range erasures {
+		data[i] = convertErasure(ctx.RequestData, &erasures[i])
+	}
+	response := app.ErasureList{
+		Data:  data,
+		Links: &app.PagingLinks{},
+		Meta:  &app.ErasureListMeta{TotalCount: count},
+	}
+	var additionalQuery []string
+	if ctx.Status != nil {
+		additionalQuery = append(additionalQuery, "status="+*ctx.Status)
+	}
+	if due {
+		additionalQuery = append(additionalQuery, "due=true")
+	}
+	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(erasures), offset, limit, count, additionalQuery...)
+	return ctx.OK(&response)
+}
+
+// Show runs the show action. The users can see their own erasure, the admin service accounts can see all of them.
+func (c *ErasureController) Show(ctx *app.ShowErasureContext) error {
+	var erasure *account.UserErasure
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		erasure, err = appl.UserErasures().Load(ctx, ctx.ID)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	currentIdentity, err := login.ContextIdentity(ctx)
+	if err != nil || *currentIdentity != erasure.IdentityID {
+		if err := checkAdmin(ctx, c.config); err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+	}
+	return ctx.OK(&app.ErasureSingle{Data: convertErasure(ctx.RequestData, erasure)})
+}
+
+// Run runs the run action.
+func (c *ErasureController) Run(ctx *app.RunErasureContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	force := ctx.Payload != nil && ctx.Payload.Force
+	erasure, err := c.erasureService.Erase(ctx, ctx.RequestData, ctx.ID, force)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.ErasureSingle{Data: convertErasure(ctx.RequestData, erasure)})
+}
+
+func convertErasure(request *goa.RequestData, erasure *account.UserErasure) *app.ErasureData {
+	id := erasure.ID.String()
+	selfURL := rest.AbsoluteURL(request, app.ErasureHref(id))
+	var step *string
+	if erasure.Step != "" {
+		step = &erasure.Step
+	}
+	var lastError *string
+	if erasure.LastError != "" {
+		lastError = &erasure.LastError
+	}
+	return &app.ErasureData{
+		ID:   id,
+		Type: "erasures",
+		Attributes: &app.ErasureDataAttributes{
+			IdentityID:  erasure.IdentityID.String(),
+			Status:      erasure.Status,
+			Step:        step,
+			Attempts:    &erasure.Attempts,
+			LastError:   lastError,
+			RequestedBy: &erasure.RequestedBy,
+			EraseAfter:  &erasure.EraseAfter,
+			CompletedAt: erasure.CompletedAt,
+			CreatedAt:   &erasure.CreatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self: &selfURL,
+		},
+	}
+}
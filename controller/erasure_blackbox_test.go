@@ -0,0 +1,161 @@
+package controller_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/resource"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestErasureSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunErasureSuite(t *testing.T) {
+	resource.Require(t, resource.Database)
+	suite.Run(t, &TestErasureSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *TestErasureSuite) newController(svc *goa.Service) *ErasureController {
+	profileService := newDummyUserProfileService(nil)
+	erasureService := login.NewUserErasureService(s.Application, profileService, s.Configuration)
+	erasureService.RemoteWITService = &dummyRemoteWITService{}
+	return NewErasureController(svc, s.Application, s.Configuration, erasureService)
+}
+
+func (s *TestErasureSuite) SecuredController(identity account.Identity) (*goa.Service, *ErasureController) {
+	svc := testsupport.ServiceAsUser("Erasure-Service", identity)
+	return svc, s.newController(svc)
+}
+
+func (s *TestErasureSuite) AdminController() (*goa.Service, *ErasureController) {
+	svc := testsupport.ServiceAsAdmin("Erasure-Admin-Service")
+	return svc, s.newController(svc)
+}
+
+func (s *TestErasureSuite) createErasure(status string, eraseAfter time.Time) (account.Identity, *account.UserErasure) {
+	identity, err := testsupport.CreateTestIdentityAndUser(s.DB, "TestErasure", account.User{})
+	require.Nil(s.T(), err)
+	erasure := &account.UserErasure{
+		IdentityID: identity.ID,
+		UserID:     identity.User.ID,
+		Status:     status,
+		EraseAfter: eraseAfter,
+	}
+	require.Nil(s.T(), s.Application.UserErasures().Create(s.Ctx, erasure))
+	return identity, erasure
+}
+
+func (s *TestErasureSuite) TestCreateErasureForAnotherUserForbidden() {
+	// given
+	identity, _ := s.createErasure(account.ErasureStatusPending, time.Now())
+	svc, ctrl := s.SecuredController(identity)
+	other := uuid.NewV4()
+	// when/then
+	test.CreateErasureForbidden(s.T(), svc.Context, svc, ctrl, &app.CreateErasurePayload{IdentityID: &other})
+}
+
+func (s *TestErasureSuite) TestCreateErasureOfUnknownIdentityNotFound() {
+	svc, ctrl := s.AdminController()
+	unknown := uuid.NewV4()
+	test.CreateErasureNotFound(s.T(), svc.Context, svc, ctrl, &app.CreateErasurePayload{IdentityID: &unknown})
+}
+
+func (s *TestErasureSuite) TestCreateErasureIsIdempotent() {
+	// given a user who is already deactivated
+	identity, erasure := s.createErasure(account.ErasureStatusPending, time.Now().Add(time.Hour))
+	svc, ctrl := s.SecuredController(identity)
+	// when
+	_, result := test.CreateErasureCreated(s.T(), svc.Context, svc, ctrl, nil)
+	// then the existing erasure is returned
+	assert.Equal(s.T(), erasure.ID.String(), result.Data.ID)
+}
+
+func (s *TestErasureSuite) TestListErasures() {
+	// given
+	s.createErasure(account.ErasureStatusPending, time.Now().Add(-time.Minute))
+	_, notDue := s.createErasure(account.ErasureStatusPending, time.Now().Add(time.Hour))
+	_, completed := s.createErasure(account.ErasureStatusCompleted, time.Now().Add(-time.Minute))
+	svc, ctrl := s.AdminController()
+	due := true
+
+	// when
+	_, result := test.ListErasureOK(s.T(), svc.Context, svc, ctrl, &due, nil, nil, nil)
+
+	// then
+	require.NotEmpty(s.T(), result.Data)
+	for _, data := range result.Data {
+		assert.NotEqual(s.T(), notDue.ID.String(), data.ID)
+		assert.NotEqual(s.T(), completed.ID.String(), data.ID)
+	}
+
+	// only the admins can list the erasures
+	identity, _ := s.createErasure(account.ErasureStatusPending, time.Now())
+	userSvc, userCtrl := s.SecuredController(identity)
+	test.ListErasureForbidden(s.T(), userSvc.Context, userSvc, userCtrl, nil, nil, nil, nil)
+}
+
+func (s *TestErasureSuite) TestShowErasure() {
+	// given
+	identity, erasure := s.createErasure(account.ErasureStatusPending, time.Now().Add(time.Hour))
+	other, _ := s.createErasure(account.ErasureStatusPending, time.Now().Add(time.Hour))
+
+	s.T().Run("own erasure", func(t *testing.T) {
+		svc, ctrl := s.SecuredController(identity)
+		_, result := test.ShowErasureOK(t, svc.Context, svc, ctrl, erasure.ID)
+		assert.Equal(t, identity.ID.String(), result.Data.Attributes.IdentityID)
+		assert.Equal(t, account.ErasureStatusPending, result.Data.Attributes.Status)
+	})
+
+	s.T().Run("erasure of another user", func(t *testing.T) {
+		svc, ctrl := s.SecuredController(other)
+		test.ShowErasureForbidden(t, svc.Context, svc, ctrl, erasure.ID)
+	})
+
+	s.T().Run("admin", func(t *testing.T) {
+		svc, ctrl := s.AdminController()
+		test.ShowErasureOK(t, svc.Context, svc, ctrl, erasure.ID)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		svc, ctrl := s.AdminController()
+		test.ShowErasureNotFound(t, svc.Context, svc, ctrl, uuid.NewV4())
+	})
+}
+
+func (s *TestErasureSuite) TestRunErasure() {
+	s.T().Run("forbidden", func(t *testing.T) {
+		identity, erasure := s.createErasure(account.ErasureStatusPending, time.Now().Add(-time.Minute))
+		svc, ctrl := s.SecuredController(identity)
+		test.RunErasureForbidden(t, svc.Context, svc, ctrl, erasure.ID, &app.RunErasurePayload{Force: true})
+	})
+
+	s.T().Run("grace period not elapsed", func(t *testing.T) {
+		_, erasure := s.createErasure(account.ErasureStatusPending, time.Now().Add(time.Hour))
+		svc, ctrl := s.AdminController()
+		test.RunErasureBadRequest(t, svc.Context, svc, ctrl, erasure.ID, nil)
+		loaded, err := s.Application.UserErasures().Load(s.Ctx, erasure.ID)
+		require.Nil(t, err)
+		assert.Equal(t, 0, loaded.Attempts)
+	})
+
+	s.T().Run("already completed", func(t *testing.T) {
+		_, erasure := s.createErasure(account.ErasureStatusCompleted, time.Now().Add(-time.Minute))
+		svc, ctrl := s.AdminController()
+		_, result := test.RunErasureOK(t, svc.Context, svc, ctrl, erasure.ID, nil)
+		assert.Equal(t, account.ErasureStatusCompleted, result.Data.Attributes.Status)
+	})
+}
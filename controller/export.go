@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+)
+
+// ExportController implements the export resource.
+type ExportController struct {
+	*goa.Controller
+	db            application.DB
+	config        AdminConfiguration
+	exportService *login.UserExportService
+}
+
+// NewExportController creates an export controller.
+func NewExportController(service *goa.Service, db application.DB, config AdminConfiguration, exportService *login.UserExportService) *ExportController {
+	return &ExportController{
+		Controller:    service.NewController("ExportController"),
+		db:            db,
+		config:        config,
+		exportService: exportService,
+	}
+}
+
+// Create runs the create action. It starts the export of the personal data of the authenticated user.
+func (c *ExportController) Create(ctx *app.CreateExportContext) error {
+	currentIdentity, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	export, err := c.exportService.Request(ctx, *currentIdentity, currentIdentity.String())
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.ExportHref(export.ID)))
+	return ctx.Accepted(&app.ExportSingle{Data: convertExport(ctx.RequestData, export)})
+}
+
+// CreateForUser runs the create_for_user action. Only the admin service accounts can export the data of any user.
+func (c *ExportController) CreateForUser(ctx *app.CreateForUserExportContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	requestedBy, _ := token.ServiceAccountName(ctx)
+	export, err := c.exportService.Request(ctx, ctx.ID, requestedBy)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.ExportHref(export.ID)))
+	return ctx.Accepted(&app.ExportSingle{Data: convertExport(ctx.RequestData, export)})
+}
+
+// Show runs the show action. The users can see their own exports, the admin service accounts can see all of them.
+func (c *ExportController) Show(ctx *app.ShowExportContext) error {
+	export, err := c.loadExport(ctx, ctx.ID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.ExportSingle{Data: convertExport(ctx.RequestData, export)})
+}
+
+// Download runs the download action. The archive can be downloaded once the export is completed until it expires.
+func (c *ExportController) Download(ctx *app.DownloadExportContext) error {
+	export, err := c.loadExport(ctx, ctx.ID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if !export.Available() {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundError("user export archive", export.ID.String()))
+	}
+	ctx.ResponseData.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"export-%s.json\"", export.ID))
+	return ctx.OK([]byte(export.Archive))
+}
+
+// loadExport loads the export if it belongs to the authenticated user or if the caller is an admin service account
+func (c *ExportController) loadExport(ctx context.Context, id uuid.UUID) (*account.UserExport, error) {
+	var export *account.UserExport
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		export, err = appl.UserExports().Load(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	currentIdentity, err := login.ContextIdentity(ctx)
+	if err != nil || *currentIdentity != export.IdentityID {
+		if err := checkAdmin(ctx, c.config); err != nil {
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+func convertExport(request *goa.RequestData, export *account.UserExport) *app.ExportData {
+	id := export.ID.String()
+	selfURL := rest.AbsoluteURL(request, app.ExportHref(id))
+	downloadURL := rest.AbsoluteURL(request, app.ExportHref(id)+"/download")
+	var lastError *string
+	if export.LastError != "" {
+		lastError = &export.LastError
+	}
+	links := &app.GenericLinks{
+		Self: &selfURL,
+	}
+	if export.Available() {
+		links.Related = &downloadURL
+	}
+	return &app.ExportData{
+		ID:   id,
+		Type: "exports",
+		Attributes: &app.ExportDataAttributes{
+			IdentityID:  export.IdentityID.String(),
+			Status:      export.Status,
+			LastError:   lastError,
+			RequestedBy: &export.RequestedBy,
+			CompletedAt: export.CompletedAt,
+			ExpiresAt:   export.ExpiresAt,
+			CreatedAt:   &export.CreatedAt,
+		},
+		Links: links,
+	}
+}
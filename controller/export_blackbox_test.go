@@ -0,0 +1,172 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/resource"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	"github.com/fabric8-services/fabric8-auth/token/provider"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestExportSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunExportSuite(t *testing.T) {
+	resource.Require(t, resource.Database)
+	suite.Run(t, &TestExportSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *TestExportSuite) newController(svc *goa.Service) *ExportController {
+	exportService := login.NewUserExportService(s.Application, s.Configuration)
+	// build the archives synchronously so the tests don't have to wait for them
+	exportService.Run = func(job func()) {
+		job()
+	}
+	return NewExportController(svc, s.Application, s.Configuration, exportService)
+}
+
+func (s *TestExportSuite) SecuredController(identity account.Identity) (*goa.Service, *ExportController) {
+	svc := testsupport.ServiceAsUser("Export-Service", identity)
+	return svc, s.newController(svc)
+}
+
+func (s *TestExportSuite) AdminController() (*goa.Service, *ExportController) {
+	svc := testsupport.ServiceAsAdmin("Export-Admin-Service")
+	return svc, s.newController(svc)
+}
+
+func (s *TestExportSuite) createIdentity() account.Identity {
+	identity, err := testsupport.CreateTestIdentityAndUser(s.DB, "TestExport", account.User{ContextInformation: account.ContextInformation{"last_visited": "https://openshift.io"}})
+	require.Nil(s.T(), err)
+	return identity
+}
+
+func (s *TestExportSuite) TestExportOwnData() {
+	// given
+	identity := s.createIdentity()
+	externalToken := provider.ExternalToken{
+		ProviderID: uuid.NewV4(),
+		Scope:      "user:full",
+		IdentityID: identity.ID,
+		Token:      "secret-token-" + uuid.NewV4().String(),
+		Username:   "export-github-user",
+	}
+	require.Nil(s.T(), s.Application.ExternalTokens().Create(s.Ctx, &externalToken))
+	svc, ctrl := s.SecuredController(identity)
+
+	// when
+	rw, result := test.CreateExportAccepted(s.T(), svc.Context, svc, ctrl)
+
+	// then
+	require.NotNil(s.T(), result.Data)
+	assert.Equal(s.T(), identity.ID.String(), result.Data.Attributes.IdentityID)
+	assert.Equal(s.T(), account.ExportStatusCompleted, result.Data.Attributes.Status)
+	assert.NotEmpty(s.T(), rw.Header().Get("Location"))
+	require.NotNil(s.T(), result.Data.Links.Related)
+
+	exportID, err := uuid.FromString(result.Data.ID)
+	require.Nil(s.T(), err)
+	export, err := s.Application.UserExports().Load(s.Ctx, exportID)
+	require.Nil(s.T(), err)
+	assert.NotContains(s.T(), export.Archive, externalToken.Token)
+	var archive login.UserExportArchive
+	require.Nil(s.T(), json.Unmarshal([]byte(export.Archive), &archive))
+	assert.Equal(s.T(), identity.User.Email, archive.User.Email)
+	assert.Equal(s.T(), "https://openshift.io", archive.User.ContextInformation["last_visited"])
+	require.Len(s.T(), archive.Identities, 1)
+	assert.Equal(s.T(), identity.ID, archive.Identities[0].ID)
+	require.Len(s.T(), archive.LinkedAccounts, 1)
+	assert.Equal(s.T(), "export-github-user", archive.LinkedAccounts[0].Username)
+
+	test.DownloadExportOK(s.T(), svc.Context, svc, ctrl, exportID)
+
+	// the completed export is returned until it expires
+	_, again := test.CreateExportAccepted(s.T(), svc.Context, svc, ctrl)
+	assert.Equal(s.T(), result.Data.ID, again.Data.ID)
+}
+
+func (s *TestExportSuite) TestExportOfAnotherUser() {
+	// given
+	identity := s.createIdentity()
+	other := s.createIdentity()
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredController(other)
+		test.CreateForUserExportForbidden(t, svc.Context, svc, ctrl, identity.ID)
+	})
+
+	s.T().Run("admin", func(t *testing.T) {
+		svc, ctrl := s.AdminController()
+		_, result := test.CreateForUserExportAccepted(t, svc.Context, svc, ctrl, identity.ID)
+		assert.Equal(t, identity.ID.String(), result.Data.Attributes.IdentityID)
+
+		exportID, err := uuid.FromString(result.Data.ID)
+		require.Nil(t, err)
+		test.ShowExportOK(t, svc.Context, svc, ctrl, exportID)
+		ownerSvc, ownerCtrl := s.SecuredController(identity)
+		test.ShowExportOK(t, ownerSvc.Context, ownerSvc, ownerCtrl, exportID)
+		otherSvc, otherCtrl := s.SecuredController(other)
+		test.ShowExportForbidden(t, otherSvc.Context, otherSvc, otherCtrl, exportID)
+		test.DownloadExportForbidden(t, otherSvc.Context, otherSvc, otherCtrl, exportID)
+	})
+
+	s.T().Run("unknown identity", func(t *testing.T) {
+		svc, ctrl := s.AdminController()
+		test.CreateForUserExportNotFound(t, svc.Context, svc, ctrl, uuid.NewV4())
+	})
+}
+
+func (s *TestExportSuite) TestDownloadExpiredExportNotFound() {
+	// given
+	identity := s.createIdentity()
+	completedAt := time.Now().Add(-2 * time.Hour)
+	expiresAt := time.Now().Add(-time.Hour)
+	export := &account.UserExport{
+		IdentityID:  identity.ID,
+		RequestedBy: identity.ID.String(),
+		Status:      account.ExportStatusCompleted,
+		Archive:     "{}",
+		CompletedAt: &completedAt,
+		ExpiresAt:   &expiresAt,
+	}
+	require.Nil(s.T(), s.Application.UserExports().Create(s.Ctx, export))
+	svc, ctrl := s.SecuredController(identity)
+	// when/then
+	test.DownloadExportNotFound(s.T(), svc.Context, svc, ctrl, export.ID)
+}
+
+func (s *TestExportSuite) TestRequestExportWhenPendingExportTimedOut() {
+	// given
+	identity := s.createIdentity()
+	export := &account.UserExport{
+		Lifecycle:   gormsupport.Lifecycle{CreatedAt: time.Now().Add(-time.Hour)},
+		IdentityID:  identity.ID,
+		RequestedBy: identity.ID.String(),
+		Status:      account.ExportStatusPending,
+	}
+	require.Nil(s.T(), s.Application.UserExports().Create(s.Ctx, export))
+	svc, ctrl := s.SecuredController(identity)
+	// when
+	_, result := test.CreateExportAccepted(s.T(), svc.Context, svc, ctrl)
+	// then a new export is started and the interrupted one is marked as failed
+	assert.NotEqual(s.T(), export.ID.String(), result.Data.ID)
+	assert.Equal(s.T(), account.ExportStatusCompleted, result.Data.Attributes.Status)
+	loaded, err := s.Application.UserExports().Load(s.Ctx, export.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), account.ExportStatusFailed, loaded.Status)
+}
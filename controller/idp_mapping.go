@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+
+	"github.com/goadesign/goa"
+)
+
+// IdpMappingController implements the idp_mapping resource.
+type IdpMappingController struct {
+	*goa.Controller
+	db     application.DB
+	config AdminConfiguration
+}
+
+// NewIdpMappingController creates an idp_mapping controller.
+func NewIdpMappingController(service *goa.Service, db application.DB, config AdminConfiguration) *IdpMappingController {
+	return &IdpMappingController{Controller: service.NewController("IdpMappingController"), db: db, config: config}
+}
+
+// List runs the list action.
+func (c *IdpMappingController) List(ctx *app.ListIdpMappingContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var mappings []auth.IdpMapping
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		mappings, err = appl.IdpMappings().List(ctx)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.IdpMappingData, len(mappings))
+	for i := range mappings {
+		data[i] = convertIdpMapping(ctx.RequestData, &mappings[i])
+	}
+	return ctx.OK(&app.IdpMappingList{Data: data})
+}
+
+// Show runs the show action.
+func (c *IdpMappingController) Show(ctx *app.ShowIdpMappingContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var mapping *auth.IdpMapping
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		mapping, err = appl.IdpMappings().Load(ctx, ctx.ID)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.IdpMappingSingle{Data: convertIdpMapping(ctx.RequestData, mapping)})
+}
+
+// Create runs the create action.
+func (c *IdpMappingController) Create(ctx *app.CreateIdpMappingContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if ctx.Payload == nil || ctx.Payload.Data == nil || ctx.Payload.Data.Attributes == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("data.attributes", nil).Expected("not empty mapping attributes"))
+	}
+	mapping := &auth.IdpMapping{}
+	if err := updateIdpMapping(mapping, ctx.Payload.Data.Attributes); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		return appl.IdpMappings().Create(ctx, mapping)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"idp_mapping_id": mapping.ID,
+		"domain":         mapping.Domain,
+		"idp_hint":       mapping.IdpHint,
+	}, "IdP mapping created")
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.IdpMappingHref(mapping.ID)))
+	return ctx.Created(&app.IdpMappingSingle{Data: convertIdpMapping(ctx.RequestData, mapping)})
+}
+
+// Update runs the update action.
+func (c *IdpMappingController) Update(ctx *app.UpdateIdpMappingContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if ctx.Payload == nil || ctx.Payload.Data == nil || ctx.Payload.Data.Attributes == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("data.attributes", nil).Expected("not empty mapping attributes"))
+	}
+	var mapping *auth.IdpMapping
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		mapping, err = appl.IdpMappings().Load(ctx, ctx.ID)
+		if err != nil {
+			return err
+		}
+		if err := updateIdpMapping(mapping, ctx.Payload.Data.Attributes); err != nil {
+			return err
+		}
+		return appl.IdpMappings().Save(ctx, mapping)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.IdpMappingSingle{Data: convertIdpMapping(ctx.RequestData, mapping)})
+}
+
+// Delete runs the delete action.
+func (c *IdpMappingController) Delete(ctx *app.DeleteIdpMappingContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		return appl.IdpMappings().Delete(ctx, ctx.ID)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"idp_mapping_id": ctx.ID,
+	}, "IdP mapping deleted")
+	return ctx.OK([]byte{})
+}
+
+// updateIdpMapping sets the mapping from the given attributes
+func updateIdpMapping(mapping *auth.IdpMapping, attributes *app.IdpMappingDataAttributes) error {
+	domain := strings.TrimSpace(attributes.Domain)
+	if domain == "" || strings.Contains(domain, "@") {
+		return errors.NewBadParameterError("domain", attributes.Domain).Expected("email domain")
+	}
+	if strings.TrimSpace(attributes.IdpHint) == "" {
+		return errors.NewBadParameterError("idp_hint", attributes.IdpHint).Expected("alias of the Identity Provider")
+	}
+	mapping.Domain = domain
+	mapping.IdpHint = strings.TrimSpace(attributes.IdpHint)
+	return nil
+}
+
+func convertIdpMapping(request *goa.RequestData, mapping *auth.IdpMapping) *app.IdpMappingData {
+	id := mapping.ID.String()
+	selfURL := rest.AbsoluteURL(request, app.IdpMappingHref(id))
+	return &app.IdpMappingData{
+		ID:   &id,
+		Type: "idp_mappings",
+		Attributes: &app.IdpMappingDataAttributes{
+			Domain:    mapping.Domain,
+			IdpHint:   mapping.IdpHint,
+			CreatedAt: &mapping.CreatedAt,
+			UpdatedAt: &mapping.UpdatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self: &selfURL,
+		},
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/login"
 	"github.com/fabric8-services/fabric8-auth/token"
 	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
 	errs "github.com/pkg/errors"
 )
 
@@ -70,3 +71,38 @@ func (c *LinkController) Callback(ctx *app.CallbackLinkContext) error {
 	ctx.ResponseData.Header().Set("Cache-Control", "no-cache")
 	return c.Auth.LinkCallback(ctx, brokerEndpoint, clientID)
 }
+
+// Status returns the link status of each identity provider
+func (c *LinkController) Status(ctx *app.StatusLinkContext) error {
+	identityID, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	brokerEndpoint, err := c.Configuration.GetKeycloakEndpointBroker(ctx.RequestData)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Unable to get Keycloak broker endpoint URL")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, errs.Wrap(err, "unable to get Keycloak broker endpoint URL")))
+	}
+	statuses, err := c.Auth.LinkStatus(ctx, *identityID, goajwt.ContextJWT(ctx).Raw, brokerEndpoint, c.Configuration)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.LinkStatusData, len(statuses))
+	for i, status := range statuses {
+		username := status.Username
+		data[i] = &app.LinkStatusData{
+			ID:   status.Provider,
+			Type: "link-status",
+			Attributes: &app.LinkStatusDataAttributes{
+				Provider: status.Provider,
+				For:      status.ForResource,
+				Linked:   status.Linked,
+				Username: &username,
+			},
+		}
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-cache")
+	return ctx.OK(&app.LinkStatusList{Data: data})
+}
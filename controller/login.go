@@ -1,9 +1,17 @@
 package controller
 
 import (
+	"bytes"
 	"fmt"
+	"html/template"
+	"net/url"
+	"time"
 
+	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/client"
 	"github.com/fabric8-services/fabric8-auth/configuration"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
@@ -18,7 +26,9 @@ import (
 )
 
 type LoginConfiguration interface {
+	account.UserPolicyConfiguration
 	GetKeycloakEndpointAuth(*goa.RequestData) (string, error)
+	GetKeycloakEndpointAdmin(*goa.RequestData) (string, error)
 	GetKeycloakEndpointToken(*goa.RequestData) (string, error)
 	GetKeycloakAccountEndpoint(req *goa.RequestData) (string, error)
 	GetKeycloakEndpointBroker(*goa.RequestData) (string, error)
@@ -27,6 +37,7 @@ type LoginConfiguration interface {
 	GetKeycloakSecret() string
 	IsPostgresDeveloperModeEnabled() bool
 	GetOpenShiftClientApiUrl() string
+	GetOSOClusters() map[string]configuration.OSOCluster
 	GetKeycloakTestUserName() string
 	GetKeycloakTestUserSecret() string
 	GetKeycloakTestUser2Name() string
@@ -38,19 +49,22 @@ type LoginConfiguration interface {
 	GetKeycloakURL() string
 	GetKeycloakRealm() string
 	GetServiceAccounts() map[string]configuration.ServiceAccount
+	GetDeviceCodeExpiresIn() time.Duration
+	GetDeviceCodePollingInterval() int
 }
 
 // LoginController implements the login resource.
 type LoginController struct {
 	*goa.Controller
+	db            application.DB
 	Auth          login.KeycloakOAuthService
 	TokenManager  token.Manager
 	Configuration LoginConfiguration
 }
 
 // NewLoginController creates a login controller.
-func NewLoginController(service *goa.Service, auth *login.KeycloakOAuthProvider, tokenManager token.Manager, configuration LoginConfiguration) *LoginController {
-	return &LoginController{Controller: service.NewController("login"), Auth: auth, TokenManager: tokenManager, Configuration: configuration}
+func NewLoginController(service *goa.Service, db application.DB, auth *login.KeycloakOAuthProvider, tokenManager token.Manager, configuration LoginConfiguration) *LoginController {
+	return &LoginController{Controller: service.NewController("login"), db: db, Auth: auth, TokenManager: tokenManager, Configuration: configuration}
 }
 
 // Login runs the login action.
@@ -84,3 +98,166 @@ func (c *LoginController) Login(ctx *app.LoginLoginContext) error {
 	ctx.ResponseData.Header().Set("Cache-Control", "no-cache")
 	return c.Auth.Perform(ctx, oauth, c.Configuration)
 }
+
+// Device runs the device action. It starts the device authorization grant by issuing a device code and a user code.
+func (c *LoginController) Device(ctx *app.DeviceLoginContext) error {
+	payload := ctx.Payload
+	if payload == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("payload", "nil").Expected("not empty payload"))
+	}
+	if _, err := login.LoadOauthClient(ctx, c.db, payload.ClientID, login.DeviceCodeGrantType); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var scope string
+	if payload.Scope != nil {
+		scope = *payload.Scope
+	}
+	code, err := login.NewDeviceCode(payload.ClientID, scope, c.Configuration)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		return appl.DeviceCodes().Create(ctx, code)
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"client_id": payload.ClientID,
+			"err":       err,
+		}, "unable to create device code")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	userCode := login.FormatUserCode(code.UserCode)
+	verificationURI := rest.AbsoluteURL(ctx.RequestData, client.VerifyLoginPath())
+	verificationURIComplete := verificationURI + "?" + url.Values{"user_code": {userCode}}.Encode()
+	ctx.ResponseData.Header().Set("Cache-Control", "no-store")
+	return ctx.OK(&app.DeviceAuthorization{
+		DeviceCode:              code.DeviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: &verificationURIComplete,
+		ExpiresIn:               int(c.Configuration.GetDeviceCodeExpiresIn().Seconds()),
+		Interval:                code.PollingInterval,
+	})
+}
+
+// Verify runs the verify action. It checks the user code and redirects to the login flow which asks the user to confirm the request of the device after successful login.
+func (c *LoginController) Verify(ctx *app.VerifyLoginContext) error {
+	var code *auth.DeviceCode
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		code, err = appl.DeviceCodes().LoadByUserCode(ctx, login.NormalizeUserCode(ctx.UserCode))
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if code.IsExpired() || code.ApprovedAt != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("user_code", ctx.UserCode).Expected("pending user code"))
+	}
+
+	redirect := ctx.Redirect
+	if redirect == nil {
+		referrer := ctx.RequestData.Header.Get("Referer")
+		if referrer == "" {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("redirect", "").Expected("redirect param or Referer header"))
+		}
+		redirect = &referrer
+	}
+
+	parameters := url.Values{}
+	parameters.Add("user_code", code.UserCode)
+	parameters.Add("redirect", *redirect)
+	if code.Scope != "" {
+		parameters.Add("scope", code.Scope)
+	}
+	if code.ClientID != "" {
+		parameters.Add("api_client", code.ClientID)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-cache")
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, client.LoginLoginPath())+"?"+parameters.Encode())
+	return ctx.TemporaryRedirect()
+}
+
+var deviceConfirmationPage = template.Must(template.New("device_confirmation").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Device access request</title>
+</head>
+<body>
+<p><strong>{{.ClientName}}</strong> is requesting access to your account from a device.</p>
+<p>Approve the request only if the device you are signing in to displays the code <strong>{{.UserCode}}</strong>.</p>
+<form method="POST" action="{{.Action}}">
+<input type="hidden" name="user_code" value="{{.UserCode}}">
+<input type="hidden" name="confirmation" value="{{.Confirmation}}">
+<button type="submit" name="decision" value="approve">Approve</button>
+<button type="submit" name="decision" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// Confirm runs the confirm action. It shows the client and the user code to the user who logged in from the verification page
+// and asks the user to approve or deny the request of the device.
+func (c *LoginController) Confirm(ctx *app.ConfirmLoginContext) error {
+	code, err := login.LoadDeviceCodeToConfirm(ctx, c.db, ctx.UserCode, ctx.Confirmation)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	clientName := code.ClientID
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		oauthClient, err := appl.OauthClients().LoadByClientID(ctx, code.ClientID)
+		if err != nil {
+			return err
+		}
+		if oauthClient.Name != "" {
+			clientName = oauthClient.Name
+		}
+		return nil
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var page bytes.Buffer
+	err = deviceConfirmationPage.Execute(&page, map[string]string{
+		"ClientName":   clientName,
+		"UserCode":     login.FormatUserCode(code.UserCode),
+		"Confirmation": ctx.Confirmation,
+		"Action":       rest.AbsoluteURL(ctx.RequestData, client.ApproveLoginPath()),
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-store")
+	// the page must not be framed so the user can't be tricked into approving the request
+	ctx.ResponseData.Header().Set("X-Frame-Options", "DENY")
+	ctx.ResponseData.Header().Set("Content-Security-Policy", "default-src 'none'; form-action 'self'; frame-ancestors 'none'")
+	return ctx.OK(page.Bytes())
+}
+
+// Approve runs the approve action. It records the decision of the user sent from the confirmation page
+// and redirects back to the page which initiated the verification.
+func (c *LoginController) Approve(ctx *app.ApproveLoginContext) error {
+	payload := ctx.Payload
+	if payload == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("payload", "nil").Expected("not empty payload"))
+	}
+	approve := payload.Decision == "approve"
+	code, err := login.ConfirmDeviceCode(ctx, c.db, payload.UserCode, payload.Confirmation, approve)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	redirectURL, err := url.Parse(code.Redirect)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	if !approve {
+		parameters := redirectURL.Query()
+		parameters.Set("error", "access_denied")
+		redirectURL.RawQuery = parameters.Encode()
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-store")
+	ctx.ResponseData.Header().Set("Location", redirectURL.String())
+	return ctx.TemporaryRedirect()
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+)
+
+// MergeController implements the merge resource.
+type MergeController struct {
+	*goa.Controller
+	db           application.DB
+	config       AdminConfiguration
+	mergeService *login.UserMergeService
+}
+
+// NewMergeController creates a merge controller.
+func NewMergeController(service *goa.Service, db application.DB, config AdminConfiguration, mergeService *login.UserMergeService) *MergeController {
+	return &MergeController{
+		Controller:   service.NewController("MergeController"),
+		db:           db,
+		config:       config,
+		mergeService: mergeService,
+	}
+}
+
+// Create runs the create action.
+func (c *MergeController) Create(ctx *app.CreateMergeContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	mergedBy, _ := token.ServiceAccountName(ctx)
+	merge, err := c.mergeService.Merge(ctx, ctx.Payload.SourceIdentityID, ctx.Payload.TargetIdentityID, mergedBy)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.MergeHref(merge.ID)))
+	return ctx.Created(&app.MergeSingle{Data: convertMerge(ctx.RequestData, merge)})
+}
+
+// Show runs the show action.
+func (c *MergeController) Show(ctx *app.ShowMergeContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var merge *account.UserMerge
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		merge, err = appl.UserMerges().Load(ctx, ctx.ID)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.MergeSingle{Data: convertMerge(ctx.RequestData, merge)})
+}
+
+func convertMerge(request *goa.RequestData, merge *account.UserMerge) *app.MergeData {
+	id := merge.ID.String()
+	selfURL := rest.AbsoluteURL(request, app.MergeHref(id))
+	relatedURL := rest.AbsoluteURL(request, app.UsersHref(merge.TargetIdentityID))
+	return &app.MergeData{
+		ID:   id,
+		Type: "merges",
+		Attributes: &app.MergeDataAttributes{
+			SourceIdentityID: merge.SourceIdentityID.String(),
+			SourceUserID:     merge.SourceUserID.String(),
+			TargetIdentityID: merge.TargetIdentityID.String(),
+			TargetUserID:     merge.TargetUserID.String(),
+			MergedBy:         &merge.MergedBy,
+			CreatedAt:        &merge.CreatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self:    &selfURL,
+			Related: &relatedURL,
+		},
+	}
+}
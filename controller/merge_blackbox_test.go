@@ -0,0 +1,176 @@
+package controller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/resource"
+	"github.com/fabric8-services/fabric8-auth/space"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	"github.com/fabric8-services/fabric8-auth/token/provider"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestMergeSuite struct {
+	gormtestsupport.DBTestSuite
+	events []account.UserMerge
+}
+
+func TestRunMergeSuite(t *testing.T) {
+	resource.Require(t, resource.Database)
+	suite.Run(t, &TestMergeSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *TestMergeSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.events = nil
+}
+
+func (s *TestMergeSuite) newController(svc *goa.Service) *MergeController {
+	mergeService := login.NewUserMergeService(s.Application)
+	mergeService.Notify = func(ctx context.Context, merge account.UserMerge) {
+		s.events = append(s.events, merge)
+	}
+	return NewMergeController(svc, s.Application, s.Configuration, mergeService)
+}
+
+func (s *TestMergeSuite) SecuredController(identity account.Identity) (*goa.Service, *MergeController) {
+	svc := testsupport.ServiceAsUser("Merge-Service", identity)
+	return svc, s.newController(svc)
+}
+
+func (s *TestMergeSuite) AdminController() (*goa.Service, *MergeController) {
+	svc := testsupport.ServiceAsAdmin("Merge-Admin-Service")
+	return svc, s.newController(svc)
+}
+
+func (s *TestMergeSuite) createIdentity(email string, providerType string, user *account.User) account.Identity {
+	if user == nil {
+		user = &account.User{
+			Email:    email,
+			FullName: "TestMerge",
+			Cluster:  "TestMerge cluster",
+		}
+		require.Nil(s.T(), s.Application.Users().Create(s.Ctx, user))
+	}
+	identity := account.Identity{
+		Username:     "TestMerge" + uuid.NewV4().String(),
+		ProviderType: providerType,
+		User:         *user,
+		UserID:       account.NullUUID{UUID: user.ID, Valid: true},
+	}
+	require.Nil(s.T(), s.Application.Identities().Create(s.Ctx, &identity))
+	return identity
+}
+
+func (s *TestMergeSuite) createToken(identityID uuid.UUID, providerID uuid.UUID) provider.ExternalToken {
+	token := provider.ExternalToken{
+		ProviderID: providerID,
+		Scope:      "user:full",
+		IdentityID: identityID,
+		Token:      uuid.NewV4().String(),
+		Username:   "TestMerge",
+	}
+	require.Nil(s.T(), s.Application.ExternalTokens().Create(s.Ctx, &token))
+	return token
+}
+
+func (s *TestMergeSuite) TestMergeOK() {
+	// given two users whose emails differ only by the case
+	email := "TestMerge" + uuid.NewV4().String() + "@example.com"
+	source := s.createIdentity(email, account.KeycloakIDP, nil)
+	target := s.createIdentity(strings.ToLower(email), account.KeycloakIDP, nil)
+	github := s.createIdentity("", "github", &source.User)
+	linkedProvider := uuid.NewV4()
+	movedToken := s.createToken(source.ID, uuid.NewV4())
+	droppedToken := s.createToken(source.ID, linkedProvider)
+	keptToken := s.createToken(target.ID, linkedProvider)
+	spaceResource, err := s.Application.SpaceResources().Create(s.Ctx, &space.Resource{
+		ResourceID: uuid.NewV4().String(),
+		SpaceID:    uuid.NewV4(),
+		OwnerID:    source.ID,
+	})
+	require.Nil(s.T(), err)
+	svc, ctrl := s.AdminController()
+
+	// when
+	rw, result := test.CreateMergeCreated(s.T(), svc.Context, svc, ctrl, &app.CreateMergePayload{
+		SourceIdentityID: source.ID,
+		TargetIdentityID: target.ID,
+	})
+
+	// then
+	assert.NotEmpty(s.T(), rw.Header().Get("Location"))
+	assert.Equal(s.T(), source.ID.String(), result.Data.Attributes.SourceIdentityID)
+	assert.Equal(s.T(), target.User.ID.String(), result.Data.Attributes.TargetUserID)
+	require.Len(s.T(), s.events, 1)
+	assert.Equal(s.T(), result.Data.ID, s.events[0].ID.String())
+
+	tokens, err := s.Application.ExternalTokens().Query(provider.ExternalTokenFilterByIdentityID(target.ID))
+	require.Nil(s.T(), err)
+	var tokenIDs []uuid.UUID
+	for _, token := range tokens {
+		tokenIDs = append(tokenIDs, token.ID)
+	}
+	assert.Len(s.T(), tokenIDs, 2)
+	assert.Contains(s.T(), tokenIDs, movedToken.ID)
+	assert.Contains(s.T(), tokenIDs, keptToken.ID)
+	assert.NotContains(s.T(), tokenIDs, droppedToken.ID)
+
+	loadedResource, err := s.Application.SpaceResources().Load(s.Ctx, spaceResource.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), target.ID, loadedResource.OwnerID)
+
+	identities, err := s.Application.Identities().Query(account.IdentityFilterByID(github.ID))
+	require.Nil(s.T(), err)
+	require.Len(s.T(), identities, 1)
+	assert.Equal(s.T(), target.User.ID, identities[0].UserID.UUID)
+
+	_, err = s.Application.Identities().Load(s.Ctx, source.ID)
+	assert.NotNil(s.T(), err)
+	_, err = s.Application.Users().Load(s.Ctx, source.User.ID)
+	assert.NotNil(s.T(), err)
+
+	test.ShowMergeOK(s.T(), svc.Context, svc, ctrl, s.events[0].ID)
+}
+
+func (s *TestMergeSuite) TestMergeFails() {
+	source := s.createIdentity(uuid.NewV4().String()+"@example.com", account.KeycloakIDP, nil)
+	target := s.createIdentity(uuid.NewV4().String()+"@example.com", account.KeycloakIDP, nil)
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredController(target)
+		test.CreateMergeForbidden(t, svc.Context, svc, ctrl, &app.CreateMergePayload{SourceIdentityID: source.ID, TargetIdentityID: target.ID})
+		test.ShowMergeForbidden(t, svc.Context, svc, ctrl, uuid.NewV4())
+	})
+
+	s.T().Run("same identity", func(t *testing.T) {
+		svc, ctrl := s.AdminController()
+		test.CreateMergeBadRequest(t, svc.Context, svc, ctrl, &app.CreateMergePayload{SourceIdentityID: source.ID, TargetIdentityID: source.ID})
+	})
+
+	s.T().Run("unknown identity", func(t *testing.T) {
+		svc, ctrl := s.AdminController()
+		test.CreateMergeNotFound(t, svc.Context, svc, ctrl, &app.CreateMergePayload{SourceIdentityID: uuid.NewV4(), TargetIdentityID: target.ID})
+	})
+
+	s.T().Run("not a keycloak identity", func(t *testing.T) {
+		github := s.createIdentity("", "github", &source.User)
+		svc, ctrl := s.AdminController()
+		test.CreateMergeBadRequest(t, svc.Context, svc, ctrl, &app.CreateMergePayload{SourceIdentityID: github.ID, TargetIdentityID: target.ID})
+	})
+
+	assert.Empty(s.T(), s.events)
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"regexp"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+
+	"github.com/goadesign/goa"
+)
+
+// OauthClientController implements the oauth_client resource.
+type OauthClientController struct {
+	*goa.Controller
+	db     application.DB
+	config AdminConfiguration
+}
+
+// NewOauthClientController creates an oauth_client controller.
+func NewOauthClientController(service *goa.Service, db application.DB, config AdminConfiguration) *OauthClientController {
+	return &OauthClientController{Controller: service.NewController("OauthClientController"), db: db, config: config}
+}
+
+// List runs the list action.
+func (c *OauthClientController) List(ctx *app.ListOauthClientContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var clients []auth.OauthClient
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		clients, err = appl.OauthClients().List(ctx)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.OauthClientData, len(clients))
+	for i := range clients {
+		data[i] = convertOauthClient(ctx.RequestData, &clients[i])
+	}
+	return ctx.OK(&app.OauthClientList{Data: data})
+}
+
+// Show runs the show action.
+func (c *OauthClientController) Show(ctx *app.ShowOauthClientContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var client *auth.OauthClient
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		client, err = appl.OauthClients().Load(ctx, ctx.ID)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.OauthClientSingle{Data: convertOauthClient(ctx.RequestData, client)})
+}
+
+// Create runs the create action.
+func (c *OauthClientController) Create(ctx *app.CreateOauthClientContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if ctx.Payload == nil || ctx.Payload.Data == nil || ctx.Payload.Data.Attributes == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("data.attributes", nil).Expected("not empty client attributes"))
+	}
+	client := &auth.OauthClient{}
+	if err := updateOauthClient(client, ctx.Payload.Data.Attributes); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		return appl.OauthClients().Create(ctx, client)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"oauth_client_id": client.ID,
+		"client_id":       client.ClientID,
+	}, "API client registered")
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.OauthClientHref(client.ID)))
+	return ctx.Created(&app.OauthClientSingle{Data: convertOauthClient(ctx.RequestData, client)})
+}
+
+// Update runs the update action.
+func (c *OauthClientController) Update(ctx *app.UpdateOauthClientContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if ctx.Payload == nil || ctx.Payload.Data == nil || ctx.Payload.Data.Attributes == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("data.attributes", nil).Expected("not empty client attributes"))
+	}
+	var client *auth.OauthClient
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		client, err = appl.OauthClients().Load(ctx, ctx.ID)
+		if err != nil {
+			return err
+		}
+		if err := updateOauthClient(client, ctx.Payload.Data.Attributes); err != nil {
+			return err
+		}
+		return appl.OauthClients().Save(ctx, client)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.OauthClientSingle{Data: convertOauthClient(ctx.RequestData, client)})
+}
+
+// Delete runs the delete action.
+func (c *OauthClientController) Delete(ctx *app.DeleteOauthClientContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		return appl.OauthClients().Delete(ctx, ctx.ID)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"oauth_client_id": ctx.ID,
+	}, "API client deleted")
+	return ctx.OK([]byte{})
+}
+
+// updateOauthClient sets the client settings from the given attributes
+func updateOauthClient(client *auth.OauthClient, attributes *app.OauthClientDataAttributes) error {
+	if attributes.ClientID == "" {
+		return errors.NewBadParameterError("client_id", attributes.ClientID).Expected("not empty client_id")
+	}
+	for _, pattern := range attributes.RedirectURIPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errors.NewBadParameterError("redirect_uri_patterns", pattern).Expected("valid regular expression")
+		}
+	}
+	client.ClientID = attributes.ClientID
+	if attributes.Name != nil {
+		client.Name = *attributes.Name
+	}
+	client.RedirectURIs = attributes.RedirectUris
+	client.RedirectURIPatterns = attributes.RedirectURIPatterns
+	client.GrantTypes = attributes.GrantTypes
+	client.AccessTokenLifetime = attributes.AccessTokenLifetime
+	client.RefreshTokenLifetime = attributes.RefreshTokenLifetime
+	client.Claims = attributes.Claims
+	return nil
+}
+
+func convertOauthClient(request *goa.RequestData, client *auth.OauthClient) *app.OauthClientData {
+	id := client.ID.String()
+	selfURL := rest.AbsoluteURL(request, app.OauthClientHref(id))
+	return &app.OauthClientData{
+		ID:   &id,
+		Type: "oauth_clients",
+		Attributes: &app.OauthClientDataAttributes{
+			ClientID:             client.ClientID,
+			Name:                 &client.Name,
+			RedirectUris:         client.RedirectURIs,
+			RedirectURIPatterns:  client.RedirectURIPatterns,
+			GrantTypes:           client.GrantTypes,
+			AccessTokenLifetime:  client.AccessTokenLifetime,
+			RefreshTokenLifetime: client.RefreshTokenLifetime,
+			Claims:               client.Claims,
+			CreatedAt:            &client.CreatedAt,
+			UpdatedAt:            &client.UpdatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self: &selfURL,
+		},
+	}
+}
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// OrganizationController implements the organization resource.
+type OrganizationController struct {
+	*goa.Controller
+	db            application.DB
+	config        AdminConfiguration
+	policyManager auth.AuthzPolicyManager
+}
+
+// NewOrganizationController creates an organization controller.
+func NewOrganizationController(service *goa.Service, db application.DB, config AdminConfiguration, policyManager auth.AuthzPolicyManager) *OrganizationController {
+	return &OrganizationController{Controller: service.NewController("OrganizationController"), db: db, config: config, policyManager: policyManager}
+}
+
+// List runs the list action.
+func (c *OrganizationController) List(ctx *app.ListOrganizationContext) error {
+	var organizations []account.Organization
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		organizations, err = appl.Organizations().List(ctx)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.OrganizationData, len(organizations))
+	for i := range organizations {
+		data[i] = convertOrganization(ctx.RequestData, &organizations[i])
+	}
+	return ctx.OK(&app.OrganizationList{Data: data})
+}
+
+// Show runs the show action.
+func (c *OrganizationController) Show(ctx *app.ShowOrganizationContext) error {
+	var organization *account.Organization
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		organization, err = appl.Organizations().Load(ctx, ctx.ID)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.OrganizationSingle{Data: convertOrganization(ctx.RequestData, organization)})
+}
+
+// Create runs the create action.
+func (c *OrganizationController) Create(ctx *app.CreateOrganizationContext) error {
+	if ctx.Payload == nil || ctx.Payload.Data == nil || ctx.Payload.Data.Attributes == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("data.attributes", nil).Expected("not empty organization attributes"))
+	}
+	attributes := ctx.Payload.Data.Attributes
+	name := strings.TrimSpace(attributes.Name)
+	if name == "" {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("name", attributes.Name).Expected("not empty name"))
+	}
+	ownerID, err := c.organizationOwner(ctx, attributes.OwnerID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	organization := &account.Organization{
+		Name:    name,
+		OwnerID: ownerID,
+	}
+	if attributes.Description != nil {
+		organization.Description = *attributes.Description
+	}
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.Identities().CheckExists(ctx, ownerID.String()); err != nil {
+			return errors.NewBadParameterError("owner_id", ownerID.String()).Expected("existing identity")
+		}
+		return appl.Organizations().Create(ctx, organization)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.OrganizationHref(organization.ID)))
+	return ctx.Created(&app.OrganizationSingle{Data: convertOrganization(ctx.RequestData, organization)})
+}
+
+// Delete runs the delete action.
+func (c *OrganizationController) Delete(ctx *app.DeleteOrganizationContext) error {
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		organization, err := appl.Organizations().Load(ctx, ctx.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkOrganizationOwner(ctx, c.config, organization); err != nil {
+			return err
+		}
+		teams, err := appl.Teams().ListByOrganization(ctx, organization.ID)
+		if err != nil {
+			return err
+		}
+		for _, team := range teams {
+			roles, memberIDs, err := listTeamRolesAndMemberIDs(ctx, appl, team.ID)
+			if err != nil {
+				return err
+			}
+			if err := appl.Teams().Delete(ctx, team.ID); err != nil {
+				return err
+			}
+			if err := removeFromTeamSpacePolicies(ctx, appl, ctx.RequestData, c.policyManager, roles, memberIDs); err != nil {
+				return err
+			}
+		}
+		return appl.Organizations().Delete(ctx, organization.ID)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"organization_id": ctx.ID,
+	}, "Organization deleted")
+	return ctx.OK([]byte{})
+}
+
+// ListTeams runs the list-teams action.
+func (c *OrganizationController) ListTeams(ctx *app.ListTeamsOrganizationContext) error {
+	var teams []account.Team
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if _, err := appl.Organizations().Load(ctx, ctx.ID); err != nil {
+			return err
+		}
+		var err error
+		teams, err = appl.Teams().ListByOrganization(ctx, ctx.ID)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.TeamData, len(teams))
+	for i := range teams {
+		data[i] = convertTeam(ctx.RequestData, &teams[i])
+	}
+	return ctx.OK(&app.TeamList{Data: data})
+}
+
+// CreateTeam runs the create-team action.
+func (c *OrganizationController) CreateTeam(ctx *app.CreateTeamOrganizationContext) error {
+	if ctx.Payload == nil || ctx.Payload.Data == nil || ctx.Payload.Data.Attributes == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("data.attributes", nil).Expected("not empty team attributes"))
+	}
+	attributes := ctx.Payload.Data.Attributes
+	name := strings.TrimSpace(attributes.Name)
+	if name == "" {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("name", attributes.Name).Expected("not empty name"))
+	}
+	team := &account.Team{
+		OrganizationID: ctx.ID,
+		Name:           name,
+	}
+	if attributes.Description != nil {
+		team.Description = *attributes.Description
+	}
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		organization, err := appl.Organizations().Load(ctx, ctx.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkOrganizationOwner(ctx, c.config, organization); err != nil {
+			return err
+		}
+		return appl.Teams().Create(ctx, team)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.TeamHref(team.ID)))
+	return ctx.Created(&app.TeamSingle{Data: convertTeam(ctx.RequestData, team)})
+}
+
+// organizationOwner returns the owner of a new organization: the current user
+// or, for the admin service accounts, the identity given in the payload
+func (c *OrganizationController) organizationOwner(ctx context.Context, ownerID *string) (uuid.UUID, error) {
+	if token.IsSpecificServiceAccount(ctx, c.config.GetAdminServiceAccounts()) {
+		if ownerID == nil {
+			return uuid.Nil, errors.NewBadParameterError("owner_id", nil).Expected("ID of the identity owning the organization")
+		}
+		id, err := uuid.FromString(*ownerID)
+		if err != nil {
+			return uuid.Nil, errors.NewBadParameterError("owner_id", *ownerID).Expected("identity ID")
+		}
+		return id, nil
+	}
+	currentIdentity, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return uuid.Nil, errors.NewUnauthorizedError(err.Error())
+	}
+	if ownerID != nil && *ownerID != currentIdentity.String() {
+		return uuid.Nil, errors.NewForbiddenError("only admin service accounts can create an organization for another identity")
+	}
+	return *currentIdentity, nil
+}
+
+// checkOrganizationOwner returns ForbiddenError if the request is done neither by the owner of the organization
+// nor by one of the admin service accounts
+func checkOrganizationOwner(ctx context.Context, config AdminConfiguration, organization *account.Organization) error {
+	if token.IsSpecificServiceAccount(ctx, config.GetAdminServiceAccounts()) {
+		return nil
+	}
+	currentIdentity, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return errors.NewUnauthorizedError(err.Error())
+	}
+	if *currentIdentity != organization.OwnerID {
+		log.Error(ctx, map[string]interface{}{
+			"organization_id": organization.ID,
+			"identity_id":     currentIdentity,
+		}, "the user is not the owner of the organization")
+		return errors.NewForbiddenError("only the owner of the organization can manage its teams")
+	}
+	return nil
+}
+
+func convertOrganization(request *goa.RequestData, organization *account.Organization) *app.OrganizationData {
+	id := organization.ID.String()
+	ownerID := organization.OwnerID.String()
+	selfURL := rest.AbsoluteURL(request, app.OrganizationHref(id))
+	relatedURL := rest.AbsoluteURL(request, app.OrganizationHref(id)+"/teams")
+	return &app.OrganizationData{
+		ID:   &id,
+		Type: "organizations",
+		Attributes: &app.OrganizationDataAttributes{
+			Name:        organization.Name,
+			Description: &organization.Description,
+			OwnerID:     &ownerID,
+			CreatedAt:   &organization.CreatedAt,
+			UpdatedAt:   &organization.UpdatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self:    &selfURL,
+			Related: &relatedURL,
+		},
+	}
+}
@@ -0,0 +1,295 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/authorization/resource"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/space"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestOrganizationSuite struct {
+	gormtestsupport.DBTestSuite
+	policyManager *organizationPolicyManager
+}
+
+// organizationPolicyManager keeps the collaborators policies of the spaces in memory
+type organizationPolicyManager struct {
+	policies map[string]*auth.KeycloakPolicy
+}
+
+func (m *organizationPolicyManager) GetPolicy(ctx context.Context, request *goa.RequestData, policyID string) (*auth.KeycloakPolicy, *string, error) {
+	policy, found := m.policies[policyID]
+	if !found {
+		return nil, nil, errors.NewNotFoundError("policy", policyID)
+	}
+	pat := ""
+	copied := *policy
+	return &copied, &pat, nil
+}
+
+func (m *organizationPolicyManager) UpdatePolicy(ctx context.Context, request *goa.RequestData, policy auth.KeycloakPolicy, pat string) error {
+	m.policies[*policy.ID] = &policy
+	return nil
+}
+
+func (m *organizationPolicyManager) AddUserToPolicy(p *auth.KeycloakPolicy, userID string) bool {
+	return p.AddUserToPolicy(userID)
+}
+
+func (m *organizationPolicyManager) RemoveUserFromPolicy(p *auth.KeycloakPolicy, userID string) bool {
+	return p.RemoveUserFromPolicy(userID)
+}
+
+func TestRunOrganizationSuite(t *testing.T) {
+	suite.Run(t, &TestOrganizationSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *TestOrganizationSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.policyManager = &organizationPolicyManager{policies: map[string]*auth.KeycloakPolicy{}}
+}
+
+func (s *TestOrganizationSuite) SecuredControllers(identity account.Identity) (*goa.Service, *OrganizationController, *TeamController) {
+	svc := testsupport.ServiceAsUser("Organization-Service", identity)
+	return svc, NewOrganizationController(svc, s.Application, s.Configuration, s.policyManager), NewTeamController(svc, s.Application, s.Configuration, s.policyManager)
+}
+
+func (s *TestOrganizationSuite) AdminControllers() (*goa.Service, *OrganizationController, *TeamController) {
+	svc := testsupport.ServiceAsAdmin("Organization-Admin-Service")
+	return svc, NewOrganizationController(svc, s.Application, s.Configuration, s.policyManager), NewTeamController(svc, s.Application, s.Configuration, s.policyManager)
+}
+
+func (s *TestOrganizationSuite) createIdentity() account.Identity {
+	identity, err := testsupport.CreateTestIdentityAndUser(s.DB, "TestOrganization", account.User{})
+	require.Nil(s.T(), err)
+	return identity
+}
+
+func (s *TestOrganizationSuite) createOrganization(owner account.Identity) (*app.OrganizationData, *app.TeamData) {
+	svc, orgCtrl, _ := s.SecuredControllers(owner)
+	_, organization := test.CreateOrganizationCreated(s.T(), svc.Context, svc, orgCtrl, &app.CreateOrganizationPayload{
+		Data: &app.OrganizationData{
+			Type:       "organizations",
+			Attributes: &app.OrganizationDataAttributes{Name: "TestOrganization" + uuid.NewV4().String()},
+		},
+	})
+	organizationID, err := uuid.FromString(*organization.Data.ID)
+	require.Nil(s.T(), err)
+	_, team := test.CreateTeamOrganizationCreated(s.T(), svc.Context, svc, orgCtrl, organizationID, &app.CreateTeamOrganizationPayload{
+		Data: &app.TeamData{
+			Type:       "teams",
+			Attributes: &app.TeamDataAttributes{Name: "developers"},
+		},
+	})
+	return organization.Data, team.Data
+}
+
+func (s *TestOrganizationSuite) TestManageTeamsOK() {
+	// given
+	owner := s.createIdentity()
+	member := s.createIdentity()
+	organization, team := s.createOrganization(owner)
+	assert.Equal(s.T(), owner.ID.String(), *organization.Attributes.OwnerID)
+	teamID, err := uuid.FromString(*team.ID)
+	require.Nil(s.T(), err)
+	svc, orgCtrl, teamCtrl := s.SecuredControllers(owner)
+
+	// when
+	test.AddMemberTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, member.ID)
+
+	// then
+	_, members := test.ListMembersTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID)
+	require.Len(s.T(), members.Data, 1)
+	assert.Equal(s.T(), member.ID.String(), *members.Data[0].ID)
+	organizationID, err := uuid.FromString(*organization.ID)
+	require.Nil(s.T(), err)
+	_, teams := test.ListTeamsOrganizationOK(s.T(), svc.Context, svc, orgCtrl, organizationID)
+	require.Len(s.T(), teams.Data, 1)
+	assert.Equal(s.T(), "developers", teams.Data[0].Attributes.Name)
+
+	test.RemoveMemberTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, member.ID)
+	_, members = test.ListMembersTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID)
+	assert.Empty(s.T(), members.Data)
+	test.RemoveMemberTeamNotFound(s.T(), svc.Context, svc, teamCtrl, teamID, member.ID)
+}
+
+func (s *TestOrganizationSuite) TestManageTeamsForbidden() {
+	// given
+	owner := s.createIdentity()
+	other := s.createIdentity()
+	organization, team := s.createOrganization(owner)
+	organizationID, err := uuid.FromString(*organization.ID)
+	require.Nil(s.T(), err)
+	teamID, err := uuid.FromString(*team.ID)
+	require.Nil(s.T(), err)
+	svc, orgCtrl, teamCtrl := s.SecuredControllers(other)
+
+	// then
+	test.CreateTeamOrganizationForbidden(s.T(), svc.Context, svc, orgCtrl, organizationID, &app.CreateTeamOrganizationPayload{
+		Data: &app.TeamData{
+			Type:       "teams",
+			Attributes: &app.TeamDataAttributes{Name: "testers"},
+		},
+	})
+	test.AddMemberTeamForbidden(s.T(), svc.Context, svc, teamCtrl, teamID, other.ID)
+	test.ShowTeamForbidden(s.T(), svc.Context, svc, teamCtrl, teamID)
+	test.ListMembersTeamForbidden(s.T(), svc.Context, svc, teamCtrl, teamID)
+	test.ListRolesTeamForbidden(s.T(), svc.Context, svc, teamCtrl, teamID)
+	test.DeleteTeamForbidden(s.T(), svc.Context, svc, teamCtrl, teamID)
+	test.DeleteOrganizationForbidden(s.T(), svc.Context, svc, orgCtrl, organizationID)
+	test.AddRoleTeamForbidden(s.T(), svc.Context, svc, teamCtrl, teamID, &app.AddRoleTeamPayload{
+		Data: &app.TeamRoleData{
+			Type:       "team_roles",
+			Attributes: &app.TeamRoleDataAttributes{ResourceID: uuid.NewV4().String(), Role: "contributor"},
+		},
+	})
+}
+
+func (s *TestOrganizationSuite) TestTeamRolesOK() {
+	// given a space resource with a contributor role
+	owner := s.createIdentity()
+	member := s.createIdentity()
+	_, team := s.createOrganization(owner)
+	teamID, err := uuid.FromString(*team.ID)
+	require.Nil(s.T(), err)
+	resourceType := resource.ResourceType{ResourceTypeID: uuid.NewV4(), Name: "TestOrganization" + uuid.NewV4().String()}
+	require.Nil(s.T(), resource.NewResourceTypeRepository(s.DB).Create(s.Ctx, &resourceType))
+	contributor := role.Role{RoleID: uuid.NewV4(), ResourceTypeID: resourceType.ResourceTypeID, Name: "contributor"}
+	require.Nil(s.T(), role.NewRoleRepository(s.DB).Create(s.Ctx, &contributor))
+	spaceID := uuid.NewV4()
+	err = s.DB.Exec("INSERT INTO resource (resource_id, owner_id, resource_type_id, name) VALUES (?, ?, ?, ?)",
+		spaceID, owner.ID, resourceType.ResourceTypeID, "TestOrganization").Error
+	require.Nil(s.T(), err)
+	otherSpaceID := uuid.NewV4()
+	err = s.DB.Exec("INSERT INTO resource (resource_id, owner_id, resource_type_id, name) VALUES (?, ?, ?, ?)",
+		otherSpaceID, member.ID, resourceType.ResourceTypeID, "TestOrganization").Error
+	require.Nil(s.T(), err)
+	svc, _, teamCtrl := s.SecuredControllers(owner)
+
+	// when
+	_, created := test.AddRoleTeamCreated(s.T(), svc.Context, svc, teamCtrl, teamID, &app.AddRoleTeamPayload{
+		Data: &app.TeamRoleData{
+			Type:       "team_roles",
+			Attributes: &app.TeamRoleDataAttributes{ResourceID: spaceID.String(), Role: "contributor"},
+		},
+	})
+	test.AddMemberTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, member.ID)
+
+	// then the member is given the role of the team
+	assert.Equal(s.T(), "contributor", created.Data.Attributes.Role)
+	roles, err := s.Application.TeamRoles().ListByMember(s.Ctx, member.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), roles, 1)
+	assert.Equal(s.T(), spaceID.String(), roles[0].ResourceID)
+	_, list := test.ListRolesTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID)
+	require.Len(s.T(), list.Data, 1)
+
+	test.AddRoleTeamNotFound(s.T(), svc.Context, svc, teamCtrl, teamID, &app.AddRoleTeamPayload{
+		Data: &app.TeamRoleData{
+			Type:       "team_roles",
+			Attributes: &app.TeamRoleDataAttributes{ResourceID: spaceID.String(), Role: "unknown"},
+		},
+	})
+	// the owner of the team can't give a role on a resource owned by someone else
+	test.AddRoleTeamForbidden(s.T(), svc.Context, svc, teamCtrl, teamID, &app.AddRoleTeamPayload{
+		Data: &app.TeamRoleData{
+			Type:       "team_roles",
+			Attributes: &app.TeamRoleDataAttributes{ResourceID: otherSpaceID.String(), Role: "contributor"},
+		},
+	})
+
+	roleID, err := uuid.FromString(*created.Data.ID)
+	require.Nil(s.T(), err)
+	test.RemoveRoleTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, roleID)
+	roles, err = s.Application.TeamRoles().ListByMember(s.Ctx, member.ID)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), roles)
+}
+
+func (s *TestOrganizationSuite) TestShowTeamAsMemberOK() {
+	// given
+	owner := s.createIdentity()
+	member := s.createIdentity()
+	_, team := s.createOrganization(owner)
+	teamID, err := uuid.FromString(*team.ID)
+	require.Nil(s.T(), err)
+	svc, _, teamCtrl := s.SecuredControllers(owner)
+	test.AddMemberTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, member.ID)
+
+	// then the members of the team can see it
+	svc, _, teamCtrl = s.SecuredControllers(member)
+	_, shown := test.ShowTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID)
+	assert.Equal(s.T(), team.ID, shown.Data.ID)
+	_, members := test.ListMembersTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID)
+	require.Len(s.T(), members.Data, 1)
+	test.ListRolesTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID)
+}
+
+func (s *TestOrganizationSuite) TestTeamMembersAreSpaceCollaborators() {
+	// given a space with its collaborators policy
+	owner := s.createIdentity()
+	member := s.createIdentity()
+	newMember := s.createIdentity()
+	_, team := s.createOrganization(owner)
+	teamID, err := uuid.FromString(*team.ID)
+	require.Nil(s.T(), err)
+	resourceType := resource.ResourceType{ResourceTypeID: uuid.NewV4(), Name: "TestOrganization" + uuid.NewV4().String()}
+	require.Nil(s.T(), resource.NewResourceTypeRepository(s.DB).Create(s.Ctx, &resourceType))
+	contributor := role.Role{RoleID: uuid.NewV4(), ResourceTypeID: resourceType.ResourceTypeID, Name: "contributor"}
+	require.Nil(s.T(), role.NewRoleRepository(s.DB).Create(s.Ctx, &contributor))
+	spaceID := uuid.NewV4()
+	err = s.DB.Exec("INSERT INTO resource (resource_id, owner_id, resource_type_id, name) VALUES (?, ?, ?, ?)",
+		spaceID, owner.ID, resourceType.ResourceTypeID, "TestOrganization").Error
+	require.Nil(s.T(), err)
+	policyID := uuid.NewV4().String()
+	s.policyManager.policies[policyID] = &auth.KeycloakPolicy{ID: &policyID, Config: auth.PolicyConfigData{UserIDs: fmt.Sprintf(`["%s"]`, owner.ID)}}
+	_, err = s.Application.SpaceResources().Create(s.Ctx, &space.Resource{SpaceID: spaceID, OwnerID: owner.ID, PolicyID: policyID, ResourceID: uuid.NewV4().String()})
+	require.Nil(s.T(), err)
+	svc, _, teamCtrl := s.SecuredControllers(owner)
+	test.AddMemberTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, member.ID)
+
+	// when the team is given a role on the space
+	_, created := test.AddRoleTeamCreated(s.T(), svc.Context, svc, teamCtrl, teamID, &app.AddRoleTeamPayload{
+		Data: &app.TeamRoleData{
+			Type:       "team_roles",
+			Attributes: &app.TeamRoleDataAttributes{ResourceID: spaceID.String(), Role: "contributor"},
+		},
+	})
+	// then the members of the team are collaborators of the space
+	assert.Contains(s.T(), s.policyManager.policies[policyID].Config.UserIDs, member.ID.String())
+
+	// when a member is added to the team
+	test.AddMemberTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, newMember.ID)
+	// then the new member is a collaborator of the space
+	assert.Contains(s.T(), s.policyManager.policies[policyID].Config.UserIDs, newMember.ID.String())
+
+	// when a member is removed from the team
+	test.RemoveMemberTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, newMember.ID)
+	// then the member is no longer a collaborator of the space
+	assert.NotContains(s.T(), s.policyManager.policies[policyID].Config.UserIDs, newMember.ID.String())
+	assert.Contains(s.T(), s.policyManager.policies[policyID].Config.UserIDs, member.ID.String())
+
+	// when the role is taken back from the team
+	roleID, err := uuid.FromString(*created.Data.ID)
+	require.Nil(s.T(), err)
+	test.RemoveRoleTeamOK(s.T(), svc.Context, svc, teamCtrl, teamID, roleID)
+	// then only the owner is left
+	assert.Equal(s.T(), fmt.Sprintf(`["%s"]`, owner.ID), s.policyManager.policies[policyID].Config.UserIDs)
+}
@@ -0,0 +1,370 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/login"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	usernameInvalid  = "invalid"
+	usernameTaken    = "taken"
+	usernameReserved = "reserved"
+)
+
+// RegistrationController implements the registration resource.
+type RegistrationController struct {
+	*goa.Controller
+	db                 application.DB
+	config             RegistrationConfiguration
+	userProfileService login.UserProfileService
+}
+
+// RegistrationConfiguration represents the configuration of the registration
+type RegistrationConfiguration interface {
+	account.UserPolicyConfiguration
+	login.ClusterConfiguration
+	GetUsernameReservationTTL() time.Duration
+	GetKeycloakAccountEndpoint(*goa.RequestData) (string, error)
+}
+
+// usernameAvailability represents the availability of a username for a user
+type usernameAvailability struct {
+	available     bool
+	reason        string
+	reservedUntil *time.Time
+	// validationErr is the policy violation of an invalid username
+	validationErr error
+}
+
+// NewRegistrationController creates a registration controller.
+func NewRegistrationController(service *goa.Service, db application.DB, config RegistrationConfiguration, userProfileService login.UserProfileService) *RegistrationController {
+	return &RegistrationController{
+		Controller:         service.NewController("RegistrationController"),
+		db:                 db,
+		config:             config,
+		userProfileService: userProfileService,
+	}
+}
+
+// Username runs the username action.
+func (c *RegistrationController) Username(ctx *app.UsernameRegistrationContext) error {
+	identityID, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	policy, err := account.NewUserPolicy(c.config)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	var availability *usernameAvailability
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		identity, err := loadRegistrationIdentity(ctx, appl, *identityID)
+		if err != nil {
+			return err
+		}
+		availability, err = checkUsernameAvailability(ctx, appl, policy, ctx.Username, *identity)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertUsernameAvailability(ctx.Username, *availability))
+}
+
+// Reserve runs the reserve action.
+func (c *RegistrationController) Reserve(ctx *app.ReserveRegistrationContext) error {
+	identityID, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	policy, err := account.NewUserPolicy(c.config)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	var availability *usernameAvailability
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		identity, err := loadRegistrationIdentity(ctx, appl, *identityID)
+		if err != nil {
+			return err
+		}
+		if identity.RegistrationCompleted {
+			return errors.NewForbiddenError(fmt.Sprintf("registration already completed for identity id %s", identity.ID))
+		}
+		availability, err = checkUsernameAvailability(ctx, appl, policy, ctx.Username, *identity)
+		if err != nil {
+			return err
+		}
+		if err := usernameAvailabilityError(ctx.Username, *availability); err != nil {
+			return err
+		}
+		reservation, err := appl.UsernameReservations().Reserve(ctx, ctx.Username, identity.ID, time.Now().Add(c.config.GetUsernameReservationTTL()))
+		if err != nil {
+			return err
+		}
+		availability.reservedUntil = &reservation.ExpiresAt
+		return nil
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertUsernameAvailability(ctx.Username, *availability))
+}
+
+// Complete runs the complete action.
+// Only users approved in Keycloak can complete the registration, as for the login.
+// The user and the identity are created if the user has never logged in to auth.
+// The user is updated in Keycloak in the same transaction so nothing is saved if Keycloak rejects the update.
+func (c *RegistrationController) Complete(ctx *app.CompleteRegistrationContext) error {
+	identityID, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	policy, err := account.NewUserPolicy(c.config)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	attributes := ctx.Payload.Data.Attributes
+	if !attributes.TermsAccepted {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("terms_accepted", attributes.TermsAccepted).Expected("true"))
+	}
+	if !isTokenApproved(ctx) {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(fmt.Sprintf("user '%s' is not approved", identityID)))
+	}
+	tokenString := goajwt.ContextJWT(ctx).Raw
+	accountAPIEndpoint, err := c.config.GetKeycloakAccountEndpoint(ctx.RequestData)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+
+	var identity *account.Identity
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		identity, err = loadRegistrationIdentity(ctx, appl, *identityID)
+		if err != nil {
+			return err
+		}
+		if identity.RegistrationCompleted {
+			return errors.NewForbiddenError(fmt.Sprintf("registration already completed for identity id %s", identity.ID))
+		}
+		availability, err := checkUsernameAvailability(ctx, appl, policy, attributes.Username, *identity)
+		if err != nil {
+			return err
+		}
+		if err := usernameAvailabilityError(attributes.Username, *availability); err != nil {
+			return err
+		}
+
+		newIdentity := identity.UserID.UUID == uuid.Nil
+		user := &identity.User
+		if newIdentity {
+			if err := fillUserFromToken(ctx, appl, policy, user); err != nil {
+				return err
+			}
+		}
+		if attributes.FullName != nil {
+			user.FullName = standardizeSpaces(*attributes.FullName)
+		}
+		if attributes.Company != nil {
+			user.Company = *attributes.Company
+		}
+		if user.Cluster == "" {
+			region := ""
+			if attributes.Region != nil {
+				region = *attributes.Region
+			}
+			user.Cluster, err = login.NewClusterService(c.db, c.config).Allocate(ctx, appl, region)
+			if err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		user.TermsAcceptedAt = &now
+		identity.Username = attributes.Username
+		identity.RegistrationCompleted = true
+
+		if newIdentity {
+			if err := appl.Users().Create(ctx, user); err != nil {
+				return err
+			}
+			identity.ProviderType = account.KeycloakIDP
+			identity.UserID = account.NullUUID{UUID: user.ID, Valid: true}
+			if err := appl.Identities().Create(ctx, identity); err != nil {
+				return err
+			}
+		} else {
+			if err := appl.Users().Save(ctx, user); err != nil {
+				return err
+			}
+			if err := appl.Identities().Save(ctx, identity); err != nil {
+				return err
+			}
+		}
+		if err := appl.UsernameReservations().Release(ctx, identity.ID); err != nil {
+			return err
+		}
+		// Keycloak is updated last so the transaction is rolled back if the update fails
+		return c.updateKeycloakUser(ctx, *identity, tokenString, accountAPIEndpoint)
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID.String(),
+			"username":    attributes.Username,
+			"err":         err,
+		}, "failed to complete the registration")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identity.ID,
+		"username":    identity.Username,
+		"cluster":     identity.User.Cluster,
+	}, "registration completed")
+	return ctx.OK(ConvertToAppUser(ctx.RequestData, &identity.User, identity))
+}
+
+// updateKeycloakUser updates the username, the name and the company of the user in Keycloak
+func (c *RegistrationController) updateKeycloakUser(ctx context.Context, identity account.Identity, tokenString string, accountAPIEndpoint string) error {
+	keycloakUserProfile := &login.KeycloakUserProfile{
+		Username:   &identity.Username,
+		Attributes: &login.KeycloakUserProfileAttributes{},
+	}
+	if identity.User.FullName != "" {
+		nameComponents := strings.Split(identity.User.FullName, " ")
+		firstName := nameComponents[0]
+		lastName := strings.Join(nameComponents[1:], " ")
+		keycloakUserProfile.FirstName = &firstName
+		keycloakUserProfile.LastName = &lastName
+	}
+	if identity.User.Company != "" {
+		(*keycloakUserProfile.Attributes)[login.CompanyAttributeName] = []string{identity.User.Company}
+	}
+	existingProfile, err := c.userProfileService.Get(ctx, tokenString, accountAPIEndpoint)
+	if err != nil {
+		return err
+	}
+	keycloakUserProfile = mergeKeycloakUserProfileInfo(keycloakUserProfile, existingProfile)
+	return c.userProfileService.Update(ctx, keycloakUserProfile, tokenString, accountAPIEndpoint)
+}
+
+// loadRegistrationIdentity loads the Keycloak identity with its user.
+// Returns a new identity without a user if the user has never logged in to auth.
+func loadRegistrationIdentity(ctx context.Context, appl application.Application, identityID uuid.UUID) (*account.Identity, error) {
+	identities, err := appl.Identities().Query(account.IdentityFilterByID(identityID), account.IdentityWithUser())
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to load identity %s", identityID)
+	}
+	if len(identities) == 0 {
+		return &account.Identity{ID: identityID}, nil
+	}
+	return &identities[0], nil
+}
+
+// checkUsernameAvailability checks if the username complies with the user policy, is not used by another user
+// and not reserved for another user
+func checkUsernameAvailability(ctx context.Context, appl application.Application, policy *account.UserPolicy, username string, identity account.Identity) (*usernameAvailability, error) {
+	if err := policy.ValidateUsername(username); err != nil {
+		return &usernameAvailability{reason: usernameInvalid, validationErr: err}, nil
+	}
+	isUnique, err := isUsernameUnique(appl, policy, username, identity)
+	if err != nil {
+		return nil, err
+	}
+	if !isUnique {
+		return &usernameAvailability{reason: usernameTaken}, nil
+	}
+	reservation, err := appl.UsernameReservations().LoadActive(ctx, username)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return &usernameAvailability{available: true}, nil
+		}
+		return nil, err
+	}
+	if reservation.IdentityID != identity.ID {
+		return &usernameAvailability{reason: usernameReserved}, nil
+	}
+	return &usernameAvailability{available: true, reservedUntil: &reservation.ExpiresAt}, nil
+}
+
+// usernameAvailabilityError returns the error to respond with if the username is not available
+func usernameAvailabilityError(username string, availability usernameAvailability) error {
+	switch availability.reason {
+	case usernameInvalid:
+		return availability.validationErr
+	case usernameTaken:
+		return errors.NewVersionConflictError(fmt.Sprintf("username %s is already in use", username))
+	case usernameReserved:
+		return errors.NewVersionConflictError(fmt.Sprintf("username %s is reserved", username))
+	}
+	return nil
+}
+
+// isTokenApproved returns true if the token has the "approved" claim set by Keycloak
+func isTokenApproved(ctx context.Context) bool {
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	approved, _ := claims["approved"].(bool)
+	return approved
+}
+
+// fillUserFromToken sets the email, the name and the company of a new user from the token claims.
+// The email must comply with the user policy and must not be used by another user.
+func fillUserFromToken(ctx context.Context, appl application.Application, policy *account.UserPolicy, user *account.User) error {
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return errors.NewUnauthorizedError("missing token")
+	}
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		user.Email, _ = claims["email"].(string)
+		user.FullName, _ = claims["name"].(string)
+		user.Company, _ = claims["company"].(string)
+	}
+	if err := policy.ValidateEmail(user.Email); err != nil {
+		return err
+	}
+	isUnique, err := isEmailUnique(appl, policy, user.Email, *user)
+	if err != nil {
+		return err
+	}
+	if !isUnique {
+		return errors.NewValidationError("email", user.Email, account.RuleUnique, fmt.Sprintf("email : %s is already in use", user.Email))
+	}
+	return nil
+}
+
+func convertUsernameAvailability(username string, availability usernameAvailability) *app.UsernameSingle {
+	attributes := &app.UsernameDataAttributes{
+		Available:     availability.available,
+		ReservedUntil: availability.reservedUntil,
+	}
+	if availability.reason != "" {
+		reason := availability.reason
+		attributes.Reason = &reason
+	}
+	return &app.UsernameSingle{
+		Data: &app.UsernameData{
+			Type:       "usernames",
+			ID:         username,
+			Attributes: attributes,
+		},
+	}
+}
@@ -0,0 +1,198 @@
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/resource"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestRegistrationSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunRegistrationSuite(t *testing.T) {
+	resource.Require(t, resource.Database)
+	suite.Run(t, &TestRegistrationSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *TestRegistrationSuite) SecuredController(identity account.Identity) (*goa.Service, *RegistrationController) {
+	svc := testsupport.ServiceAsUser("Registration-Service", identity)
+	goajwt.ContextJWT(svc.Context).Claims.(jwt.MapClaims)["approved"] = true
+	value := "a"
+	profileService := newDummyUserProfileService(createDummyUserProfileResponse(&value, &value, &value))
+	return svc, NewRegistrationController(svc, s.Application, s.Configuration, profileService)
+}
+
+func (s *TestRegistrationSuite) createIdentity() account.Identity {
+	identity, err := testsupport.CreateTestIdentityAndUser(s.DB, "TestRegistration", account.User{Cluster: s.Configuration.GetOpenShiftClientApiUrl()})
+	require.Nil(s.T(), err)
+	return identity
+}
+
+func newCompleteRegistrationPayload(username string, termsAccepted bool) *app.CompleteRegistrationPayload {
+	return &app.CompleteRegistrationPayload{
+		Data: &app.RegistrationData{
+			Type: "registrations",
+			Attributes: &app.RegistrationDataAttributes{
+				Username:      username,
+				TermsAccepted: termsAccepted,
+			},
+		},
+	}
+}
+
+func (s *TestRegistrationSuite) TestUsernameAvailability() {
+	// given
+	identity := s.createIdentity()
+	other := s.createIdentity()
+	svc, ctrl := s.SecuredController(identity)
+
+	s.T().Run("available", func(t *testing.T) {
+		_, result := test.UsernameRegistrationOK(t, svc.Context, svc, ctrl, "available"+uuid.NewV4().String())
+		assert.True(t, result.Data.Attributes.Available)
+		assert.Nil(t, result.Data.Attributes.Reason)
+	})
+
+	s.T().Run("own username", func(t *testing.T) {
+		_, result := test.UsernameRegistrationOK(t, svc.Context, svc, ctrl, identity.Username)
+		assert.True(t, result.Data.Attributes.Available)
+	})
+
+	s.T().Run("taken", func(t *testing.T) {
+		_, result := test.UsernameRegistrationOK(t, svc.Context, svc, ctrl, other.Username)
+		assert.False(t, result.Data.Attributes.Available)
+		require.NotNil(t, result.Data.Attributes.Reason)
+		assert.Equal(t, "taken", *result.Data.Attributes.Reason)
+	})
+
+	s.T().Run("invalid", func(t *testing.T) {
+		_, result := test.UsernameRegistrationOK(t, svc.Context, svc, ctrl, " ")
+		assert.False(t, result.Data.Attributes.Available)
+		require.NotNil(t, result.Data.Attributes.Reason)
+		assert.Equal(t, "invalid", *result.Data.Attributes.Reason)
+	})
+
+	s.T().Run("reserved word", func(t *testing.T) {
+		_, result := test.UsernameRegistrationOK(t, svc.Context, svc, ctrl, "Admin")
+		assert.False(t, result.Data.Attributes.Available)
+		require.NotNil(t, result.Data.Attributes.Reason)
+		assert.Equal(t, "invalid", *result.Data.Attributes.Reason)
+		test.ReserveRegistrationBadRequest(t, svc.Context, svc, ctrl, "Admin")
+	})
+}
+
+func (s *TestRegistrationSuite) TestReserveUsername() {
+	// given
+	identity := s.createIdentity()
+	other := s.createIdentity()
+	svc, ctrl := s.SecuredController(identity)
+	otherSvc, otherCtrl := s.SecuredController(other)
+	username := "reserved" + uuid.NewV4().String()
+
+	// when
+	_, result := test.ReserveRegistrationOK(s.T(), svc.Context, svc, ctrl, username)
+
+	// then
+	assert.True(s.T(), result.Data.Attributes.Available)
+	require.NotNil(s.T(), result.Data.Attributes.ReservedUntil)
+	// the username is not available for another user anymore
+	_, result = test.UsernameRegistrationOK(s.T(), otherSvc.Context, otherSvc, otherCtrl, username)
+	assert.False(s.T(), result.Data.Attributes.Available)
+	assert.Equal(s.T(), "reserved", *result.Data.Attributes.Reason)
+	test.ReserveRegistrationConflict(s.T(), otherSvc.Context, otherSvc, otherCtrl, username)
+	test.CompleteRegistrationConflict(s.T(), otherSvc.Context, otherSvc, otherCtrl, newCompleteRegistrationPayload(username, true))
+	// the previous reservation is released when reserving another username
+	test.ReserveRegistrationOK(s.T(), svc.Context, svc, ctrl, "another"+username)
+	test.ReserveRegistrationOK(s.T(), otherSvc.Context, otherSvc, otherCtrl, username)
+}
+
+func (s *TestRegistrationSuite) TestCompleteRegistration() {
+	// given
+	identity := s.createIdentity()
+	svc, ctrl := s.SecuredController(identity)
+	username := "registered" + uuid.NewV4().String()
+	test.ReserveRegistrationOK(s.T(), svc.Context, svc, ctrl, username)
+
+	// terms must be accepted
+	test.CompleteRegistrationBadRequest(s.T(), svc.Context, svc, ctrl, newCompleteRegistrationPayload(username, false))
+
+	// when
+	_, result := test.CompleteRegistrationOK(s.T(), svc.Context, svc, ctrl, newCompleteRegistrationPayload(username, true))
+
+	// then
+	assert.Equal(s.T(), username, *result.Data.Attributes.Username)
+	assert.True(s.T(), *result.Data.Attributes.RegistrationCompleted)
+	assert.Equal(s.T(), s.Configuration.GetOpenShiftClientApiUrl(), *result.Data.Attributes.Cluster)
+	user, err := s.Application.Users().Load(s.Ctx, identity.UserID.UUID)
+	require.Nil(s.T(), err)
+	assert.NotNil(s.T(), user.TermsAcceptedAt)
+	// the reservation has been released
+	_, err = s.Application.UsernameReservations().LoadActive(s.Ctx, username)
+	assert.NotNil(s.T(), err)
+	// the registration can be completed only once
+	test.CompleteRegistrationForbidden(s.T(), svc.Context, svc, ctrl, newCompleteRegistrationPayload("again"+username, true))
+	test.ReserveRegistrationForbidden(s.T(), svc.Context, svc, ctrl, "again"+username)
+}
+
+func (s *TestRegistrationSuite) TestCompleteRegistrationCreatesIdentity() {
+	// given a user who has never logged in to auth
+	identity := account.Identity{ID: uuid.NewV4()}
+	svc, ctrl := s.SecuredController(identity)
+	email := uuid.NewV4().String() + "@example.com"
+	goajwt.ContextJWT(svc.Context).Claims.(jwt.MapClaims)["email"] = email
+	username := "newuser" + uuid.NewV4().String()
+
+	// when
+	_, result := test.CompleteRegistrationOK(s.T(), svc.Context, svc, ctrl, newCompleteRegistrationPayload(username, true))
+
+	// then
+	assert.Equal(s.T(), identity.ID.String(), *result.Data.ID)
+	assert.Equal(s.T(), email, *result.Data.Attributes.Email)
+	// a cluster is allocated to the new user
+	assert.Contains(s.T(), s.Configuration.GetOSOClusters(), *result.Data.Attributes.Cluster)
+	loaded, err := s.Application.Identities().Load(s.Ctx, identity.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), username, loaded.Username)
+	assert.Equal(s.T(), account.KeycloakIDP, loaded.ProviderType)
+	assert.True(s.T(), loaded.RegistrationCompleted)
+}
+
+func (s *TestRegistrationSuite) TestCompleteRegistrationNotApprovedUnauthorized() {
+	identity := account.Identity{ID: uuid.NewV4()}
+	svc, ctrl := s.SecuredController(identity)
+	delete(goajwt.ContextJWT(svc.Context).Claims.(jwt.MapClaims), "approved")
+
+	test.CompleteRegistrationUnauthorized(s.T(), svc.Context, svc, ctrl, newCompleteRegistrationPayload("newuser"+uuid.NewV4().String(), true))
+	_, err := s.Application.Identities().Load(s.Ctx, identity.ID)
+	assert.NotNil(s.T(), err)
+}
+
+func (s *TestRegistrationSuite) TestCompleteRegistrationEmailBadRequest() {
+	// the email of another user
+	other := s.createIdentity()
+	identity := account.Identity{ID: uuid.NewV4()}
+	svc, ctrl := s.SecuredController(identity)
+	goajwt.ContextJWT(svc.Context).Claims.(jwt.MapClaims)["email"] = other.User.Email
+	test.CompleteRegistrationBadRequest(s.T(), svc.Context, svc, ctrl, newCompleteRegistrationPayload("newuser"+uuid.NewV4().String(), true))
+
+	// invalid email
+	goajwt.ContextJWT(svc.Context).Claims.(jwt.MapClaims)["email"] = "invalid"
+	test.CompleteRegistrationBadRequest(s.T(), svc.Context, svc, ctrl, newCompleteRegistrationPayload("newuser"+uuid.NewV4().String(), true))
+
+	_, err := s.Application.Identities().Load(s.Ctx, identity.ID)
+	assert.NotNil(s.T(), err)
+}
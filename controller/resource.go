@@ -1,56 +1,414 @@
 package controller
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authorization/resource"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/token"
+
 	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
 )
 
 // ResourceController implements the resource resource.
 type ResourceController struct {
 	*goa.Controller
+	db     application.DB
+	config AdminConfiguration
 }
 
 // NewResourceController creates a resource controller.
-func NewResourceController(service *goa.Service) *ResourceController {
-	return &ResourceController{Controller: service.NewController("ResourceController")}
+func NewResourceController(service *goa.Service, db application.DB, config AdminConfiguration) *ResourceController {
+	return &ResourceController{Controller: service.NewController("ResourceController"), db: db, config: config}
 }
 
 // Delete runs the delete action.
 func (c *ResourceController) Delete(ctx *app.DeleteResourceContext) error {
-	// ResourceController_Delete: start_implement
-
-	// Put your logic here
-
-	// ResourceController_Delete: end_implement
-	return nil
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		res, err := loadResource(ctx, appl, ctx.ResourceID)
+		if err != nil {
+			return err
+		}
+		if err := checkResourceOwner(ctx, c.config, res); err != nil {
+			return err
+		}
+		children, err := appl.Resources().CountChildren(ctx, res.ResourceID)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		if children > 0 {
+			return errors.NewVersionConflictError(fmt.Sprintf("resource %s still has %d child resource(s)", res.ResourceID, children))
+		}
+		// the roles assigned on the resource are removed with it
+		if err := appl.IdentityRoles().DeleteByResourceID(ctx, res.ResourceID); err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		if err := appl.TeamRoles().DeleteByResourceID(ctx, res.ResourceID); err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		return appl.Resources().Delete(ctx, res.ResourceID)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"resource_id": ctx.ResourceID,
+	}, "Resource deleted")
+	return ctx.OK([]byte{})
 }
 
 // Read runs the read action.
 func (c *ResourceController) Read(ctx *app.ReadResourceContext) error {
-	// ResourceController_Read: start_implement
-
-	// Put your logic here
-
-	// ResourceController_Read: end_implement
-	return nil
+	var res *resource.Resource
+	var scopes []resource.ResourceTypeScope
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		res, err = loadResource(ctx, appl, ctx.ResourceID)
+		if err != nil {
+			return err
+		}
+		scopes, err = appl.ResourceTypeScopes().List(ctx, &res.ResourceType)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertResource(res, scopes))
 }
 
 // Register runs the register action.
 func (c *ResourceController) Register(ctx *app.RegisterResourceContext) error {
-	// ResourceController_Register: start_implement
-
-	// Put your logic here
-
-	// ResourceController_Register: end_implement
-	return nil
+	ownerID, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	name := strings.TrimSpace(ctx.Payload.Name)
+	if name == "" {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("name", ctx.Payload.Name).Expected("not empty name"))
+	}
+	res := &resource.Resource{
+		Name:    name,
+		OwnerID: *ownerID,
+	}
+	if ctx.Payload.ResourceID != nil && *ctx.Payload.ResourceID != "" {
+		if _, err := uuid.FromString(*ctx.Payload.ResourceID); err != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("resource_id", *ctx.Payload.ResourceID).Expected("UUID"))
+		}
+		res.ResourceID = *ctx.Payload.ResourceID
+	}
+	var scopes []resource.ResourceTypeScope
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.Identities().CheckExists(ctx, ownerID.String()); err != nil {
+			return errors.NewUnauthorizedError(fmt.Sprintf("auth token contains id %s of unknown Identity", ownerID))
+		}
+		resourceType, err := appl.ResourceTypes().Lookup(ctx, ctx.Payload.Type)
+		if err != nil {
+			if ok, _ := errors.IsNotFoundError(err); ok {
+				return errors.NewBadParameterError("type", ctx.Payload.Type).Expected("name of an existing resource type")
+			}
+			return err
+		}
+		res.ResourceTypeID = resourceType.ResourceTypeID
+		if err := checkParentResource(ctx, appl, res, ctx.Payload.ParentResourceID); err != nil {
+			return err
+		}
+		scopes, err = checkResourceScopes(ctx, appl, resourceType, ctx.Payload.ResourceScopes)
+		if err != nil {
+			return err
+		}
+		if err := appl.Resources().Create(ctx, res); err != nil {
+			return err
+		}
+		res.ResourceType = *resourceType
+		return nil
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"resource_id": res.ResourceID,
+		"owner_id":    res.OwnerID,
+	}, "Resource registered")
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.ResourceHref(res.ResourceID)))
+	return ctx.Created(convertResource(res, scopes))
 }
 
 // Update runs the update action.
 func (c *ResourceController) Update(ctx *app.UpdateResourceContext) error {
-	// ResourceController_Update: start_implement
+	name := strings.TrimSpace(ctx.Payload.Name)
+	if name == "" {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("name", ctx.Payload.Name).Expected("not empty name"))
+	}
+	if ctx.Payload.ResourceID != nil && *ctx.Payload.ResourceID != "" && *ctx.Payload.ResourceID != ctx.ResourceID {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("resource_id", *ctx.Payload.ResourceID).Expected(ctx.ResourceID))
+	}
+	var res *resource.Resource
+	var scopes []resource.ResourceTypeScope
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		res, err = loadResource(ctx, appl, ctx.ResourceID)
+		if err != nil {
+			return err
+		}
+		if err := checkResourceOwner(ctx, c.config, res); err != nil {
+			return err
+		}
+		if ctx.Payload.Type != res.ResourceType.Name {
+			return errors.NewBadParameterError("type", ctx.Payload.Type).Expected(res.ResourceType.Name)
+		}
+		if err := checkParentResource(ctx, appl, res, ctx.Payload.ParentResourceID); err != nil {
+			return err
+		}
+		scopes, err = checkResourceScopes(ctx, appl, &res.ResourceType, ctx.Payload.ResourceScopes)
+		if err != nil {
+			return err
+		}
+		res.Name = name
+		return appl.Resources().Save(ctx, res)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertResource(res, scopes))
+}
+
+// ListRoles runs the list-roles action.
+func (c *ResourceController) ListRoles(ctx *app.ListRolesResourceContext) error {
+	var roles []role.IdentityRole
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		res, err := loadResource(ctx, appl, ctx.ResourceID)
+		if err != nil {
+			return err
+		}
+		if err := checkResourceOwner(ctx, c.config, res); err != nil {
+			return err
+		}
+		roles, err = appl.IdentityRoles().ListByResource(ctx, ctx.ResourceID)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.IdentityRoleData, len(roles))
+	for i := range roles {
+		data[i] = convertIdentityRole(&roles[i])
+	}
+	return ctx.OK(&app.IdentityRoleList{Data: data})
+}
 
-	// Put your logic here
+// AssignRole runs the assign-role action.
+func (c *ResourceController) AssignRole(ctx *app.AssignRoleResourceContext) error {
+	if ctx.Payload == nil || ctx.Payload.Data == nil || ctx.Payload.Data.Attributes == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("data.attributes", nil).Expected("not empty identity role attributes"))
+	}
+	attributes := ctx.Payload.Data.Attributes
+	identityID, err := uuid.FromString(attributes.IdentityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("identity_id", attributes.IdentityID).Expected("identity ID"))
+	}
+	var identityRole *role.IdentityRole
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		res, err := loadResource(ctx, appl, ctx.ResourceID)
+		if err != nil {
+			return err
+		}
+		if err := checkResourceOwner(ctx, c.config, res); err != nil {
+			return err
+		}
+		if err := appl.Identities().CheckExists(ctx, identityID.String()); err != nil {
+			return errors.NewBadParameterError("identity_id", attributes.IdentityID).Expected("existing identity")
+		}
+		// the role must be defined for the type of the resource
+		r, err := appl.Roles().LookupForResource(ctx, res.ResourceID, attributes.Role)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return errors.NewBadParameterError("role", attributes.Role).Expected(fmt.Sprintf("role defined for the resource type %s", res.ResourceType.Name))
+			}
+			return errors.NewInternalError(ctx, err)
+		}
+		identityRole, err = appl.IdentityRoles().FindForIdentityAndResource(ctx, identityID, res.ResourceID)
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			identityRole = &role.IdentityRole{
+				IdentityID: identityID,
+				ResourceID: res.ResourceID,
+				RoleID:     r.RoleID,
+			}
+			if err := appl.IdentityRoles().Create(ctx, identityRole); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return errors.NewInternalError(ctx, err)
+		} else if identityRole.RoleID != r.RoleID {
+			// the role previously assigned to the identity is replaced
+			identityRole.RoleID = r.RoleID
+			identityRole.Role = role.Role{}
+			if err := appl.IdentityRoles().Save(ctx, identityRole); err != nil {
+				return err
+			}
+		}
+		identityRole.Role = *r
+		return nil
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+		"resource_id": ctx.ResourceID,
+		"role":        identityRole.Role.Name,
+	}, "Role assigned to the identity")
+	return ctx.OK(&app.IdentityRoleSingle{Data: convertIdentityRole(identityRole)})
+}
+
+// RevokeRole runs the revoke-role action.
+func (c *ResourceController) RevokeRole(ctx *app.RevokeRoleResourceContext) error {
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		res, err := loadResource(ctx, appl, ctx.ResourceID)
+		if err != nil {
+			return err
+		}
+		if err := checkResourceOwner(ctx, c.config, res); err != nil {
+			return err
+		}
+		identityRole, err := appl.IdentityRoles().FindForIdentityAndResource(ctx, ctx.IdentityID, res.ResourceID)
+		if err != nil {
+			return err
+		}
+		return appl.IdentityRoles().Delete(ctx, identityRole.IdentityRoleID)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": ctx.IdentityID,
+		"resource_id": ctx.ResourceID,
+	}, "Role revoked from the identity")
+	return ctx.OK([]byte{})
+}
+
+// loadResource loads the resource with the given ID and its type.
+// Returns NotFoundError if the ID is not a valid resource identifier
+func loadResource(ctx context.Context, appl application.Application, resourceID string) (*resource.Resource, error) {
+	if _, err := uuid.FromString(resourceID); err != nil {
+		return nil, errors.NewNotFoundError("resource", resourceID)
+	}
+	return appl.Resources().Load(ctx, resourceID)
+}
+
+// checkParentResource sets the parent of the given resource after checking that it exists, is of the same type
+// and is not the resource itself nor one of its descendants
+func checkParentResource(ctx context.Context, appl application.Application, res *resource.Resource, parentResourceID *string) error {
+	if parentResourceID == nil || *parentResourceID == "" {
+		res.ParentResourceID = nil
+		return nil
+	}
+	parent, err := loadResource(ctx, appl, *parentResourceID)
+	if err != nil {
+		if ok, _ := errors.IsNotFoundError(err); ok {
+			return errors.NewBadParameterError("parent_resource_id", *parentResourceID).Expected("ID of an existing resource")
+		}
+		return err
+	}
+	if parent.ResourceTypeID != res.ResourceTypeID {
+		return errors.NewBadParameterError("parent_resource_id", *parentResourceID).Expected("resource of the same type")
+	}
+	// walk up the ancestors of the parent to prevent cycles
+	for ancestor := parent; ancestor != nil; {
+		if ancestor.ResourceID == res.ResourceID {
+			return errors.NewBadParameterError("parent_resource_id", *parentResourceID).Expected("resource which is not a descendant of the resource")
+		}
+		if ancestor.ParentResourceID == nil {
+			break
+		}
+		ancestor, err = appl.Resources().Load(ctx, *ancestor.ParentResourceID)
+		if err != nil {
+			return err
+		}
+	}
+	res.ParentResourceID = &parent.ResourceID
+	return nil
+}
+
+// checkResourceScopes returns the scopes of the given resource type after checking that all the given scopes are defined for the type.
+// The scopes of a resource are always those of its type: the given scopes are only validated, not stored
+func checkResourceScopes(ctx context.Context, appl application.Application, resourceType *resource.ResourceType, scopeNames []string) ([]resource.ResourceTypeScope, error) {
+	scopes, err := appl.ResourceTypeScopes().List(ctx, resourceType)
+	if err != nil {
+		return nil, err
+	}
+	for _, scopeName := range scopeNames {
+		found := false
+		for _, scope := range scopes {
+			if scope.Name == scopeName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.NewBadParameterError("resource_scopes", scopeName).Expected(fmt.Sprintf("scope of the resource type %s", resourceType.Name))
+		}
+	}
+	return scopes, nil
+}
 
-	// ResourceController_Update: end_implement
+// checkResourceOwner returns ForbiddenError if the request is done neither by the owner of the resource
+// nor by one of the admin service accounts
+func checkResourceOwner(ctx context.Context, config AdminConfiguration, res *resource.Resource) error {
+	if token.IsSpecificServiceAccount(ctx, config.GetAdminServiceAccounts()) {
+		return nil
+	}
+	currentIdentity, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return errors.NewUnauthorizedError(err.Error())
+	}
+	if *currentIdentity != res.OwnerID {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": res.ResourceID,
+			"identity_id": currentIdentity,
+		}, "the user is not the owner of the resource")
+		return errors.NewForbiddenError("only the owner of the resource can manage it")
+	}
 	return nil
 }
+
+func convertResource(res *resource.Resource, scopes []resource.ResourceTypeScope) *app.Resource {
+	resourceID := res.ResourceID
+	scopeNames := make([]string, len(scopes))
+	for i, scope := range scopes {
+		scopeNames[i] = scope.Name
+	}
+	return &app.Resource{
+		ResourceID:       &resourceID,
+		Name:             res.Name,
+		Type:             res.ResourceType.Name,
+		ParentResourceID: res.ParentResourceID,
+		ResourceScopes:   scopeNames,
+	}
+}
+
+func convertIdentityRole(identityRole *role.IdentityRole) *app.IdentityRoleData {
+	id := identityRole.IdentityRoleID.String()
+	return &app.IdentityRoleData{
+		ID:   &id,
+		Type: "identity_roles",
+		Attributes: &app.IdentityRoleDataAttributes{
+			IdentityID: identityRole.IdentityID.String(),
+			Role:       identityRole.Role.Name,
+			CreatedAt:  &identityRole.CreatedAt,
+			UpdatedAt:  &identityRole.UpdatedAt,
+		},
+	}
+}
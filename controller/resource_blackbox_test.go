@@ -0,0 +1,365 @@
+package controller_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authorization/resource"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestResourceSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunResourceSuite(t *testing.T) {
+	suite.Run(t, &TestResourceSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *TestResourceSuite) SecuredController(identity account.Identity) (*goa.Service, *ResourceController) {
+	svc := testsupport.ServiceAsUser("Resource-Service", identity)
+	return svc, NewResourceController(svc, s.Application, s.Configuration)
+}
+
+func (s *TestResourceSuite) AdminController() (*goa.Service, *ResourceController) {
+	svc := testsupport.ServiceAsAdmin("Resource-Admin-Service")
+	return svc, NewResourceController(svc, s.Application, s.Configuration)
+}
+
+func (s *TestResourceSuite) createIdentity() account.Identity {
+	identity, err := testsupport.CreateTestIdentityAndUser(s.DB, "TestResource", account.User{})
+	require.Nil(s.T(), err)
+	return identity
+}
+
+// createResourceType creates a resource type with the given scopes
+func (s *TestResourceSuite) createResourceType(scopes ...string) resource.ResourceType {
+	resourceType := resource.ResourceType{ResourceTypeID: uuid.NewV4(), Name: "TestResource" + uuid.NewV4().String()}
+	require.Nil(s.T(), s.Application.ResourceTypes().Create(s.Ctx, &resourceType))
+	for _, scope := range scopes {
+		require.Nil(s.T(), s.Application.ResourceTypeScopes().Create(s.Ctx, &resource.ResourceTypeScope{
+			ResourceTypeID: resourceType.ResourceTypeID,
+			Name:           scope,
+		}))
+	}
+	return resourceType
+}
+
+func (s *TestResourceSuite) registerResource(owner account.Identity, resourceType resource.ResourceType, parentResourceID *string) *app.Resource {
+	svc, ctrl := s.SecuredController(owner)
+	_, created := test.RegisterResourceCreated(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name:             "TestResource",
+		Type:             resourceType.Name,
+		ParentResourceID: parentResourceID,
+	})
+	return created
+}
+
+func (s *TestResourceSuite) TestRegisterResourceOK() {
+	// given
+	owner := s.createIdentity()
+	resourceType := s.createResourceType("view", "manage")
+	parent := s.registerResource(owner, resourceType, nil)
+	svc, ctrl := s.SecuredController(owner)
+	resourceID := uuid.NewV4().String()
+
+	// when
+	res, created := test.RegisterResourceCreated(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name:             " TestRegisterResourceOK ",
+		Type:             resourceType.Name,
+		ParentResourceID: parent.ResourceID,
+		ResourceID:       &resourceID,
+		ResourceScopes:   []string{"view"},
+	})
+
+	// then
+	assert.Equal(s.T(), resourceID, *created.ResourceID)
+	assert.Equal(s.T(), "TestRegisterResourceOK", created.Name)
+	assert.Equal(s.T(), resourceType.Name, created.Type)
+	assert.Equal(s.T(), *parent.ResourceID, *created.ParentResourceID)
+	assert.Equal(s.T(), []string{"manage", "view"}, created.ResourceScopes)
+	assert.True(s.T(), strings.HasSuffix(res.Header().Get("Location"), "/api/resource/"+resourceID))
+	stored, err := s.Application.Resources().Load(s.Ctx, resourceID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), owner.ID, stored.OwnerID)
+	_, read := test.ReadResourceOK(s.T(), svc.Context, svc, ctrl, resourceID)
+	assert.Equal(s.T(), created, read)
+
+	// the same identifier can't be registered twice
+	test.RegisterResourceConflict(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name:       "TestRegisterResourceOK",
+		Type:       resourceType.Name,
+		ResourceID: &resourceID,
+	})
+}
+
+func (s *TestResourceSuite) TestRegisterResourceBadRequest() {
+	// given
+	owner := s.createIdentity()
+	resourceType := s.createResourceType("view")
+	otherParent := s.registerResource(owner, s.createResourceType(), nil)
+	svc, ctrl := s.SecuredController(owner)
+	unknownID := uuid.NewV4().String()
+	invalidID := "foo"
+
+	// unknown type
+	test.RegisterResourceBadRequest(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name: "TestRegisterResourceBadRequest",
+		Type: "unknown" + uuid.NewV4().String(),
+	})
+	// unknown parent
+	test.RegisterResourceBadRequest(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name:             "TestRegisterResourceBadRequest",
+		Type:             resourceType.Name,
+		ParentResourceID: &unknownID,
+	})
+	// parent of another type
+	test.RegisterResourceBadRequest(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name:             "TestRegisterResourceBadRequest",
+		Type:             resourceType.Name,
+		ParentResourceID: otherParent.ResourceID,
+	})
+	// scope not defined for the type
+	test.RegisterResourceBadRequest(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name:           "TestRegisterResourceBadRequest",
+		Type:           resourceType.Name,
+		ResourceScopes: []string{"view", "delete"},
+	})
+	// invalid identifier
+	test.RegisterResourceBadRequest(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name:       "TestRegisterResourceBadRequest",
+		Type:       resourceType.Name,
+		ResourceID: &invalidID,
+	})
+	// blank name
+	test.RegisterResourceBadRequest(s.T(), svc.Context, svc, ctrl, &app.RegisterResourcePayload{
+		Name: " ",
+		Type: resourceType.Name,
+	})
+}
+
+func (s *TestResourceSuite) TestUpdateResourceOK() {
+	// given
+	owner := s.createIdentity()
+	resourceType := s.createResourceType("view")
+	parent := s.registerResource(owner, resourceType, nil)
+	created := s.registerResource(owner, resourceType, nil)
+	svc, ctrl := s.SecuredController(owner)
+
+	// when
+	_, updated := test.UpdateResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID, &app.UpdateResourcePayload{
+		Name:             "TestUpdateResourceOK",
+		Type:             resourceType.Name,
+		ParentResourceID: parent.ResourceID,
+		ResourceScopes:   []string{"view"},
+	})
+
+	// then
+	assert.Equal(s.T(), "TestUpdateResourceOK", updated.Name)
+	assert.Equal(s.T(), *parent.ResourceID, *updated.ParentResourceID)
+	_, read := test.ReadResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+	assert.Equal(s.T(), updated, read)
+
+	// the parent can't be a descendant of the resource
+	test.UpdateResourceBadRequest(s.T(), svc.Context, svc, ctrl, *parent.ResourceID, &app.UpdateResourcePayload{
+		Name:             "TestUpdateResourceOK",
+		Type:             resourceType.Name,
+		ParentResourceID: created.ResourceID,
+	})
+	// the type can't be changed
+	test.UpdateResourceBadRequest(s.T(), svc.Context, svc, ctrl, *created.ResourceID, &app.UpdateResourcePayload{
+		Name: "TestUpdateResourceOK",
+		Type: s.createResourceType().Name,
+	})
+
+	// an admin can update the resource of another identity
+	adminSvc, adminCtrl := s.AdminController()
+	_, updated = test.UpdateResourceOK(s.T(), adminSvc.Context, adminSvc, adminCtrl, *created.ResourceID, &app.UpdateResourcePayload{
+		Name: "TestUpdateResourceOK by admin",
+		Type: resourceType.Name,
+	})
+	assert.Equal(s.T(), "TestUpdateResourceOK by admin", updated.Name)
+	assert.Nil(s.T(), updated.ParentResourceID)
+}
+
+func (s *TestResourceSuite) TestDeleteResourceOK() {
+	// given
+	owner := s.createIdentity()
+	created := s.registerResource(owner, s.createResourceType(), nil)
+	svc, ctrl := s.SecuredController(owner)
+
+	// when
+	test.DeleteResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+
+	// then
+	test.ReadResourceNotFound(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+	test.DeleteResourceNotFound(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+}
+
+func (s *TestResourceSuite) TestDeleteResourceWithChildrenConflict() {
+	// given
+	owner := s.createIdentity()
+	resourceType := s.createResourceType()
+	parent := s.registerResource(owner, resourceType, nil)
+	child := s.registerResource(owner, resourceType, parent.ResourceID)
+	svc, ctrl := s.SecuredController(owner)
+
+	// when
+	test.DeleteResourceConflict(s.T(), svc.Context, svc, ctrl, *parent.ResourceID)
+
+	// then
+	test.ReadResourceOK(s.T(), svc.Context, svc, ctrl, *parent.ResourceID)
+	// the parent can be deleted once its child is gone
+	test.DeleteResourceOK(s.T(), svc.Context, svc, ctrl, *child.ResourceID)
+	test.DeleteResourceOK(s.T(), svc.Context, svc, ctrl, *parent.ResourceID)
+}
+
+func (s *TestResourceSuite) TestDeleteResourceRemovesRoles() {
+	// given
+	owner := s.createIdentity()
+	member := s.createIdentity()
+	resourceType := s.createResourceType()
+	s.createRole(resourceType, "contributor")
+	created := s.registerResource(owner, resourceType, nil)
+	other := s.registerResource(owner, resourceType, nil)
+	svc, ctrl := s.SecuredController(owner)
+	test.AssignRoleResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID, assignRolePayload(member.ID, "contributor"))
+	test.AssignRoleResourceOK(s.T(), svc.Context, svc, ctrl, *other.ResourceID, assignRolePayload(member.ID, "contributor"))
+
+	// when
+	test.DeleteResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+
+	// then only the role on the other resource is kept
+	identityRoles, err := s.Application.IdentityRoles().ListByIdentity(s.Ctx, member.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), identityRoles, 1)
+	assert.Equal(s.T(), *other.ResourceID, identityRoles[0].ResourceID)
+}
+
+func (s *TestResourceSuite) TestManageResourceForbidden() {
+	// given
+	owner := s.createIdentity()
+	resourceType := s.createResourceType()
+	created := s.registerResource(owner, resourceType, nil)
+	svc, ctrl := s.SecuredController(s.createIdentity())
+
+	// then
+	test.UpdateResourceForbidden(s.T(), svc.Context, svc, ctrl, *created.ResourceID, &app.UpdateResourcePayload{
+		Name: "TestManageResourceForbidden",
+		Type: resourceType.Name,
+	})
+	test.DeleteResourceForbidden(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+	// other identities can still read the resource
+	_, read := test.ReadResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+	assert.Equal(s.T(), "TestResource", read.Name)
+}
+
+func (s *TestResourceSuite) TestReadResourceNotFound() {
+	svc, ctrl := s.SecuredController(s.createIdentity())
+	test.ReadResourceNotFound(s.T(), svc.Context, svc, ctrl, uuid.NewV4().String())
+	test.ReadResourceNotFound(s.T(), svc.Context, svc, ctrl, "foo")
+}
+
+func (s *TestResourceSuite) createRole(resourceType resource.ResourceType, name string) role.Role {
+	r := role.Role{RoleID: uuid.NewV4(), ResourceTypeID: resourceType.ResourceTypeID, Name: name}
+	require.Nil(s.T(), s.Application.Roles().Create(s.Ctx, &r))
+	return r
+}
+
+func assignRolePayload(identityID uuid.UUID, roleName string) *app.AssignRoleResourcePayload {
+	return &app.AssignRoleResourcePayload{
+		Data: &app.IdentityRoleData{
+			Type:       "identity_roles",
+			Attributes: &app.IdentityRoleDataAttributes{IdentityID: identityID.String(), Role: roleName},
+		},
+	}
+}
+
+func (s *TestResourceSuite) TestManageRolesOK() {
+	// given
+	owner := s.createIdentity()
+	member := s.createIdentity()
+	resourceType := s.createResourceType()
+	s.createRole(resourceType, "contributor")
+	s.createRole(resourceType, "admin")
+	created := s.registerResource(owner, resourceType, nil)
+	other := s.registerResource(owner, resourceType, nil)
+	svc, ctrl := s.SecuredController(owner)
+
+	// when
+	_, assigned := test.AssignRoleResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID, assignRolePayload(member.ID, "contributor"))
+	test.AssignRoleResourceOK(s.T(), svc.Context, svc, ctrl, *other.ResourceID, assignRolePayload(member.ID, "admin"))
+
+	// then
+	assert.Equal(s.T(), member.ID.String(), assigned.Data.Attributes.IdentityID)
+	assert.Equal(s.T(), "contributor", assigned.Data.Attributes.Role)
+	_, roles := test.ListRolesResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+	require.Len(s.T(), roles.Data, 1)
+	assert.Equal(s.T(), *assigned.Data.ID, *roles.Data[0].ID)
+
+	// when the role of the identity is replaced
+	_, replaced := test.AssignRoleResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID, assignRolePayload(member.ID, "admin"))
+	// then
+	assert.Equal(s.T(), *assigned.Data.ID, *replaced.Data.ID)
+	assert.Equal(s.T(), "admin", replaced.Data.Attributes.Role)
+	_, roles = test.ListRolesResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+	require.Len(s.T(), roles.Data, 1)
+	assert.Equal(s.T(), "admin", roles.Data[0].Attributes.Role)
+
+	// when the role is revoked
+	test.RevokeRoleResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID, member.ID)
+	// then
+	_, roles = test.ListRolesResourceOK(s.T(), svc.Context, svc, ctrl, *created.ResourceID)
+	assert.Empty(s.T(), roles.Data)
+	test.RevokeRoleResourceNotFound(s.T(), svc.Context, svc, ctrl, *created.ResourceID, member.ID)
+	// the role on the other resource is kept
+	identityRoles, err := s.Application.IdentityRoles().ListByIdentity(s.Ctx, member.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), identityRoles, 1)
+	assert.Equal(s.T(), *other.ResourceID, identityRoles[0].ResourceID)
+	assert.Equal(s.T(), "admin", identityRoles[0].Role.Name)
+}
+
+func (s *TestResourceSuite) TestAssignRoleFails() {
+	// given
+	owner := s.createIdentity()
+	member := s.createIdentity()
+	resourceType := s.createResourceType()
+	s.createRole(resourceType, "contributor")
+	// a role with the same name defined for another resource type
+	s.createRole(s.createResourceType(), "viewer")
+	created := s.registerResource(owner, resourceType, nil)
+	svc, ctrl := s.SecuredController(owner)
+
+	// the role must be defined for the type of the resource
+	test.AssignRoleResourceBadRequest(s.T(), svc.Context, svc, ctrl, *created.ResourceID, assignRolePayload(member.ID, "viewer"))
+	// unknown identity
+	test.AssignRoleResourceBadRequest(s.T(), svc.Context, svc, ctrl, *created.ResourceID, assignRolePayload(uuid.NewV4(), "contributor"))
+	// unknown resource
+	test.AssignRoleResourceNotFound(s.T(), svc.Context, svc, ctrl, uuid.NewV4().String(), assignRolePayload(member.ID, "contributor"))
+	test.ListRolesResourceNotFound(s.T(), svc.Context, svc, ctrl, uuid.NewV4().String())
+
+	// only the owner and the admins can manage the roles
+	memberSvc, memberCtrl := s.SecuredController(member)
+	test.AssignRoleResourceForbidden(s.T(), memberSvc.Context, memberSvc, memberCtrl, *created.ResourceID, assignRolePayload(member.ID, "contributor"))
+	test.RevokeRoleResourceForbidden(s.T(), memberSvc.Context, memberSvc, memberCtrl, *created.ResourceID, member.ID)
+	test.ListRolesResourceForbidden(s.T(), memberSvc.Context, memberSvc, memberCtrl, *created.ResourceID)
+	adminSvc, adminCtrl := s.AdminController()
+	test.AssignRoleResourceOK(s.T(), adminSvc.Context, adminSvc, adminCtrl, *created.ResourceID, assignRolePayload(member.ID, "contributor"))
+	_, roles := test.ListRolesResourceOK(s.T(), adminSvc.Context, adminSvc, adminCtrl, *created.ResourceID)
+	require.Len(s.T(), roles.Data, 1)
+	// the member is still not allowed to list the roles once a role is assigned
+	test.ListRolesResourceForbidden(s.T(), memberSvc.Context, memberSvc, memberCtrl, *created.ResourceID)
+}
@@ -2,7 +2,9 @@ package controller
 
 import (
 	"fmt"
+	"net/url"
 	"regexp"
+	"strconv"
 
 	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/app"
@@ -38,7 +40,7 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 		return ctx.BadRequest(goa.ErrBadRequest(fmt.Errorf("search query should be longer")))
 	}
 
-	var result []account.Identity
+	var result []account.IdentitySearchResult
 	var count int
 	var err error
 
@@ -55,9 +57,15 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 		searchLimit = c.configuration.GetMaxUsersListLimit() - offset
 	}
 
+	filter := account.IdentitySearchFilter{
+		ProviderType:          ctx.FilterProviderType,
+		Cluster:               ctx.FilterCluster,
+		RegistrationCompleted: ctx.FilterRegistrationCompleted,
+		TeamID:                ctx.FilterTeam,
+	}
 	if r.MatchString(q) {
 		err = application.Transactional(c.db, func(appl application.Application) error {
-			result, count, err = appl.Identities().Search(ctx, q, offset, searchLimit)
+			result, count, err = appl.Identities().Search(ctx, q, filter, offset, searchLimit)
 			return err
 		})
 		if err != nil {
@@ -69,7 +77,7 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 	}
 
 	if exceeded {
-		result = []account.Identity{}
+		result = []account.IdentitySearchResult{}
 	}
 	if count > c.configuration.GetMaxUsersListLimit() {
 		// Hide the real count if it's more than the max allowed limit
@@ -86,17 +94,23 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 			Type: "identities",
 			ID:   &id,
 			Attributes: &app.UserDataAttributes{
-				CreatedAt:  &ident.User.CreatedAt,
-				UpdatedAt:  &ident.User.UpdatedAt,
-				Username:   &ident.Username,
-				FullName:   &ident.User.FullName,
-				ImageURL:   &ident.User.ImageURL,
-				Bio:        &ident.User.Bio,
-				URL:        &ident.User.URL,
-				UserID:     &userID,
-				IdentityID: &id,
-				Email:      &ident.User.Email,
-				Company:    &ident.User.Company,
+				CreatedAt:             &ident.User.CreatedAt,
+				UpdatedAt:             &ident.User.UpdatedAt,
+				Username:              &ident.Username,
+				FullName:              &ident.User.FullName,
+				ImageURL:              &ident.User.ImageURL,
+				Bio:                   &ident.User.Bio,
+				URL:                   &ident.User.URL,
+				UserID:                &userID,
+				IdentityID:            &id,
+				Email:                 &ident.User.Email,
+				Company:               &ident.User.Company,
+				ProviderType:          &ident.ProviderType,
+				Cluster:               &ident.User.Cluster,
+				RegistrationCompleted: &ident.RegistrationCompleted,
+			},
+			Meta: map[string]interface{}{
+				"rank": ident.Rank,
 			},
 		})
 	}
@@ -111,8 +125,26 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 		Links: &app.PagingLinks{},
 		Meta:  &app.UserListMeta{TotalCount: count},
 	}
-	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(result), offset, limit, count, "q="+q)
+	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(result), offset, limit, count, searchQuery(q, filter)...)
 
 	return ctx.OK(&response)
 
 }
+
+// searchQuery returns the query parameters to repeat in the paging links of a search
+func searchQuery(q string, filter account.IdentitySearchFilter) []string {
+	query := []string{"q=" + q}
+	if filter.ProviderType != nil {
+		query = append(query, "filter[provider_type]="+url.QueryEscape(*filter.ProviderType))
+	}
+	if filter.Cluster != nil {
+		query = append(query, "filter[cluster]="+url.QueryEscape(*filter.Cluster))
+	}
+	if filter.RegistrationCompleted != nil {
+		query = append(query, "filter[registration_completed]="+strconv.FormatBool(*filter.RegistrationCompleted))
+	}
+	if filter.TeamID != nil {
+		query = append(query, "filter[team]="+filter.TeamID.String())
+	}
+	return query
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/fabric8-services/fabric8-auth/account"
@@ -16,6 +17,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/resource"
 	"github.com/goadesign/goa"
 	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -79,7 +81,7 @@ func (s *TestSearchUserSearch) TestUsersSearchOK() {
 	}
 
 	for _, tt := range tests {
-		_, result := test.UsersSearchOK(s.T(), context.Background(), s.svc, s.controller, tt.userSearchTestArgs.pageLimit, tt.userSearchTestArgs.pageOffset, tt.userSearchTestArgs.q)
+		_, result := test.UsersSearchOK(s.T(), context.Background(), s.svc, s.controller, nil, nil, nil, nil, tt.userSearchTestArgs.pageLimit, tt.userSearchTestArgs.pageOffset, tt.userSearchTestArgs.q)
 		for _, userSearchTestExpect := range tt.userSearchTestExpects {
 			userSearchTestExpect(s.T(), tt, result)
 		}
@@ -96,10 +98,98 @@ func (s *TestSearchUserSearch) TestUsersSearchBadRequest() {
 	}
 
 	for _, tt := range tests {
-		test.UsersSearchBadRequest(t, context.Background(), s.svc, s.controller, tt.userSearchTestArgs.pageLimit, tt.userSearchTestArgs.pageOffset, tt.userSearchTestArgs.q)
+		test.UsersSearchBadRequest(t, context.Background(), s.svc, s.controller, nil, nil, nil, nil, tt.userSearchTestArgs.pageLimit, tt.userSearchTestArgs.pageOffset, tt.userSearchTestArgs.q)
 	}
 }
 
+func (s *TestSearchUserSearch) TestUsersSearchRankingAndFilters() {
+	// given
+	term := strings.Replace(uuid.NewV4().String(), "-", "", -1)[:12]
+	exact := s.createSearchIdentity(term, "exact"+term, "kc", "https://api.cluster-a.example.com/", true)
+	zorro := s.createSearchIdentity(term+" Zorro", "zorro"+term, "kc", "https://api.cluster-b.example.com/", false)
+	github := s.createSearchIdentity("Someone "+term, "gh"+term, "github", "https://api.cluster-a.example.com/", false)
+	defer s.cleanTestData([]account.Identity{exact, zorro, github})
+	t := s.T()
+	limit := 10
+
+	t.Run("best match first", func(t *testing.T) {
+		_, result := test.UsersSearchOK(t, context.Background(), s.svc, s.controller, nil, nil, nil, nil, &limit, nil, strings.ToUpper(term))
+		// only the Keycloak identities are returned by default
+		require.Len(t, result.Data, 2)
+		assert.Equal(t, exact.ID.String(), *result.Data[0].ID)
+		for i := 1; i < len(result.Data); i++ {
+			assert.True(t, result.Data[i-1].Meta["rank"].(float64) >= result.Data[i].Meta["rank"].(float64))
+		}
+	})
+
+	t.Run("all terms must match", func(t *testing.T) {
+		_, result := test.UsersSearchOK(t, context.Background(), s.svc, s.controller, nil, nil, nil, nil, &limit, nil, "zorro "+term)
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, zorro.ID.String(), *result.Data[0].ID)
+	})
+
+	t.Run("filter by provider type", func(t *testing.T) {
+		providerType := "github"
+		_, result := test.UsersSearchOK(t, context.Background(), s.svc, s.controller, nil, &providerType, nil, nil, &limit, nil, term)
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, github.ID.String(), *result.Data[0].ID)
+		assert.Equal(t, "github", *result.Data[0].Attributes.ProviderType)
+	})
+
+	t.Run("filter by cluster", func(t *testing.T) {
+		cluster := "https://api.cluster-b.example.com/"
+		_, result := test.UsersSearchOK(t, context.Background(), s.svc, s.controller, &cluster, nil, nil, nil, &limit, nil, term)
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, zorro.ID.String(), *result.Data[0].ID)
+		assert.Equal(t, cluster, *result.Data[0].Attributes.Cluster)
+	})
+
+	t.Run("filter by registration status", func(t *testing.T) {
+		completed := true
+		_, result := test.UsersSearchOK(t, context.Background(), s.svc, s.controller, nil, nil, &completed, nil, &limit, nil, term)
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, exact.ID.String(), *result.Data[0].ID)
+	})
+
+	t.Run("filter by team", func(t *testing.T) {
+		organization := account.Organization{Name: "TestUsersSearch" + term, OwnerID: exact.ID}
+		require.Nil(t, s.Application.Organizations().Create(context.Background(), &organization))
+		team := account.Team{OrganizationID: organization.ID, Name: "developers"}
+		require.Nil(t, s.Application.Teams().Create(context.Background(), &team))
+		require.Nil(t, s.Application.Teams().AddMember(context.Background(), team.ID, zorro.ID))
+		defer s.Application.Teams().RemoveMember(context.Background(), team.ID, zorro.ID)
+		_, result := test.UsersSearchOK(t, context.Background(), s.svc, s.controller, nil, nil, nil, &team.ID, &limit, nil, term)
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, zorro.ID.String(), *result.Data[0].ID)
+	})
+
+	t.Run("filters are kept in paging links", func(t *testing.T) {
+		providerType := "kc"
+		one := 1
+		_, result := test.UsersSearchOK(t, context.Background(), s.svc, s.controller, nil, &providerType, nil, nil, &one, nil, term)
+		assert.Equal(t, 2, result.Meta.TotalCount)
+		require.NotNil(t, result.Links.Next)
+		assert.Contains(t, *result.Links.Next, "filter[provider_type]=kc")
+	})
+}
+
+func (s *TestSearchUserSearch) createSearchIdentity(fullName, username, providerType, cluster string, registrationCompleted bool) account.Identity {
+	user := account.User{
+		FullName: fullName,
+		Email:    username + "@example.com",
+		Cluster:  cluster,
+	}
+	require.Nil(s.T(), s.Application.Users().Create(context.Background(), &user))
+	ident := account.Identity{
+		User:                  user,
+		Username:              username,
+		ProviderType:          providerType,
+		RegistrationCompleted: registrationCompleted,
+	}
+	require.Nil(s.T(), s.Application.Identities().Create(context.Background(), &ident))
+	return ident
+}
+
 func (s *TestSearchUserSearch) createTestData() []account.Identity {
 	names := []string{"X_TEST_A", "X_TEST_AB", "X_TEST_B", "X_TEST_C"}
 	emails := []string{"email_x_test_ab@redhat.org", "email_x_test_a@redhat.org", "email_x_test_c@redhat.org", "email_x_test_b@redhat.org"}
@@ -0,0 +1,426 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/rest"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// TeamController implements the team resource.
+type TeamController struct {
+	*goa.Controller
+	db            application.DB
+	config        AdminConfiguration
+	policyManager auth.AuthzPolicyManager
+}
+
+// NewTeamController creates a team controller.
+func NewTeamController(service *goa.Service, db application.DB, config AdminConfiguration, policyManager auth.AuthzPolicyManager) *TeamController {
+	return &TeamController{Controller: service.NewController("TeamController"), db: db, config: config, policyManager: policyManager}
+}
+
+// Show runs the show action.
+func (c *TeamController) Show(ctx *app.ShowTeamContext) error {
+	var team *account.Team
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := c.checkTeamMember(ctx, appl, ctx.ID); err != nil {
+			return err
+		}
+		var err error
+		team, err = appl.Teams().Load(ctx, ctx.ID)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TeamSingle{Data: convertTeam(ctx.RequestData, team)})
+}
+
+// Delete runs the delete action.
+func (c *TeamController) Delete(ctx *app.DeleteTeamContext) error {
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := c.checkTeamOwner(ctx, appl, ctx.ID); err != nil {
+			return err
+		}
+		roles, memberIDs, err := listTeamRolesAndMemberIDs(ctx, appl, ctx.ID)
+		if err != nil {
+			return err
+		}
+		if err := appl.Teams().Delete(ctx, ctx.ID); err != nil {
+			return err
+		}
+		// Keycloak is updated last so the transaction is rolled back if the update fails
+		return removeFromTeamSpacePolicies(ctx, appl, ctx.RequestData, c.policyManager, roles, memberIDs)
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"team_id": ctx.ID,
+	}, "Team deleted")
+	return ctx.OK([]byte{})
+}
+
+// ListMembers runs the list-members action.
+func (c *TeamController) ListMembers(ctx *app.ListMembersTeamContext) error {
+	var members []account.Identity
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := c.checkTeamMember(ctx, appl, ctx.ID); err != nil {
+			return err
+		}
+		var err error
+		members, err = appl.Teams().ListMembers(ctx, ctx.ID)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.UserData, len(members))
+	for i := range members {
+		data[i] = ConvertToAppUser(ctx.RequestData, &members[i].User, &members[i]).Data
+	}
+	return ctx.OK(&app.UserList{
+		Data: data,
+		Meta: &app.UserListMeta{TotalCount: len(members)},
+	})
+}
+
+// AddMember runs the add-member action.
+func (c *TeamController) AddMember(ctx *app.AddMemberTeamContext) error {
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := c.checkTeamOwner(ctx, appl, ctx.ID); err != nil {
+			return err
+		}
+		if err := appl.Identities().CheckExists(ctx, ctx.IdentityID.String()); err != nil {
+			return errors.NewNotFoundError("identity", ctx.IdentityID.String())
+		}
+		if err := appl.Teams().AddMember(ctx, ctx.ID, ctx.IdentityID); err != nil {
+			return err
+		}
+		roles, err := appl.TeamRoles().ListByTeam(ctx, ctx.ID)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		return addToTeamSpacePolicies(ctx, appl, ctx.RequestData, c.policyManager, roles, []uuid.UUID{ctx.IdentityID})
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK([]byte{})
+}
+
+// RemoveMember runs the remove-member action.
+func (c *TeamController) RemoveMember(ctx *app.RemoveMemberTeamContext) error {
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := c.checkTeamOwner(ctx, appl, ctx.ID); err != nil {
+			return err
+		}
+		if err := appl.Teams().RemoveMember(ctx, ctx.ID, ctx.IdentityID); err != nil {
+			return err
+		}
+		roles, err := appl.TeamRoles().ListByTeam(ctx, ctx.ID)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		return removeFromTeamSpacePolicies(ctx, appl, ctx.RequestData, c.policyManager, roles, []uuid.UUID{ctx.IdentityID})
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK([]byte{})
+}
+
+// ListRoles runs the list-roles action.
+func (c *TeamController) ListRoles(ctx *app.ListRolesTeamContext) error {
+	var roles []role.TeamRole
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := c.checkTeamMember(ctx, appl, ctx.ID); err != nil {
+			return err
+		}
+		var err error
+		roles, err = appl.TeamRoles().ListByTeam(ctx, ctx.ID)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.TeamRoleData, len(roles))
+	for i := range roles {
+		data[i] = convertTeamRole(&roles[i])
+	}
+	return ctx.OK(&app.TeamRoleList{Data: data})
+}
+
+// AddRole runs the add-role action.
+func (c *TeamController) AddRole(ctx *app.AddRoleTeamContext) error {
+	if ctx.Payload == nil || ctx.Payload.Data == nil || ctx.Payload.Data.Attributes == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("data.attributes", nil).Expected("not empty team role attributes"))
+	}
+	attributes := ctx.Payload.Data.Attributes
+	if _, err := uuid.FromString(attributes.ResourceID); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("resource_id", attributes.ResourceID).Expected("resource ID"))
+	}
+	var teamRole *role.TeamRole
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := c.checkTeamOwner(ctx, appl, ctx.ID); err != nil {
+			return err
+		}
+		// the owner of the team can only give roles on the resources they own
+		res, err := loadResource(ctx, appl, attributes.ResourceID)
+		if err != nil {
+			return err
+		}
+		if err := checkResourceOwner(ctx, c.config, res); err != nil {
+			return err
+		}
+		r, err := appl.Roles().LookupForResource(ctx, attributes.ResourceID, attributes.Role)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return err
+			}
+			return errors.NewInternalError(ctx, err)
+		}
+		teamRole = &role.TeamRole{
+			TeamID:     ctx.ID,
+			ResourceID: attributes.ResourceID,
+			RoleID:     r.RoleID,
+		}
+		if err := appl.TeamRoles().Create(ctx, teamRole); err != nil {
+			return err
+		}
+		teamRole.Role = *r
+		members, err := appl.Teams().ListMembers(ctx, ctx.ID)
+		if err != nil {
+			return err
+		}
+		// Keycloak is updated last so the transaction is rolled back if the update fails
+		return addToTeamSpacePolicies(ctx, appl, ctx.RequestData, c.policyManager, []role.TeamRole{*teamRole}, identityIDs(members))
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"team_id":     ctx.ID,
+		"resource_id": teamRole.ResourceID,
+		"role":        teamRole.Role.Name,
+	}, "Role given to the team")
+	return ctx.Created(&app.TeamRoleSingle{Data: convertTeamRole(teamRole)})
+}
+
+// RemoveRole runs the remove-role action.
+func (c *TeamController) RemoveRole(ctx *app.RemoveRoleTeamContext) error {
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := c.checkTeamOwner(ctx, appl, ctx.ID); err != nil {
+			return err
+		}
+		teamRole, err := appl.TeamRoles().Load(ctx, ctx.RoleID)
+		if err != nil {
+			return err
+		}
+		if teamRole.TeamID != ctx.ID {
+			return errors.NewNotFoundError("team_role", ctx.RoleID.String())
+		}
+		if err := appl.TeamRoles().Delete(ctx, ctx.RoleID); err != nil {
+			return err
+		}
+		members, err := appl.Teams().ListMembers(ctx, ctx.ID)
+		if err != nil {
+			return err
+		}
+		return removeFromTeamSpacePolicies(ctx, appl, ctx.RequestData, c.policyManager, []role.TeamRole{*teamRole}, identityIDs(members))
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK([]byte{})
+}
+
+// checkTeamOwner returns ForbiddenError if the request is done neither by the owner of the organization of the team
+// nor by one of the admin service accounts
+func (c *TeamController) checkTeamOwner(ctx context.Context, appl application.Application, teamID uuid.UUID) error {
+	team, err := appl.Teams().Load(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	organization, err := appl.Organizations().Load(ctx, team.OrganizationID)
+	if err != nil {
+		return err
+	}
+	return checkOrganizationOwner(ctx, c.config, organization)
+}
+
+// checkTeamMember returns ForbiddenError if the request is done neither by a member of the team,
+// nor by the owner of the organization of the team nor by one of the admin service accounts
+func (c *TeamController) checkTeamMember(ctx context.Context, appl application.Application, teamID uuid.UUID) error {
+	err := c.checkTeamOwner(ctx, appl, teamID)
+	if forbidden, _ := errors.IsForbiddenError(err); !forbidden {
+		return err
+	}
+	currentIdentity, err := login.ContextIdentity(ctx)
+	if err != nil {
+		return errors.NewUnauthorizedError(err.Error())
+	}
+	teams, err := appl.Teams().ListByMember(ctx, *currentIdentity)
+	if err != nil {
+		return err
+	}
+	for _, team := range teams {
+		if team.ID == teamID {
+			return nil
+		}
+	}
+	log.Error(ctx, map[string]interface{}{
+		"team_id":     teamID,
+		"identity_id": currentIdentity,
+	}, "the user is not a member of the team")
+	return errors.NewForbiddenError("only the members of the team and the owner of its organization can see the team")
+}
+
+// listTeamRolesAndMemberIDs returns the roles held by the team and the IDs of its members
+func listTeamRolesAndMemberIDs(ctx context.Context, appl application.Application, teamID uuid.UUID) ([]role.TeamRole, []uuid.UUID, error) {
+	roles, err := appl.TeamRoles().ListByTeam(ctx, teamID)
+	if err != nil {
+		return nil, nil, errors.NewInternalError(ctx, err)
+	}
+	members, err := appl.Teams().ListMembers(ctx, teamID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, identityIDs(members), nil
+}
+
+// addToTeamSpacePolicies adds the given identities to the collaborators policies of the spaces on which the team roles are held
+func addToTeamSpacePolicies(ctx context.Context, appl application.Application, req *goa.RequestData, policyManager auth.AuthzPolicyManager, roles []role.TeamRole, ids []uuid.UUID) error {
+	return updateTeamSpacePolicies(ctx, appl, req, policyManager, roles, ids, true)
+}
+
+// removeFromTeamSpacePolicies removes the given identities from the collaborators policies of the spaces on which the team roles were held.
+// The owner of a space and the members of the other teams holding a role on the space are kept.
+func removeFromTeamSpacePolicies(ctx context.Context, appl application.Application, req *goa.RequestData, policyManager auth.AuthzPolicyManager, roles []role.TeamRole, ids []uuid.UUID) error {
+	return updateTeamSpacePolicies(ctx, appl, req, policyManager, roles, ids, false)
+}
+
+// updateTeamSpacePolicies adds the given identities to (or removes them from) the collaborators policies of the spaces
+// on which the team roles are held, so the members of a team have access to the spaces of the team.
+// The resources which are not spaces are skipped.
+func updateTeamSpacePolicies(ctx context.Context, appl application.Application, req *goa.RequestData, policyManager auth.AuthzPolicyManager, roles []role.TeamRole, ids []uuid.UUID, add bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	updatedSpaces := map[string]bool{}
+	for _, teamRole := range roles {
+		if updatedSpaces[teamRole.ResourceID] {
+			continue
+		}
+		updatedSpaces[teamRole.ResourceID] = true
+		spaceID, err := uuid.FromString(teamRole.ResourceID)
+		if err != nil {
+			continue
+		}
+		spaceResource, err := appl.SpaceResources().LoadBySpace(ctx, &spaceID)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				continue
+			}
+			return err
+		}
+		// the identities which still have access to the space through a team are kept
+		kept := map[uuid.UUID]bool{spaceResource.OwnerID: true}
+		if !add {
+			memberIDs, err := appl.TeamRoles().ListMemberIDsByResource(ctx, teamRole.ResourceID)
+			if err != nil {
+				return errors.NewInternalError(ctx, err)
+			}
+			for _, id := range memberIDs {
+				kept[id] = true
+			}
+		}
+		policy, pat, err := policyManager.GetPolicy(ctx, req, spaceResource.PolicyID)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		updated := false
+		for _, id := range ids {
+			if add {
+				updated = policyManager.AddUserToPolicy(policy, id.String()) || updated
+			} else if !kept[id] {
+				updated = policyManager.RemoveUserFromPolicy(policy, id.String()) || updated
+			}
+		}
+		if !updated {
+			continue
+		}
+		if err := policyManager.UpdatePolicy(ctx, req, *policy, *pat); err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		// the space resource is updated to trigger the refresh of the RPT tokens of the users accessing the space
+		if _, err := appl.SpaceResources().Save(ctx, spaceResource); err != nil {
+			return err
+		}
+		log.Info(ctx, map[string]interface{}{
+			"space_id":     spaceID,
+			"identity_ids": ids,
+			"added":        add,
+		}, "collaborators policy of the space updated with the members of the team")
+	}
+	return nil
+}
+
+// identityIDs returns the IDs of the given identities
+func identityIDs(identities []account.Identity) []uuid.UUID {
+	ids := make([]uuid.UUID, len(identities))
+	for i, identity := range identities {
+		ids[i] = identity.ID
+	}
+	return ids
+}
+
+func convertTeam(request *goa.RequestData, team *account.Team) *app.TeamData {
+	id := team.ID.String()
+	organizationID := team.OrganizationID.String()
+	selfURL := rest.AbsoluteURL(request, app.TeamHref(id))
+	relatedURL := rest.AbsoluteURL(request, app.TeamHref(id)+"/members")
+	return &app.TeamData{
+		ID:   &id,
+		Type: "teams",
+		Attributes: &app.TeamDataAttributes{
+			Name:           team.Name,
+			Description:    &team.Description,
+			OrganizationID: &organizationID,
+			CreatedAt:      &team.CreatedAt,
+			UpdatedAt:      &team.UpdatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self:    &selfURL,
+			Related: &relatedURL,
+		},
+	}
+}
+
+func convertTeamRole(teamRole *role.TeamRole) *app.TeamRoleData {
+	id := teamRole.TeamRoleID.String()
+	return &app.TeamRoleData{
+		ID:   &id,
+		Type: "team_roles",
+		Attributes: &app.TeamRoleDataAttributes{
+			ResourceID: teamRole.ResourceID,
+			Role:       teamRole.Role.Name,
+			CreatedAt:  &teamRole.CreatedAt,
+		},
+	}
+}
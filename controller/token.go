@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -42,6 +43,16 @@ type TokenController struct {
 	Configuration                LoginConfiguration
 	keycloakExternalTokenService keycloak.KeycloakExternalTokenService
 	providerConfigFactory        link.OauthProviderFactory
+	refreshTokenRotation         *login.RefreshTokenRotation
+	Lockout                      ClientLockout
+}
+
+// ClientLockout locks out the clients after repeated failed client secret checks from the same IP
+type ClientLockout interface {
+	ClientIP(req *http.Request) string
+	LockedOut(clientID string, ip string) time.Duration
+	RecordFailure(clientID string, ip string) time.Duration
+	RecordSuccess(clientID string, ip string)
 }
 
 // NewTokenController creates a token controller.
@@ -54,7 +65,8 @@ func NewTokenController(service *goa.Service, db application.DB, auth *login.Key
 		Configuration:                configuration,
 		keycloakExternalTokenService: kclient,
 		providerConfigFactory:        providerConfigFactory,
-		db: db,
+		refreshTokenRotation:         login.NewRefreshTokenRotation(db, tokenManager, configuration),
+		db:                           db,
 	}
 }
 
@@ -77,6 +89,13 @@ func (c *TokenController) Refresh(ctx *app.RefreshTokenContext) error {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("refresh_token", nil).Expected("not nil"))
 	}
 
+	// Refresh tokens are rotated on every use. A refresh token which has already been used is rejected
+	// and the whole token family is revoked.
+	checked, err := c.refreshTokenRotation.Check(ctx, ctx.RequestData, *refreshToken)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
 	client := &http.Client{Timeout: 10 * time.Second}
 	endpoint, err := c.Configuration.GetKeycloakEndpointToken(ctx.RequestData)
 	if err != nil {
@@ -110,6 +129,23 @@ func (c *TokenController) Refresh(ctx *app.RefreshTokenContext) error {
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
+	err = c.refreshTokenRotation.Rotate(ctx, ctx.RequestData, checked, t)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to rotate the refresh token")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if checked.ClientID != "" {
+		// The settings of the API client the token family has been issued to also apply to the refreshed tokens
+		apiClient, err := login.LoadOauthClient(ctx, c.db, checked.ClientID, login.RefreshTokenGrantType)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+		if err := login.LimitTokenSet(ctx, c.TokenManager, t, apiClient); err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+	}
 	ctx.ResponseData.Header().Set("Cache-Control", "no-cache")
 	return ctx.OK(convertToken(*t))
 }
@@ -222,6 +258,9 @@ func (c *TokenController) Retrieve(ctx *app.RetrieveTokenContext) error {
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
+	if err := login.CheckUserActive(ctx, c.db, *currentIdentity); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 	tokenString := goajwt.ContextJWT(ctx).Raw
 
 	if ctx.For == "" {
@@ -345,15 +384,20 @@ func (c *TokenController) Delete(ctx *app.DeleteTokenContext) error {
 	return ctx.OK([]byte{})
 }
 
-// Exchange provides OAuth2 token exchange. Currently only grant_type="client_credentials" is supported
+// Exchange provides OAuth2 token exchange. Currently grant_type="client_credentials" is supported
 // allowing clients to authenticate using a service account ID and secret value.
 // A service account token is returned as the result of successful exchange.
+// grant_type="urn:ietf:params:oauth:grant-type:device_code" is used by devices polling for
+// the token issued as part of the device authorization grant.
 // May be expanded in the future to support other exchange types.
 func (c *TokenController) Exchange(ctx *app.ExchangeTokenContext) error {
 	payload := ctx.Payload
 	if payload == nil {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("payload", "nil").Expected("not empty payload"))
 	}
+	if payload.GrantType == login.DeviceCodeGrantType {
+		return c.exchangeDeviceCode(ctx)
+	}
 	if payload.ClientID == nil {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("client_id", "nil").Expected("Service Account ID"))
 	}
@@ -361,17 +405,26 @@ func (c *TokenController) Exchange(ctx *app.ExchangeTokenContext) error {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("client_secret", "nil").Expected("Service Account secret"))
 	}
 
+	if c.Lockout != nil {
+		if lockedOut := c.Lockout.LockedOut(*payload.ClientID, c.Lockout.ClientIP(ctx.Request)); lockedOut > 0 {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewTooManyRequestsError("too many failed attempts to authenticate the Service Account", lockedOut))
+		}
+	}
 	sa, found := c.Configuration.GetServiceAccounts()[*payload.ClientID]
 	if !found {
 		log.Error(ctx, map[string]interface{}{
 			"client_id":     *payload.ClientID,
 			"client_secret": *payload.ClientSecret,
 		}, "Unknown Service Account ID")
+		c.recordSecretCheckFailure(ctx, ctx.Request, *payload.ClientID)
 		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid Service Account ID or secret"))
 	}
 	secret := []byte(*payload.ClientSecret)
 	for _, hash := range sa.Secrets {
 		if bcrypt.CompareHashAndPassword([]byte(hash), secret) == nil {
+			if c.Lockout != nil {
+				c.Lockout.RecordSuccess(*payload.ClientID, c.Lockout.ClientIP(ctx.Request))
+			}
 			tokenType := "bearer"
 			accessToken, err := c.TokenManager.GenerateServiceAccountToken(ctx.RequestData, sa.ID, sa.Name)
 			if err != nil {
@@ -388,9 +441,58 @@ func (c *TokenController) Exchange(ctx *app.ExchangeTokenContext) error {
 		"client_id":     *payload.ClientID,
 		"client_secret": *payload.ClientSecret,
 	}, "Service Account secret doesn't match")
+	c.recordSecretCheckFailure(ctx, ctx.Request, *payload.ClientID)
 	return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid Service Account ID or secret"))
 }
 
+// recordSecretCheckFailure records the failed secret check so the client is locked out after repeated failures
+func (c *TokenController) recordSecretCheckFailure(ctx context.Context, req *http.Request, clientID string) {
+	if c.Lockout == nil {
+		return
+	}
+	ip := c.Lockout.ClientIP(req)
+	if lockedOut := c.Lockout.RecordFailure(clientID, ip); lockedOut > 0 {
+		log.Warn(ctx, map[string]interface{}{
+			"client_id":  clientID,
+			"client_ip":  ip,
+			"locked_out": lockedOut.String(),
+		}, "Service Account locked out after repeated failed secret checks")
+	}
+}
+
+// exchangeDeviceCode returns the token issued for the device if the user code has been approved (RFC 8628)
+func (c *TokenController) exchangeDeviceCode(ctx *app.ExchangeTokenContext) error {
+	payload := ctx.Payload
+	if payload.DeviceCode == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("device_code", "nil").Expected("device code"))
+	}
+	if payload.ClientID == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("client_id", "nil").Expected("api client which requested the device code"))
+	}
+	apiClient, err := login.LoadOauthClient(ctx, c.db, *payload.ClientID, login.DeviceCodeGrantType)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	tokenSet, err := login.ExchangeDeviceCode(ctx, c.db, *payload.DeviceCode, *payload.ClientID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if err := login.IssueTokenSet(ctx, c.db, c.TokenManager, tokenSet, apiClient); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-store")
+	oauthToken := &app.OauthToken{
+		AccessToken:  tokenSet.AccessToken,
+		TokenType:    tokenSet.TokenType,
+		RefreshToken: tokenSet.RefreshToken,
+	}
+	if tokenSet.ExpiresIn != nil {
+		expiresIn := strconv.FormatInt(*tokenSet.ExpiresIn, 10)
+		oauthToken.ExpiresIn = &expiresIn
+	}
+	return ctx.OK(oauthToken)
+}
+
 func (c *TokenController) saveKeycloakToken(ctx context.Context, keycloakTokenResponse keycloak.KeycloakExternalTokenResponse, providerConfig link.ProviderConfig, currentIdentity uuid.UUID) (*provider.ExternalToken, error) {
 	var externalToken provider.ExternalToken
 	err := application.Transactional(c.db, func(appl application.Application) error {
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/app"
@@ -113,6 +114,25 @@ func (rest *TestTokenStorageREST) TestRetrieveOSOServiceAccountTokenForUnknownSA
 	}
 }
 
+func (rest *TestTokenStorageREST) TestRetrieveExternalTokenDeactivatedUserUnauthorized() {
+	now := time.Now()
+	user := account.User{
+		Email:         uuid.NewV4().String() + "@example.com",
+		DeactivatedAt: &now,
+	}
+	require.Nil(rest.T(), rest.userRepository.Create(rest.Ctx, &user))
+	identity := account.Identity{
+		Username:     "TestRetrieveDeactivated" + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+		User:         user,
+		UserID:       account.NullUUID{UUID: user.ID, Valid: true},
+	}
+	require.Nil(rest.T(), rest.identityRepository.Create(rest.Ctx, &identity))
+	rest.mockKeycloakExternalTokenServiceClient.scenario = "positive"
+	service, controller := rest.SecuredControllerWithIdentityAndDummyProviderFactory(identity)
+	test.RetrieveTokenUnauthorized(rest.T(), service.Context, service, controller, "https://github.com/a/b", nil)
+}
+
 // Not present in DB but present in Keycloak
 func (rest *TestTokenStorageREST) TestRetrieveExternalTokenGithubOK() {
 	identity, err := testsupport.CreateTestIdentity(rest.DB, uuid.NewV4().String(), "KC")
@@ -2,13 +2,18 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
 	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/login"
 	"github.com/fabric8-services/fabric8-auth/token"
 
 	"github.com/goadesign/goa"
@@ -26,6 +31,7 @@ type UserController struct {
 
 // UserControllerConfiguration the Configuration for the UserController
 type UserControllerConfiguration interface {
+	login.LinkStatusConfiguration
 	GetCacheControlUser() string
 }
 
@@ -74,3 +80,113 @@ func (c *UserController) Show(ctx *app.ShowUserContext) error {
 		})
 	})
 }
+
+// ShowContext returns a namespace of the context information of the authorized user based on the provided Token
+func (c *UserController) ShowContext(ctx *app.ShowContextUserContext) error {
+	id, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		jerrors, _ := jsonapi.ErrorToJSONAPIErrors(ctx, goa.ErrBadRequest(err.Error()))
+		return ctx.BadRequest(jerrors)
+	}
+
+	return application.Transactional(c.db, func(appl application.Application) error {
+		identity, err := appl.Identities().Load(ctx, id)
+		if err != nil || identity == nil {
+			log.Error(ctx, map[string]interface{}{
+				"identity_id": id,
+			}, "Auth token contains id %s of unknown Identity", id)
+			jerrors, _ := jsonapi.ErrorToJSONAPIErrors(ctx, goa.ErrUnauthorized(fmt.Sprintf("Auth token contains id %s of unknown Identity\n", id)))
+			return ctx.Unauthorized(jerrors)
+		}
+		if !identity.UserID.Valid {
+			return jsonapi.JSONErrorResponse(ctx, autherrors.NewNotFoundError("user", id.String()))
+		}
+		user, err := appl.Users().Load(ctx.Context, identity.UserID.UUID)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.Wrap(err, fmt.Sprintf("Can't load user with id %s", identity.UserID.UUID)))
+		}
+		namespace, found := user.ContextInformation[ctx.Namespace]
+		if !found || namespace == nil {
+			return jsonapi.JSONErrorResponse(ctx, autherrors.NewNotFoundError("context namespace", ctx.Namespace))
+		}
+		// the response has no media type so the conditional request methods are not generated for this context
+		eTag := app.GenerateEntityTag(*user)
+		ctx.ResponseData.Header().Set(app.LastModified, app.ToHTTPTime(user.GetLastModified()))
+		ctx.ResponseData.Header().Set(app.ETag, eTag)
+		ctx.ResponseData.Header().Set(app.CacheControl, c.config.GetCacheControlUser())
+		if ctx.IfNoneMatch != nil {
+			if *ctx.IfNoneMatch == eTag {
+				return ctx.NotModified()
+			}
+		} else if ctx.IfModifiedSince != nil {
+			ifModifiedSince, err := http.ParseTime(*ctx.IfModifiedSince)
+			if err == nil && !ifModifiedSince.UTC().Truncate(time.Second).Before(user.GetLastModified().UTC().Truncate(time.Second)) {
+				return ctx.NotModified()
+			}
+		}
+		body, err := json.Marshal(namespace)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, autherrors.NewInternalError(ctx, err))
+		}
+		return ctx.OK(body)
+	})
+}
+
+// ListIdentities returns the identities of the authorized user based on the provided Token
+func (c *UserController) ListIdentities(ctx *app.ListIdentitiesUserContext) error {
+	id, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, autherrors.NewUnauthorizedError(err.Error()))
+	}
+	var identities []account.Identity
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		identity, err := appl.Identities().Load(ctx, id)
+		if err != nil {
+			return autherrors.NewUnauthorizedError(fmt.Sprintf("auth token contains id %s of unknown identity", id))
+		}
+		if !identity.UserID.Valid {
+			identities = []account.Identity{*identity}
+			return nil
+		}
+		identities, err = appl.Identities().Query(account.IdentityFilterByUserID(identity.UserID.UUID), account.IdentityOrderBy("created_at"))
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.IdentityData, len(identities))
+	for i := range identities {
+		data[i] = convertIdentity(&identities[i])
+	}
+	return ctx.OK(&app.IdentityList{Data: data})
+}
+
+// UnlinkIdentity removes the identity of a linked account from the authorized user
+func (c *UserController) UnlinkIdentity(ctx *app.UnlinkIdentityUserContext) error {
+	id, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, autherrors.NewUnauthorizedError(err.Error()))
+	}
+	if err := login.UnlinkIdentity(ctx, c.db, c.config, id, ctx.IdentityID); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK([]byte{})
+}
+
+func convertIdentity(identity *account.Identity) *app.IdentityData {
+	id := identity.ID.String()
+	return &app.IdentityData{
+		ID:   &id,
+		Type: "identities",
+		Attributes: &app.IdentityDataAttributes{
+			Username:     &identity.Username,
+			ProviderType: &identity.ProviderType,
+			ProfileURL:   identity.ProfileURL,
+			CreatedAt:    &identity.CreatedAt,
+			UpdatedAt:    &identity.UpdatedAt,
+		},
+	}
+}
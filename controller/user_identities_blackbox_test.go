@@ -0,0 +1,124 @@
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/resource"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	testtoken "github.com/fabric8-services/fabric8-auth/test/token"
+	"github.com/fabric8-services/fabric8-auth/token/link"
+	"github.com/fabric8-services/fabric8-auth/token/provider"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestUserIdentitiesSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunUserIdentitiesSuite(t *testing.T) {
+	resource.Require(t, resource.Database)
+	suite.Run(t, &TestUserIdentitiesSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *TestUserIdentitiesSuite) SecuredController(identity account.Identity) (*goa.Service, *UserController) {
+	svc := testsupport.ServiceAsUser("User-Identities-Service", identity)
+	return svc, NewUserController(svc, s.Application, testtoken.TokenManager, s.Configuration)
+}
+
+func (s *TestUserIdentitiesSuite) createUser() account.User {
+	user := account.User{
+		Email:    "TestUserIdentities" + uuid.NewV4().String() + "@example.com",
+		FullName: "TestUserIdentities",
+		Cluster:  "https://api.starter-us-east-2.openshift.com",
+	}
+	require.Nil(s.T(), s.Application.Users().Create(s.Ctx, &user))
+	return user
+}
+
+func (s *TestUserIdentitiesSuite) createIdentity(user account.User, providerType string) account.Identity {
+	profileURL := "https://example.com/" + uuid.NewV4().String()
+	identity := account.Identity{
+		Username:     "TestUserIdentities" + uuid.NewV4().String(),
+		ProviderType: providerType,
+		ProfileURL:   &profileURL,
+		User:         user,
+		UserID:       account.NullUUID{UUID: user.ID, Valid: true},
+	}
+	require.Nil(s.T(), s.Application.Identities().Create(s.Ctx, &identity))
+	return identity
+}
+
+func (s *TestUserIdentitiesSuite) TestListIdentitiesOK() {
+	// given
+	user := s.createUser()
+	kcIdentity := s.createIdentity(user, account.KeycloakIDP)
+	githubIdentity := s.createIdentity(user, account.GitHubIDP)
+	s.createIdentity(s.createUser(), account.GitHubIDP)
+	svc, ctrl := s.SecuredController(kcIdentity)
+	// when
+	_, result := test.ListIdentitiesUserOK(s.T(), svc.Context, svc, ctrl)
+	// then
+	require.Len(s.T(), result.Data, 2)
+	assert.Equal(s.T(), kcIdentity.ID.String(), *result.Data[0].ID)
+	assert.Equal(s.T(), account.KeycloakIDP, *result.Data[0].Attributes.ProviderType)
+	assert.Equal(s.T(), githubIdentity.ID.String(), *result.Data[1].ID)
+	assert.Equal(s.T(), account.GitHubIDP, *result.Data[1].Attributes.ProviderType)
+	assert.Equal(s.T(), githubIdentity.Username, *result.Data[1].Attributes.Username)
+	assert.Equal(s.T(), *githubIdentity.ProfileURL, *result.Data[1].Attributes.ProfileURL)
+}
+
+func (s *TestUserIdentitiesSuite) TestUnlinkIdentityOK() {
+	// given
+	user := s.createUser()
+	kcIdentity := s.createIdentity(user, account.KeycloakIDP)
+	githubIdentity := s.createIdentity(user, account.GitHubIDP)
+	providerID, err := uuid.FromString(link.GitHubProviderID)
+	require.Nil(s.T(), err)
+	externalToken := provider.ExternalToken{
+		ProviderID: providerID,
+		Token:      "1234-github",
+		Scope:      "user:full",
+		Username:   githubIdentity.Username,
+		IdentityID: kcIdentity.ID,
+	}
+	require.Nil(s.T(), s.Application.ExternalTokens().Create(s.Ctx, &externalToken))
+	svc, ctrl := s.SecuredController(kcIdentity)
+	// when
+	test.UnlinkIdentityUserOK(s.T(), svc.Context, svc, ctrl, githubIdentity.ID)
+	// then
+	_, err = s.Application.Identities().Load(s.Ctx, githubIdentity.ID)
+	assert.NotNil(s.T(), err)
+	tokens, err := s.Application.ExternalTokens().LoadByProviderIDAndIdentityID(s.Ctx, providerID, kcIdentity.ID)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), tokens)
+	_, result := test.ListIdentitiesUserOK(s.T(), svc.Context, svc, ctrl)
+	require.Len(s.T(), result.Data, 1)
+	assert.Equal(s.T(), kcIdentity.ID.String(), *result.Data[0].ID)
+}
+
+func (s *TestUserIdentitiesSuite) TestUnlinkIdentityFails() {
+	// given
+	user := s.createUser()
+	kcIdentity := s.createIdentity(user, account.KeycloakIDP)
+	otherIdentity := s.createIdentity(s.createUser(), account.GitHubIDP)
+	svc, ctrl := s.SecuredController(kcIdentity)
+
+	// the Keycloak identity can't be unlinked
+	test.UnlinkIdentityUserBadRequest(s.T(), svc.Context, svc, ctrl, kcIdentity.ID)
+	// identity of another user
+	test.UnlinkIdentityUserNotFound(s.T(), svc.Context, svc, ctrl, otherIdentity.ID)
+	// unknown identity
+	test.UnlinkIdentityUserNotFound(s.T(), svc.Context, svc, ctrl, uuid.NewV4())
+
+	_, err := s.Application.Identities().Load(s.Ctx, otherIdentity.ID)
+	assert.Nil(s.T(), err)
+}
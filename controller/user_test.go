@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -12,6 +13,8 @@ import (
 	"github.com/fabric8-services/fabric8-auth/app/test"
 	"github.com/fabric8-services/fabric8-auth/application"
 	"github.com/fabric8-services/fabric8-auth/auth"
+	authresource "github.com/fabric8-services/fabric8-auth/authorization/resource"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
 	"github.com/fabric8-services/fabric8-auth/configuration"
 	. "github.com/fabric8-services/fabric8-auth/controller"
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
@@ -136,6 +139,28 @@ func (rest *TestUserREST) TestCurrentAuthorizedNotModifiedUsingIfNoneMatchHeader
 	rest.assertResponseHeaders(res, usr)
 }
 
+func (rest *TestUserREST) TestShowContextOK() {
+	// given
+	ctx, _, usr, ident := rest.initTestCurrentAuthorized()
+	usr.ContextInformation = account.ContextInformation{
+		"fabric8-ui": map[string]interface{}{"theme": "dark"},
+		"count":      1,
+	}
+	userCtrl := rest.newUserController(&ident, &usr)
+	// when
+	res := test.ShowContextUserOK(rest.T(), ctx, nil, userCtrl, "fabric8-ui", nil, nil)
+	// then
+	assert.JSONEq(rest.T(), `{"theme": "dark"}`, res.(*httptest.ResponseRecorder).Body.String())
+	rest.assertResponseHeaders(res, usr)
+}
+
+func (rest *TestUserREST) TestShowContextNotFound() {
+	// given
+	ctx, userCtrl, _, _ := rest.initTestCurrentAuthorized()
+	// when/then
+	test.ShowContextUserNotFound(rest.T(), ctx, nil, userCtrl, "fabric8-ui", nil, nil)
+}
+
 func (rest *TestUserREST) initTestCurrentAuthorized() (context.Context, app.UserController, account.User, account.Identity) {
 	jwtToken := token.New(token.SigningMethodRS256)
 	jwtToken.Claims.(token.MapClaims)["sub"] = uuid.NewV4().String()
@@ -202,13 +227,18 @@ func (m TestIdentityRepository) Create(ctx context.Context, model *account.Ident
 	return nil
 }
 
-// Lookup looks up a record or creates a new one.
-func (m TestIdentityRepository) Lookup(ctx context.Context, username, profileURL, providerType string) (*account.Identity, error) {
+// Lookup returns the identity linked to the given account
+func (m TestIdentityRepository) Lookup(ctx context.Context, providerType string, username string) (*account.Identity, error) {
 	return nil, nil
 }
 
-// Lookup looks up a record or creates a new one.
-func (m TestIdentityRepository) Search(ctx context.Context, q string, start int, limit int) ([]account.Identity, int, error) {
+// Count returns the number of matching records.
+func (m TestIdentityRepository) Count(funcs ...func(*gorm.DB) *gorm.DB) (int, error) {
+	return 0, nil
+}
+
+// Search searches for the identities matching the query.
+func (m TestIdentityRepository) Search(ctx context.Context, q string, filter account.IdentitySearchFilter, start int, limit int) ([]account.IdentitySearchResult, int, error) {
 	return nil, 0, nil
 }
 
@@ -277,6 +307,14 @@ func (m TestUserRepository) List(ctx context.Context) ([]account.User, error) {
 	return []account.User{*m.User}, nil
 }
 
+// CountByCluster returns the number of users by cluster
+func (m TestUserRepository) CountByCluster(ctx context.Context) (map[string]int, error) {
+	if m.User == nil {
+		return map[string]int{}, nil
+	}
+	return map[string]int{m.User.Cluster: 1}, nil
+}
+
 // Query expose an open ended Query model
 func (m TestUserRepository) Query(funcs ...func(*gorm.DB) *gorm.DB) ([]account.User, error) {
 	return []account.User{*m.User}, nil
@@ -309,6 +347,82 @@ func (g *GormTestBase) ExternalTokens() provider.ExternalTokenRepository {
 	return nil
 }
 
+func (g *GormTestBase) DeviceCodes() auth.DeviceCodeRepository {
+	return nil
+}
+
+func (g *GormTestBase) RevokedSessions() auth.RevokedSessionRepository {
+	return nil
+}
+
+func (g *GormTestBase) RefreshTokens() auth.RefreshTokenRepository {
+	return nil
+}
+
+func (g *GormTestBase) OauthClients() auth.OauthClientRepository {
+	return nil
+}
+
+func (g *GormTestBase) PendingApprovals() account.PendingApprovalRepository {
+	return nil
+}
+
+func (g *GormTestBase) IdpMappings() auth.IdpMappingRepository {
+	return nil
+}
+
+func (g *GormTestBase) UsernameReservations() account.UsernameReservationRepository {
+	return nil
+}
+
+func (g *GormTestBase) UserErasures() account.UserErasureRepository {
+	return nil
+}
+
+func (g *GormTestBase) UserExports() account.UserExportRepository {
+	return nil
+}
+
+func (g *GormTestBase) UserMerges() account.UserMergeRepository {
+	return nil
+}
+
+func (g *GormTestBase) UserProfileHistory() account.UserProfileHistoryRepository {
+	return nil
+}
+
+func (g *GormTestBase) IdentityRoles() role.IdentityRoleRepository {
+	return nil
+}
+
+func (g *GormTestBase) Resources() authresource.ResourceRepository {
+	return nil
+}
+
+func (g *GormTestBase) ResourceTypes() authresource.ResourceTypeRepository {
+	return nil
+}
+
+func (g *GormTestBase) ResourceTypeScopes() authresource.ResourceTypeScopeRepository {
+	return nil
+}
+
+func (g *GormTestBase) Roles() role.RoleRepository {
+	return nil
+}
+
+func (g *GormTestBase) Organizations() account.OrganizationRepository {
+	return nil
+}
+
+func (g *GormTestBase) Teams() account.TeamRepository {
+	return nil
+}
+
+func (g *GormTestBase) TeamRoles() role.TeamRoleRepository {
+	return nil
+}
+
 func (g *GormTestBase) DB() *gorm.DB {
 	return nil
 }
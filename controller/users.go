@@ -3,6 +3,8 @@ package controller
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,10 +36,20 @@ type UsersController struct {
 	userProfileService  login.UserProfileService
 	RemoteWITService    wit.RemoteWITService
 	keycloakLinkService linkAPI.KeycloakIDPService
+	StepUp              StepUpChecker
+}
+
+// StepUpChecker checks that the token of the request meets the step-up authentication requirement of an action
+type StepUpChecker interface {
+	Check(ctx context.Context, action string) error
 }
 
 // UsersControllerConfiguration the Configuration for the UsersController
 type UsersControllerConfiguration interface {
+	account.UserPolicyConfiguration
+	account.ContextInformationConfiguration
+	login.ClusterConfiguration
+	AdminConfiguration
 	GetCacheControlUsers() string
 	GetCacheControlUser() string
 	GetKeycloakAccountEndpoint(*goa.RequestData) (string, error)
@@ -69,6 +81,14 @@ func (c *UsersController) Show(ctx *app.ShowUsersContext) error {
 			return jsonapi.JSONErrorResponse(ctx, errs.Wrap(errors.NewBadParameterError("identity_id", ctx.ID), err.Error()))
 		}
 		identity, err := appl.Identities().Load(ctx.Context, identityID)
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			// the identity may have been merged into another user
+			merge, mergeErr := appl.UserMerges().LoadBySourceIdentityID(ctx, identityID)
+			if mergeErr == nil {
+				ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.UsersHref(merge.TargetIdentityID)))
+				return ctx.MovedPermanently()
+			}
+		}
 		if err != nil {
 			jerrors, httpStatusCode := jsonapi.ErrorToJSONAPIErrors(ctx, err)
 			return ctx.ResponseData.Service.Send(ctx.Context, httpStatusCode, jerrors)
@@ -96,6 +116,30 @@ func (c *UsersController) Create(ctx *app.CreateUsersContext) error {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("account not authorized to create users."))
 	}
 
+	policy, err := account.NewUserPolicy(c.config)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	if err := c.validateNewUser(ctx, policy); err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"username": ctx.Payload.Data.Attributes.Username,
+		}, "the new user doesn't comply with the user policy")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	var contextInformation account.ContextInformation
+	if ctx.Payload.Data.Attributes.ContextInformation != nil {
+		contextPolicy, err := c.newContextInformationPolicy(ctx)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+		clientID, _ := token.ClientID(ctx)
+		contextInformation, err = contextPolicy.Apply(clientID, account.ContextInformation{}, ctx.Payload.Data.Attributes.ContextInformation)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+	}
+
 	tokenEndpoint, err := c.config.GetKeycloakEndpointToken(ctx.RequestData)
 	if err != nil {
 		return errors.NewInternalError(ctx, err)
@@ -135,7 +179,7 @@ func (c *UsersController) Create(ctx *app.CreateUsersContext) error {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
 	}
 
-	identity, user, err := c.createUserInDB(ctx, identityID)
+	identity, user, err := c.createUserInDB(ctx, identityID, contextInformation)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"err":              err,
@@ -168,6 +212,35 @@ func (c *UsersController) Create(ctx *app.CreateUsersContext) error {
 	return ctx.OK(ConvertToAppUser(ctx.RequestData, user, identity))
 }
 
+// validateNewUser checks the username and the email of the user to create against the user policy
+// and makes sure they are not used by another user
+func (c *UsersController) validateNewUser(ctx *app.CreateUsersContext, policy *account.UserPolicy) error {
+	attributes := ctx.Payload.Data.Attributes
+	if err := policy.ValidateUsername(attributes.Username); err != nil {
+		return err
+	}
+	if err := policy.ValidateEmail(attributes.Email); err != nil {
+		return err
+	}
+	return application.Transactional(c.db, func(appl application.Application) error {
+		isUnique, err := isUsernameUnique(appl, policy, attributes.Username, account.Identity{})
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		if !isUnique {
+			return errors.NewValidationError("username", attributes.Username, account.RuleUnique, fmt.Sprintf("username : %s is already in use", attributes.Username))
+		}
+		isUnique, err = isEmailUnique(appl, policy, attributes.Email, account.User{})
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		if !isUnique {
+			return errors.NewValidationError("email", attributes.Email, account.RuleUnique, fmt.Sprintf("email : %s is already in use", attributes.Email))
+		}
+		return nil
+	})
+}
+
 func (c *UsersController) linkUserToRHD(ctx *app.CreateUsersContext, identityID string, rhdUsername string, protectedAccessToken string) error {
 	idpName := "rhd"
 	linkRequest := linkAPI.KeycloakLinkIDPRequest{
@@ -250,7 +323,7 @@ func (c *UsersController) createUserInKeycloak(ctx *app.CreateUsersContext, prot
 	return &identityID, nil
 }
 
-func (c *UsersController) createUserInDB(ctx *app.CreateUsersContext, identityID uuid.UUID) (*account.Identity, *account.User, error) {
+func (c *UsersController) createUserInDB(ctx *app.CreateUsersContext, identityID uuid.UUID, contextInformation account.ContextInformation) (*account.Identity, *account.User, error) {
 
 	userID := uuid.NewV4()
 	var err error
@@ -259,12 +332,11 @@ func (c *UsersController) createUserInDB(ctx *app.CreateUsersContext, identityID
 	var identity *account.Identity
 
 	// Mandatory attributes
-	// "username", "email", "cluster"
+	// "username", "email"
 
 	user = &account.User{
-		ID:      userID,
-		Email:   ctx.Payload.Data.Attributes.Email,
-		Cluster: ctx.Payload.Data.Attributes.Cluster,
+		ID:    userID,
+		Email: ctx.Payload.Data.Attributes.Email,
 	}
 	identity = &account.Identity{
 		ID:           identityID,
@@ -307,17 +379,26 @@ func (c *UsersController) createUserInDB(ctx *app.CreateUsersContext, identityID
 		user.URL = *url
 	}
 
-	contextInformation := ctx.Payload.Data.Attributes.ContextInformation
 	if contextInformation != nil {
-		if user.ContextInformation == nil {
-			user.ContextInformation = account.ContextInformation{}
-		}
-		for fieldName, fieldValue := range contextInformation {
-			user.ContextInformation[fieldName] = fieldValue
-		}
+		user.ContextInformation = contextInformation
+	}
+
+	cluster := ctx.Payload.Data.Attributes.Cluster
+	if cluster != nil {
+		user.Cluster = *cluster
 	}
 
 	returnErrorResponse := application.Transactional(c.db, func(appl application.Application) error {
+		if user.Cluster == "" {
+			region := ""
+			if ctx.Payload.Data.Attributes.Region != nil {
+				region = *ctx.Payload.Data.Attributes.Region
+			}
+			user.Cluster, err = login.NewClusterService(c.db, c.config).Allocate(ctx, appl, region)
+			if err != nil {
+				return err
+			}
+		}
 		err = appl.Users().Create(ctx, user)
 		if err != nil {
 			return err
@@ -416,6 +497,30 @@ func (c *UsersController) getKeycloakProfileInformation(ctx context.Context, tok
 	return response, err
 }
 
+// newContextInformationPolicy creates the policy protecting the namespaces of the user context information
+// of the configured clients and of the registered OAuth clients
+func (c *UsersController) newContextInformationPolicy(ctx context.Context) (*account.ContextInformationPolicy, error) {
+	var clientIDs []string
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		clients, err := appl.OauthClients().List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, client := range clients {
+			clientIDs = append(clientIDs, client.ClientID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	policy, err := account.NewContextInformationPolicy(c.config, clientIDs...)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return policy, nil
+}
+
 // Update updates the authorized user based on the provided Token
 func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 
@@ -423,6 +528,14 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
 	}
+	policy, err := account.NewUserPolicy(c.config)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	contextPolicy, err := c.newContextInformationPolicy(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 
 	keycloakUserProfile := &login.KeycloakUserProfile{}
 	keycloakUserProfile.Attributes = &login.KeycloakUserProfileAttributes{}
@@ -446,21 +559,29 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 			if err != nil {
 				return errs.Wrap(err, fmt.Sprintf("Can't load user with id %s", identity.UserID.UUID))
 			}
+			// reject the update if the user changed since the client retrieved it
+			if err := ctx.CheckIfMatch(*user); err != nil {
+				return err
+			}
 		}
 
 		updatedEmail := ctx.Payload.Data.Attributes.Email
 		if updatedEmail != nil && *updatedEmail != user.Email {
-			isValid := isEmailValid(*updatedEmail)
-			if !isValid {
-				return errors.NewBadParameterError("email", *updatedEmail).Expected("valid email")
+			if c.StepUp != nil {
+				// Changing the email requires a recent authentication
+				if err := c.StepUp.Check(ctx, "users.update.email"); err != nil {
+					return err
+				}
 			}
-			isUnique, err := isEmailUnique(appl, *updatedEmail, *user)
+			if err := policy.ValidateEmail(*updatedEmail); err != nil {
+				return err
+			}
+			isUnique, err := isEmailUnique(appl, policy, *updatedEmail, *user)
 			if err != nil {
 				return errs.Wrap(err, fmt.Sprintf("error updating identitity with id %s and user with id %s", identity.ID, identity.UserID.UUID))
 			}
 			if !isUnique {
-				// TODO: Add errors.NewConflictError(..)
-				return errs.Wrap(errors.NewBadParameterError("email", *updatedEmail).Expected("unique email"), fmt.Sprintf("email : %s is already in use", *updatedEmail))
+				return errors.NewValidationError("email", *updatedEmail, account.RuleUnique, fmt.Sprintf("email : %s is already in use", *updatedEmail))
 			}
 			user.Email = *updatedEmail
 			isKeycloakUserProfileUpdateNeeded = true
@@ -469,20 +590,18 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 
 		updatedUserName := ctx.Payload.Data.Attributes.Username
 		if updatedUserName != nil && *updatedUserName != identity.Username {
-			isValid := isUsernameValid(*updatedUserName)
-			if !isValid {
-				return errs.Wrap(errors.NewBadParameterError("username", "required"), fmt.Sprintf("invalid value assigned to username for identity with id %s and user with id %s", identity.ID, identity.UserID.UUID))
+			if err := policy.ValidateUsername(*updatedUserName); err != nil {
+				return errs.Wrap(err, fmt.Sprintf("invalid value assigned to username for identity with id %s and user with id %s", identity.ID, identity.UserID.UUID))
 			}
 			if identity.RegistrationCompleted {
 				return errors.NewForbiddenError(fmt.Sprintf("username cannot be updated more than once for identity id %s ", *id))
 			}
-			isUnique, err := isUsernameUnique(appl, *updatedUserName, *identity)
+			isUnique, err := isUsernameUnique(appl, policy, *updatedUserName, *identity)
 			if err != nil {
 				return errs.Wrap(err, fmt.Sprintf("error updating identitity with id %s and user with id %s", identity.ID, identity.UserID.UUID))
 			}
 			if !isUnique {
-				// TODO : Add errors.NewConflictError(..)
-				return errs.Wrap(errors.NewBadParameterError("username", *updatedUserName).Expected("unique username"), fmt.Sprintf("username : %s is already in use", *updatedUserName))
+				return errors.NewValidationError("username", *updatedUserName, account.RuleUnique, fmt.Sprintf("username : %s is already in use", *updatedUserName))
 			}
 			identity.Username = *updatedUserName
 			isKeycloakUserProfileUpdateNeeded = true
@@ -555,15 +674,11 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 
 		updatedContextInformation := ctx.Payload.Data.Attributes.ContextInformation
 		if updatedContextInformation != nil {
-			// if user.ContextInformation , we get to PATCH the ContextInformation field,
-			// instead of over-writing it altogether. Note: The PATCH-ing is only for the
-			// 1st level of JSON.
-			if user.ContextInformation == nil {
-				user.ContextInformation = make(map[string]interface{})
-			}
-			for fieldName, fieldValue := range updatedContextInformation {
-				// Save it as is, for short-term.
-				user.ContextInformation[fieldName] = fieldValue
+			// the ContextInformation field is a JSON merge patch (RFC 7396) of the existing context information
+			clientID, _ := token.ClientID(ctx)
+			user.ContextInformation, err = contextPolicy.Apply(clientID, user.ContextInformation, updatedContextInformation)
+			if err != nil {
+				return err
 			}
 		}
 
@@ -629,6 +744,7 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 		// Let's not disrupt the response if there was an issue with updating WIT.
 	}
 
+	ctx.ResponseData.Header().Set(app.ETag, app.GenerateEntityTag(*user))
 	return ctx.OK(ConvertToAppUser(ctx.RequestData, user, identity))
 }
 
@@ -656,23 +772,8 @@ func (c *UsersController) updateWITUser(ctx *app.UpdateUsersContext, request *go
 	return c.RemoteWITService.UpdateWITUser(ctx, request, updateUserPayload, witURL, identityID)
 }
 
-func isEmailValid(email string) bool {
-	// TODO: Add regex to verify email format, later
-	if len(strings.TrimSpace(email)) > 0 {
-		return true
-	}
-	return false
-}
-
-func isUsernameValid(username string) bool {
-	if len(strings.TrimSpace(username)) > 0 {
-		return true
-	}
-	return false
-}
-
-func isUsernameUnique(appl application.Application, username string, identity account.Identity) (bool, error) {
-	usersWithSameUserName, err := appl.Identities().Query(account.IdentityFilterByUsername(username), account.IdentityFilterByProviderType(account.KeycloakIDP))
+func isUsernameUnique(appl application.Application, policy *account.UserPolicy, username string, identity account.Identity) (bool, error) {
+	usersWithSameUserName, err := appl.Identities().Query(policy.UsernameFilter(username), account.IdentityFilterByProviderType(account.KeycloakIDP))
 	if err != nil {
 		log.Error(nil, map[string]interface{}{
 			"user_name": username,
@@ -688,8 +789,8 @@ func isUsernameUnique(appl application.Application, username string, identity ac
 	return true, nil
 }
 
-func isEmailUnique(appl application.Application, email string, user account.User) (bool, error) {
-	usersWithSameEmail, err := appl.Users().Query(account.UserFilterByEmail(email))
+func isEmailUnique(appl application.Application, policy *account.UserPolicy, email string, user account.User) (bool, error) {
+	usersWithSameEmail, err := appl.Users().Query(policy.EmailFilter(email))
 	if err != nil {
 		log.Error(nil, map[string]interface{}{
 			"email": email,
@@ -707,78 +808,282 @@ func isEmailUnique(appl application.Application, email string, user account.User
 
 // List runs the list action.
 func (c *UsersController) List(ctx *app.ListUsersContext) error {
-	return application.Transactional(c.db, func(appl application.Application) error {
-		users, identities, err := filterUsers(appl, ctx)
+	// Only the admin service accounts can enumerate the users, anybody else can only look a user up by username, email, GitHub login or ID
+	isAdmin := token.IsSpecificServiceAccount(ctx, c.config.GetAdminServiceAccounts())
+	if !isAdmin && (ctx.FilterProviderType != nil || ctx.FilterCluster != nil || ctx.FilterCreatedAfter != nil || ctx.FilterCreatedBefore != nil ||
+		ctx.FilterApproval != nil || ctx.FilterRegistrationCompleted != nil || ctx.Sort != nil) {
+		if err := checkAdmin(ctx, c.config); err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+	}
+	orders, err := userListOrder(ctx.Sort)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	offset, limit := computePagingLimits(ctx.PageOffset, ctx.PageLimit)
+	var ids []uuid.UUID
+	if ctx.FilterID != nil {
+		ids, err = parseIdentityIDs(*ctx.FilterID)
 		if err != nil {
 			return jsonapi.JSONErrorResponse(ctx, err)
 		}
+		// all the looked up users are returned at once
+		offset, limit = 0, len(ids)
+	}
+	return application.Transactional(c.db, func(appl application.Application) error {
+		users := []account.User{}
+		identities := []account.Identity{}
+		var count int
+		if isAdmin || ctx.FilterUsername != nil || ctx.FilterEmail != nil || ctx.FilterGithub != nil || ids != nil {
+			users, identities, count, err = filterUsers(appl, ctx, ids, orders, offset, limit)
+			if err != nil {
+				return jsonapi.JSONErrorResponse(ctx, err)
+			}
+		}
 		return ctx.ConditionalEntities(users, c.config.GetCacheControlUsers, func() error {
 			appUsers := make([]*app.UserData, len(users))
 			for i := range users {
 				appUser := ConvertToAppUser(ctx.RequestData, &users[i], &identities[i])
 				appUsers[i] = appUser.Data
 			}
-			return ctx.OK(&app.UserArray{Data: appUsers})
+			response := app.UserArray{
+				Data:  appUsers,
+				Links: &app.PagingLinks{},
+				Meta:  &app.UserListMeta{TotalCount: count},
+			}
+			if ids != nil {
+				response.Meta.UnknownIDs = unknownIdentityIDs(ids, identities)
+			}
+			setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(users), offset, limit, count, userListQuery(ctx)...)
+			return ctx.OK(&response)
 		})
 	})
 }
 
-func filterUsers(appl application.Application, ctx *app.ListUsersContext) ([]account.User, []account.Identity, error) {
-	var err error
-	var resultUsers []account.User
-	var resultIdentities []account.Identity
-	/*
-		There are 2 database tables we fetch the data from : identities , users
-		First, we filter on the attributes of identities table - providerType , username
-		After that we use the above result to cumulatively filter on users  - email , company
-	*/
-	identityFilters := []func(*gorm.DB) *gorm.DB{}
-	userFilters := []func(*gorm.DB) *gorm.DB{}
-	/*** Start filtering on Identities table ****/
-	if ctx.FilterUsername != nil {
-		identityFilters = append(identityFilters, account.IdentityFilterByUsername(*ctx.FilterUsername))
-	}
-	// Add more filters when needed , here. ..
-	if len(identityFilters) != 0 {
-		identityFilters = append(identityFilters, account.IdentityFilterByProviderType(account.KeycloakIDP))
-		identityFilters = append(identityFilters, account.IdentityWithUser())
-		// From a data model perspective, we are querying by identity ( and not user )
-		filteredIdentities, err := appl.Identities().Query(identityFilters...)
+// History runs the history action.
+func (c *UsersController) History(ctx *app.HistoryUsersContext) error {
+	if err := checkAdmin(ctx, c.config); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	offset, limit := computePagingLimits(ctx.PageOffset, ctx.PageLimit)
+	var changes []account.UserProfileChange
+	var count int
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		identity, err := appl.Identities().Load(ctx, ctx.ID)
 		if err != nil {
-			return nil, nil, errs.Wrap(err, "error fetching identities with filter(s)")
-		}
-		// cumulatively filter out those not matching the user-based filters.
-		for _, identity := range filteredIdentities {
-			// this is where you keep trying all other filters one by one for 'user' fields like email.
-			if ctx.FilterEmail == nil || identity.User.Email == *ctx.FilterEmail {
-				resultUsers = append(resultUsers, identity.User)
-				resultIdentities = append(resultIdentities, identity)
-			}
+			return err
 		}
-	} else {
-		var filteredUsers []account.User
-		/*** Start filtering on Users table ****/
-		if ctx.FilterEmail != nil {
-			userFilters = append(userFilters, account.UserFilterByEmail(*ctx.FilterEmail))
+		if !identity.UserID.Valid {
+			return errors.NewNotFoundError("user", ctx.ID.String())
 		}
-		// .. Add other filters in future when needed into the userFilters slice in the above manner.
-		if len(userFilters) != 0 {
-			filteredUsers, err = appl.Users().Query(userFilters...)
-		} else {
-			// Soft-kill the API for listing all Users /api/users
-			resultUsers = []account.User{}
-			resultIdentities = []account.Identity{}
-			return resultUsers, resultIdentities, nil
+		changes, count, err = appl.UserProfileHistory().List(ctx, identity.UserID.UUID, offset, limit)
+		return err
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.UserProfileChangeData, len(changes))
+	for i := range changes {
+		data[i] = convertUserProfileChange(changes[i])
+	}
+	response := app.UserProfileHistoryList{
+		Data:  data,
+		Links: &app.PagingLinks{},
+		Meta:  &app.UserProfileHistoryMeta{TotalCount: count},
+	}
+	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(changes), offset, limit, count)
+	return ctx.OK(&response)
+}
+
+func convertUserProfileChange(change account.UserProfileChange) *app.UserProfileChangeData {
+	attributes := &app.UserProfileChangeDataAttributes{
+		Field:     change.Field,
+		OldValue:  change.OldValue,
+		NewValue:  change.NewValue,
+		CreatedAt: change.CreatedAt,
+	}
+	if change.IdentityID.Valid {
+		identityID := change.IdentityID.UUID.String()
+		attributes.IdentityID = &identityID
+	}
+	if change.Actor != "" {
+		attributes.Actor = &change.Actor
+	}
+	if change.RequestID != "" {
+		attributes.RequestID = &change.RequestID
+	}
+	return &app.UserProfileChangeData{
+		ID:         change.ID.String(),
+		Type:       "user_profile_changes",
+		Attributes: attributes,
+	}
+}
+
+// parseIdentityIDs parses the comma separated list of identity IDs to look up
+// returns BadParameterError if the list is empty, too long or contains an invalid ID
+func parseIdentityIDs(param string) ([]uuid.UUID, error) {
+	values := strings.Split(param, ",")
+	if len(values) > pageSizeMax {
+		return nil, errors.NewBadParameterError("filter[id]", param).Expected(fmt.Sprintf("at most %d IDs", pageSizeMax))
+	}
+	var ids []uuid.UUID
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
 		}
+		id, err := uuid.FromString(value)
 		if err != nil {
-			return nil, nil, errs.Wrap(err, "error fetching users")
+			return nil, errors.NewBadParameterError("filter[id]", value).Expected("uuid")
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, errors.NewBadParameterError("filter[id]", param).Expected("at least one ID")
+	}
+	return ids, nil
+}
+
+// unknownIdentityIDs returns the looked up IDs which are not among the given identities
+func unknownIdentityIDs(ids []uuid.UUID, identities []account.Identity) []string {
+	found := make(map[uuid.UUID]bool, len(identities))
+	for _, identity := range identities {
+		found[identity.ID] = true
+	}
+	unknown := []string{}
+	for _, id := range ids {
+		if !found[id] {
+			unknown = append(unknown, id.String())
+			found[id] = true
+		}
+	}
+	return unknown
+}
+
+// userSortColumns maps the attributes the users can be sorted by to their column
+var userSortColumns = map[string]string{
+	"created_at": "users.created_at",
+	"username":   "identities.username",
+	"email":      "users.email",
+	"full_name":  "users.full_name",
+	"cluster":    "users.cluster",
+}
+
+// userListOrder converts the sort parameter of the user list into ORDER BY clauses
+// returns BadParameterError if an attribute can't be sorted by
+func userListOrder(sort *string) ([]string, error) {
+	attributes := []string{"created_at"}
+	if sort != nil {
+		attributes = strings.Split(*sort, ",")
+	}
+	var orders []string
+	for _, attribute := range attributes {
+		attribute = strings.TrimSpace(attribute)
+		direction := "asc"
+		if strings.HasPrefix(attribute, "-") {
+			direction = "desc"
+			attribute = strings.TrimPrefix(attribute, "-")
+		}
+		column, ok := userSortColumns[attribute]
+		if !ok {
+			return nil, errors.NewBadParameterError("sort", *sort)
 		}
-		resultUsers, resultIdentities, err = LoadKeyCloakIdentities(appl, filteredUsers)
+		orders = append(orders, column+" "+direction)
+	}
+	// keeps the order of the users with the same values stable from one page to another
+	return append(orders, "identities.id"), nil
+}
+
+// userListQuery returns the query parameters to repeat in the paging links of the user list
+func userListQuery(ctx *app.ListUsersContext) []string {
+	var query []string
+	addParam := func(name string, value *string) {
+		if value != nil {
+			query = append(query, name+"="+url.QueryEscape(*value))
+		}
+	}
+	addDateParam := func(name string, value *time.Time) {
+		if value != nil {
+			query = append(query, name+"="+url.QueryEscape(value.Format(time.RFC3339)))
+		}
+	}
+	addParam("filter[username]", ctx.FilterUsername)
+	addParam("filter[email]", ctx.FilterEmail)
+	addParam("filter[github]", ctx.FilterGithub)
+	addParam("filter[id]", ctx.FilterID)
+	addParam("filter[provider_type]", ctx.FilterProviderType)
+	addParam("filter[cluster]", ctx.FilterCluster)
+	addDateParam("filter[created_after]", ctx.FilterCreatedAfter)
+	addDateParam("filter[created_before]", ctx.FilterCreatedBefore)
+	addParam("filter[approval]", ctx.FilterApproval)
+	if ctx.FilterRegistrationCompleted != nil {
+		query = append(query, "filter[registration_completed]="+strconv.FormatBool(*ctx.FilterRegistrationCompleted))
+	}
+	addParam("sort", ctx.Sort)
+	return query
+}
+
+// filterUsers returns the page of users matching all the filters of the request along with their identity, and the total count.
+// Unless identity IDs are looked up, each user is returned with its identity of the requested provider type (Keycloak by default)
+// and users without such identity are ignored.
+func filterUsers(appl application.Application, ctx *app.ListUsersContext, ids []uuid.UUID, orders []string, offset, limit int) ([]account.User, []account.Identity, int, error) {
+	filters := []func(*gorm.DB) *gorm.DB{
+		account.IdentityJoinUser(),
+	}
+	if ctx.FilterProviderType != nil {
+		filters = append(filters, account.IdentityFilterByProviderType(*ctx.FilterProviderType))
+	} else if ids == nil {
+		filters = append(filters, account.IdentityFilterByProviderType(account.KeycloakIDP))
+	}
+	if ids != nil {
+		filters = append(filters, account.IdentityFilterByIDs(ids))
+	}
+	if ctx.FilterUsername != nil {
+		filters = append(filters, account.IdentityFilterByUsername(*ctx.FilterUsername))
+	}
+	if ctx.FilterEmail != nil {
+		filters = append(filters, account.IdentityFilterByUserEmail(*ctx.FilterEmail))
+	}
+	if ctx.FilterGithub != nil {
+		// the users are looked up by the GitHub account linked to them
+		github, err := appl.Identities().Lookup(ctx, account.GitHubIDP, *ctx.FilterGithub)
 		if err != nil {
-			return nil, nil, errs.Wrap(err, "error fetching keycloak identities")
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return []account.User{}, []account.Identity{}, 0, nil
+			}
+			return nil, nil, 0, err
 		}
+		filters = append(filters, account.IdentityFilterByUserID(github.UserID.UUID))
 	}
-	return resultUsers, resultIdentities, nil
+	if ctx.FilterCluster != nil {
+		filters = append(filters, account.IdentityFilterByUserCluster(*ctx.FilterCluster))
+	}
+	if ctx.FilterCreatedAfter != nil {
+		filters = append(filters, account.IdentityFilterByUserCreatedAfter(*ctx.FilterCreatedAfter))
+	}
+	if ctx.FilterCreatedBefore != nil {
+		filters = append(filters, account.IdentityFilterByUserCreatedBefore(*ctx.FilterCreatedBefore))
+	}
+	if ctx.FilterApproval != nil {
+		filters = append(filters, account.IdentityFilterByApprovalStatus(*ctx.FilterApproval))
+	}
+	if ctx.FilterRegistrationCompleted != nil {
+		filters = append(filters, account.IdentityFilterByRegistrationCompleted(*ctx.FilterRegistrationCompleted))
+	}
+
+	count, err := appl.Identities().Count(filters...)
+	if err != nil {
+		return nil, nil, 0, errs.Wrap(err, "error counting identities with filter(s)")
+	}
+	filters = append(filters, account.IdentityWithUser(), account.IdentityOrderBy(orders...), account.IdentityPage(offset, limit))
+	identities, err := appl.Identities().Query(filters...)
+	if err != nil {
+		return nil, nil, 0, errs.Wrap(err, "error fetching identities with filter(s)")
+	}
+	users := make([]account.User, len(identities))
+	for i, identity := range identities {
+		users[i] = identity.User
+	}
+	return users, identities, count, nil
 }
 
 // LoadKeyCloakIdentities loads keycloak identities for the users and returns the valid users along with their KC identities
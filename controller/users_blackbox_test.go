@@ -4,22 +4,28 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/auth"
 	. "github.com/fabric8-services/fabric8-auth/controller"
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
 	"github.com/fabric8-services/fabric8-auth/log"
 	"github.com/fabric8-services/fabric8-auth/login"
 	"github.com/fabric8-services/fabric8-auth/login/link"
 	"github.com/fabric8-services/fabric8-auth/resource"
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	testtoken "github.com/fabric8-services/fabric8-auth/test/token"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
 	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -98,7 +104,7 @@ func (s *TestUsersSuite) TestUpdateUserOK() {
 	}
 	//secureController, secureService := createSecureController(t, identity)
 	updateUsersPayload := createUpdateUsersPayload(&newEmail, &newFullName, &newBio, &newImageURL, &newProfileURL, &newCompany, nil, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 
 	// then
 	require.NotNil(s.T(), result)
@@ -138,16 +144,16 @@ func (s *TestUsersSuite) TestUpdateUserNameMulitpleTimesForbidden() {
 	// you can update username multiple times.
 	// also omit registrationCompleted
 	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &newUserName, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 
 	boolTrue := true
 	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &newUserName, &boolTrue, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 
 	// next attempt should fail.
 	newUserName = identity.Username + uuid.NewV4().String()
 	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &newUserName, nil, contextInformation)
-	test.UpdateUsersForbidden(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersForbidden(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 }
 
 func (s *TestUsersSuite) TestUpdateUserNameMulitpleTimesOK() {
@@ -165,11 +171,11 @@ func (s *TestUsersSuite) TestUpdateUserNameMulitpleTimesOK() {
 	}
 
 	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &newUserName, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	require.False(s.T(), *result.Data.Attributes.RegistrationCompleted)
 
 	// next attempt should PASS.
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	require.False(s.T(), *result.Data.Attributes.RegistrationCompleted)
 
 }
@@ -187,13 +193,13 @@ func (s *TestUsersSuite) TestUpdateRegistrationCompletedOK() {
 	}
 
 	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	require.False(s.T(), *result.Data.Attributes.RegistrationCompleted)
 
 	// next attempt should PASS.
 	boolTrue := true
 	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, &boolTrue, contextInformation)
-	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 }
 
 func (s *TestUsersSuite) TestUpdateRegistrationCompletedBadRequest() {
@@ -209,13 +215,13 @@ func (s *TestUsersSuite) TestUpdateRegistrationCompletedBadRequest() {
 	}
 
 	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	require.False(s.T(), *result.Data.Attributes.RegistrationCompleted)
 
 	// next attempt should fail.
 	boolFalse := false
 	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, &boolFalse, contextInformation)
-	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 
 }
 
@@ -236,13 +242,13 @@ func (s *TestUsersSuite) TestUpdateRegistrationCompletedAndUsernameOK() {
 	}
 
 	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	require.False(s.T(), *result.Data.Attributes.RegistrationCompleted)
 
 	boolTrue := true
 	newUserName := identity.Username + uuid.NewV4().String()
 	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &newUserName, &boolTrue, contextInformation)
-	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 
 }
 
@@ -267,7 +273,7 @@ func (s *TestUsersSuite) TestUpdateExistingUsernameForbidden() {
 
 	newUserName := identity.Username
 	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &newUserName, nil, contextInformation)
-	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 }
 
 func (s *TestUsersSuite) TestUpdateExistingEmailForbidden() {
@@ -291,7 +297,7 @@ func (s *TestUsersSuite) TestUpdateExistingEmailForbidden() {
 
 	newEmail := user.Email
 	updateUsersPayload := createUpdateUsersPayload(&newEmail, nil, nil, nil, nil, nil, nil, nil, contextInformation)
-	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 }
 
 func (s *TestUsersSuite) TestUpdateUserVariableSpacesInNameOK() {
@@ -326,7 +332,7 @@ func (s *TestUsersSuite) TestUpdateUserVariableSpacesInNameOK() {
 	}
 	//secureController, secureService := createSecureController(t, identity)
 	updateUsersPayload := createUpdateUsersPayload(&newEmail, &newFullName, &newBio, &newImageURL, &newProfileURL, &newCompany, nil, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	// then
 	require.NotNil(s.T(), result)
 	// let's fetch it and validate
@@ -375,7 +381,7 @@ func (s *TestUsersSuite) TestUpdateUserUnsetVariableInContextInfo() {
 	}
 	//secureController, secureService := createSecureController(t, identity)
 	updateUsersPayload := createUpdateUsersPayload(&newEmail, &newFullName, &newBio, &newImageURL, &newProfileURL, nil, nil, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	// then
 	require.NotNil(s.T(), result)
 	// let's fetch it and validate the usual stuff.
@@ -398,7 +404,7 @@ func (s *TestUsersSuite) TestUpdateUserUnsetVariableInContextInfo() {
 	}
 
 	updateUsersPayload = createUpdateUsersPayload(&newEmail, &newFullName, &newBio, &newImageURL, &newProfileURL, nil, nil, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	// then
 	require.NotNil(s.T(), result)
 	// let's fetch it and validate the usual stuff.
@@ -436,7 +442,40 @@ func (s *TestUsersSuite) TestUpdateUserOKWithoutContextInfo() {
 	secureService, secureController := s.SecuredController(identity)
 
 	updateUsersPayload := createUpdateUsersPayloadWithoutContextInformation(&newEmail, &newFullName, &newBio, &newImageURL, &newProfileURL)
-	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
+}
+
+func (s *TestUsersSuite) TestUpdateUserWithIfMatch() {
+	// given
+	user := s.createRandomUser("TestUpdateUserWithIfMatch")
+	identity := s.createRandomIdentity(user, account.KeycloakIDP)
+	res, _ := test.ShowUsersOK(s.T(), nil, nil, s.controller, identity.ID.String(), nil, nil, nil)
+	eTag := res.Header().Get(app.ETag)
+	require.NotEmpty(s.T(), eTag)
+	secureService, secureController := s.SecuredController(identity)
+
+	// when
+	newFullName := "TestUpdateUserWithIfMatch"
+	updateUsersPayload := createUpdateUsersPayload(nil, &newFullName, nil, nil, nil, nil, nil, nil, nil)
+	res, result := test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, &eTag, updateUsersPayload)
+	// then
+	assert.Equal(s.T(), newFullName, *result.Data.Attributes.FullName)
+	newETag := res.Header().Get(app.ETag)
+	assert.NotEqual(s.T(), eTag, newETag)
+
+	// when the update is based on the previous version of the user
+	staleFullName := "TestUpdateUserWithIfMatch stale"
+	updateUsersPayload = createUpdateUsersPayload(nil, &staleFullName, nil, nil, nil, nil, nil, nil, nil)
+	test.UpdateUsersPreconditionFailed(s.T(), secureService.Context, secureService, secureController, &eTag, updateUsersPayload)
+	// then
+	_, result = test.ShowUsersOK(s.T(), nil, nil, s.controller, identity.ID.String(), nil, nil, nil)
+	assert.Equal(s.T(), newFullName, *result.Data.Attributes.FullName)
+
+	// when the update is based on the current version of the user
+	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, &newETag, updateUsersPayload)
+	// then
+	_, result = test.ShowUsersOK(s.T(), nil, nil, s.controller, identity.ID.String(), nil, nil, nil)
+	assert.Equal(s.T(), staleFullName, *result.Data.Attributes.FullName)
 }
 
 //Pass " " as email in HTTP PATCH  /api/Users
@@ -457,7 +496,7 @@ func (s *TestUsersSuite) TestUpdateUserWithInvalidEmail() {
 
 	//then
 	updateUsersPayload := createUpdateUsersPayloadWithoutContextInformation(&newEmail, &newFullName, &newBio, &newImageURL, &newProfileURL)
-	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 }
 
 //Pass " " as username in HTTP PATCH  /api/Users
@@ -478,7 +517,38 @@ func (s *TestUsersSuite) TestUpdateUserWithInvalidUsername() {
 	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &username, nil, contextInformation)
 
 	//then
-	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
+}
+
+func (s *TestUsersSuite) TestUpdateUserWithReservedUsername() {
+	// given
+	user := s.createRandomUser("TestUpdateUserWithReservedUsername")
+	identity := s.createRandomIdentity(user, account.KeycloakIDP)
+	secureService, secureController := s.SecuredController(identity)
+	// when
+	username := "OpenShift-Infra"
+	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &username, nil, nil)
+	// then
+	_, jerrs := test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
+	require.Len(s.T(), jerrs.Errors, 1)
+	assert.Equal(s.T(), jsonapi.ErrorCodeValidationError, *jerrs.Errors[0].Code)
+	assert.Equal(s.T(), account.RuleReserved, jerrs.Errors[0].Meta["rule"])
+}
+
+func (s *TestUsersSuite) TestUpdateExistingUsernameIgnoringCaseBadRequest() {
+	// given
+	user := s.createRandomUser("TestUpdateExistingUsernameIgnoringCase")
+	identity := s.createRandomIdentity(user, account.KeycloakIDP)
+	user2 := s.createRandomUser("TestUpdateExistingUsernameIgnoringCase2")
+	identity2 := s.createRandomIdentity(user2, account.KeycloakIDP)
+	secureService, secureController := s.SecuredController(identity2)
+	// when
+	username := strings.ToUpper(identity.Username)
+	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, &username, nil, nil)
+	// then
+	_, jerrs := test.UpdateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
+	require.Len(s.T(), jerrs.Errors, 1)
+	assert.Equal(s.T(), account.RuleUnique, jerrs.Errors[0].Meta["rule"])
 }
 
 func (s *TestUsersSuite) TestPatchUserContextInformation() {
@@ -497,7 +567,7 @@ func (s *TestUsersSuite) TestPatchUserContextInformation() {
 	}
 	//secureController, secureService := createSecureController(t, identity)
 	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, contextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	// then
 	require.NotNil(s.T(), result)
 
@@ -519,7 +589,7 @@ func (s *TestUsersSuite) TestPatchUserContextInformation() {
 	}
 
 	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, patchedContextInformation)
-	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, updateUsersPayload)
+	_, result = test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
 	require.NotNil(s.T(), result)
 
 	// let's fetch it and validate the usual stuff.
@@ -537,6 +607,87 @@ func (s *TestUsersSuite) TestPatchUserContextInformation() {
 
 }
 
+func (s *TestUsersSuite) TestMergePatchUserContextInformation() {
+	// given
+	user := s.createRandomUser("TestMergePatchUserContextInformation")
+	identity := s.createRandomIdentity(user, account.KeycloakIDP)
+	secureService, secureController := s.SecuredController(identity)
+	contextInformation := map[string]interface{}{
+		"recent": map[string]interface{}{
+			"spaces":   []interface{}{"a"},
+			"projects": []interface{}{"b"},
+		},
+	}
+	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, contextInformation)
+	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
+	// when the nested objects are patched
+	patchedContextInformation := map[string]interface{}{
+		"recent": map[string]interface{}{
+			"projects":  nil,
+			"pipelines": []interface{}{"c"},
+		},
+	}
+	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, patchedContextInformation)
+	_, result := test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
+	// then
+	require.NotNil(s.T(), result)
+	assert.Equal(s.T(), map[string]interface{}{
+		"spaces":    []interface{}{"a"},
+		"pipelines": []interface{}{"c"},
+	}, result.Data.Attributes.ContextInformation["recent"])
+}
+
+func (s *TestUsersSuite) TestUpdateUserContextNamespaceWithUserToken() {
+	// given two registered API clients
+	user := s.createRandomUser("TestUpdateUserContextNamespaceWithUserToken")
+	identity := s.createRandomIdentity(user, account.KeycloakIDP)
+	clientID := "client-" + uuid.NewV4().String()
+	otherClientID := "client-" + uuid.NewV4().String()
+	for _, id := range []string{clientID, otherClientID} {
+		require.Nil(s.T(), s.Application.OauthClients().Create(s.Ctx, &auth.OauthClient{
+			ClientID:   id,
+			Name:       id,
+			GrantTypes: auth.StringList{login.AuthorizationCodeGrantType},
+		}))
+	}
+	// and the token of the user issued to the first client
+	keycloakToken, err := testtoken.GenerateTokenWithClaims(map[string]interface{}{
+		"sub": identity.ID.String(),
+		"azp": "fabric8-online-platform",
+	})
+	require.Nil(s.T(), err)
+	accessToken, err := testtoken.TokenManager.ReissueAccessToken(s.Ctx, keycloakToken, nil, nil, clientID)
+	require.Nil(s.T(), err)
+	claims, err := testtoken.TokenManager.ParseTokenWithMapClaims(s.Ctx, accessToken)
+	require.Nil(s.T(), err)
+	svc := goa.New("Users-Service")
+	svc.Context = goajwt.WithJWT(svc.Context, &jwt.Token{Raw: accessToken, Claims: claims, Valid: true})
+	ctrl := NewUsersController(s.svc, s.Application, s.Configuration, s.profileService, s.linkAPIService)
+	ctrl.RemoteWITService = &dummyRemoteWITService{}
+
+	// when the client updates its own namespace
+	updateUsersPayload := createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, map[string]interface{}{
+		clientID: map[string]interface{}{"theme": "dark"},
+	})
+	_, result := test.UpdateUsersOK(s.T(), svc.Context, svc, ctrl, nil, updateUsersPayload)
+	// then
+	assert.Equal(s.T(), map[string]interface{}{"theme": "dark"}, result.Data.Attributes.ContextInformation[clientID])
+
+	// when the client updates the namespace of the other client
+	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, map[string]interface{}{
+		otherClientID: map[string]interface{}{"theme": "dark"},
+	})
+	// then
+	test.UpdateUsersForbidden(s.T(), svc.Context, svc, ctrl, nil, updateUsersPayload)
+
+	// the Keycloak token has not been issued to any client
+	svc.Context = goajwt.WithJWT(goa.New("Users-Service").Context, &jwt.Token{Raw: keycloakToken, Claims: jwt.MapClaims{"sub": identity.ID.String(), "azp": "fabric8-online-platform"}, Valid: true})
+	updateUsersPayload = createUpdateUsersPayload(nil, nil, nil, nil, nil, nil, nil, nil, map[string]interface{}{
+		clientID: map[string]interface{}{"theme": "light"},
+	})
+	test.UpdateUsersForbidden(s.T(), svc.Context, svc, ctrl, nil, updateUsersPayload)
+}
+
 func (s *TestUsersSuite) TestUpdateUserUnauthorized() {
 	// given
 	user := s.createRandomUser("TestUpdateUserUnauthorized")
@@ -559,7 +710,7 @@ func (s *TestUsersSuite) TestUpdateUserUnauthorized() {
 	//secureController, secureService := createSecureController(t, identity)
 	updateUsersPayload := createUpdateUsersPayload(&newEmail, &newFullName, &newBio, &newImageURL, &newProfileURL, nil, nil, nil, contextInformation)
 	// when/then
-	test.UpdateUsersUnauthorized(s.T(), context.Background(), nil, s.controller, updateUsersPayload)
+	test.UpdateUsersUnauthorized(s.T(), context.Background(), nil, s.controller, nil, updateUsersPayload)
 }
 
 func (s *TestUsersSuite) TestShowUserOK() {
@@ -623,6 +774,20 @@ func (s *TestUsersSuite) TestShowUserNotFound() {
 	test.ShowUsersNotFound(s.T(), nil, nil, s.controller, uuid.NewV4().String(), nil, nil)
 }
 
+func (s *TestUsersSuite) TestShowMergedUserMovedPermanently() {
+	// given a user merged into another user
+	source := s.createRandomIdentity(s.createRandomUser("TestShowMergedUserSource"), account.KeycloakIDP)
+	target := s.createRandomIdentity(s.createRandomUser("TestShowMergedUserTarget"), account.KeycloakIDP)
+	merge, err := login.NewUserMergeService(s.Application).Merge(s.Ctx, source.ID, target.ID, "fabric8-auth-admin")
+	require.Nil(s.T(), err)
+	require.Equal(s.T(), target.ID, merge.TargetIdentityID)
+	// when
+	rw := test.ShowUsersMovedPermanently(s.T(), nil, nil, s.controller, source.ID.String(), nil, nil)
+	// then
+	assert.Contains(s.T(), rw.Header().Get("Location"), "/api/users/"+target.ID.String())
+	test.ShowUsersOK(s.T(), nil, nil, s.controller, target.ID.String(), nil, nil)
+}
+
 func (s *TestUsersSuite) TestShowUserBadRequest() {
 	// given user
 	user := s.createRandomUser("TestShowUserBadRequest")
@@ -640,11 +805,11 @@ func (s *TestUsersSuite) TestListUsersOK() {
 	user2 := s.createRandomUser("TestListUsersOK2")
 	identity2 := s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
-	res, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity1.Username, nil, nil)
+	res, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity1.Username, nil, nil, nil, nil, nil)
 	// then
 	assertUser(s.T(), findUser(identity1.ID, result.Data), user1, identity1)
 
-	res, result = test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity2.Username, nil, nil)
+	res, result = test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity2.Username, nil, nil, nil, nil, nil)
 	assertUser(s.T(), findUser(identity2.ID, result.Data), user2, identity2)
 	assertMultiUsersResponseHeaders(s.T(), res, user2)
 }
@@ -658,7 +823,7 @@ func (s *TestUsersSuite) TestListUsersWithMissingKeycloakIdentityOK() {
 	user2 := s.createRandomUser("TestListUsersOK2")
 	identity2 := s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
-	res, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity2.Username, nil, nil)
+	res, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity2.Username, nil, nil, nil, nil, nil)
 	// then
 	assertUser(s.T(), findUser(identity2.ID, result.Data), user2, identity2)
 	assertMultiUsersResponseHeaders(s.T(), res, user2)
@@ -674,11 +839,11 @@ func (s *TestUsersSuite) TestListUsersOKUsingExpiredIfModifiedSinceHeader() {
 	identity2 := s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
 	ifModifiedSinceHeader := app.ToHTTPTime(user2.UpdatedAt.Add(-1 * time.Hour))
-	res, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity1.Username, &ifModifiedSinceHeader, nil)
+	res, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity1.Username, nil, nil, nil, &ifModifiedSinceHeader, nil)
 	// then
 	assertUser(s.T(), findUser(identity1.ID, result.Data), user1, identity1)
 
-	res, result = test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity2.Username, &ifModifiedSinceHeader, nil)
+	res, result = test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity2.Username, nil, nil, nil, &ifModifiedSinceHeader, nil)
 	assertUser(s.T(), findUser(identity2.ID, result.Data), user2, identity2)
 	assertMultiUsersResponseHeaders(s.T(), res, user2)
 }
@@ -693,11 +858,11 @@ func (s *TestUsersSuite) TestListUsersOKUsingExpiredIfNoneMatchHeader() {
 	identity2 := s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
 	ifNoneMatch := "foo"
-	res, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity1.Username, nil, &ifNoneMatch)
+	res, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity1.Username, nil, nil, nil, nil, &ifNoneMatch)
 	// then
 	assertUser(s.T(), findUser(identity1.ID, result.Data), user1, identity1)
 
-	res, result = test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity2.Username, nil, &ifNoneMatch)
+	res, result = test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity2.Username, nil, nil, nil, nil, &ifNoneMatch)
 	assertUser(s.T(), findUser(identity2.ID, result.Data), user2, identity2)
 
 	assertMultiUsersResponseHeaders(s.T(), res, user2)
@@ -713,7 +878,7 @@ func (s *TestUsersSuite) TestListUsersNotModifiedUsingIfModifiedSinceHeader() {
 	s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
 	ifModifiedSinceHeader := app.ToHTTPTime(user2.UpdatedAt)
-	res := test.ListUsersNotModified(s.T(), nil, nil, s.controller, nil, nil, &ifModifiedSinceHeader, nil)
+	res := test.ListUsersNotModified(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &ifModifiedSinceHeader, nil)
 	// then
 	assertResponseHeaders(s.T(), res)
 }
@@ -727,7 +892,7 @@ func (s *TestUsersSuite) TestListUsersByUsernameOK() {
 	user2 := s.createRandomUser("TestListUsersOK2")
 	s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
-	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity11.Username, nil, nil)
+	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity11.Username, nil, nil, nil, nil, nil)
 	// then
 	for i, data := range result.Data {
 		s.T().Log(fmt.Sprintf("Result #%d: %s %v", i, *data.ID, *data.Attributes.Username))
@@ -746,7 +911,7 @@ func (s *TestUsersSuite) TestListUsersByUsernameOKEmptyResult() {
 	s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
 	username := "foobar"
-	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &username, nil, nil)
+	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &username, nil, nil, nil, nil, nil)
 	// then
 	require.Len(s.T(), result.Data, 0)
 }
@@ -759,11 +924,11 @@ func (s *TestUsersSuite) TestListUsersByUsernameNotModifiedUsingIfNoneMatchHeade
 	// given user2
 	user2 := s.createRandomUser("TestListUsersOK2")
 	s.createRandomIdentity(user2, account.KeycloakIDP)
-	_, filteredUsers := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, &identity11.Username, nil, nil)
+	_, filteredUsers := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity11.Username, nil, nil, nil, nil, nil)
 	// when/then
 	ifNoneMatch := s.generateUsersTag(*filteredUsers)
 	// when
-	res := test.ListUsersNotModified(s.T(), nil, nil, s.controller, nil, &identity11.Username, nil, &ifNoneMatch)
+	res := test.ListUsersNotModified(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, nil, nil, nil, &identity11.Username, nil, nil, nil, nil, &ifNoneMatch)
 	// then
 	assertResponseHeaders(s.T(), res)
 }
@@ -778,7 +943,7 @@ func (s *TestUsersSuite) TestListUsersByEmailOK() {
 	user2 := s.createRandomUser("TestListUsersOK2")
 	s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
-	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, &user1.Email, nil, nil, nil)
+	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, &user1.Email, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	// then
 	for i, data := range result.Data {
 		s.T().Log(fmt.Sprintf("Result #%d: %s %v", i, *data.ID, *data.Attributes.Username))
@@ -799,7 +964,7 @@ func (s *TestUsersSuite) TestListUsersByEmailOKEmptyResult() {
 	s.createRandomIdentity(user2, account.KeycloakIDP)
 	// when
 	email := "foo@bar.com"
-	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, &email, nil, nil, nil)
+	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, &email, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	// then
 	require.Len(s.T(), result.Data, 0)
 }
@@ -812,14 +977,164 @@ func (s *TestUsersSuite) TestListUsersByEmailNotModifiedUsingIfNoneMatchHeader()
 	// given user2
 	user2 := s.createRandomUser("TestListUsersOK2")
 	s.createRandomIdentity(user2, account.KeycloakIDP)
-	_, filteredUsers := test.ListUsersOK(s.T(), nil, nil, s.controller, &user1.Email, nil, nil, nil)
+	_, filteredUsers := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, &user1.Email, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	// when
 	ifNoneMatch := s.generateUsersTag(*filteredUsers)
-	res := test.ListUsersNotModified(s.T(), nil, nil, s.controller, &user1.Email, nil, nil, &ifNoneMatch)
+	res := test.ListUsersNotModified(s.T(), nil, nil, s.controller, nil, nil, nil, nil, &user1.Email, nil, nil, nil, nil, nil, nil, nil, nil, nil, &ifNoneMatch)
 	// then
 	assertResponseHeaders(s.T(), res)
 }
 
+func (s *TestUsersSuite) TestListUsersByIDsOK() {
+	// given
+	user1 := s.createRandomUser("TestListUsersByIDsOK1")
+	identity1 := s.createRandomIdentity(user1, account.KeycloakIDP)
+	user2 := s.createRandomUser("TestListUsersByIDsOK2")
+	identity2 := s.createRandomIdentity(user2, account.KeycloakIDP)
+	unknownID := uuid.NewV4()
+	ids := strings.Join([]string{identity1.ID.String(), unknownID.String(), identity2.ID.String()}, ",")
+	// when
+	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, &ids, nil, nil, nil, nil, nil, nil, nil, nil)
+	// then
+	require.Len(s.T(), result.Data, 2)
+	assertUser(s.T(), findUser(identity1.ID, result.Data), user1, identity1)
+	assertUser(s.T(), findUser(identity2.ID, result.Data), user2, identity2)
+	assert.Equal(s.T(), []string{unknownID.String()}, result.Meta.UnknownIDs)
+
+	// the ETag covers the whole set of users
+	ifNoneMatch := s.generateUsersTag(*result)
+	res := test.ListUsersNotModified(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, &ids, nil, nil, nil, nil, nil, nil, nil, &ifNoneMatch)
+	assertResponseHeaders(s.T(), res)
+}
+
+func (s *TestUsersSuite) TestListUsersByIDsBadRequest() {
+	invalidIDs := uuid.NewV4().String() + ",foo"
+	test.ListUsersBadRequest(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, &invalidIDs, nil, nil, nil, nil, nil, nil, nil, nil)
+	tooManyIDs := strings.TrimSuffix(strings.Repeat(uuid.NewV4().String()+",", 101), ",")
+	test.ListUsersBadRequest(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, nil, &tooManyIDs, nil, nil, nil, nil, nil, nil, nil, nil)
+}
+
+func (s *TestUsersSuite) TestListUsersByGitHubLoginOK() {
+	// given a user with a linked GitHub account
+	user := s.createRandomUser("TestListUsersByGitHubLoginOK")
+	identity := s.createRandomIdentity(user, account.KeycloakIDP)
+	github := s.createRandomIdentity(user, account.GitHubIDP)
+	// when the login doesn't match the case
+	githubLogin := strings.ToUpper(github.Username)
+	_, result := test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, &githubLogin, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	// then
+	require.Len(s.T(), result.Data, 1)
+	assertUser(s.T(), result.Data[0], user, identity)
+
+	// unknown login
+	unknown := "TestListUsersByGitHubLoginOK" + uuid.NewV4().String()
+	_, result = test.ListUsersOK(s.T(), nil, nil, s.controller, nil, nil, nil, nil, nil, &unknown, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	assert.Empty(s.T(), result.Data)
+}
+
+func (s *TestUsersSuite) TestUserHistory() {
+	// given a user who changed the email
+	user := s.createRandomUser("TestUserHistory")
+	identity := s.createRandomIdentity(user, account.KeycloakIDP)
+	newEmail := "TestUserHistory-" + uuid.NewV4().String() + "@email.com"
+	secureService, secureController := s.SecuredController(identity)
+	updateUsersPayload := createUpdateUsersPayload(&newEmail, nil, nil, nil, nil, nil, nil, nil, nil)
+	test.UpdateUsersOK(s.T(), secureService.Context, secureService, secureController, nil, updateUsersPayload)
+	svc, ctrl := s.SecuredServiceAccountController(account.Identity{Username: "fabric8-auth-admin"})
+
+	// when
+	_, result := test.HistoryUsersOK(s.T(), svc.Context, svc, ctrl, identity.ID, nil, nil)
+
+	// then
+	require.Len(s.T(), result.Data, 1)
+	assert.Equal(s.T(), 1, result.Meta.TotalCount)
+	change := result.Data[0].Attributes
+	assert.Equal(s.T(), account.ProfileFieldEmail, change.Field)
+	assert.Equal(s.T(), user.Email, change.OldValue)
+	assert.Equal(s.T(), newEmail, change.NewValue)
+	require.NotNil(s.T(), change.Actor)
+	assert.Equal(s.T(), identity.ID.String(), *change.Actor)
+
+	// unknown identity
+	test.HistoryUsersNotFound(s.T(), svc.Context, svc, ctrl, uuid.NewV4(), nil, nil)
+	// only admins can see the history
+	test.HistoryUsersForbidden(s.T(), secureService.Context, secureService, secureController, identity.ID, nil, nil)
+}
+
+func (s *TestUsersSuite) TestListUsersAsAdmin() {
+	// given two users on the same cluster
+	cluster := "https://api.list-users-" + uuid.NewV4().String() + ".example.com/"
+	user1 := s.createRandomUser("TestListUsersAsAdmin1")
+	user1.Cluster = cluster
+	require.Nil(s.T(), s.userRepo.Save(context.Background(), &user1))
+	identity1 := s.createRandomIdentity(user1, account.KeycloakIDP)
+	user2 := s.createRandomUser("TestListUsersAsAdmin2")
+	user2.Cluster = cluster
+	require.Nil(s.T(), s.userRepo.Save(context.Background(), &user2))
+	identity2 := s.createRandomIdentity(user2, account.KeycloakIDP)
+	identity2.RegistrationCompleted = true
+	require.Nil(s.T(), s.identityRepo.Save(context.Background(), &identity2))
+	svc, ctrl := s.SecuredServiceAccountController(account.Identity{Username: "fabric8-auth-admin"})
+
+	s.T().Run("filter by cluster and sort", func(t *testing.T) {
+		sort := "-full_name"
+		_, result := test.ListUsersOK(t, svc.Context, svc, ctrl, nil, &cluster, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &sort, nil, nil)
+		require.Len(t, result.Data, 2)
+		assert.Equal(t, 2, result.Meta.TotalCount)
+		assertUser(t, result.Data[0], user2, identity2)
+		assertUser(t, result.Data[1], user1, identity1)
+	})
+
+	s.T().Run("filter by registration status", func(t *testing.T) {
+		registrationCompleted := true
+		_, result := test.ListUsersOK(t, svc.Context, svc, ctrl, nil, &cluster, nil, nil, nil, nil, nil, nil, &registrationCompleted, nil, nil, nil, nil, nil, nil)
+		require.Len(t, result.Data, 1)
+		assertUser(t, result.Data[0], user2, identity2)
+	})
+
+	s.T().Run("filter by creation date", func(t *testing.T) {
+		anHourAgo := time.Now().Add(-time.Hour)
+		_, result := test.ListUsersOK(t, svc.Context, svc, ctrl, nil, &cluster, &anHourAgo, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		assert.Len(t, result.Data, 2)
+		_, result = test.ListUsersOK(t, svc.Context, svc, ctrl, nil, &cluster, nil, &anHourAgo, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		assert.Len(t, result.Data, 0)
+	})
+
+	s.T().Run("filter by provider type", func(t *testing.T) {
+		providerType := "github-test"
+		_, result := test.ListUsersOK(t, svc.Context, svc, ctrl, nil, &cluster, nil, nil, nil, nil, nil, &providerType, nil, nil, nil, nil, nil, nil, nil)
+		assert.Len(t, result.Data, 0)
+	})
+
+	s.T().Run("paging", func(t *testing.T) {
+		limit := 1
+		_, result := test.ListUsersOK(t, svc.Context, svc, ctrl, nil, &cluster, nil, nil, nil, nil, nil, nil, nil, nil, &limit, nil, nil, nil, nil)
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, 2, result.Meta.TotalCount)
+		require.NotNil(t, result.Links.Next)
+		assert.Contains(t, *result.Links.Next, "filter[cluster]=")
+	})
+
+	s.T().Run("unknown sort attribute", func(t *testing.T) {
+		sort := "company"
+		test.ListUsersBadRequest(t, svc.Context, svc, ctrl, nil, &cluster, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &sort, nil, nil)
+	})
+}
+
+func (s *TestUsersSuite) TestListUsersAsUser() {
+	// given
+	user := s.createRandomUser("TestListUsersAsUser")
+	identity := s.createRandomIdentity(user, account.KeycloakIDP)
+	svc, ctrl := s.SecuredController(identity)
+	// when/then
+	test.ListUsersForbidden(s.T(), svc.Context, svc, ctrl, nil, &user.Cluster, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	sort := "created_at"
+	test.ListUsersForbidden(s.T(), svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &sort, nil, nil)
+	// ordinary users can't enumerate all the users
+	_, result := test.ListUsersOK(s.T(), svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	assert.Len(s.T(), result.Data, 0)
+}
+
 func (s *TestUsersSuite) createRandomUser(fullname string) account.User {
 	user := account.User{
 		Email:    uuid.NewV4().String() + "primaryForUpdat7e@example.com",
@@ -1011,6 +1326,18 @@ func (d *dummyUserProfileService) Create(ctx context.Context, keycloakUserProfil
 	return &url, nil
 }
 
+func (d *dummyUserProfileService) SetAttributes(ctx context.Context, attributes login.KeycloakUserProfileAttributes, protectedAccessToken string, keycloakAdminUserURL string) error {
+	return nil
+}
+
+func (d *dummyUserProfileService) SetEnabled(ctx context.Context, enabled bool, protectedAccessToken string, keycloakAdminUserURL string) error {
+	return nil
+}
+
+func (d *dummyUserProfileService) Delete(ctx context.Context, protectedAccessToken string, keycloakAdminUserURL string) error {
+	return nil
+}
+
 func (d *dummyUserProfileService) SetDummyGetResponse(dummyGetResponse *login.KeycloakUserProfileResponse) {
 	d.dummyGetResponse = dummyGetResponse
 }
@@ -1089,10 +1416,33 @@ func (s *TestUsersSuite) TestCreateUserAsServiceAccountWithMissingRequiredFields
 	// Missing email
 	createUserPayload = createCreateUsersAsServiceAccountPayload(nil, nil, nil, nil, nil, nil, &identity.Username, nil, &cluster, nil, nil, nil)
 	require.NotNil(s.T(), createUserPayload.Validate())
+}
 
-	// Missing cluster
-	createUserPayload = createCreateUsersAsServiceAccountPayload(&user.Email, nil, nil, nil, nil, nil, &identity.Username, nil, nil, nil, nil, nil)
-	require.NotNil(s.T(), createUserPayload.Validate())
+func (s *TestUsersSuite) TestCreateUserAsServiceAccountWithoutClusterAllocatesCluster() {
+	user := testsupport.TestUser
+	identity := testsupport.TestIdentity
+
+	secureService, secureController := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+
+	createUserPayload := createCreateUsersAsServiceAccountPayload(&user.Email, nil, nil, nil, nil, nil, &identity.Username, nil, nil, nil, nil, nil)
+	require.Nil(s.T(), createUserPayload.Validate())
+
+	_, appUser := test.CreateUsersOK(s.T(), secureService.Context, secureService, secureController, createUserPayload)
+	assert.Contains(s.T(), s.Configuration.GetOSOClusters(), *appUser.Data.Attributes.Cluster)
+}
+
+func (s *TestUsersSuite) TestCreateUserAsServiceAccountWithReservedUsernameBadRequest() {
+	// given
+	user := testsupport.TestUser
+	username := "admin"
+	cluster := "some cluster"
+	secureService, secureController := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+	// when
+	createUserPayload := createCreateUsersAsServiceAccountPayload(&user.Email, nil, nil, nil, nil, nil, &username, nil, &cluster, nil, nil, nil)
+	// then
+	_, jerrs := test.CreateUsersBadRequest(s.T(), secureService.Context, secureService, secureController, createUserPayload)
+	require.Len(s.T(), jerrs.Errors, 1)
+	assert.Equal(s.T(), account.RuleReserved, jerrs.Errors[0].Meta["rule"])
 }
 
 func (s *TestUsersSuite) TestCreateUserAsServiceAccountUnauthorized() {
@@ -1152,9 +1502,7 @@ func createCreateUsersAsServiceAccountPayload(email, fullName, bio, imageURL, pr
 	if username != nil {
 		attributes.Username = *username
 	}
-	if cluster != nil {
-		attributes.Cluster = *cluster
-	}
+	attributes.Cluster = cluster
 
 	return &app.CreateUsersPayload{
 		Data: &app.CreateUserData{
@@ -73,17 +73,22 @@ var userArray = a.MediaType("application/vnd.user-array+json", func() {
 	a.Description("User Array")
 	a.Attributes(func() {
 		a.Attribute("data", a.ArrayOf(userData))
+		a.Attribute("links", pagingLinks)
+		a.Attribute("meta", userListMeta)
 		a.Required("data")
 
 	})
 	a.View("default", func() {
 		a.Attribute("data")
+		a.Attribute("links")
+		a.Attribute("meta")
 		a.Required("data")
 	})
 })
 
 var userListMeta = a.Type("UserListMeta", func() {
 	a.Attribute("totalCount", d.Integer)
+	a.Attribute("unknownIDs", a.ArrayOf(d.String), "The looked up identity IDs which don't match any user")
 	a.Required("totalCount")
 })
 
@@ -109,6 +114,51 @@ var _ = a.Resource("user", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 	})
+
+	a.Action("list_identities", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/identities"),
+		)
+		a.Description("List the identities of the authenticated user: the Keycloak identity and the identities of the linked GitHub and OpenShift accounts")
+		a.Response(d.OK, identityList)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("unlink_identity", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/identities/:identityID"),
+		)
+		a.Description("Unlink a GitHub or OpenShift account from the authenticated user. The token of the account is removed too")
+		a.Params(func() {
+			a.Param("identityID", d.UUID, "ID of the identity of the linked account")
+		})
+		a.Response(d.OK)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("show_context", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/context/:namespace"),
+		)
+		a.Description("Get a namespace of the context information of the authenticated user")
+		a.Params(func() {
+			a.Param("namespace", d.String, "Name of the namespace, i.e. the ID of the API client owning it")
+		})
+		a.UseTrait("conditional")
+		a.Response(d.OK, "application/json")
+		a.Response(d.NotModified)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
 })
 
 var _ = a.Resource("users", func() {
@@ -118,13 +168,14 @@ var _ = a.Resource("users", func() {
 		a.Routing(
 			a.GET("/:id"),
 		)
-		a.Description("Retrieve user for the given ID.")
+		a.Description("Retrieve user for the given ID. The IDs of the merged identities are redirected to the identity they have been merged into.")
 		a.Params(func() {
 			a.Param("id", d.String, "id")
 		})
 		a.UseTrait("conditional")
 		a.Response(d.OK, user)
 		a.Response(d.NotModified)
+		a.Response(d.MovedPermanently)
 		a.Response(d.NotFound, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
@@ -143,6 +194,7 @@ var _ = a.Resource("users", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
 
 	})
 
@@ -151,7 +203,10 @@ var _ = a.Resource("users", func() {
 		a.Routing(
 			a.PATCH(""),
 		)
-		a.Description("update the authenticated user")
+		a.Description(`update the authenticated user. The contextInformation attribute is a JSON merge patch (RFC 7396) of the context information of the user.
+The namespaces of the context information can only be modified by the API client owning them.
+The update is rejected with a 412 Precondition Failed response if the If-Match header doesn't match the current ETag of the user.`)
+		a.UseTrait("conditional-update")
 		a.Payload(updateUser)
 		a.Response(d.OK, func() {
 			a.Media(user)
@@ -162,6 +217,7 @@ var _ = a.Resource("users", func() {
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.Forbidden, JSONAPIErrors)
 		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.PreconditionFailed, JSONAPIErrors)
 
 	})
 
@@ -169,26 +225,96 @@ var _ = a.Resource("users", func() {
 		a.Routing(
 			a.GET(""),
 		)
-		a.Description("List all users.")
+		a.Description(`List users matching all the given filters. Anybody can look users up by username, email, GitHub login or identity IDs.
+Listing all users, filtering on other attributes and sorting are reserved to the admin service accounts.`)
 		a.Params(func() {
-			// This is not filtering - mutliple params do not work as "AND".
 			a.Param("filter[username]", d.String, "username to search users")
 			a.Param("filter[email]", d.String, "email to search users")
+			a.Param("filter[github]", d.String, "GitHub login of the account linked to the users")
+			a.Param("filter[id]", d.String, `Comma separated list of the identity IDs of the users to look up (at most 100).
+All the matching users are returned at once and the IDs which don't match any user are listed in the meta`)
+			a.Param("filter[provider_type]", d.String, "Type of the identity provider of the users. Defaults to 'kc'")
+			a.Param("filter[cluster]", d.String, "OpenShift API URL of the cluster the users are provisioned to")
+			a.Param("filter[created_after]", d.DateTime, "Only users created at or after the given date")
+			a.Param("filter[created_before]", d.DateTime, "Only users created before the given date")
+			a.Param("filter[approval]", d.String, "Status of the approval of the users", func() {
+				a.Enum("pending", "approved", "rejected")
+			})
+			a.Param("filter[registration_completed]", d.Boolean, "Whether the registration of the users is completed")
+			a.Param("sort", d.String, `Comma separated list of the attributes to sort by: created_at, username, email, full_name or cluster.
+Prefix an attribute with '-' to sort in descending order. Defaults to created_at`)
+			a.Param("page[offset]", d.String, "Paging start position")
+			a.Param("page[limit]", d.Integer, "Paging size")
 		})
 		a.UseTrait("conditional")
 		a.Response(d.OK, userArray)
 		a.Response(d.NotModified)
 		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("history", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/history"),
+		)
+		a.Description("List the changes of the username, email, full name and cluster of the user, most recent first. Only admin service accounts are allowed to see the history")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the user")
+			a.Param("page[offset]", d.String, "Paging start position")
+			a.Param("page[limit]", d.Integer, "Paging size")
+		})
+		a.Response(d.OK, userProfileHistoryList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
 })
 
+// userProfileChangeData represents the change of a field of the profile of a user
+var userProfileChangeData = a.Type("UserProfileChangeData", func() {
+	a.Attribute("type", d.String, "type of the change", func() {
+		a.Enum("user_profile_changes")
+	})
+	a.Attribute("id", d.String, "ID of the change")
+	a.Attribute("attributes", userProfileChangeDataAttributes, "Attributes of the change")
+	a.Required("type", "id", "attributes")
+})
+
+var userProfileChangeDataAttributes = a.Type("UserProfileChangeDataAttributes", func() {
+	a.Attribute("field", d.String, "The changed field", func() {
+		a.Enum("username", "email", "full_name", "cluster")
+	})
+	a.Attribute("old_value", d.String, "The value before the change")
+	a.Attribute("new_value", d.String, "The value after the change")
+	a.Attribute("identity_id", d.String, "The ID of the identity whose username changed")
+	a.Attribute("actor", d.String, "The ID of the identity or of the service account which made the change")
+	a.Attribute("request_id", d.String, "The ID of the request which made the change")
+	a.Attribute("created-at", d.DateTime, "The date of the change")
+	a.Required("field", "old_value", "new_value", "created-at")
+})
+
+var userProfileHistoryMeta = a.Type("UserProfileHistoryMeta", func() {
+	a.Attribute("totalCount", d.Integer)
+	a.Required("totalCount")
+})
+
+var userProfileHistoryList = JSONList(
+	"UserProfileHistory", "Holds the paginated list of the changes of the profile of a user",
+	userProfileChangeData,
+	pagingLinks,
+	userProfileHistoryMeta)
+
 // userData represents an identified user object
 var userData = a.Type("UserData", func() {
 	a.Attribute("id", d.String, "unique id for the user")
 	a.Attribute("type", d.String, "type of the user")
 	a.Attribute("attributes", userDataAttributes, "Attributes of the user")
 	a.Attribute("links", genericLinks)
+	a.Attribute("meta", a.HashOf(d.String, d.Any), "a meta object containing non-standard meta-information about the user, such as its search rank")
 	a.Required("type", "attributes")
 })
 
@@ -242,9 +368,16 @@ var identityDataAttributes = a.Type("IdentityDataAttributes", func() {
 	a.Attribute("created-at", d.DateTime, "The date of creation of the user")
 	a.Attribute("updated-at", d.DateTime, "The date of update of the user")
 	a.Attribute("username", d.String, "The username")
-	a.Attribute("providerType", d.String, "The IDP provided this identity")
+	a.Attribute("providerType", d.String, "The IDP provided this identity: 'kc', 'github' or 'openshift-<cluster name>'")
+	a.Attribute("profileURL", d.String, "The URL of the profile of the user in the IDP")
 })
 
+var identityList = JSONList(
+	"Identity", "Holds the list of the identities of a user",
+	identityData,
+	nil,
+	nil)
+
 var createUserDataAttributes = a.Type("CreateIdentityDataAttributes", func() {
 	a.Attribute("fullName", d.String, "The user's full name")
 	a.Attribute("imageURL", d.String, "The avatar image for the user")
@@ -258,11 +391,12 @@ var createUserDataAttributes = a.Type("CreateIdentityDataAttributes", func() {
 	a.Attribute("bio", d.String, "The bio")
 	a.Attribute("url", d.String, "The url")
 	a.Attribute("company", d.String, "The company")
-	a.Attribute("cluster", d.String, "The OpenShift API URL of the cluster where the user is provisioned to")
+	a.Attribute("cluster", d.String, "The OpenShift API URL of the cluster where the user is provisioned to. A cluster is allocated to the user if not set")
+	a.Attribute("region", d.String, "The preferred region of the cluster allocated to the user if the cluster is not set")
 	a.Attribute("providerType", d.String, "The IDP provided this identity")
 	a.Attribute("contextInformation", a.HashOf(d.String, d.Any), "User context information of any type as a json", func() {
 		a.Example(map[string]interface{}{"last_visited_url": "https://a.openshift.io", "space": "3d6dab8d-f204-42e8-ab29-cdb1c93130ad"})
 	})
 	// Based on the request from online-registration app.
-	a.Required("username", "email", "cluster")
+	a.Required("username", "email")
 })
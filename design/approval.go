@@ -0,0 +1,127 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// approvalData represents a user waiting for approval or the decision made about the user
+var approvalData = a.Type("ApprovalData", func() {
+	a.Attribute("type", d.String, "type of the approval", func() {
+		a.Enum("approvals")
+	})
+	a.Attribute("id", d.String, "ID of the approval", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", approvalDataAttributes, "Attributes of the approval")
+	a.Required("type", "id", "attributes")
+})
+
+var approvalDataAttributes = a.Type("ApprovalDataAttributes", func() {
+	a.Attribute("user_id", d.String, "The Keycloak ID of the user")
+	a.Attribute("username", d.String, "The username of the user")
+	a.Attribute("email", d.String, "The email of the user")
+	a.Attribute("full_name", d.String, "The full name of the user")
+	a.Attribute("status", d.String, "The approval status", func() {
+		a.Enum("pending", "approved", "rejected")
+	})
+	a.Attribute("reason", d.String, "The reason of the decision")
+	a.Attribute("decided_by", d.String, "The admin who made the decision")
+	a.Attribute("decided_at", d.DateTime, "The date of the decision")
+	a.Attribute("created-at", d.DateTime, "The date of the first login of the unapproved user")
+	a.Required("user_id", "status")
+})
+
+var approvalDecision = a.Type("ApprovalDecision", func() {
+	a.Attribute("reason", d.String, "The reason of the decision. Required when rejecting a user")
+	a.Attribute("init_tenant", d.Boolean, "Initialize the tenant of the approved user when the user checks the approval status", func() {
+		a.Default(false)
+	})
+})
+
+var approvalListMeta = a.Type("ApprovalListMeta", func() {
+	a.Attribute("totalCount", d.Integer)
+	a.Required("totalCount")
+})
+
+var approvalSingle = JSONSingle(
+	"Approval", "Holds a single approval",
+	approvalData,
+	nil)
+
+var approvalList = JSONList(
+	"Approval", "Holds the paginated list of approvals",
+	approvalData,
+	pagingLinks,
+	approvalListMeta)
+
+var _ = a.Resource("approval", func() {
+	a.BasePath("/approvals")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the users waiting for approval. Only admin service accounts are allowed to approve users.")
+		a.Params(func() {
+			a.Param("status", d.String, "Filter by the approval status", func() {
+				a.Enum("pending", "approved", "rejected")
+			})
+			a.Param("page[offset]", d.String, "Paging start position")
+			a.Param("page[limit]", d.Integer, "Paging size")
+		})
+		a.Response(d.OK, approvalList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("approve", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/approve"),
+		)
+		a.Description("Approve the user and set the approved attribute of the user in Keycloak")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the approval")
+		})
+		a.Payload(approvalDecision)
+		a.Response(d.OK, approvalSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("reject", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/reject"),
+		)
+		a.Description("Reject the user")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the approval")
+		})
+		a.Payload(approvalDecision)
+		a.Response(d.OK, approvalSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("status", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/status"),
+		)
+		a.Description("Get the approval status of the authenticated user")
+		a.Response(d.OK, approvalSingle)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
@@ -0,0 +1,78 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// clusterData represents a configured OpenShift cluster and the number of users provisioned to it
+var clusterData = a.Type("ClusterData", func() {
+	a.Attribute("type", d.String, "type of the cluster", func() {
+		a.Enum("clusters")
+	})
+	a.Attribute("id", d.String, "Name of the cluster", func() {
+		a.Example("us-east-2")
+	})
+	a.Attribute("attributes", clusterDataAttributes, "Attributes of the cluster")
+	a.Required("type", "id", "attributes")
+})
+
+var clusterDataAttributes = a.Type("ClusterDataAttributes", func() {
+	a.Attribute("name", d.String, "The name of the cluster")
+	a.Attribute("api-url", d.String, "The OpenShift API URL of the cluster", func() {
+		a.Example("https://api.starter-us-east-2.openshift.com")
+	})
+	a.Attribute("region", d.String, "The region the cluster is located in")
+	a.Attribute("capacity", d.Integer, "The maximum number of users provisioned to the cluster. 0 if unlimited")
+	a.Attribute("weight", d.Integer, "The relative share of the new users given to the cluster")
+	a.Attribute("users", d.Integer, "The number of users provisioned to the cluster")
+	a.Attribute("full", d.Boolean, "True if the cluster has reached its capacity. No new users are allocated to a full cluster")
+	a.Required("name", "api-url", "users", "full")
+})
+
+var clusterList = JSONList(
+	"Cluster", "Holds the list of clusters",
+	clusterData,
+	nil,
+	nil)
+
+var clusterMoveRequest = a.Type("ClusterMoveRequest", func() {
+	a.Attribute("cluster", d.String, "The OpenShift API URL of the cluster to move the user to")
+	a.Required("cluster")
+})
+
+var _ = a.Resource("cluster", func() {
+	a.BasePath("/clusters")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the configured clusters with the number of users provisioned to each of them. Only admin service accounts are allowed to see the cluster usage")
+		a.Response(d.OK, clusterList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("move-user", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/users/:identityID"),
+		)
+		a.Description("Move the user to another cluster. Only admin service accounts are allowed to move users")
+		a.Params(func() {
+			a.Param("identityID", d.UUID, "ID of the identity of the user to move")
+		})
+		a.Payload(clusterMoveRequest)
+		a.Response(d.OK, func() {
+			a.Media(user)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
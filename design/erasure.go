@@ -0,0 +1,136 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// erasureData represents the deactivation of a user and the erasure of the user data
+var erasureData = a.Type("ErasureData", func() {
+	a.Attribute("type", d.String, "type of the erasure", func() {
+		a.Enum("erasures")
+	})
+	a.Attribute("id", d.String, "ID of the erasure", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", erasureDataAttributes, "Attributes of the erasure")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var erasureDataAttributes = a.Type("ErasureDataAttributes", func() {
+	a.Attribute("identity_id", d.String, "The ID of the Keycloak identity of the deactivated user")
+	a.Attribute("status", d.String, "The erasure status", func() {
+		a.Enum("pending", "completed", "failed")
+	})
+	a.Attribute("step", d.String, "The last step of the erasure completed successfully", func() {
+		a.Enum("external_tokens", "identity_roles", "exports", "keycloak", "wit", "profile")
+	})
+	a.Attribute("attempts", d.Integer, "The number of attempts to erase the user")
+	a.Attribute("last_error", d.String, "The error of the last failed attempt")
+	a.Attribute("requested_by", d.String, "The user or the admin who requested the deactivation")
+	a.Attribute("erase_after", d.DateTime, "The end of the grace period. The user data is not erased before this date")
+	a.Attribute("completed_at", d.DateTime, "The date the erasure has been completed")
+	a.Attribute("created-at", d.DateTime, "The date of the deactivation")
+	a.Required("identity_id", "status")
+})
+
+var erasureRequest = a.Type("ErasureRequest", func() {
+	a.Attribute("identity_id", d.UUID, "The ID of the Keycloak identity of the user to deactivate. Only admin service accounts can deactivate other users")
+})
+
+var erasureRunRequest = a.Type("ErasureRunRequest", func() {
+	a.Attribute("force", d.Boolean, "Erase the user before the end of the grace period", func() {
+		a.Default(false)
+	})
+})
+
+var erasureListMeta = a.Type("ErasureListMeta", func() {
+	a.Attribute("totalCount", d.Integer)
+	a.Required("totalCount")
+})
+
+var erasureSingle = JSONSingle(
+	"Erasure", "Holds a single user erasure",
+	erasureData,
+	nil)
+
+var erasureList = JSONList(
+	"Erasure", "Holds the paginated list of user erasures",
+	erasureData,
+	pagingLinks,
+	erasureListMeta)
+
+var _ = a.Resource("erasure", func() {
+	a.BasePath("/erasures")
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Deactivate a user and schedule the erasure of the user data. Deactivated users can't log in nor retrieve tokens. Without identity_id the authenticated user is deactivated")
+		a.OptionalPayload(erasureRequest)
+		a.Response(d.Created, erasureSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the user erasures. Only admin service accounts are allowed to list the erasures.")
+		a.Params(func() {
+			a.Param("status", d.String, "Filter by the erasure status", func() {
+				a.Enum("pending", "completed", "failed")
+			})
+			a.Param("due", d.Boolean, "If true then list only the erasures which are not completed and whose grace period has elapsed")
+			a.Param("page[offset]", d.String, "Paging start position")
+			a.Param("page[limit]", d.Integer, "Paging size")
+		})
+		a.Response(d.OK, erasureList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Get the user erasure")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the erasure")
+		})
+		a.Response(d.OK, erasureSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("run", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/run"),
+		)
+		a.Description("Erase the data of the deactivated user from auth, Keycloak and WIT. A failed erasure is resumed from the last completed step")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the erasure")
+		})
+		a.OptionalPayload(erasureRunRequest)
+		a.Response(d.OK, erasureSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
@@ -0,0 +1,103 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// exportData represents the export of the personal data of a user
+var exportData = a.Type("ExportData", func() {
+	a.Attribute("type", d.String, "type of the export", func() {
+		a.Enum("exports")
+	})
+	a.Attribute("id", d.String, "ID of the export", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", exportDataAttributes, "Attributes of the export")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var exportDataAttributes = a.Type("ExportDataAttributes", func() {
+	a.Attribute("identity_id", d.String, "The ID of the identity whose data is exported")
+	a.Attribute("status", d.String, "The export status", func() {
+		a.Enum("pending", "completed", "failed")
+	})
+	a.Attribute("last_error", d.String, "The error if the export failed")
+	a.Attribute("requested_by", d.String, "The user or the admin who requested the export")
+	a.Attribute("completed_at", d.DateTime, "The date the archive has been built")
+	a.Attribute("expires_at", d.DateTime, "The date after which the archive can't be downloaded anymore")
+	a.Attribute("created-at", d.DateTime, "The date of the request")
+	a.Required("identity_id", "status")
+})
+
+var exportSingle = JSONSingle(
+	"Export", "Holds a single personal data export",
+	exportData,
+	nil)
+
+var _ = a.Resource("export", func() {
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/user/export"),
+		)
+		a.Description("Export the personal data of the authenticated user. The archive is built in the background and can be downloaded from the related link once completed")
+		a.Response(d.Accepted, exportSingle)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create_for_user", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/users/:id/export"),
+		)
+		a.Description("Export the personal data of the user of the given identity. Only admin service accounts are allowed to export the data of other users")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity")
+		})
+		a.Response(d.Accepted, exportSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/exports/:id"),
+		)
+		a.Description("Get the status of the personal data export")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the export")
+		})
+		a.Response(d.OK, exportSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("download", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/exports/:id/download"),
+		)
+		a.Description("Download the archive with the personal data. The archive is not found if the export is not completed or has expired")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the export")
+		})
+		a.Response(d.OK, "application/json")
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
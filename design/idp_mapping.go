@@ -0,0 +1,127 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// idpMappingData represents a home-realm discovery mapping
+var idpMappingData = a.Type("IdpMappingData", func() {
+	a.Attribute("type", d.String, "type of the mapping", func() {
+		a.Enum("idp_mappings")
+	})
+	a.Attribute("id", d.String, "ID of the mapping", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", idpMappingDataAttributes, "Attributes of the mapping")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "attributes")
+})
+
+// idpMappingDataAttributes maps an email domain to an Identity Provider
+var idpMappingDataAttributes = a.Type("IdpMappingDataAttributes", func() {
+	a.Attribute("domain", d.String, "The email domain of the users. The mapping applies to the subdomains too unless they have their own mapping", func() {
+		a.Example("redhat.com")
+	})
+	a.Attribute("idp_hint", d.String, "The alias of the Keycloak Identity Provider (such as an upstream SAML or OpenID Connect broker) passed to Keycloak as the kc_idp_hint param", func() {
+		a.Example("redhat-sso")
+	})
+	a.Attribute("created-at", d.DateTime, "The date of creation of the mapping")
+	a.Attribute("updated-at", d.DateTime, "The date of the last update of the mapping")
+	a.Required("domain", "idp_hint")
+})
+
+var idpMappingSingle = JSONSingle(
+	"IdpMapping", "Holds a single Identity Provider mapping",
+	idpMappingData,
+	nil)
+
+var idpMappingList = JSONList(
+	"IdpMapping", "Holds the list of Identity Provider mappings",
+	idpMappingData,
+	nil,
+	nil)
+
+var _ = a.Resource("idp_mapping", func() {
+	a.BasePath("/idp_mappings")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the mappings of email domains to Identity Providers used for home-realm discovery. Only admin service accounts are allowed to manage the mappings.")
+		a.Response(d.OK, idpMappingList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Retrieve the Identity Provider mapping for the given ID")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the mapping")
+		})
+		a.Response(d.OK, idpMappingSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Map an email domain to an Identity Provider")
+		a.Payload(idpMappingSingle)
+		a.Response(d.Created, idpMappingSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("update", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:id"),
+		)
+		a.Description("Update the Identity Provider mapping")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the mapping")
+		})
+		a.Payload(idpMappingSingle)
+		a.Response(d.OK, idpMappingSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id"),
+		)
+		a.Description("Delete the Identity Provider mapping")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the mapping")
+		})
+		a.Response(d.OK)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
@@ -30,7 +30,7 @@ var _ = a.Resource("link", func() {
 			a.GET("/session"),
 		)
 		a.Params(func() {
-			a.Param("provider", d.String, "Identity Provider name to link to the user's account. If not set then link all available providers.")
+			a.Param("provider", d.String, "Identity Provider name to link to the user's account. Multiple providers should be separated by comma. If not set then link all available providers.")
 			a.Param("redirect", d.String, "URL to be redirected to after successful account linking. If not set then will redirect to the referrer instead.")
 			a.Param("sessionState", d.String, "Session state")
 		})
@@ -47,7 +47,7 @@ var _ = a.Resource("link", func() {
 		)
 		a.Params(func() {
 			a.Param("state", d.String, "State generated by the link request")
-			a.Param("next", d.String, "Next provider to be linked. If not set then linking is complete.")
+			a.Param("next", d.String, "Next providers to be linked separated by comma. If not set then linking is complete.")
 			a.Param("sessionState", d.String, "Session state")
 		})
 		a.Description("Callback from Keyckloak when Identity Provider account successfully linked to the user account")
@@ -56,4 +56,43 @@ var _ = a.Resource("link", func() {
 		a.Response(d.BadRequest, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
+
+	a.Action("status", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/status"),
+		)
+		a.Description("Get the link status of each Identity Provider (GitHub and each OpenShift cluster) and the username of the user in the linked providers")
+		a.Response(d.OK, linkStatusList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
 })
+
+// linkStatusData represents the link status between the user account and an Identity Provider
+var linkStatusData = a.Type("LinkStatusData", func() {
+	a.Attribute("type", d.String, "type of the link status", func() {
+		a.Enum("link-status")
+	})
+	a.Attribute("id", d.String, "Name of the Identity Provider, \"github\" or \"openshift-<cluster name>\"", func() {
+		a.Example("openshift-us-east-2")
+	})
+	a.Attribute("attributes", linkStatusDataAttributes, "Attributes of the link status")
+	a.Required("type", "id", "attributes")
+})
+
+var linkStatusDataAttributes = a.Type("LinkStatusDataAttributes", func() {
+	a.Attribute("provider", d.String, "Name of the Identity Provider")
+	a.Attribute("for", d.String, "The resource URL to be used to link the provider via /api/token/link", func() {
+		a.Example("https://api.starter-us-east-2.openshift.com")
+	})
+	a.Attribute("linked", d.Boolean, "True if the user account is linked to the Identity Provider")
+	a.Attribute("username", d.String, "The username of the user in the linked Identity Provider if known")
+	a.Required("provider", "for", "linked")
+})
+
+var linkStatusList = JSONList(
+	"LinkStatus", "Holds the link status of each Identity Provider",
+	linkStatusData,
+	nil,
+	nil)
@@ -20,13 +20,122 @@ var _ = a.Resource("login", func() {
 				a.Enum("offline_access")
 				a.Description("If scope=offline_access then an offline token will be issued instead of a regular refresh token")
 			})
-			a.Param("api_client", d.String, "The name of the api client which is requesting a token")
+			a.Param("api_client", d.String, "The client ID of the api client which is requesting a token. Clients which are not registered yet are limited to the valid redirect URLs of auth")
+			a.Param("user_code", d.String, "The user code of the device authorization request to approve after successful login")
+			a.Param("login_hint", d.String, "The email or the email domain of the user. If the domain is mapped to an Identity Provider then the user is sent straight to that provider instead of the Keycloak login page")
+			a.Param("max_age", d.Integer, func() {
+				a.Minimum(0)
+				a.Description("The max time in seconds since the last active authentication of the user. If elapsed then the user has to authenticate again. Use the max_age value from the WWW-Authenticate step-up challenge")
+			})
+			a.Param("acr_values", d.String, "Space separated Authentication Context Class Reference values requested for the authentication. Use the acr_values value from the WWW-Authenticate step-up challenge")
 		})
 		a.Description("Login user")
 		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
 		a.Response(d.TemporaryRedirect)
+		a.Response(d.TooManyRequests, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+	})
+
+	a.Action("device", func() {
+		a.Routing(
+			a.POST("device"),
+		)
+		a.Payload(deviceAuthorizationRequest)
+		a.Description("Start the OAuth 2.0 device authorization grant. Issues a device code to be used by the device when polling the token endpoint and a user code to be approved by the user in a browser")
+		a.Response(d.OK, func() {
+			a.Media(DeviceAuthorization)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("verify", func() {
+		a.Routing(
+			a.GET("device/verify"),
+		)
+		a.Params(func() {
+			a.Param("user_code", d.String, "The user code displayed by the device")
+			a.Param("redirect", d.String, "URL to be redirected to after the user code is approved. If not set then will redirect to the referrer instead.")
+			a.Required("user_code")
+		})
+		a.Description("Verification page of the device authorization grant. Redirects to the login flow which asks the user to confirm the request of the device after successful login")
+		a.Response(d.TemporaryRedirect)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("confirm", func() {
+		a.Routing(
+			a.GET("device/confirm"),
+		)
+		a.Params(func() {
+			a.Param("user_code", d.String, "The user code displayed by the device")
+			a.Param("confirmation", d.String, "The confirmation code issued after successful login from the verification page")
+			a.Required("user_code", "confirmation")
+		})
+		a.Description("Confirmation page of the device authorization grant. Shows the client and the user code so the logged in user can approve or deny the request of the device")
+		a.Response(d.OK, "text/html")
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("approve", func() {
+		a.Routing(
+			a.POST("device/confirm"),
+		)
+		a.Payload(deviceConfirmation)
+		a.Description("Approve or deny the user code of the device authorization grant. Only the approved device gets the token of the logged in user")
+		a.Response(d.TemporaryRedirect)
 		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var deviceConfirmation = a.Type("DeviceConfirmation", func() {
+	a.Attribute("user_code", d.String, "The user code displayed by the device")
+	a.Attribute("confirmation", d.String, "The confirmation code issued after successful login from the verification page")
+	a.Attribute("decision", d.String, func() {
+		a.Enum("approve", "deny")
+		a.Description("The decision of the user")
+	})
+	a.Required("user_code", "confirmation", "decision")
+})
+
+var deviceAuthorizationRequest = a.Type("DeviceAuthorizationRequest", func() {
+	a.Attribute("client_id", d.String, "The client ID of the registered api client which is requesting a token")
+	a.Attribute("scope", d.String, func() {
+		a.Enum("offline_access")
+		a.Description("If scope=offline_access then an offline token will be issued instead of a regular refresh token")
+	})
+	a.Required("client_id")
+})
+
+// DeviceAuthorization represents a device authorization response as defined in RFC 8628
+var DeviceAuthorization = a.MediaType("application/vnd.deviceauthorization+json", func() {
+	a.TypeName("DeviceAuthorization")
+	a.Description("OAuth 2.0 device authorization response")
+	a.Attributes(func() {
+		a.Attribute("device_code", d.String, "The device verification code")
+		a.Attribute("user_code", d.String, "The end-user verification code")
+		a.Attribute("verification_uri", d.String, "The end-user verification URI")
+		a.Attribute("verification_uri_complete", d.String, "The verification URI that includes the user code")
+		a.Attribute("expires_in", d.Integer, "The lifetime in seconds of the device code and user code")
+		a.Attribute("interval", d.Integer, "The minimum amount of time in seconds that the client should wait between polling requests to the token endpoint")
+		a.Required("device_code", "user_code", "verification_uri", "expires_in", "interval")
+	})
+	a.View("default", func() {
+		a.Attribute("device_code")
+		a.Attribute("user_code")
+		a.Attribute("verification_uri")
+		a.Attribute("verification_uri_complete")
+		a.Attribute("expires_in")
+		a.Attribute("interval")
+		a.Required("device_code", "user_code", "verification_uri", "expires_in", "interval")
 	})
 })
 
@@ -41,7 +150,7 @@ var _ = a.Resource("logout", func() {
 		a.Params(func() {
 			a.Param("redirect", d.String, "URL to be redirected to after successful logout. If not set then will redirect to the referrer instead.")
 		})
-		a.Description("Logout user")
+		a.Description("Logout user. If the request is authorized then the session of the token is revoked: the refresh and offline tokens of the session are revoked, tokens from this session are rejected by auth and the registered clients are notified via OpenID Connect Back-Channel Logout")
 		a.Response(d.BadRequest, JSONAPIErrors)
 		a.Response(d.TemporaryRedirect)
 		a.Response(d.InternalServerError, JSONAPIErrors)
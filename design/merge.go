@@ -0,0 +1,76 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// mergeData represents the merge of a duplicate user into another user
+var mergeData = a.Type("MergeData", func() {
+	a.Attribute("type", d.String, "type of the merge", func() {
+		a.Enum("merges")
+	})
+	a.Attribute("id", d.String, "ID of the merge", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", mergeDataAttributes, "Attributes of the merge")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var mergeDataAttributes = a.Type("MergeDataAttributes", func() {
+	a.Attribute("source_identity_id", d.String, "The ID of the merged Keycloak identity")
+	a.Attribute("source_user_id", d.String, "The ID of the merged user")
+	a.Attribute("target_identity_id", d.String, "The ID of the Keycloak identity the user has been merged into")
+	a.Attribute("target_user_id", d.String, "The ID of the user the user has been merged into")
+	a.Attribute("merged_by", d.String, "The admin who merged the users")
+	a.Attribute("created-at", d.DateTime, "The date of the merge")
+	a.Required("source_identity_id", "source_user_id", "target_identity_id", "target_user_id")
+})
+
+var mergeRequest = a.Type("MergeRequest", func() {
+	a.Attribute("source_identity_id", d.UUID, "The ID of the Keycloak identity of the duplicate user. The identity and its user are deleted")
+	a.Attribute("target_identity_id", d.UUID, "The ID of the Keycloak identity of the user to keep")
+	a.Required("source_identity_id", "target_identity_id")
+})
+
+var mergeSingle = JSONSingle(
+	"Merge", "Holds a single user merge",
+	mergeData,
+	nil)
+
+var _ = a.Resource("merge", func() {
+	a.BasePath("/merges")
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Merge a duplicate user into another user. Only admin service accounts are allowed to merge users")
+		a.Payload(mergeRequest)
+		a.Response(d.Created, mergeSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Get the user merge")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the merge")
+		})
+		a.Response(d.OK, mergeSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
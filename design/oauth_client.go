@@ -0,0 +1,133 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// oauthClientData represents a registered API client
+var oauthClientData = a.Type("OauthClientData", func() {
+	a.Attribute("type", d.String, "type of the registered client", func() {
+		a.Enum("oauth_clients")
+	})
+	a.Attribute("id", d.String, "ID of the registered client", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", oauthClientDataAttributes, "Attributes of the registered client")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "attributes")
+})
+
+// oauthClientDataAttributes represents the settings of a registered API client
+var oauthClientDataAttributes = a.Type("OauthClientDataAttributes", func() {
+	a.Attribute("client_id", d.String, "The client ID used as the api_client param of the login endpoint and the client_id param of the token endpoint", func() {
+		a.Example("vscode")
+	})
+	a.Attribute("name", d.String, "The display name of the client")
+	a.Attribute("redirect_uris", a.ArrayOf(d.String), "The redirect URIs allowed for the client. The redirect is compared without its query and fragment")
+	a.Attribute("redirect_uri_patterns", a.ArrayOf(d.String), "Regular expressions matching the complete redirect URIs allowed for the client")
+	a.Attribute("grant_types", a.ArrayOf(d.String), "The OAuth 2.0 grant types the client is allowed to use", func() {
+		a.Example([]string{"authorization_code", "refresh_token"})
+	})
+	a.Attribute("access_token_lifetime", d.Integer, "The max lifetime in seconds of the access tokens returned to the client")
+	a.Attribute("refresh_token_lifetime", d.Integer, "The max lifetime in seconds of the refresh tokens returned to the client")
+	a.Attribute("claims", a.ArrayOf(d.String), "The token claims the client receives")
+	a.Attribute("created-at", d.DateTime, "The date of creation of the client")
+	a.Attribute("updated-at", d.DateTime, "The date of the last update of the client")
+	a.Required("client_id")
+})
+
+var oauthClientSingle = JSONSingle(
+	"OauthClient", "Holds a single registered API client",
+	oauthClientData,
+	nil)
+
+var oauthClientList = JSONList(
+	"OauthClient", "Holds the list of registered API clients",
+	oauthClientData,
+	nil,
+	nil)
+
+var _ = a.Resource("oauth_client", func() {
+	a.BasePath("/clients")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the registered API clients. Only admin service accounts are allowed to manage clients.")
+		a.Response(d.OK, oauthClientList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Retrieve the registered API client for the given ID")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the registered client")
+		})
+		a.Response(d.OK, oauthClientSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Register a new API client")
+		a.Payload(oauthClientSingle)
+		a.Response(d.Created, oauthClientSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("update", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:id"),
+		)
+		a.Description("Update the settings of the registered API client")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the registered client")
+		})
+		a.Payload(oauthClientSingle)
+		a.Response(d.OK, oauthClientSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id"),
+		)
+		a.Description("Delete the registered API client")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the registered client")
+		})
+		a.Response(d.OK)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
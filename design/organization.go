@@ -0,0 +1,357 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// organizationData represents an organization managing the access of its users by team
+var organizationData = a.Type("OrganizationData", func() {
+	a.Attribute("type", d.String, "type of the organization", func() {
+		a.Enum("organizations")
+	})
+	a.Attribute("id", d.String, "ID of the organization", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", organizationDataAttributes, "Attributes of the organization")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "attributes")
+})
+
+var organizationDataAttributes = a.Type("OrganizationDataAttributes", func() {
+	a.Attribute("name", d.String, "The name of the organization", func() {
+		a.Example("Red Hat")
+	})
+	a.Attribute("description", d.String, "The description of the organization")
+	a.Attribute("owner_id", d.String, "The ID of the identity managing the teams of the organization. Defaults to the identity creating the organization. Only admin service accounts can create an organization for another identity")
+	a.Attribute("created-at", d.DateTime, "The date of creation of the organization")
+	a.Attribute("updated-at", d.DateTime, "The date of the last update of the organization")
+	a.Required("name")
+})
+
+var organizationSingle = JSONSingle(
+	"Organization", "Holds a single organization",
+	organizationData,
+	nil)
+
+var organizationList = JSONList(
+	"Organization", "Holds the list of organizations",
+	organizationData,
+	nil,
+	nil)
+
+// teamData represents a team of users of an organization
+var teamData = a.Type("TeamData", func() {
+	a.Attribute("type", d.String, "type of the team", func() {
+		a.Enum("teams")
+	})
+	a.Attribute("id", d.String, "ID of the team", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", teamDataAttributes, "Attributes of the team")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "attributes")
+})
+
+var teamDataAttributes = a.Type("TeamDataAttributes", func() {
+	a.Attribute("name", d.String, "The name of the team, unique in its organization", func() {
+		a.Example("developers")
+	})
+	a.Attribute("description", d.String, "The description of the team")
+	a.Attribute("organization_id", d.String, "The ID of the organization of the team")
+	a.Attribute("created-at", d.DateTime, "The date of creation of the team")
+	a.Attribute("updated-at", d.DateTime, "The date of the last update of the team")
+	a.Required("name")
+})
+
+var teamSingle = JSONSingle(
+	"Team", "Holds a single team",
+	teamData,
+	nil)
+
+var teamList = JSONList(
+	"Team", "Holds the list of teams",
+	teamData,
+	nil,
+	nil)
+
+// teamRoleData represents a role held by a team on a resource
+var teamRoleData = a.Type("TeamRoleData", func() {
+	a.Attribute("type", d.String, "type of the team role", func() {
+		a.Enum("team_roles")
+	})
+	a.Attribute("id", d.String, "ID of the team role", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", teamRoleDataAttributes, "Attributes of the team role")
+	a.Required("type", "attributes")
+})
+
+var teamRoleDataAttributes = a.Type("TeamRoleDataAttributes", func() {
+	a.Attribute("resource_id", d.String, "The ID of the resource", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("role", d.String, "The name of the role, defined for the type of the resource", func() {
+		a.Example("contributor")
+	})
+	a.Attribute("created-at", d.DateTime, "The date the role was given to the team")
+	a.Required("resource_id", "role")
+})
+
+var teamRoleSingle = JSONSingle(
+	"TeamRole", "Holds a single role of a team",
+	teamRoleData,
+	nil)
+
+var teamRoleList = JSONList(
+	"TeamRole", "Holds the list of roles of a team",
+	teamRoleData,
+	nil,
+	nil)
+
+var _ = a.Resource("organization", func() {
+	a.BasePath("/organizations")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the organizations")
+		a.Response(d.OK, organizationList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Retrieve the organization for the given ID")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the organization")
+		})
+		a.Response(d.OK, organizationSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Create an organization managed by the current user")
+		a.Payload(organizationSingle)
+		a.Response(d.Created, organizationSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id"),
+		)
+		a.Description("Delete the organization and its teams. The members of the teams are removed from the collaborators of the spaces of the teams. Only the owner of the organization and the admin service accounts are allowed to delete it")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the organization")
+		})
+		a.Response(d.OK)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("list-teams", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/teams"),
+		)
+		a.Description("List the teams of the organization")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the organization")
+		})
+		a.Response(d.OK, teamList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create-team", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/teams"),
+		)
+		a.Description("Create a team in the organization. Only the owner of the organization and the admin service accounts are allowed to manage its teams")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the organization")
+		})
+		a.Payload(teamSingle)
+		a.Response(d.Created, teamSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var _ = a.Resource("team", func() {
+	a.BasePath("/teams")
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Retrieve the team for the given ID. Only the members of the team, the owner of the organization and the admin service accounts are allowed to see the team")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the team")
+		})
+		a.Response(d.OK, teamSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id"),
+		)
+		a.Description("Delete the team. Its members are removed from the collaborators of the spaces on which the team holds a role")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the team")
+		})
+		a.Response(d.OK)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("list-members", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/members"),
+		)
+		a.Description("List the members of the team. Only the members of the team, the owner of the organization and the admin service accounts are allowed to list them")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the team")
+		})
+		a.Response(d.OK, userList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("add-member", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/members/:identityID"),
+		)
+		a.Description("Add a user to the members of the team. The user is added to the collaborators of the spaces on which the team holds a role")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the team")
+			a.Param("identityID", d.UUID, "ID of the identity of the user")
+		})
+		a.Response(d.OK)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("remove-member", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id/members/:identityID"),
+		)
+		a.Description(`Remove a user from the members of the team. The user is removed from the collaborators of the spaces of the team
+unless they own the space or hold a role on it through another team`)
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the team")
+			a.Param("identityID", d.UUID, "ID of the identity of the user")
+		})
+		a.Response(d.OK)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("list-roles", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/roles"),
+		)
+		a.Description("List the roles held by the team on resources. Only the members of the team, the owner of the organization and the admin service accounts are allowed to list them")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the team")
+		})
+		a.Response(d.OK, teamRoleList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("add-role", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/roles"),
+		)
+		a.Description(`Give the team a role on a resource, such as the contributor role on a space.
+The members of the team are added to the collaborators of the space, so they have access to the space as long as they belong to the team.
+Only the owner of the organization, on the resources they own, and the admin service accounts are allowed to give roles to teams`)
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the team")
+		})
+		a.Payload(teamRoleSingle)
+		a.Response(d.Created, teamRoleSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("remove-role", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id/roles/:roleID"),
+		)
+		a.Description(`Take the role back from the team. The members of the team are removed from the collaborators of the space
+unless they own the space or hold a role on it through another team. Only the owner of the organization and the admin service accounts are allowed to take roles back`)
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the team")
+			a.Param("roleID", d.UUID, "ID of the team role")
+		})
+		a.Response(d.OK)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
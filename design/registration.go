@@ -0,0 +1,107 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// usernameData represents the availability of a username or the reservation of the username
+var usernameData = a.Type("UsernameData", func() {
+	a.Attribute("type", d.String, "type of the username", func() {
+		a.Enum("usernames")
+	})
+	a.Attribute("id", d.String, "The username", func() {
+		a.Example("john-doe")
+	})
+	a.Attribute("attributes", usernameDataAttributes, "Attributes of the username")
+	a.Required("type", "id", "attributes")
+})
+
+var usernameDataAttributes = a.Type("UsernameDataAttributes", func() {
+	a.Attribute("available", d.Boolean, "True if the username can be used by the authenticated user")
+	a.Attribute("reason", d.String, "The reason why the username is not available", func() {
+		a.Enum("invalid", "taken", "reserved")
+	})
+	a.Attribute("reserved_until", d.DateTime, "The date until the username is held for the authenticated user")
+	a.Required("available")
+})
+
+var usernameSingle = JSONSingle(
+	"Username", "Holds the availability of a username",
+	usernameData,
+	nil)
+
+// registrationData represents the final step of the registration
+var registrationData = a.Type("RegistrationData", func() {
+	a.Attribute("type", d.String, "type of the registration", func() {
+		a.Enum("registrations")
+	})
+	a.Attribute("attributes", registrationDataAttributes, "Attributes of the registration")
+	a.Required("type", "attributes")
+})
+
+var registrationDataAttributes = a.Type("RegistrationDataAttributes", func() {
+	a.Attribute("username", d.String, "The username chosen by the user. Should be available or reserved for the user")
+	a.Attribute("terms_accepted", d.Boolean, "Should be true. The user has accepted the terms of service")
+	a.Attribute("fullName", d.String, "The user's full name")
+	a.Attribute("company", d.String, "The company")
+	a.Attribute("region", d.String, "The preferred region of the cluster to provision the user to")
+	a.Required("username", "terms_accepted")
+})
+
+var registrationSingle = JSONSingle(
+	"Registration", "Holds the final step of the registration",
+	registrationData,
+	nil)
+
+var _ = a.Resource("registration", func() {
+	a.BasePath("/registration")
+
+	a.Action("username", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/usernames/:username"),
+		)
+		a.Description("Check if the username is available for the authenticated user")
+		a.Params(func() {
+			a.Param("username", d.String, "The username to check")
+		})
+		a.Response(d.OK, usernameSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("reserve", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/usernames/:username"),
+		)
+		a.Description("Hold the username for the authenticated user while the user completes the registration. The previous reservation of the user is released")
+		a.Params(func() {
+			a.Param("username", d.String, "The username to reserve")
+		})
+		a.Response(d.OK, usernameSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("complete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Complete the registration of the authenticated user. Creates or updates the user and the identity, assigns a cluster and updates the user in Keycloak")
+		a.Payload(registrationSingle)
+		a.Response(d.OK, func() {
+			a.Media(user)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
@@ -9,21 +9,26 @@ var _ = a.Resource("resource", func() {
 
 	a.BasePath("/resource")
 
-	a.DefaultMedia(ResourceMedia)
+	a.CanonicalActionName("read")
 
 	a.Action("register", func() {
+		a.Security("jwt")
 		a.Routing(
 			a.POST(""),
 		)
-		a.Description("Register a new resource")
+		a.Description(`Register a new resource owned by the calling identity. The type is the name of an existing resource type,
+the parent resource must be of the same type and the scopes must be defined for the type.
+The scopes of a resource are always all the scopes of its type, the requested scopes are only validated`)
 		a.Payload(ResourceMedia)
 		a.Response(d.Unauthorized, JSONAPIErrors)
-		a.Response(d.Created, JSONAPIErrors)
+		a.Response(d.Created, ResourceMedia)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
 	})
 
 	a.Action("read", func() {
+		a.Security("jwt")
 		a.Routing(
 			a.GET("/:resourceId"),
 		)
@@ -31,6 +36,7 @@ var _ = a.Resource("resource", func() {
 			a.Param("resourceId", d.String, "The identifier of the resource to read")
 		})
 		a.Description("Read a specific resource")
+		a.Response(d.OK, ResourceMedia)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.TemporaryRedirect)
 		a.Response(d.InternalServerError, JSONAPIErrors)
@@ -39,13 +45,17 @@ var _ = a.Resource("resource", func() {
 	})
 
 	a.Action("update", func() {
+		a.Security("jwt")
 		a.Routing(
 			a.PUT("/:resourceId"),
 		)
 		a.Params(func() {
 			a.Param("resourceId", d.String, "Identifier of the resource to update")
 		})
-		a.Description("Update the details of the specified resource")
+		a.Description(`Update the details of the specified resource. Only the owner of the resource and the admin service accounts are allowed to update it.
+The type and the identifier of a resource can't be changed and the requested scopes are only validated against the scopes of the type`)
+		a.Payload(ResourceMedia)
+		a.Response(d.OK, ResourceMedia)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.Forbidden, JSONAPIErrors)
 		a.Response(d.TemporaryRedirect)
@@ -55,18 +65,77 @@ var _ = a.Resource("resource", func() {
 	})
 
 	a.Action("delete", func() {
+		a.Security("jwt")
 		a.Routing(
 			a.DELETE("/:resourceId"),
 		)
 		a.Params(func() {
 			a.Param("resourceId", d.String, "Identifier of the resource to delete")
 		})
-		a.Description("Delete a resource")
+		a.Description(`Delete a resource and the roles assigned on it. Only the owner of the resource and the admin service accounts are allowed to delete it.
+A resource can't be deleted while it is the parent of other resources`)
+		a.Response(d.OK)
 		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
 		a.Response(d.TemporaryRedirect)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
 		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+	})
+
+	a.Action("list-roles", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:resourceId/roles"),
+		)
+		a.Params(func() {
+			a.Param("resourceId", d.String, "Identifier of the resource")
+		})
+		a.Description("List the roles assigned to identities on the resource. Only the owner of the resource and the admin service accounts are allowed to list the roles")
+		a.Response(d.OK, identityRoleList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("assign-role", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:resourceId/roles"),
+		)
+		a.Params(func() {
+			a.Param("resourceId", d.String, "Identifier of the resource")
+		})
+		a.Description(`Assign a role on the resource to an identity, replacing the role previously assigned to the identity.
+The role must be defined for the type of the resource. Only the owner of the resource and the admin service accounts are allowed to assign roles`)
+		a.Payload(identityRoleSingle)
+		a.Response(d.OK, identityRoleSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("revoke-role", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:resourceId/roles"),
+		)
+		a.Params(func() {
+			a.Param("resourceId", d.String, "Identifier of the resource")
+			a.Param("identity_id", d.UUID, "ID of the identity whose role is revoked")
+			a.Required("identity_id")
+		})
+		a.Description("Revoke the role assigned to an identity on the resource. Only the owner of the resource and the admin service accounts are allowed to revoke roles")
+		a.Response(d.OK)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
 	})
 
 })
@@ -75,7 +144,7 @@ var _ = a.Resource("resource", func() {
 var ResourceMedia = a.MediaType("application/vnd.resource+json", func() {
 	a.Description("A Protected Resource")
 	a.Attributes(func() {
-		a.Attribute("resource_scopes", a.ArrayOf(d.String), "The valid scopes for this resource")
+		a.Attribute("resource_scopes", a.ArrayOf(d.String), "The valid scopes for this resource, which are the scopes of its type")
 		a.Attribute("icon_uri", d.String, "The URI containing the icon for the resource")
 		a.Attribute("name", d.String, "The name of the resource")
 		a.Attribute("type", d.String, "The type of resource")
@@ -92,3 +161,38 @@ var ResourceMedia = a.MediaType("application/vnd.resource+json", func() {
 		a.Attribute("resource_id")
 	})
 })
+
+// identityRoleData represents a role assigned to an identity on a resource
+var identityRoleData = a.Type("IdentityRoleData", func() {
+	a.Attribute("type", d.String, "type of the identity role", func() {
+		a.Enum("identity_roles")
+	})
+	a.Attribute("id", d.String, "ID of the identity role", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("attributes", identityRoleDataAttributes, "Attributes of the identity role")
+	a.Required("type", "attributes")
+})
+
+var identityRoleDataAttributes = a.Type("IdentityRoleDataAttributes", func() {
+	a.Attribute("identity_id", d.String, "The ID of the identity", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
+	a.Attribute("role", d.String, "The name of the role, defined for the type of the resource", func() {
+		a.Example("contributor")
+	})
+	a.Attribute("created-at", d.DateTime, "The date the role was assigned to the identity")
+	a.Attribute("updated-at", d.DateTime, "The date of the last change of the role assigned to the identity")
+	a.Required("identity_id", "role")
+})
+
+var identityRoleSingle = JSONSingle(
+	"IdentityRole", "Holds a single role of an identity",
+	identityRoleData,
+	nil)
+
+var identityRoleList = JSONList(
+	"IdentityRole", "Holds the list of roles assigned to identities on a resource",
+	identityRoleData,
+	nil,
+	nil)
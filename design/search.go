@@ -12,9 +12,13 @@ var _ = a.Resource("search", func() {
 		a.Routing(
 			a.GET("users"),
 		)
-		a.Description("Search by fullname")
+		a.Description("Search users by fullname, email or username. Every whitespace separated term of the query must match and the results are ordered by relevance.")
 		a.Params(func() {
 			a.Param("q", d.String)
+			a.Param("filter[provider_type]", d.String, "Only return identities of the given provider type. Defaults to 'kc' so that each user is returned once")
+			a.Param("filter[cluster]", d.String, "Only return users provisioned on the given cluster")
+			a.Param("filter[registration_completed]", d.Boolean, "Only return users whose registration is (or is not) completed")
+			a.Param("filter[team]", d.UUID, "Only return the members of the given team")
 			a.Param("page[offset]", d.String, "Paging start position") // #428
 			a.Param("page[limit]", d.Integer, "Paging size")
 			a.Required("q")
@@ -75,6 +75,7 @@ var _ = a.Resource("token", func() {
 		})
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.TooManyRequests, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
 
@@ -117,6 +118,7 @@ var _ = a.Resource("token", func() {
 		})
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.TooManyRequests, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
 
@@ -176,11 +178,12 @@ var refreshToken = a.Type("RefreshToken", func() {
 
 var tokenExchange = a.Type("TokenExchange", func() {
 	a.Attribute("grant_type", d.String, func() {
-		a.Enum("client_credentials")
-		a.Description("Grant type. If set to \"client_credentials\" then this token exchange request is for a Protection API Token (PAT). PAT can be used to authenticate the corresponding Service Account.")
+		a.Enum("client_credentials", "urn:ietf:params:oauth:grant-type:device_code")
+		a.Description("Grant type. If set to \"client_credentials\" then this token exchange request is for a Protection API Token (PAT). PAT can be used to authenticate the corresponding Service Account. If set to \"urn:ietf:params:oauth:grant-type:device_code\" then this is a polling request of the device authorization grant.")
 	})
-	a.Attribute("client_id", d.String, "Service Account ID. Used to obtain a PAT for this service account.")
+	a.Attribute("client_id", d.String, "Service Account ID. Used to obtain a PAT for this service account. For the device authorization grant it's the client ID of the registered api client which requested the device code.")
 	a.Attribute("client_secret", d.String, "Service Account secret. Used to obtain a PAT for this service account.")
+	a.Attribute("device_code", d.String, "The device verification code issued by the device authorization endpoint. Required for the device authorization grant.")
 	a.Required("grant_type")
 })
 
@@ -216,10 +219,14 @@ var OauthToken = a.MediaType("application/vnd.oauthtoken+json", func() {
 	a.Attributes(func() {
 		a.Attribute("access_token", d.String, "Access token")
 		a.Attribute("token_type", d.String, "Token type")
+		a.Attribute("refresh_token", d.String, "Refresh token")
+		a.Attribute("expires_in", d.String, "Access token expires in seconds")
 	})
 	a.View("default", func() {
 		a.Attribute("access_token")
 		a.Attribute("token_type")
+		a.Attribute("refresh_token")
+		a.Attribute("expires_in")
 	})
 })
 
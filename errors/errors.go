@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	errs "github.com/pkg/errors"
 )
@@ -96,14 +97,70 @@ type ForbiddenError struct {
 	simpleError
 }
 
-// VersionConflictError means that the version was not as expected in an update operation
+// VersionConflictError means that the version was not as expected in an update operation.
+// Stale is true if the entity has been modified since the version the update is based on,
+// in which case the update precondition failed.
 type VersionConflictError struct {
 	simpleError
+	Stale bool
+}
+
+// TooManyRequestsError means that the request has been throttled.
+// RetryAfter is the time the client should wait before retrying.
+type TooManyRequestsError struct {
+	simpleError
+	RetryAfter time.Duration
+}
+
+// NewTooManyRequestsError returns the custom defined error of type TooManyRequestsError.
+func NewTooManyRequestsError(msg string, retryAfter time.Duration) TooManyRequestsError {
+	return TooManyRequestsError{simpleError: simpleError{msg}, RetryAfter: retryAfter}
+}
+
+// IsTooManyRequestsError returns true if the cause of the given error can be
+// converted to a TooManyRequestsError, which is returned as the second result.
+func IsTooManyRequestsError(err error) (bool, error) {
+	e, ok := errs.Cause(err).(TooManyRequestsError)
+	if !ok {
+		return false, nil
+	}
+	return true, e
+}
+
+// StepUpRequiredError means that the token is valid but the user must re-authenticate
+// because the authentication is too old or its level is too low for the requested action.
+// MaxAge is the max allowed age of the authentication or zero if not required.
+// ACRValues are the accepted Authentication Context Class References or empty if not required.
+type StepUpRequiredError struct {
+	simpleError
+	MaxAge    time.Duration
+	ACRValues []string
+}
+
+// NewStepUpRequiredError returns the custom defined error of type StepUpRequiredError.
+func NewStepUpRequiredError(msg string, maxAge time.Duration, acrValues []string) StepUpRequiredError {
+	return StepUpRequiredError{simpleError: simpleError{msg}, MaxAge: maxAge, ACRValues: acrValues}
+}
+
+// IsStepUpRequiredError returns true if the cause of the given error can be
+// converted to a StepUpRequiredError, which is returned as the second result.
+func IsStepUpRequiredError(err error) (bool, error) {
+	e, ok := errs.Cause(err).(StepUpRequiredError)
+	if !ok {
+		return false, nil
+	}
+	return true, e
 }
 
 // NewVersionConflictError returns the custom defined error of type VersionConflictError.
 func NewVersionConflictError(msg string) VersionConflictError {
-	return VersionConflictError{simpleError{msg}}
+	return VersionConflictError{simpleError: simpleError{msg}}
+}
+
+// NewStaleVersionError returns a VersionConflictError for the update of an entity which has been modified
+// since the version the update is based on.
+func NewStaleVersionError(entity string, id string) VersionConflictError {
+	return VersionConflictError{simpleError: simpleError{fmt.Sprintf("%s %s has been modified since the given version", entity, id)}, Stale: true}
 }
 
 // IsVersionConflictError returns true if the cause of the given error can be
@@ -199,3 +256,28 @@ func IsNotFoundError(err error) (bool, error) {
 	}
 	return true, e
 }
+
+// ValidationError means that a value doesn't comply with the configured policy.
+// Field is the name of the invalid attribute and Rule is the name of the rule which is not satisfied,
+// for example "reserved" or "max_length".
+type ValidationError struct {
+	simpleError
+	Field string
+	Value interface{}
+	Rule  string
+}
+
+// NewValidationError returns the custom defined error of type ValidationError.
+func NewValidationError(field string, value interface{}, rule string, msg string) ValidationError {
+	return ValidationError{simpleError: simpleError{msg}, Field: field, Value: value, Rule: rule}
+}
+
+// IsValidationError returns true if the cause of the given error can be
+// converted to a ValidationError, which is returned as the second result.
+func IsValidationError(err error) (bool, error) {
+	e, ok := errs.Cause(err).(ValidationError)
+	if !ok {
+		return false, nil
+	}
+	return true, e
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/resource"
@@ -100,6 +101,15 @@ func TestIsXYError(t *testing.T) {
 		{"IsVersionConflictError - is a VersionConflictError", errors.NewVersionConflictError("some message"), errors.IsVersionConflictError, true},
 		{"IsVersionConflictError - is a wrapped VersionConflictError", errs.Wrap(errs.Wrap(errors.NewVersionConflictError("some message"), "msg1"), "msg2"), errors.IsVersionConflictError, true},
 		{"IsVersionConflictError - is not a VersionConflictError", errors.NewInternalError(ctx, errs.New("some message")), errors.IsVersionConflictError, false},
+		{"IsTooManyRequestsError - is a TooManyRequestsError", errors.NewTooManyRequestsError("some message", time.Second), errors.IsTooManyRequestsError, true},
+		{"IsTooManyRequestsError - is a wrapped TooManyRequestsError", errs.Wrap(errs.Wrap(errors.NewTooManyRequestsError("some message", time.Second), "msg1"), "msg2"), errors.IsTooManyRequestsError, true},
+		{"IsTooManyRequestsError - is not a TooManyRequestsError", errors.NewUnauthorizedError("some message"), errors.IsTooManyRequestsError, false},
+		{"IsStepUpRequiredError - is a StepUpRequiredError", errors.NewStepUpRequiredError("some message", time.Minute, nil), errors.IsStepUpRequiredError, true},
+		{"IsStepUpRequiredError - is a wrapped StepUpRequiredError", errs.Wrap(errs.Wrap(errors.NewStepUpRequiredError("some message", 0, []string{"2"}), "msg1"), "msg2"), errors.IsStepUpRequiredError, true},
+		{"IsStepUpRequiredError - is not a StepUpRequiredError", errors.NewUnauthorizedError("some message"), errors.IsStepUpRequiredError, false},
+		{"IsValidationError - is a ValidationError", errors.NewValidationError("username", "admin", "reserved", "some message"), errors.IsValidationError, true},
+		{"IsValidationError - is a wrapped ValidationError", errs.Wrap(errs.Wrap(errors.NewValidationError("username", "admin", "reserved", "some message"), "msg1"), "msg2"), errors.IsValidationError, true},
+		{"IsValidationError - is not a ValidationError", errors.NewBadParameterError("username", "admin"), errors.IsValidationError, false},
 	}
 	for _, tc := range testCases {
 		// Note that we need to capture the range variable to ensure that tc
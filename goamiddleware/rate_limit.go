@@ -0,0 +1,362 @@
+package goamiddleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// maxRateLimitBodySize is the max size of the request body read to extract the client_id
+	maxRateLimitBodySize = 64 * 1024
+	// bucketSweepInterval is the interval between the removals of the idle buckets
+	bucketSweepInterval = time.Minute
+	// maxTrackedKeys is the number of buckets or lockouts above which the idle ones are removed right away
+	maxTrackedKeys = 100000
+)
+
+// RateLimitConfiguration represents the configuration of the rate limiter
+type RateLimitConfiguration interface {
+	GetRateLimits() map[string]string
+	GetRateLimitLockoutThreshold() int
+	GetRateLimitLockoutBase() time.Duration
+	GetRateLimitLockoutMax() time.Duration
+	GetRateLimitTrustedProxies() int
+}
+
+// rateLimit is the token bucket limit of an action
+type rateLimit struct {
+	// burst is the capacity of the bucket
+	burst float64
+	// rate is the number of tokens added to the bucket per second
+	rate float64
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type lockout struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// RateLimiter limits the number of requests per action using the token bucket algorithm.
+// Each request consumes a token from the bucket of the client IP, the client_id and the identity
+// of the request. The request is throttled if any of these buckets is empty.
+// The client IP is only limited if the service is configured to run behind trusted proxies:
+// otherwise the remote address is the address of the router and is shared by all the clients.
+// RateLimiter also locks out clients after repeated failed client secret checks from the same IP.
+// The state is kept in memory so the limits apply per instance of the service.
+// Idle buckets and expired lockouts are removed periodically.
+type RateLimiter struct {
+	limits           map[string]rateLimit
+	lockoutThreshold int
+	lockoutBase      time.Duration
+	lockoutMax       time.Duration
+	trustedProxies   int
+
+	mux       sync.Mutex
+	buckets   map[string]*bucket
+	lockouts  map[string]*lockout
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a new rate limiter with the per action limits from the configuration
+func NewRateLimiter(config RateLimitConfiguration) (*RateLimiter, error) {
+	limits := map[string]rateLimit{}
+	for action, value := range config.GetRateLimits() {
+		limit, err := parseRateLimit(value)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid rate limit of action '%s'", action)
+		}
+		limits[strings.ToLower(action)] = *limit
+	}
+	return &RateLimiter{
+		limits:           limits,
+		lockoutThreshold: config.GetRateLimitLockoutThreshold(),
+		lockoutBase:      config.GetRateLimitLockoutBase(),
+		lockoutMax:       config.GetRateLimitLockoutMax(),
+		trustedProxies:   config.GetRateLimitTrustedProxies(),
+		buckets:          map[string]*bucket{},
+		lockouts:         map[string]*lockout{},
+		now:              time.Now,
+	}, nil
+}
+
+// parseRateLimit parses a rate limit in the "<requests>/<period>" format, for example "20/1m"
+func parseRateLimit(value string) (*rateLimit, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected '<requests>/<period>' but got '%s'", value)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || requests <= 0 {
+		return nil, fmt.Errorf("expected a positive number of requests but got '%s'", parts[0])
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || period <= 0 {
+		return nil, fmt.Errorf("expected a positive period but got '%s'", parts[1])
+	}
+	return &rateLimit{
+		burst: float64(requests),
+		rate:  float64(requests) / period.Seconds(),
+	}, nil
+}
+
+// Middleware returns a goa middleware which throttles the requests of the actions with a configured rate limit.
+// Throttled requests are rejected with a TooManyRequestsError.
+func (l *RateLimiter) Middleware() goa.Middleware {
+	return func(nextHandler goa.Handler) goa.Handler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			action := actionName(ctx)
+			if _, found := l.limits[action]; !found {
+				return nextHandler(ctx, rw, req)
+			}
+			keys := l.requestKeys(ctx, req)
+			if retryAfter := l.Allow(action, keys...); retryAfter > 0 {
+				log.Warn(ctx, map[string]interface{}{
+					"action":      action,
+					"keys":        keys,
+					"retry_after": retryAfter.String(),
+				}, "request throttled")
+				return errors.NewTooManyRequestsError("too many requests", retryAfter)
+			}
+			return nextHandler(ctx, rw, req)
+		}
+	}
+}
+
+// requestKeys returns the keys of the buckets the request consumes a token from.
+// The client IP is left out if no trusted proxy is configured.
+func (l *RateLimiter) requestKeys(ctx context.Context, req *http.Request) []string {
+	var keys []string
+	if l.trustedProxies > 0 {
+		keys = append(keys, "ip:"+l.ClientIP(req))
+	}
+	if clientID := requestClientID(req); clientID != "" {
+		keys = append(keys, "client:"+clientID)
+	}
+	if identity := requestIdentity(ctx); identity != "" {
+		keys = append(keys, "identity:"+identity)
+	}
+	return keys
+}
+
+// Allow consumes a token from the bucket of each given key of the action.
+// Returns zero if the request is allowed. Otherwise no token is consumed and the time to wait
+// until all the buckets have a token again is returned.
+func (l *RateLimiter) Allow(action string, keys ...string) time.Duration {
+	limit, found := l.limits[strings.ToLower(action)]
+	if !found {
+		return 0
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	now := l.now()
+	l.sweep(now)
+	var wait time.Duration
+	buckets := make([]*bucket, len(keys))
+	for i, key := range keys {
+		b := l.refill(strings.ToLower(action)+"/"+key, limit, now)
+		if b.tokens < 1 {
+			missing := time.Duration((1 - b.tokens) / limit.rate * float64(time.Second))
+			if missing > wait {
+				wait = missing
+			}
+		}
+		buckets[i] = b
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0
+}
+
+// refill returns the bucket of the given key with the tokens added since the last request
+func (l *RateLimiter) refill(key string, limit rateLimit, now time.Time) *bucket {
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: limit.burst, last: now}
+		l.buckets[key] = b
+		return b
+	}
+	b.tokens = math.Min(limit.burst, b.tokens+now.Sub(b.last).Seconds()*limit.rate)
+	b.last = now
+	return b
+}
+
+// sweep removes the buckets which have been refilled completely and the lockouts of the clients
+// which have not failed for longer than the max lockout duration.
+// The sweep runs periodically or right away if too many keys are tracked.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketSweepInterval && len(l.buckets) < maxTrackedKeys && len(l.lockouts) < maxTrackedKeys {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		action := key[:strings.Index(key, "/")]
+		limit := l.limits[action]
+		if b.tokens+now.Sub(b.last).Seconds()*limit.rate >= limit.burst {
+			delete(l.buckets, key)
+		}
+	}
+	for key, lo := range l.lockouts {
+		if now.Sub(lo.lastFailure) > l.lockoutMax && now.After(lo.lockedUntil) {
+			delete(l.lockouts, key)
+		}
+	}
+}
+
+// lockoutKey returns the key of the failed client secret checks of the client from the given IP.
+// Failures from one IP don't lock out the client from the other IPs.
+func lockoutKey(clientID string, ip string) string {
+	return clientID + "@" + ip
+}
+
+// LockedOut returns the remaining lockout time of the client from the given IP or zero if the client is not locked out
+func (l *RateLimiter) LockedOut(clientID string, ip string) time.Duration {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	now := l.now()
+	l.sweep(now)
+	lo, found := l.lockouts[lockoutKey(clientID, ip)]
+	if !found {
+		return 0
+	}
+	if remaining := lo.lockedUntil.Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// RecordFailure records a failed client secret check from the given IP. Once the number of consecutive failures
+// reaches the threshold the client is locked out from this IP. The lockout duration is doubled with every subsequent
+// failure up to the configured maximum. Returns the lockout duration or zero if the client is not locked out.
+func (l *RateLimiter) RecordFailure(clientID string, ip string) time.Duration {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	now := l.now()
+	l.sweep(now)
+	key := lockoutKey(clientID, ip)
+	lo, found := l.lockouts[key]
+	if !found {
+		lo = &lockout{}
+		l.lockouts[key] = lo
+	}
+	lo.failures++
+	lo.lastFailure = now
+	if lo.failures < l.lockoutThreshold {
+		return 0
+	}
+	duration := l.lockoutMax
+	if exp := uint(lo.failures - l.lockoutThreshold); exp < 32 {
+		if backoff := l.lockoutBase * time.Duration(1<<exp); backoff > 0 && backoff < l.lockoutMax {
+			duration = backoff
+		}
+	}
+	lo.lockedUntil = now.Add(duration)
+	return duration
+}
+
+// RecordSuccess resets the failed client secret checks of the client from the given IP
+func (l *RateLimiter) RecordSuccess(clientID string, ip string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	delete(l.lockouts, lockoutKey(clientID, ip))
+}
+
+// ClientIP returns the IP of the client. If the service runs behind trusted proxies then the address
+// added to the X-Forwarded-For header by the outermost trusted proxy is used. The addresses before it
+// are set by the client and can't be trusted. Otherwise the remote address of the connection is used.
+func (l *RateLimiter) ClientIP(req *http.Request) string {
+	if l.trustedProxies > 0 {
+		forwarded := strings.Split(strings.Join(req.Header["X-Forwarded-For"], ","), ",")
+		if len(forwarded) >= l.trustedProxies {
+			if ip := strings.TrimSpace(forwarded[len(forwarded)-l.trustedProxies]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// requestClientID returns the client_id (or api_client) from the query or the request body.
+// The body is restored so it can still be decoded by the action.
+func requestClientID(req *http.Request) string {
+	query := req.URL.Query()
+	if clientID := query.Get("client_id"); clientID != "" {
+		return clientID
+	}
+	if clientID := query.Get("api_client"); clientID != "" {
+		return clientID
+	}
+	if req.Body == nil || req.ContentLength > maxRateLimitBodySize {
+		return ""
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxRateLimitBodySize+1))
+	req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+	if err != nil || len(body) > maxRateLimitBodySize {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err == nil {
+			return values.Get("client_id")
+		}
+	case "application/json", "":
+		var payload struct {
+			ClientID string `json:"client_id"`
+		}
+		if json.Unmarshal(body, &payload) == nil {
+			return payload.ClientID
+		}
+	}
+	return ""
+}
+
+// requestIdentity returns the subject of the valid token of the request if any
+func requestIdentity(ctx context.Context) string {
+	token := goajwt.ContextJWT(ctx)
+	if token == nil || !token.Valid {
+		return ""
+	}
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if sub, ok := claims["sub"].(string); ok {
+			return sub
+		}
+	}
+	return ""
+}
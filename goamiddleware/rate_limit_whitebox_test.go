@@ -0,0 +1,218 @@
+package goamiddleware
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rateLimitConfig struct {
+	limits         map[string]string
+	trustedProxies int
+}
+
+func (c rateLimitConfig) GetRateLimits() map[string]string {
+	return c.limits
+}
+
+func (c rateLimitConfig) GetRateLimitLockoutThreshold() int {
+	return 3
+}
+
+func (c rateLimitConfig) GetRateLimitLockoutBase() time.Duration {
+	return 5 * time.Second
+}
+
+func (c rateLimitConfig) GetRateLimitLockoutMax() time.Duration {
+	return time.Minute
+}
+
+func (c rateLimitConfig) GetRateLimitTrustedProxies() int {
+	return c.trustedProxies
+}
+
+func newTestRateLimiter(t *testing.T, now *time.Time) *RateLimiter {
+	limiter, err := NewRateLimiter(rateLimitConfig{limits: map[string]string{"token.Exchange": "2/1m"}})
+	require.Nil(t, err)
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestParseRateLimit(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	limit, err := parseRateLimit("20/1m")
+	require.Nil(t, err)
+	assert.Equal(t, float64(20), limit.burst)
+	assert.InDelta(t, 20.0/60, limit.rate, 0.0001)
+
+	for _, value := range []string{"", "20", "0/1m", "x/1m", "20/0s", "20/x", "1/2/3"} {
+		_, err := parseRateLimit(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	now := time.Now()
+	limiter := newTestRateLimiter(t, &now)
+
+	// actions without a limit are never throttled
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, time.Duration(0), limiter.Allow("token.refresh", "ip:1.1.1.1"))
+	}
+
+	// the burst is allowed
+	assert.Equal(t, time.Duration(0), limiter.Allow("token.exchange", "ip:1.1.1.1", "client:sa"))
+	assert.Equal(t, time.Duration(0), limiter.Allow("token.exchange", "ip:1.1.1.1", "client:sa"))
+	// the bucket is empty now
+	retryAfter := limiter.Allow("token.exchange", "ip:1.1.1.1", "client:sa")
+	assert.InDelta(t, float64(30*time.Second), float64(retryAfter), float64(time.Millisecond))
+	// the same client is throttled from another IP
+	assert.True(t, limiter.Allow("token.exchange", "ip:2.2.2.2", "client:sa") > 0)
+	// the throttled request didn't consume the token of the other IP
+	assert.Equal(t, time.Duration(0), limiter.Allow("token.exchange", "ip:2.2.2.2"))
+
+	// a token is added after 30 seconds
+	now = now.Add(31 * time.Second)
+	assert.Equal(t, time.Duration(0), limiter.Allow("token.exchange", "ip:1.1.1.1", "client:sa"))
+	assert.True(t, limiter.Allow("token.exchange", "ip:1.1.1.1", "client:sa") > 0)
+}
+
+func TestLockout(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	now := time.Now()
+	limiter := newTestRateLimiter(t, &now)
+
+	// not locked out until the threshold is reached
+	assert.Equal(t, time.Duration(0), limiter.RecordFailure("sa", "1.1.1.1"))
+	assert.Equal(t, time.Duration(0), limiter.RecordFailure("sa", "1.1.1.1"))
+	assert.Equal(t, time.Duration(0), limiter.LockedOut("sa", "1.1.1.1"))
+
+	// the lockout is doubled with every failure up to the max
+	assert.Equal(t, 5*time.Second, limiter.RecordFailure("sa", "1.1.1.1"))
+	assert.Equal(t, 5*time.Second, limiter.LockedOut("sa", "1.1.1.1"))
+	assert.Equal(t, 10*time.Second, limiter.RecordFailure("sa", "1.1.1.1"))
+	assert.Equal(t, 20*time.Second, limiter.RecordFailure("sa", "1.1.1.1"))
+	assert.Equal(t, 40*time.Second, limiter.RecordFailure("sa", "1.1.1.1"))
+	assert.Equal(t, time.Minute, limiter.RecordFailure("sa", "1.1.1.1"))
+	for i := 0; i < 100; i++ {
+		limiter.RecordFailure("sa", "1.1.1.1")
+	}
+	assert.Equal(t, time.Minute, limiter.LockedOut("sa", "1.1.1.1"))
+	assert.Equal(t, time.Duration(0), limiter.LockedOut("another-sa", "1.1.1.1"))
+	// the client is not locked out from the other IPs
+	assert.Equal(t, time.Duration(0), limiter.LockedOut("sa", "2.2.2.2"))
+
+	// the lockout expires
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), limiter.LockedOut("sa", "1.1.1.1"))
+
+	// a successful check resets the failures
+	limiter.RecordSuccess("sa", "1.1.1.1")
+	assert.Equal(t, time.Duration(0), limiter.RecordFailure("sa", "1.1.1.1"))
+
+	// the expired lockouts are removed
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, time.Duration(0), limiter.LockedOut("sa", "1.1.1.1"))
+	assert.Empty(t, limiter.lockouts)
+}
+
+func TestSweep(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	now := time.Now()
+	limiter := newTestRateLimiter(t, &now)
+
+	limiter.Allow("token.exchange", "ip:1.1.1.1", "client:sa")
+	assert.Len(t, limiter.buckets, 2)
+	// the buckets are removed once they have been refilled completely
+	now = now.Add(2 * time.Minute)
+	limiter.Allow("token.exchange", "ip:2.2.2.2")
+	assert.Len(t, limiter.buckets, 1)
+}
+
+func TestRequestClientID(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	// form payload
+	body := "grant_type=client_credentials&client_id=sa&client_secret=secret"
+	req, err := http.NewRequest("POST", "http://auth/api/token", strings.NewReader(body))
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.Equal(t, "sa", requestClientID(req))
+	// the body can still be decoded by the action
+	restored, err := ioutil.ReadAll(req.Body)
+	require.Nil(t, err)
+	assert.Equal(t, body, string(restored))
+
+	// json payload
+	req, err = http.NewRequest("POST", "http://auth/api/token", strings.NewReader(`{"client_id":"sa"}`))
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	assert.Equal(t, "sa", requestClientID(req))
+
+	// query
+	req, err = http.NewRequest("GET", "http://auth/api/login?api_client=vscode", nil)
+	require.Nil(t, err)
+	assert.Equal(t, "vscode", requestClientID(req))
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	req, err := http.NewRequest("GET", "http://auth/api/login", nil)
+	require.Nil(t, err)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 3.3.3.3, 10.0.0.2")
+
+	// the header is ignored if there is no trusted proxy
+	limiter, err := NewRateLimiter(rateLimitConfig{})
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", limiter.ClientIP(req))
+
+	// the address added by the trusted proxy is used, not the ones set by the client
+	limiter, err = NewRateLimiter(rateLimitConfig{trustedProxies: 1})
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.2", limiter.ClientIP(req))
+	limiter, err = NewRateLimiter(rateLimitConfig{trustedProxies: 2})
+	require.Nil(t, err)
+	assert.Equal(t, "3.3.3.3", limiter.ClientIP(req))
+
+	// the request didn't go through all the trusted proxies
+	limiter, err = NewRateLimiter(rateLimitConfig{trustedProxies: 4})
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", limiter.ClientIP(req))
+}
+
+func TestRequestKeys(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	req, err := http.NewRequest("GET", "http://auth/api/login?api_client=vscode", nil)
+	require.Nil(t, err)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1")
+
+	// the clients behind the router share the remote address so the IP is not limited without a trusted proxy
+	limiter, err := NewRateLimiter(rateLimitConfig{})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"client:vscode"}, limiter.requestKeys(context.Background(), req))
+
+	limiter, err = NewRateLimiter(rateLimitConfig{trustedProxies: 1})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"ip:1.1.1.1", "client:vscode"}, limiter.requestKeys(context.Background(), req))
+}
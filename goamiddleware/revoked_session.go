@@ -0,0 +1,45 @@
+package goamiddleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+)
+
+// RevokedSessionValidation is a JWT validation middleware which rejects tokens issued
+// for a session which has been logged out. Tokens without the session_state claim
+// (such as service account tokens) are not affected.
+func RevokedSessionValidation(db application.DB) goa.Middleware {
+	return func(nextHandler goa.Handler) goa.Handler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			token := goajwt.ContextJWT(ctx)
+			if token != nil {
+				if claims, ok := token.Claims.(jwt.MapClaims); ok {
+					if sessionState, ok := claims["session_state"].(string); ok && sessionState != "" {
+						revoked, err := db.RevokedSessions().IsRevoked(ctx, sessionState)
+						if err != nil {
+							log.Error(ctx, map[string]interface{}{
+								"session_state": sessionState,
+								"err":           err,
+							}, "unable to check if the session has been revoked")
+							return err
+						}
+						if revoked {
+							log.Warn(ctx, map[string]interface{}{
+								"session_state": sessionState,
+							}, "token from a revoked session")
+							return goajwt.ErrJWTError("session has been logged out")
+						}
+					}
+				}
+			}
+			return nextHandler(ctx, rw, req)
+		}
+	}
+}
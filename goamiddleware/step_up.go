@@ -0,0 +1,172 @@
+package goamiddleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	errs "github.com/pkg/errors"
+)
+
+// StepUpConfiguration represents the configuration of the step-up authentication policy
+type StepUpConfiguration interface {
+	GetStepUpPolicies() map[string]string
+}
+
+// stepUpRequirement is the authentication required by an action
+type stepUpRequirement struct {
+	// maxAge is the max time elapsed since the user authenticated or zero if any authentication time is accepted
+	maxAge time.Duration
+	// acrValues are the accepted authentication levels (acr claim) or empty if any level is accepted
+	acrValues []string
+}
+
+// StepUpPolicy requires a recent authentication (auth_time claim) or a given authentication
+// level (acr claim) from the user tokens used to call the sensitive actions.
+// Tokens which don't qualify are rejected with a StepUpRequiredError telling the client
+// how to re-authenticate via /api/login before retrying the request.
+// Service account tokens are not affected.
+type StepUpPolicy struct {
+	requirements map[string]stepUpRequirement
+	now          func() time.Time
+}
+
+// NewStepUpPolicy creates a new step-up authentication policy with the per action requirements from the configuration
+func NewStepUpPolicy(config StepUpConfiguration) (*StepUpPolicy, error) {
+	requirements := map[string]stepUpRequirement{}
+	for action, value := range config.GetStepUpPolicies() {
+		requirement, err := parseStepUpRequirement(value)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid step-up requirement of action '%s'", action)
+		}
+		requirements[strings.ToLower(action)] = *requirement
+	}
+	return &StepUpPolicy{
+		requirements: requirements,
+		now:          time.Now,
+	}, nil
+}
+
+// parseStepUpRequirement parses a step-up requirement in the "max_age=<duration>,acr=<values>" format
+// where both settings are optional and the accepted acr values are separated by spaces, for example "max_age=5m,acr=1 2"
+func parseStepUpRequirement(value string) (*stepUpRequirement, error) {
+	requirement := stepUpRequirement{}
+	for _, setting := range strings.Split(value, ",") {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected '<name>=<value>' but got '%s'", setting)
+		}
+		switch strings.TrimSpace(parts[0]) {
+		case "max_age":
+			maxAge, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+			if err != nil || maxAge <= 0 {
+				return nil, fmt.Errorf("expected a positive max_age but got '%s'", parts[1])
+			}
+			requirement.maxAge = maxAge
+		case "acr":
+			requirement.acrValues = strings.Fields(parts[1])
+			if len(requirement.acrValues) == 0 {
+				return nil, fmt.Errorf("expected at least one acr value but got '%s'", parts[1])
+			}
+		default:
+			return nil, fmt.Errorf("expected 'max_age' or 'acr' but got '%s'", parts[0])
+		}
+	}
+	return &requirement, nil
+}
+
+// Middleware returns a goa middleware which checks the token of the requests to the actions with a step-up requirement.
+// Requests without a token are passed through and left to the security of the action.
+func (p *StepUpPolicy) Middleware() goa.Middleware {
+	return func(nextHandler goa.Handler) goa.Handler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			if err := p.Check(ctx, actionName(ctx)); err != nil {
+				return err
+			}
+			return nextHandler(ctx, rw, req)
+		}
+	}
+}
+
+// Check checks that the token in the context meets the step-up requirement of the given action.
+// It's used directly by the actions which only require a step-up for some of the requests,
+// for example "users.update.email" is checked only if the user is changing the email.
+// Returns a StepUpRequiredError if the token doesn't qualify.
+func (p *StepUpPolicy) Check(ctx context.Context, action string) error {
+	requirement, found := p.requirements[strings.ToLower(action)]
+	if !found {
+		return nil
+	}
+	jwtToken := goajwt.ContextJWT(ctx)
+	if jwtToken == nil || !jwtToken.Valid {
+		// Missing or invalid tokens are rejected by the security of the action
+		return nil
+	}
+	if _, isServiceAccount := token.ServiceAccountName(ctx); isServiceAccount {
+		return nil
+	}
+	claims, ok := jwtToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil
+	}
+	if requirement.maxAge > 0 {
+		authTime, ok := claims["auth_time"].(float64)
+		if !ok || p.now().Sub(time.Unix(int64(authTime), 0)) > requirement.maxAge {
+			log.Warn(ctx, map[string]interface{}{
+				"action":    action,
+				"auth_time": claims["auth_time"],
+				"max_age":   requirement.maxAge.String(),
+			}, "step-up authentication required: the authentication is too old")
+			return errors.NewStepUpRequiredError("the authentication is too old, please log in again", requirement.maxAge, requirement.acrValues)
+		}
+	}
+	if len(requirement.acrValues) > 0 {
+		acr, _ := claims["acr"].(string)
+		if !acrAccepted(acr, requirement.acrValues) {
+			log.Warn(ctx, map[string]interface{}{
+				"action":     action,
+				"acr":        acr,
+				"acr_values": requirement.acrValues,
+			}, "step-up authentication required: the authentication level is too low")
+			return errors.NewStepUpRequiredError("the authentication level is too low, please log in again", requirement.maxAge, requirement.acrValues)
+		}
+	}
+	return nil
+}
+
+// acrAccepted returns true if the acr equals one of the accepted values.
+// Numeric levels (such as the Keycloak "0" and "1" levels) are also accepted if higher than an accepted level.
+func acrAccepted(acr string, acrValues []string) bool {
+	if acr == "" {
+		return false
+	}
+	level, err := strconv.Atoi(acr)
+	for _, value := range acrValues {
+		if value == acr {
+			return true
+		}
+		if err == nil {
+			if minLevel, err := strconv.Atoi(value); err == nil && level >= minLevel {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// actionName returns the "<resource>.<action>" name of the action of the request in lower case.
+// Both "TokenController" and "token" controller names result in the "token" resource.
+func actionName(ctx context.Context) string {
+	controller := strings.TrimSuffix(strings.ToLower(goa.ContextController(ctx)), "controller")
+	return controller + "." + strings.ToLower(goa.ContextAction(ctx))
+}
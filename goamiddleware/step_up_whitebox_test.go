@@ -0,0 +1,110 @@
+package goamiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stepUpConfig struct {
+	policies map[string]string
+}
+
+func (c stepUpConfig) GetStepUpPolicies() map[string]string {
+	return c.policies
+}
+
+func contextWithClaims(claims jwt.MapClaims) context.Context {
+	return goajwt.WithJWT(context.Background(), &jwt.Token{Claims: claims, Valid: true})
+}
+
+func TestParseStepUpRequirement(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	requirement, err := parseStepUpRequirement("max_age=5m, acr=1 2")
+	require.Nil(t, err)
+	assert.Equal(t, 5*time.Minute, requirement.maxAge)
+	assert.Equal(t, []string{"1", "2"}, requirement.acrValues)
+
+	requirement, err = parseStepUpRequirement("acr=1")
+	require.Nil(t, err)
+	assert.Equal(t, time.Duration(0), requirement.maxAge)
+	assert.Equal(t, []string{"1"}, requirement.acrValues)
+
+	for _, value := range []string{"", "max_age", "max_age=0s", "max_age=x", "acr=", "foo=bar"} {
+		_, err := parseStepUpRequirement(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestStepUpCheck(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	policy, err := NewStepUpPolicy(stepUpConfig{policies: map[string]string{
+		"Token.Delete":       "max_age=5m",
+		"users.update.email": "acr=1",
+	}})
+	require.Nil(t, err)
+	now := time.Now()
+	policy.now = func() time.Time { return now }
+
+	t.Run("recent authentication", func(t *testing.T) {
+		ctx := contextWithClaims(jwt.MapClaims{"auth_time": float64(now.Add(-time.Minute).Unix())})
+		assert.Nil(t, policy.Check(ctx, "token.delete"))
+	})
+
+	t.Run("old authentication", func(t *testing.T) {
+		ctx := contextWithClaims(jwt.MapClaims{"auth_time": float64(now.Add(-10 * time.Minute).Unix())})
+		err := policy.Check(ctx, "token.delete")
+		stepUp, stepUpErr := errors.IsStepUpRequiredError(err)
+		require.True(t, stepUp)
+		assert.Equal(t, 5*time.Minute, stepUpErr.(errors.StepUpRequiredError).MaxAge)
+	})
+
+	t.Run("missing auth_time", func(t *testing.T) {
+		err := policy.Check(contextWithClaims(jwt.MapClaims{}), "token.delete")
+		stepUp, _ := errors.IsStepUpRequiredError(err)
+		assert.True(t, stepUp)
+	})
+
+	t.Run("acr", func(t *testing.T) {
+		assert.Nil(t, policy.Check(contextWithClaims(jwt.MapClaims{"acr": "1"}), "users.update.email"))
+		assert.Nil(t, policy.Check(contextWithClaims(jwt.MapClaims{"acr": "2"}), "users.update.email"))
+		err := policy.Check(contextWithClaims(jwt.MapClaims{"acr": "0"}), "users.update.email")
+		stepUp, stepUpErr := errors.IsStepUpRequiredError(err)
+		require.True(t, stepUp)
+		assert.Equal(t, []string{"1"}, stepUpErr.(errors.StepUpRequiredError).ACRValues)
+	})
+
+	t.Run("service account", func(t *testing.T) {
+		ctx := contextWithClaims(jwt.MapClaims{"service_accountname": "fabric8-tenant"})
+		assert.Nil(t, policy.Check(ctx, "token.delete"))
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		assert.Nil(t, policy.Check(context.Background(), "token.delete"))
+	})
+
+	t.Run("action without requirement", func(t *testing.T) {
+		assert.Nil(t, policy.Check(contextWithClaims(jwt.MapClaims{}), "token.retrieve"))
+	})
+}
+
+func TestActionName(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	service := goa.New("test")
+	assert.Equal(t, "token.delete", actionName(goa.WithAction(service.NewController("token").Context, "Delete")))
+	assert.Equal(t, "collaborators.remove-many", actionName(goa.WithAction(service.NewController("CollaboratorsController").Context, "remove-many")))
+}
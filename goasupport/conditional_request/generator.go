@@ -103,6 +103,7 @@ func canIgnoreStruct(structName string) bool {
 func WriteNames(api *design.APIDefinition, outDir string) ([]string, error) {
 	// Now iterate through the resources to gather their names
 	var contexts []RequestContext
+	var updateContexts []RequestContext
 	var entities []Entity
 
 	api.IterateResources(func(res *design.ResourceDefinition) error {
@@ -110,6 +111,10 @@ func WriteNames(api *design.APIDefinition, outDir string) ([]string, error) {
 			name := fmt.Sprintf("%v%vContext", codegen.Goify(act.Name, true), codegen.Goify(res.Name, true))
 			// look-up headers for conditional request support
 			if act.Headers != nil {
+				// reads are conditional on the 'If-Modified-Since' and 'If-None-Match' headers, updates on the 'If-Match' header
+				requestHeaders := act.Headers.Type.ToObject()
+				_, isConditionalRead := requestHeaders["If-None-Match"]
+				_, isConditionalUpdate := requestHeaders["If-Match"]
 				// look-up headers and entity types in responses
 				if act.Responses != nil {
 					for _, response := range act.Responses {
@@ -152,7 +157,12 @@ func WriteNames(api *design.APIDefinition, outDir string) ([]string, error) {
 									// 	fmt.Printf("%s -> %v\n", k, v)
 									// }
 									ctx := RequestContext{Name: name, Entity: *entity}
-									contexts = append(contexts, ctx)
+									if isConditionalRead {
+										contexts = append(contexts, ctx)
+									}
+									if isConditionalUpdate {
+										updateContexts = append(updateContexts, ctx)
+									}
 									if !contains(entities, *entity) {
 										entities = append(entities, *entity)
 									}
@@ -183,7 +193,9 @@ func WriteNames(api *design.APIDefinition, outDir string) ([]string, error) {
 		codegen.SimpleImport("time"),
 		codegen.SimpleImport("fmt"),
 		codegen.SimpleImport("reflect"),
+		codegen.SimpleImport("strings"),
 		codegen.SimpleImport("github.com/fabric8-services/fabric8-auth/configuration"),
+		codegen.SimpleImport("github.com/fabric8-services/fabric8-auth/errors"),
 		codegen.SimpleImport("github.com/fabric8-services/fabric8-auth/log"),
 		codegen.NewImport("uuid", "github.com/satori/go.uuid"),
 	}
@@ -201,6 +213,9 @@ func WriteNames(api *design.APIDefinition, outDir string) ([]string, error) {
 	if err := ctxWr.ExecuteTemplate("conditionalRequestContext", conditionalRequestContext, nil, nil); err != nil {
 		return nil, err
 	}
+	if err := ctxWr.ExecuteTemplate("conditionalUpdateContext", conditionalUpdateContext, nil, nil); err != nil {
+		return nil, err
+	}
 	if err := ctxWr.ExecuteTemplate("conditionalResponseEntity", conditionalResponseEntity, nil, nil); err != nil {
 		return nil, err
 	}
@@ -216,6 +231,9 @@ func WriteNames(api *design.APIDefinition, outDir string) ([]string, error) {
 	if err := ctxWr.ExecuteTemplate("matchesETag", matchesETag, nil, nil); err != nil {
 		return nil, err
 	}
+	if err := ctxWr.ExecuteTemplate("doCheckIfMatch", doCheckIfMatch, nil, nil); err != nil {
+		return nil, err
+	}
 	if err := ctxWr.ExecuteTemplate("toHTTPTime", toHTTPTime, nil, nil); err != nil {
 		return nil, err
 	}
@@ -239,6 +257,14 @@ func WriteNames(api *design.APIDefinition, outDir string) ([]string, error) {
 			return nil, err
 		}
 	}
+	for _, ctx := range updateContexts {
+		if err := ctxWr.ExecuteTemplate("conditionalUpdate", conditionalUpdate, nil, ctx); err != nil {
+			return nil, err
+		}
+		if err := ctxWr.ExecuteTemplate("getIfMatch", getIfMatch, nil, ctx); err != nil {
+			return nil, err
+		}
+	}
 	err = ctxWr.FormatCode()
 	if err != nil {
 		return nil, err
@@ -255,6 +281,8 @@ const (
 	LastModified = "Last-Modified"
 	// IfNoneMatch the "If-None-Match" HTTP request header name
 	IfNoneMatch = "If-None-Match"
+	// IfMatch the "If-Match" HTTP request header name
+	IfMatch = "If-Match"
 	// ETag the "ETag" HTTP response header name
 	// should be ETag but GOA will convert it to "Etag" when setting the header.
 	// Plus, RFC 2616 specifies that header names are case insensitive:
@@ -277,6 +305,12 @@ type ConditionalRequestContext interface {
 	setCacheControl(string)
 }`
 
+	conditionalUpdateContext = `
+// ConditionalUpdateContext interface with methods for the contexts of the updates
+type ConditionalUpdateContext interface {
+	getIfMatch() *string
+}`
+
 	conditionalResponseEntity = `
 	// ConditionalRequestEntity interface with methods for the response entities
 type ConditionalRequestEntity interface {
@@ -367,6 +401,17 @@ func (ctx *{{$resp.Name}}) ConditionalEntities(entities []{{$entity.DomainTypeNa
 	return doConditionalEntities(ctx, conditionalEntities, cacheControlConfig, nonConditionalCallback)
 }
 
+{{ end }}`
+	conditionalUpdate = `
+{{ $resp := . }}
+{{ $entity := $resp.Entity }}
+{{ if $entity.IsSingle }}
+// CheckIfMatch checks that the entity to update still matches the 'If-Match' header sent by the client.
+// Returns a stale VersionConflictError if the entity changed since the client's last call.
+func (ctx *{{$resp.Name}}) CheckIfMatch(entity {{$entity.DomainTypeName}}) error {
+	return doCheckIfMatch(ctx, entity)
+}
+
 {{ end }}`
 	generateETag = `
 // GenerateEmptyTag generates the value to return in the "ETag" HTTP response header for the an empty list of entities
@@ -458,6 +503,25 @@ func (ctx *{{$resp.Name}}) getIfNoneMatch() *string {
 	return ctx.IfNoneMatch
 }`
 
+	getIfMatch = `
+{{ $resp := . }}
+// getIfMatch gets the 'If-Match' header
+func (ctx *{{$resp.Name}}) getIfMatch() *string {
+	return ctx.IfMatch
+}`
+
+	doCheckIfMatch = `
+// doCheckIfMatch compares the context's 'IfMatch' value with the ETag of the given entity.
+// Returns nil if the 'If-Match' field was not found, is "*" or matches the ETag of the entity
+// Returns a stale VersionConflictError if the 'If-Match' field was found but did not match the ETag of the entity
+func doCheckIfMatch(ctx ConditionalUpdateContext, entity ConditionalRequestEntity) error {
+	ifMatch := ctx.getIfMatch()
+	if ifMatch == nil || *ifMatch == "*" || *ifMatch == GenerateEntityTag(entity) {
+		return nil
+	}
+	return errors.NewStaleVersionError(strings.ToLower(reflect.TypeOf(entity).Name()), fmt.Sprintf("%v", entity.GetETagData()[0]))
+}`
+
 	matchesETag = `
 // matchesETag compares the given 'etag' argument matches with the context's 'IfNoneMatch' value.
 // Returns 'true, true' if the 'If-None-Match' field was found and matched given 'etag' argument
@@ -0,0 +1,23 @@
+package goasupport
+
+import (
+	"context"
+
+	"github.com/goadesign/goa/client"
+	"github.com/goadesign/goa/middleware"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+)
+
+// DetachContext returns a new context which is not cancelled when the request of the given context completes.
+// The JWT and the request ID of the given context are kept so the new context can be used by background jobs
+// calling other services on behalf of the user.
+func DetachContext(ctx context.Context) context.Context {
+	detached := context.Background()
+	if token := goajwt.ContextJWT(ctx); token != nil {
+		detached = goajwt.WithJWT(detached, token)
+	}
+	if reqID := middleware.ContextRequestID(ctx); reqID != "" {
+		detached = client.SetContextRequestID(detached, reqID)
+	}
+	return detached
+}
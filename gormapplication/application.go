@@ -7,6 +7,8 @@ import (
 	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/application"
 	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/authorization/resource"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
 	"github.com/fabric8-services/fabric8-auth/space"
 	"github.com/fabric8-services/fabric8-auth/token/provider"
 	"github.com/jinzhu/gorm"
@@ -83,6 +85,101 @@ func (g *GormBase) ExternalTokens() provider.ExternalTokenRepository {
 	return provider.NewExternalTokenRepository(g.db)
 }
 
+// DeviceCodes returns a device code repository
+func (g *GormBase) DeviceCodes() auth.DeviceCodeRepository {
+	return auth.NewDeviceCodeRepository(g.db)
+}
+
+// RevokedSessions returns a revoked session repository
+func (g *GormBase) RevokedSessions() auth.RevokedSessionRepository {
+	return auth.NewRevokedSessionRepository(g.db)
+}
+
+// RefreshTokens returns a refresh token repository
+func (g *GormBase) RefreshTokens() auth.RefreshTokenRepository {
+	return auth.NewRefreshTokenRepository(g.db)
+}
+
+// OauthClients returns an oauth client repository
+func (g *GormBase) OauthClients() auth.OauthClientRepository {
+	return auth.NewOauthClientRepository(g.db)
+}
+
+// PendingApprovals returns a pending approval repository
+func (g *GormBase) PendingApprovals() account.PendingApprovalRepository {
+	return account.NewPendingApprovalRepository(g.db)
+}
+
+// IdpMappings returns an idp mapping repository
+func (g *GormBase) IdpMappings() auth.IdpMappingRepository {
+	return auth.NewIdpMappingRepository(g.db)
+}
+
+// UsernameReservations returns a username reservation repository
+func (g *GormBase) UsernameReservations() account.UsernameReservationRepository {
+	return account.NewUsernameReservationRepository(g.db)
+}
+
+// UserErasures returns a user erasure repository
+func (g *GormBase) UserErasures() account.UserErasureRepository {
+	return account.NewUserErasureRepository(g.db)
+}
+
+// UserExports returns a user export repository
+func (g *GormBase) UserExports() account.UserExportRepository {
+	return account.NewUserExportRepository(g.db)
+}
+
+// UserMerges returns a user merge repository
+func (g *GormBase) UserMerges() account.UserMergeRepository {
+	return account.NewUserMergeRepository(g.db)
+}
+
+// UserProfileHistory returns a user profile history repository
+func (g *GormBase) UserProfileHistory() account.UserProfileHistoryRepository {
+	return account.NewUserProfileHistoryRepository(g.db)
+}
+
+// IdentityRoles returns an identity role repository
+func (g *GormBase) IdentityRoles() role.IdentityRoleRepository {
+	return role.NewIdentityRoleRepository(g.db)
+}
+
+// Resources returns a resource repository
+func (g *GormBase) Resources() resource.ResourceRepository {
+	return resource.NewResourceRepository(g.db)
+}
+
+// ResourceTypes returns a resource type repository
+func (g *GormBase) ResourceTypes() resource.ResourceTypeRepository {
+	return resource.NewResourceTypeRepository(g.db)
+}
+
+// ResourceTypeScopes returns a resource type scope repository
+func (g *GormBase) ResourceTypeScopes() resource.ResourceTypeScopeRepository {
+	return resource.NewResourceTypeScopeRepository(g.db)
+}
+
+// Roles returns a role repository
+func (g *GormBase) Roles() role.RoleRepository {
+	return role.NewRoleRepository(g.db)
+}
+
+// Organizations returns an organization repository
+func (g *GormBase) Organizations() account.OrganizationRepository {
+	return account.NewOrganizationRepository(g.db)
+}
+
+// Teams returns a team repository
+func (g *GormBase) Teams() account.TeamRepository {
+	return account.NewTeamRepository(g.db)
+}
+
+// TeamRoles returns a team role repository
+func (g *GormBase) TeamRoles() role.TeamRoleRepository {
+	return role.NewTeamRoleRepository(g.db)
+}
+
 func (g *GormBase) DB() *gorm.DB {
 	return g.db
 }
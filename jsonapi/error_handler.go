@@ -43,6 +43,8 @@ func ErrorHandler(service *goa.Service, verbose bool) goa.Middleware {
 			var respBody interface{}
 			respBody, status = ErrorToJSONAPIErrors(ctx, e)
 			rw.Header().Set("Content-Type", ErrorMediaIdentifier)
+			SetRetryAfter(rw.Header(), e)
+			SetStepUpChallenge(rw.Header(), e)
 			if err, ok := cause.(goa.ServiceError); ok {
 				status = err.ResponseStatus()
 				//respBody = err
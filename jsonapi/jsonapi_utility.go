@@ -2,8 +2,11 @@ package jsonapi
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/errors"
@@ -23,6 +26,10 @@ const (
 	ErrorCodeUnauthorizedError = "unauthorized_error"
 	ErrorCodeForbiddenError    = "forbidden_error"
 	ErrorCodeJWTSecurityError  = "jwt_security_error"
+	ErrorCodeTooManyRequests   = "too_many_requests"
+	ErrorCodeValidationError   = "validation_error"
+	// ErrorCodeInsufficientUserAuthentication is the RFC 9470 error code of the step-up authentication challenge
+	ErrorCodeInsufficientUserAuthentication = "insufficient_user_authentication"
 )
 
 // ErrorToJSONAPIError returns the JSONAPI representation
@@ -35,6 +42,7 @@ func ErrorToJSONAPIError(ctx context.Context, err error) (app.JSONAPIError, int)
 	var title, code string
 	var statusCode int
 	var id *string
+	var source, meta map[string]interface{}
 	log.Info(ctx, map[string]interface{}{"err": cause, "error_message": cause.Error()}, "an error occurred in our api")
 	switch cause.(type) {
 	case errors.NotFoundError:
@@ -49,10 +57,21 @@ func ErrorToJSONAPIError(ctx context.Context, err error) (app.JSONAPIError, int)
 		code = ErrorCodeBadParameter
 		title = "Bad parameter error"
 		statusCode = http.StatusBadRequest
+	case errors.ValidationError:
+		code = ErrorCodeValidationError
+		title = "Validation error"
+		statusCode = http.StatusBadRequest
+		validationErr := cause.(errors.ValidationError)
+		source = map[string]interface{}{"pointer": "/data/attributes/" + validationErr.Field}
+		meta = map[string]interface{}{"rule": validationErr.Rule}
 	case errors.VersionConflictError:
 		code = ErrorCodeVersionConflict
 		title = "Version conflict error"
 		statusCode = http.StatusConflict
+		if cause.(errors.VersionConflictError).Stale {
+			title = "Precondition failed error"
+			statusCode = http.StatusPreconditionFailed
+		}
 	case errors.InternalError:
 		code = ErrorCodeInternalError
 		title = "Internal error"
@@ -61,10 +80,18 @@ func ErrorToJSONAPIError(ctx context.Context, err error) (app.JSONAPIError, int)
 		code = ErrorCodeUnauthorizedError
 		title = "Unauthorized error"
 		statusCode = http.StatusUnauthorized
+	case errors.StepUpRequiredError:
+		code = ErrorCodeInsufficientUserAuthentication
+		title = "Unauthorized error"
+		statusCode = http.StatusUnauthorized
 	case errors.ForbiddenError:
 		code = ErrorCodeForbiddenError
 		title = "Forbidden error"
 		statusCode = http.StatusForbidden
+	case errors.TooManyRequestsError:
+		code = ErrorCodeTooManyRequests
+		title = "Too many requests error"
+		statusCode = http.StatusTooManyRequests
 	default:
 		code = ErrorCodeUnknownError
 		title = "Unknown error"
@@ -89,6 +116,8 @@ func ErrorToJSONAPIError(ctx context.Context, err error) (app.JSONAPIError, int)
 		Status: &statusCodeStr,
 		Title:  &title,
 		Detail: detail,
+		Source: source,
+		Meta:   meta,
 	}
 	return jerr, statusCode
 }
@@ -133,6 +162,47 @@ type Conflict interface {
 	Conflict(*app.JSONAPIErrors) error
 }
 
+// PreconditionFailed represent a Context that can return a PreconditionFailed HTTP status
+type PreconditionFailed interface {
+	PreconditionFailed(*app.JSONAPIErrors) error
+}
+
+// TooManyRequests represent a Context that can return a TooManyRequests HTTP status
+type TooManyRequests interface {
+	TooManyRequests(*app.JSONAPIErrors) error
+}
+
+// SetRetryAfter sets the "Retry-After" HTTP response header if the cause of the given error is a TooManyRequestsError
+func SetRetryAfter(header http.Header, err error) {
+	if tooMany, ok := errs.Cause(err).(errors.TooManyRequestsError); ok {
+		seconds := int64(math.Ceil(tooMany.RetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		header.Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+}
+
+// SetStepUpChallenge sets the "WWW-Authenticate" HTTP response header if the cause of the given error is a StepUpRequiredError.
+// The challenge tells the client the max_age and acr_values to use when re-authenticating via /api/login
+// before retrying the request, for example:
+// Bearer error="insufficient_user_authentication", error_description="authentication is too old", max_age="300"
+func SetStepUpChallenge(header http.Header, err error) {
+	stepUp, ok := errs.Cause(err).(errors.StepUpRequiredError)
+	if !ok {
+		return
+	}
+	challenge := fmt.Sprintf("Bearer error=\"%s\", error_description=\"%s\"", ErrorCodeInsufficientUserAuthentication, stepUp.Error())
+	if stepUp.MaxAge > 0 {
+		challenge += fmt.Sprintf(", max_age=\"%d\"", int64(stepUp.MaxAge.Seconds()))
+	}
+	if len(stepUp.ACRValues) > 0 {
+		challenge += fmt.Sprintf(", acr_values=\"%s\"", strings.Join(stepUp.ACRValues, " "))
+	}
+	header.Set("Access-Control-Expose-Headers", "WWW-Authenticate")
+	header.Set("WWW-Authenticate", challenge)
+}
+
 // JSONErrorResponse auto maps the provided error to the correct response type
 // If all else fails, InternalServerError is returned
 func JSONErrorResponse(obj interface{}, err error) error {
@@ -150,6 +220,9 @@ func JSONErrorResponse(obj interface{}, err error) error {
 			return errs.WithStack(ctx.NotFound(jsonErr))
 		}
 	case http.StatusUnauthorized:
+		if resp := goa.ContextResponse(c); resp != nil {
+			SetStepUpChallenge(resp.Header(), err)
+		}
 		if ctx, ok := x.(Unauthorized); ok {
 			return errs.WithStack(ctx.Unauthorized(jsonErr))
 		}
@@ -161,6 +234,22 @@ func JSONErrorResponse(obj interface{}, err error) error {
 		if ctx, ok := x.(Conflict); ok {
 			return errs.WithStack(ctx.Conflict(jsonErr))
 		}
+	case http.StatusPreconditionFailed:
+		if ctx, ok := x.(PreconditionFailed); ok {
+			return errs.WithStack(ctx.PreconditionFailed(jsonErr))
+		}
+		if ctx, ok := x.(Conflict); ok {
+			return errs.WithStack(ctx.Conflict(jsonErr))
+		}
+	case http.StatusTooManyRequests:
+		if resp := goa.ContextResponse(c); resp != nil {
+			SetRetryAfter(resp.Header(), err)
+		}
+		if ctx, ok := x.(TooManyRequests); ok {
+			return errs.WithStack(ctx.TooManyRequests(jsonErr))
+		}
+		// Let the error handler send the response if the action doesn't define the TooManyRequests response
+		return errs.WithStack(err)
 	default:
 		return errs.WithStack(x.InternalServerError(jsonErr))
 	}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/errors"
@@ -70,6 +71,48 @@ func TestErrorToJSONAPIError(t *testing.T) {
 	require.Equal(t, jsonapi.ErrorCodeForbiddenError, *jerr.Code)
 	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
 
+	// test version conflict error
+	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, errors.NewVersionConflictError("foo"))
+	require.Equal(t, http.StatusConflict, httpStatus)
+	require.NotNil(t, jerr.Code)
+	require.NotNil(t, jerr.Status)
+	require.Equal(t, jsonapi.ErrorCodeVersionConflict, *jerr.Code)
+	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
+
+	// test stale version error
+	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, errs.Wrap(errors.NewStaleVersionError("user", "bar"), "foo"))
+	require.Equal(t, http.StatusPreconditionFailed, httpStatus)
+	require.NotNil(t, jerr.Code)
+	require.NotNil(t, jerr.Status)
+	require.Equal(t, jsonapi.ErrorCodeVersionConflict, *jerr.Code)
+	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
+
+	// test too many requests error
+	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, errors.NewTooManyRequestsError("foo", time.Second))
+	require.Equal(t, http.StatusTooManyRequests, httpStatus)
+	require.NotNil(t, jerr.Code)
+	require.NotNil(t, jerr.Status)
+	require.Equal(t, jsonapi.ErrorCodeTooManyRequests, *jerr.Code)
+	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
+
+	// test step-up required error
+	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, errors.NewStepUpRequiredError("foo", time.Minute, nil))
+	require.Equal(t, http.StatusUnauthorized, httpStatus)
+	require.NotNil(t, jerr.Code)
+	require.NotNil(t, jerr.Status)
+	require.Equal(t, jsonapi.ErrorCodeInsufficientUserAuthentication, *jerr.Code)
+	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
+
+	// test validation error
+	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, errs.Wrap(errors.NewValidationError("username", "admin", "reserved", "foo"), "bar"))
+	require.Equal(t, http.StatusBadRequest, httpStatus)
+	require.NotNil(t, jerr.Code)
+	require.NotNil(t, jerr.Status)
+	require.Equal(t, jsonapi.ErrorCodeValidationError, *jerr.Code)
+	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
+	require.Equal(t, "/data/attributes/username", jerr.Source["pointer"])
+	require.Equal(t, "reserved", jerr.Meta["rule"])
+
 	// test unspecified error
 	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, fmt.Errorf("foobar"))
 	require.Equal(t, http.StatusInternalServerError, httpStatus)
@@ -78,3 +121,38 @@ func TestErrorToJSONAPIError(t *testing.T) {
 	require.Equal(t, jsonapi.ErrorCodeUnknownError, *jerr.Code)
 	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
 }
+
+func TestSetRetryAfter(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	header := http.Header{}
+	jsonapi.SetRetryAfter(header, errs.Wrap(errors.NewTooManyRequestsError("foo", 1500*time.Millisecond), "bar"))
+	require.Equal(t, "2", header.Get("Retry-After"))
+
+	header = http.Header{}
+	jsonapi.SetRetryAfter(header, errors.NewTooManyRequestsError("foo", 0))
+	require.Equal(t, "1", header.Get("Retry-After"))
+
+	header = http.Header{}
+	jsonapi.SetRetryAfter(header, errors.NewUnauthorizedError("foo"))
+	require.Empty(t, header.Get("Retry-After"))
+}
+
+func TestSetStepUpChallenge(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	header := http.Header{}
+	jsonapi.SetStepUpChallenge(header, errs.Wrap(errors.NewStepUpRequiredError("authentication is too old", 5*time.Minute, []string{"1", "2"}), "bar"))
+	require.Equal(t, `Bearer error="insufficient_user_authentication", error_description="authentication is too old", max_age="300", acr_values="1 2"`, header.Get("WWW-Authenticate"))
+	require.Equal(t, "WWW-Authenticate", header.Get("Access-Control-Expose-Headers"))
+
+	header = http.Header{}
+	jsonapi.SetStepUpChallenge(header, errors.NewStepUpRequiredError("authentication is too old", 5*time.Minute, nil))
+	require.Equal(t, `Bearer error="insufficient_user_authentication", error_description="authentication is too old", max_age="300"`, header.Get("WWW-Authenticate"))
+
+	header = http.Header{}
+	jsonapi.SetStepUpChallenge(header, errors.NewUnauthorizedError("foo"))
+	require.Empty(t, header.Get("WWW-Authenticate"))
+}
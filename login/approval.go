@@ -0,0 +1,133 @@
+package login
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// KeycloakApprovalService approves and rejects the users waiting for approval.
+// The decision is stored in the Keycloak "approved" user attribute which is used as the "approved" token claim.
+type KeycloakApprovalService struct {
+	db             application.DB
+	profileService UserProfileService
+	config         KeycloakAdminConfiguration
+}
+
+// NewKeycloakApprovalService creates a new approval service
+func NewKeycloakApprovalService(db application.DB, profileService UserProfileService, config KeycloakAdminConfiguration) *KeycloakApprovalService {
+	return &KeycloakApprovalService{
+		db:             db,
+		profileService: profileService,
+		config:         config,
+	}
+}
+
+// RecordPendingApproval adds the unapproved user to the list of the users waiting for approval.
+// Nothing is changed if the user is already in the list.
+func RecordPendingApproval(ctx context.Context, db application.DB, claims *token.TokenClaims) error {
+	keycloakUserID, err := uuid.FromString(claims.Subject)
+	if err != nil {
+		return autherrors.NewBadParameterError("sub", claims.Subject).Expected("uuid")
+	}
+	return application.Transactional(db, func(appl application.Application) error {
+		_, err := appl.PendingApprovals().LoadByKeycloakUserID(ctx, keycloakUserID)
+		if err == nil {
+			return nil
+		}
+		if notFound, _ := autherrors.IsNotFoundError(err); !notFound {
+			return err
+		}
+		return appl.PendingApprovals().Create(ctx, &account.PendingApproval{
+			KeycloakUserID: keycloakUserID,
+			Username:       claims.Username,
+			Email:          claims.Email,
+			FullName:       claims.Name,
+			Status:         account.ApprovalStatusPending,
+		})
+	})
+}
+
+// Approve approves the user and sets the Keycloak "approved" attribute. Approving an already approved user is a no-op.
+// If initTenant is true then the tenant is initialized once the approved user checks the approval status.
+func (s *KeycloakApprovalService) Approve(ctx context.Context, req *goa.RequestData, approvalID uuid.UUID, reason string, decidedBy string, initTenant bool) (*account.PendingApproval, error) {
+	return s.decide(ctx, req, approvalID, account.ApprovalStatusApproved, reason, decidedBy, initTenant)
+}
+
+// Reject rejects the user. If the user has been approved before then the Keycloak "approved" attribute is reset.
+func (s *KeycloakApprovalService) Reject(ctx context.Context, req *goa.RequestData, approvalID uuid.UUID, reason string, decidedBy string) (*account.PendingApproval, error) {
+	return s.decide(ctx, req, approvalID, account.ApprovalStatusRejected, reason, decidedBy, false)
+}
+
+func (s *KeycloakApprovalService) decide(ctx context.Context, req *goa.RequestData, approvalID uuid.UUID, status string, reason string, decidedBy string, initTenant bool) (*account.PendingApproval, error) {
+	var approval *account.PendingApproval
+	decided := false
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		var err error
+		approval, err = appl.PendingApprovals().Load(ctx, approvalID)
+		if err != nil {
+			return err
+		}
+		if approval.Status == status {
+			return nil
+		}
+		// Update Keycloak before the approval is saved so the approval list never claims a decision which has not been applied
+		if status == account.ApprovalStatusApproved || approval.Status == account.ApprovalStatusApproved {
+			err = s.setApprovedAttribute(ctx, req, approval.KeycloakUserID, status == account.ApprovalStatusApproved)
+			if err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		approval.Status = status
+		approval.Reason = reason
+		approval.DecidedBy = decidedBy
+		approval.DecidedAt = &now
+		approval.InitTenant = initTenant
+		decided = true
+		return appl.PendingApprovals().Save(ctx, approval)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decided {
+		log.Info(ctx, map[string]interface{}{
+			"pending_approval_id": approval.ID,
+			"keycloak_user_id":    approval.KeycloakUserID,
+			"status":              status,
+			"decided_by":          decidedBy,
+		}, "user approval decision made")
+	}
+	return approval, nil
+}
+
+func (s *KeycloakApprovalService) setApprovedAttribute(ctx context.Context, req *goa.RequestData, keycloakUserID uuid.UUID, approved bool) error {
+	adminEndpoint, err := s.config.GetKeycloakEndpointAdmin(req)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, errs.Wrap(err, "unable to get Keycloak admin endpoint URL"))
+	}
+	tokenEndpoint, err := s.config.GetKeycloakEndpointToken(req)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, errs.Wrap(err, "unable to get Keycloak token endpoint URL"))
+	}
+	pat, err := auth.GetProtectedAPIToken(ctx, tokenEndpoint, s.config.GetKeycloakClientID(), s.config.GetKeycloakSecret())
+	if err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	attributes := KeycloakUserProfileAttributes{
+		ApprovedAttributeName: []string{strconv.FormatBool(approved)},
+	}
+	return s.profileService.SetAttributes(ctx, attributes, pat, adminEndpoint+"/users/"+url.PathEscape(keycloakUserID.String()))
+}
@@ -0,0 +1,241 @@
+package login
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/configuration"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	// ClusterAllocationLeastLoaded assigns the cluster with the lowest number of users relative to its weight
+	ClusterAllocationLeastLoaded = "least-loaded"
+	// ClusterAllocationRegionAffinity assigns the least loaded cluster of the region of the user
+	// and falls back to the least loaded cluster of any region if the region has no free capacity
+	ClusterAllocationRegionAffinity = "region-affinity"
+)
+
+// ClusterConfiguration represents the configuration of the cluster allocation
+type ClusterConfiguration interface {
+	GetOSOClusters() map[string]configuration.OSOCluster
+	GetClusterAllocationStrategy() string
+}
+
+// ClusterUsage represents the number of users provisioned to a configured cluster
+type ClusterUsage struct {
+	configuration.OSOCluster
+	Users int
+}
+
+// Full returns true if the cluster has reached its capacity
+func (u ClusterUsage) Full() bool {
+	return u.Capacity > 0 && u.Users >= u.Capacity
+}
+
+// load returns the number of users relative to the weight of the cluster
+func (u ClusterUsage) load() float64 {
+	weight := u.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	return float64(u.Users) / float64(weight)
+}
+
+// ClusterMigration represents the move of a user to another cluster
+type ClusterMigration struct {
+	IdentityID    uuid.UUID
+	UserID        uuid.UUID
+	SourceCluster string
+	TargetCluster string
+	MovedBy       string
+}
+
+// ClusterService assigns the clusters to the new users and moves the users between clusters
+type ClusterService struct {
+	db     application.DB
+	config ClusterConfiguration
+	// Notify emits the migration event once the move is committed. The event is logged by default.
+	Notify func(ctx context.Context, migration ClusterMigration)
+}
+
+// NewClusterService creates a new cluster service
+func NewClusterService(db application.DB, config ClusterConfiguration) *ClusterService {
+	return &ClusterService{
+		db:     db,
+		config: config,
+		Notify: LogClusterMigrationEvent,
+	}
+}
+
+// LogClusterMigrationEvent emits the migration event in the logs
+func LogClusterMigrationEvent(ctx context.Context, migration ClusterMigration) {
+	log.Info(ctx, map[string]interface{}{
+		"event":          "user_cluster_migrated",
+		"identity_id":    migration.IdentityID,
+		"user_id":        migration.UserID,
+		"source_cluster": migration.SourceCluster,
+		"target_cluster": migration.TargetCluster,
+		"moved_by":       migration.MovedBy,
+	}, "user moved to another cluster")
+}
+
+// Usage returns the number of users provisioned to each configured cluster, sorted by cluster name
+func (s *ClusterService) Usage(ctx context.Context) ([]ClusterUsage, error) {
+	var usage []ClusterUsage
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		var err error
+		usage, err = s.usage(ctx, appl)
+		return err
+	})
+	return usage, err
+}
+
+func (s *ClusterService) usage(ctx context.Context, appl application.Application) ([]ClusterUsage, error) {
+	counts, err := appl.Users().CountByCluster(ctx)
+	if err != nil {
+		return nil, autherrors.NewInternalError(ctx, err)
+	}
+	return clusterUsage(s.config.GetOSOClusters(), counts), nil
+}
+
+// clusterUsage returns the usage of the given clusters, sorted by cluster name.
+// The users are counted regardless of the trailing slash of their cluster URL.
+func clusterUsage(clusters map[string]configuration.OSOCluster, counts map[string]int) []ClusterUsage {
+	users := make(map[string]int, len(clusters))
+	for clusterURL, count := range counts {
+		if apiURL, found := lookupClusterURL(clusters, clusterURL); found {
+			users[apiURL] += count
+		}
+	}
+	usage := make([]ClusterUsage, 0, len(clusters))
+	for url, cluster := range clusters {
+		usage = append(usage, ClusterUsage{OSOCluster: cluster, Users: users[url]})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Name < usage[j].Name
+	})
+	return usage
+}
+
+// Allocate returns the API URL of the cluster to provision a new user to, using the configured allocation strategy.
+// The region is the preferred region of the user and can be empty.
+// The capacity is checked against the committed users so concurrent registrations can slightly exceed it.
+func (s *ClusterService) Allocate(ctx context.Context, appl application.Application, region string) (string, error) {
+	usage, err := s.usage(ctx, appl)
+	if err != nil {
+		return "", err
+	}
+	cluster, err := selectCluster(usage, s.config.GetClusterAllocationStrategy(), region)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"strategy": s.config.GetClusterAllocationStrategy(),
+			"region":   region,
+			"err":      err,
+		}, "unable to allocate a cluster")
+		return "", autherrors.NewInternalError(ctx, err)
+	}
+	return cluster, nil
+}
+
+// selectCluster returns the API URL of the least loaded cluster which is not full.
+// The clusters of the given region are preferred by the region affinity strategy.
+func selectCluster(usage []ClusterUsage, strategy string, region string) (string, error) {
+	var available []ClusterUsage
+	for _, u := range usage {
+		if !u.Full() {
+			available = append(available, u)
+		}
+	}
+	if len(available) == 0 {
+		return "", fmt.Errorf("none of the %d configured clusters has free capacity", len(usage))
+	}
+	switch strategy {
+	case ClusterAllocationLeastLoaded:
+	case ClusterAllocationRegionAffinity:
+		var inRegion []ClusterUsage
+		for _, u := range available {
+			if region != "" && strings.EqualFold(u.Region, region) {
+				inRegion = append(inRegion, u)
+			}
+		}
+		if len(inRegion) > 0 {
+			available = inRegion
+		}
+	default:
+		return "", fmt.Errorf("unknown cluster allocation strategy '%s'", strategy)
+	}
+	selected := available[0]
+	for _, u := range available[1:] {
+		if u.load() < selected.load() {
+			selected = u
+		}
+	}
+	return selected.URL, nil
+}
+
+// Move moves the user of the given identity to the given cluster and emits the migration event.
+// The cluster of the user is only changed in auth. Provisioning the user on the new cluster is up to the consumers of the event.
+func (s *ClusterService) Move(ctx context.Context, identityID uuid.UUID, cluster string, movedBy string) (*account.Identity, error) {
+	apiURL, found := lookupClusterURL(s.config.GetOSOClusters(), cluster)
+	if !found {
+		return nil, autherrors.NewBadParameterError("cluster", cluster).Expected("API URL of a configured cluster")
+	}
+	cluster = apiURL
+	var identity *account.Identity
+	var migration ClusterMigration
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		identities, err := appl.Identities().Query(account.IdentityFilterByID(identityID), account.IdentityWithUser())
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		if len(identities) == 0 || identities[0].User.ID == uuid.Nil {
+			return autherrors.NewNotFoundError("identity", identityID.String())
+		}
+		identity = &identities[0]
+		if strings.TrimSuffix(identity.User.Cluster, "/") == strings.TrimSuffix(cluster, "/") {
+			return autherrors.NewBadParameterError("cluster", cluster).Expected("cluster different from the current cluster of the user")
+		}
+		usage, err := s.usage(ctx, appl)
+		if err != nil {
+			return err
+		}
+		for _, u := range usage {
+			if u.URL == cluster && u.Full() {
+				return autherrors.NewBadParameterError("cluster", cluster).Expected("cluster with free capacity")
+			}
+		}
+		migration = ClusterMigration{
+			IdentityID:    identity.ID,
+			UserID:        identity.User.ID,
+			SourceCluster: identity.User.Cluster,
+			TargetCluster: cluster,
+			MovedBy:       movedBy,
+		}
+		identity.User.Cluster = cluster
+		return appl.Users().Save(ctx, &identity.User)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Notify(ctx, migration)
+	return identity, nil
+}
+
+// lookupClusterURL returns the API URL of the configured cluster matching the given URL.
+// The URLs are compared regardless of the trailing slash.
+func lookupClusterURL(clusters map[string]configuration.OSOCluster, clusterURL string) (string, bool) {
+	for apiURL := range clusters {
+		if strings.TrimSuffix(apiURL, "/") == strings.TrimSuffix(clusterURL, "/") {
+			return apiURL, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,100 @@
+package login
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/configuration"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClusterUsage(name string, region string, capacity int, weight int, users int) ClusterUsage {
+	return ClusterUsage{
+		OSOCluster: configuration.OSOCluster{
+			Name:     name,
+			URL:      "https://api." + name + ".openshift.com",
+			Capacity: capacity,
+			Weight:   weight,
+			Region:   region,
+		},
+		Users: users,
+	}
+}
+
+func TestSelectCluster(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	usage := []ClusterUsage{
+		newClusterUsage("eu-west-1", "eu-west", 100, 1, 10),
+		newClusterUsage("us-east-1", "us-east", 100, 1, 30),
+		newClusterUsage("us-east-2", "us-east", 0, 4, 80),
+	}
+
+	t.Run("least loaded", func(t *testing.T) {
+		cluster, err := selectCluster(usage, ClusterAllocationLeastLoaded, "us-east")
+		require.Nil(t, err)
+		assert.Equal(t, "https://api.eu-west-1.openshift.com", cluster)
+	})
+
+	t.Run("weighted", func(t *testing.T) {
+		cluster, err := selectCluster(usage[1:], ClusterAllocationLeastLoaded, "")
+		require.Nil(t, err)
+		// 80 users for a weight of 4 is less loaded than 30 users for a weight of 1
+		assert.Equal(t, "https://api.us-east-2.openshift.com", cluster)
+	})
+
+	t.Run("region affinity", func(t *testing.T) {
+		cluster, err := selectCluster(usage, ClusterAllocationRegionAffinity, "US-East")
+		require.Nil(t, err)
+		assert.Equal(t, "https://api.us-east-2.openshift.com", cluster)
+	})
+
+	t.Run("region affinity falls back to other regions", func(t *testing.T) {
+		full := []ClusterUsage{
+			newClusterUsage("eu-west-1", "eu-west", 100, 1, 100),
+			newClusterUsage("us-east-1", "us-east", 100, 1, 30),
+		}
+		cluster, err := selectCluster(full, ClusterAllocationRegionAffinity, "eu-west")
+		require.Nil(t, err)
+		assert.Equal(t, "https://api.us-east-1.openshift.com", cluster)
+	})
+
+	t.Run("all clusters full", func(t *testing.T) {
+		_, err := selectCluster([]ClusterUsage{newClusterUsage("eu-west-1", "eu-west", 100, 1, 100)}, ClusterAllocationLeastLoaded, "")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("unknown strategy", func(t *testing.T) {
+		_, err := selectCluster(usage, "random", "")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestClusterUsageIgnoresTrailingSlash(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	eu := newClusterUsage("eu-west-1", "eu-west", 100, 1, 0).OSOCluster
+	us := newClusterUsage("us-east-1", "us-east", 100, 1, 0).OSOCluster
+	us.URL = us.URL + "/"
+	clusters := map[string]configuration.OSOCluster{
+		eu.URL: eu,
+		us.URL: us,
+	}
+	counts := map[string]int{
+		eu.URL:                                 3,
+		eu.URL + "/":                           2,
+		"https://api.us-east-1.openshift.com":  4,
+		"https://api.unknown-1.openshift.com/": 7,
+	}
+
+	usage := clusterUsage(clusters, counts)
+	require.Len(t, usage, 2)
+	assert.Equal(t, "eu-west-1", usage[0].Name)
+	assert.Equal(t, 5, usage[0].Users)
+	assert.Equal(t, "us-east-1", usage[1].Name)
+	assert.Equal(t, 4, usage[1].Users)
+}
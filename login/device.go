@@ -0,0 +1,271 @@
+package login
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/oauth2"
+)
+
+// DeviceCodeGrantType is the grant type used by devices when polling the token endpoint (RFC 8628)
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	deviceUserCodeParam = "device_user_code"
+	// user codes use a case insensitive base-20 alphabet without vowels to avoid ambiguous characters and real words
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+)
+
+var (
+	// ErrAuthorizationPending means the user code has not been approved yet
+	ErrAuthorizationPending = goa.NewErrorClass("authorization_pending", 400)
+	// ErrSlowDown means the device polls the token endpoint too often
+	ErrSlowDown = goa.NewErrorClass("slow_down", 400)
+	// ErrExpiredToken means the device code has expired and the device should start a new authorization request
+	ErrExpiredToken = goa.NewErrorClass("expired_token", 400)
+)
+
+// DeviceCodeConfiguration represents configuration needed by the device authorization grant
+type DeviceCodeConfiguration interface {
+	GetDeviceCodeExpiresIn() time.Duration
+	GetDeviceCodePollingInterval() int
+}
+
+// NewDeviceCode generates a new device code and user code pair for the given api client
+func NewDeviceCode(clientID string, scope string, config DeviceCodeConfiguration) (*auth.DeviceCode, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errs.WithStack(err)
+	}
+	userCode := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeCharset)))
+	for i := range userCode {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, errs.WithStack(err)
+		}
+		userCode[i] = userCodeCharset[n.Int64()]
+	}
+	return &auth.DeviceCode{
+		DeviceCode:      base64.RawURLEncoding.EncodeToString(b),
+		UserCode:        string(userCode),
+		ClientID:        clientID,
+		Scope:           scope,
+		ExpiresAt:       time.Now().Add(config.GetDeviceCodeExpiresIn()),
+		PollingInterval: config.GetDeviceCodePollingInterval(),
+	}, nil
+}
+
+// NormalizeUserCode converts the user code typed by the user to the stored form.
+// Dashes, spaces and other separators are ignored and the code is case insensitive.
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return -1
+	}, userCode)
+}
+
+// FormatUserCode formats the user code to be displayed to the user, for example "BCDF-GHJK"
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+// authenticateDeviceCode stores the token obtained during the login flow for the device which requested the given user code.
+// Returns the confirmation code to be sent back by the user to approve the user code.
+func (keycloak *KeycloakOAuthProvider) authenticateDeviceCode(ctx context.Context, userCode string, identity *account.Identity, keycloakToken *oauth2.Token, redirect string) (string, error) {
+	tokenJSON, err := TokenToJson(ctx, keycloakToken)
+	if err != nil {
+		return "", autherrors.NewInternalError(ctx, err)
+	}
+	return AuthenticateDeviceCode(ctx, keycloak.db, userCode, identity.ID, tokenJSON, redirect)
+}
+
+// AuthenticateDeviceCode stores the token issued to the user who logged in from the verification page.
+// The user code is not approved yet: the device gets the token only once the user explicitly approves
+// the request on the confirmation page, so opening a verification link sent by someone else
+// doesn't give away the token of a user who already has an active session (RFC 8628 section 5.4).
+// Returns the confirmation code to be sent back with the decision of the user.
+func AuthenticateDeviceCode(ctx context.Context, db application.DB, userCode string, identityID uuid.UUID, tokenJSON string, redirect string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", autherrors.NewInternalError(ctx, err)
+	}
+	confirmation := base64.RawURLEncoding.EncodeToString(b)
+	err := application.Transactional(db, func(appl application.Application) error {
+		code, err := appl.DeviceCodes().LoadByUserCode(ctx, NormalizeUserCode(userCode))
+		if err != nil {
+			return err
+		}
+		if code.IsExpired() {
+			return autherrors.NewBadParameterError("user_code", userCode).Expected("not expired user code")
+		}
+		if code.ApprovedAt != nil {
+			return autherrors.NewBadParameterError("user_code", userCode).Expected("not yet approved user code")
+		}
+		code.IdentityID = &identityID
+		code.Token = tokenJSON
+		code.ConfirmationCode = hashConfirmationCode(confirmation)
+		code.Redirect = redirect
+		log.Info(ctx, map[string]interface{}{
+			"device_code_id": code.ID,
+			"client_id":      code.ClientID,
+			"identity_id":    identityID,
+		}, "user logged in to approve the device code")
+		return appl.DeviceCodes().Save(ctx, code)
+	})
+	if err != nil {
+		return "", err
+	}
+	return confirmation, nil
+}
+
+// LoadDeviceCodeToConfirm loads the device code waiting for the confirmation of the user who logged in from the verification page.
+// Returns BadParameterError if the confirmation code doesn't match or if the user code can't be approved anymore.
+func LoadDeviceCodeToConfirm(ctx context.Context, db application.DB, userCode string, confirmation string) (*auth.DeviceCode, error) {
+	var code *auth.DeviceCode
+	err := application.Transactional(db, func(appl application.Application) error {
+		var err error
+		code, err = loadDeviceCodeToConfirm(ctx, appl, userCode, confirmation)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// ConfirmDeviceCode records the decision of the user who logged in from the verification page.
+// An approved device code releases the token to the device. A denied device code is deleted.
+func ConfirmDeviceCode(ctx context.Context, db application.DB, userCode string, confirmation string, approve bool) (*auth.DeviceCode, error) {
+	var code *auth.DeviceCode
+	err := application.Transactional(db, func(appl application.Application) error {
+		var err error
+		code, err = loadDeviceCodeToConfirm(ctx, appl, userCode, confirmation)
+		if err != nil {
+			return err
+		}
+		if !approve {
+			log.Info(ctx, map[string]interface{}{
+				"device_code_id": code.ID,
+				"client_id":      code.ClientID,
+				"identity_id":    code.IdentityID,
+			}, "device code denied")
+			return appl.DeviceCodes().Delete(ctx, code.ID)
+		}
+		now := time.Now()
+		code.ApprovedAt = &now
+		code.ConfirmationCode = ""
+		log.Info(ctx, map[string]interface{}{
+			"device_code_id": code.ID,
+			"client_id":      code.ClientID,
+			"identity_id":    code.IdentityID,
+		}, "device code approved")
+		return appl.DeviceCodes().Save(ctx, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+func loadDeviceCodeToConfirm(ctx context.Context, appl application.Application, userCode string, confirmation string) (*auth.DeviceCode, error) {
+	code, err := appl.DeviceCodes().LoadByUserCode(ctx, NormalizeUserCode(userCode))
+	if err != nil {
+		return nil, err
+	}
+	if code.ConfirmationCode == "" || subtle.ConstantTimeCompare([]byte(code.ConfirmationCode), []byte(hashConfirmationCode(confirmation))) != 1 {
+		log.Error(ctx, map[string]interface{}{
+			"device_code_id": code.ID,
+		}, "invalid confirmation code for the device code")
+		return nil, autherrors.NewBadParameterError("confirmation", "").Expected("confirmation code issued after login")
+	}
+	if code.IsExpired() {
+		return nil, autherrors.NewBadParameterError("user_code", userCode).Expected("not expired user code")
+	}
+	if code.ApprovedAt != nil {
+		return nil, autherrors.NewBadParameterError("user_code", userCode).Expected("not yet approved user code")
+	}
+	return code, nil
+}
+
+// hashConfirmationCode returns the hex encoded SHA-256 hash of the confirmation code
+func hashConfirmationCode(confirmation string) string {
+	sum := sha256.Sum256([]byte(confirmation))
+	return hex.EncodeToString(sum[:])
+}
+
+// ExchangeDeviceCode returns the token set issued for the device if the corresponding user code has been approved.
+// Once the token set is returned the device code is deleted and can't be used again.
+func ExchangeDeviceCode(ctx context.Context, db application.DB, deviceCode string, clientID string) (*token.TokenSet, error) {
+	var tokenSet *token.TokenSet
+	// polling errors must not roll back the transaction which records the time of the last polling request
+	var pollingErr error
+	err := application.Transactional(db, func(appl application.Application) error {
+		code, err := appl.DeviceCodes().LoadByDeviceCode(ctx, deviceCode)
+		if err != nil {
+			if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+				return autherrors.NewUnauthorizedError("invalid device code")
+			}
+			return err
+		}
+		if code.ClientID != clientID {
+			log.Error(ctx, map[string]interface{}{
+				"device_code_id": code.ID,
+				"client_id":      clientID,
+			}, "device code was issued to another client")
+			return autherrors.NewUnauthorizedError("invalid device code")
+		}
+		if code.IsExpired() {
+			pollingErr = ErrExpiredToken("device code has expired")
+			return appl.DeviceCodes().Delete(ctx, code.ID)
+		}
+		if !code.IsApproved() {
+			now := time.Now()
+			if code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < time.Duration(code.PollingInterval)*time.Second {
+				pollingErr = ErrSlowDown("polling too frequently")
+			} else {
+				pollingErr = ErrAuthorizationPending("user code has not been approved yet")
+			}
+			code.LastPolledAt = &now
+			return appl.DeviceCodes().Save(ctx, code)
+		}
+		tokenSet, err = token.ReadTokenSetFromJson(ctx, code.Token)
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		return appl.DeviceCodes().Delete(ctx, code.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pollingErr != nil {
+		return nil, pollingErr
+	}
+	return tokenSet, nil
+}
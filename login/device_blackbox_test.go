@@ -0,0 +1,174 @@
+package login_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/login"
+	"github.com/fabric8-services/fabric8-auth/resource"
+	"github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type deviceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunDeviceBlackBoxTest(t *testing.T) {
+	resource.Require(t, resource.Database)
+	suite.Run(t, &deviceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *deviceBlackBoxTest) TestNormalizeAndFormatUserCode() {
+	assert.Equal(s.T(), "BCDFGHJK", login.NormalizeUserCode("bcdf-ghjk"))
+	assert.Equal(s.T(), "BCDFGHJK", login.NormalizeUserCode(" BCDF GHJK "))
+	assert.Equal(s.T(), "BCDF-GHJK", login.FormatUserCode("BCDFGHJK"))
+	assert.Equal(s.T(), "BCD", login.FormatUserCode("BCD"))
+}
+
+func (s *deviceBlackBoxTest) TestNewDeviceCode() {
+	code, err := login.NewDeviceCode("fabric8-cli", "offline_access", s.Configuration)
+	require.Nil(s.T(), err)
+	assert.NotEmpty(s.T(), code.DeviceCode)
+	assert.Len(s.T(), code.UserCode, 8)
+	assert.Equal(s.T(), code.UserCode, login.NormalizeUserCode(code.UserCode))
+	assert.Equal(s.T(), "fabric8-cli", code.ClientID)
+	assert.Equal(s.T(), "offline_access", code.Scope)
+	assert.Equal(s.T(), s.Configuration.GetDeviceCodePollingInterval(), code.PollingInterval)
+	assert.True(s.T(), code.ExpiresAt.After(time.Now()))
+
+	other, err := login.NewDeviceCode("fabric8-cli", "", s.Configuration)
+	require.Nil(s.T(), err)
+	assert.NotEqual(s.T(), code.DeviceCode, other.DeviceCode)
+}
+
+func (s *deviceBlackBoxTest) TestExchangePendingDeviceCode() {
+	code := s.createDeviceCode()
+
+	_, err := login.ExchangeDeviceCode(s.Ctx, s.Application, code.DeviceCode, code.ClientID)
+	s.assertPollingError("authorization_pending", err)
+
+	// polling again right away
+	_, err = login.ExchangeDeviceCode(s.Ctx, s.Application, code.DeviceCode, code.ClientID)
+	s.assertPollingError("slow_down", err)
+}
+
+func (s *deviceBlackBoxTest) TestExchangeExpiredDeviceCode() {
+	code := s.createDeviceCode()
+	code.ExpiresAt = time.Now().Add(-time.Minute)
+	require.Nil(s.T(), s.Application.DeviceCodes().Save(s.Ctx, code))
+
+	_, err := login.ExchangeDeviceCode(s.Ctx, s.Application, code.DeviceCode, code.ClientID)
+	s.assertPollingError("expired_token", err)
+
+	_, err = s.Application.DeviceCodes().LoadByDeviceCode(s.Ctx, code.DeviceCode)
+	require.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *deviceBlackBoxTest) TestExchangeUnknownDeviceCode() {
+	code := s.createDeviceCode()
+
+	_, err := login.ExchangeDeviceCode(s.Ctx, s.Application, "unknown", code.ClientID)
+	require.IsType(s.T(), errors.UnauthorizedError{}, err)
+
+	_, err = login.ExchangeDeviceCode(s.Ctx, s.Application, code.DeviceCode, "another-client")
+	require.IsType(s.T(), errors.UnauthorizedError{}, err)
+}
+
+func (s *deviceBlackBoxTest) TestExchangeApprovedDeviceCode() {
+	code := s.createDeviceCode()
+	identity, err := test.CreateTestIdentity(s.DB, "device-"+code.UserCode, account.KeycloakIDP)
+	require.Nil(s.T(), err)
+	confirmation, err := login.AuthenticateDeviceCode(s.Ctx, s.Application, code.UserCode, identity.ID, `{"access_token":"someaccesstoken","refresh_token":"somerefreshtoken","token_type":"bearer","expires_in":300}`, "https://openshift.io/device")
+	require.Nil(s.T(), err)
+	// the token is not released before the user approves the request of the device
+	_, err = login.ExchangeDeviceCode(s.Ctx, s.Application, code.DeviceCode, code.ClientID)
+	s.assertPollingError("authorization_pending", err)
+	approved, err := login.ConfirmDeviceCode(s.Ctx, s.Application, code.UserCode, confirmation, true)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "https://openshift.io/device", approved.Redirect)
+
+	tokenSet, err := login.ExchangeDeviceCode(s.Ctx, s.Application, code.DeviceCode, code.ClientID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "someaccesstoken", *tokenSet.AccessToken)
+	assert.Equal(s.T(), "somerefreshtoken", *tokenSet.RefreshToken)
+	assert.Equal(s.T(), int64(300), *tokenSet.ExpiresIn)
+
+	// the device code can't be used twice
+	_, err = login.ExchangeDeviceCode(s.Ctx, s.Application, code.DeviceCode, code.ClientID)
+	require.IsType(s.T(), errors.UnauthorizedError{}, err)
+}
+
+func (s *deviceBlackBoxTest) TestConfirmDeviceCode() {
+	identity, err := test.CreateTestIdentity(s.DB, "device-confirm-"+uuid.NewV4().String(), account.KeycloakIDP)
+	require.Nil(s.T(), err)
+	tokenJSON := `{"access_token":"someaccesstoken","token_type":"bearer"}`
+
+	s.T().Run("wrong confirmation code", func(t *testing.T) {
+		code := s.createDeviceCode()
+		_, err := login.AuthenticateDeviceCode(s.Ctx, s.Application, code.UserCode, identity.ID, tokenJSON, "https://openshift.io/device")
+		require.Nil(t, err)
+
+		_, err = login.LoadDeviceCodeToConfirm(s.Ctx, s.Application, code.UserCode, "wrong")
+		require.IsType(t, errors.BadParameterError{}, err)
+		_, err = login.ConfirmDeviceCode(s.Ctx, s.Application, code.UserCode, "wrong", true)
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	s.T().Run("not logged in", func(t *testing.T) {
+		code := s.createDeviceCode()
+		_, err := login.ConfirmDeviceCode(s.Ctx, s.Application, code.UserCode, "", true)
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	s.T().Run("approved once", func(t *testing.T) {
+		code := s.createDeviceCode()
+		confirmation, err := login.AuthenticateDeviceCode(s.Ctx, s.Application, login.FormatUserCode(code.UserCode), identity.ID, tokenJSON, "https://openshift.io/device")
+		require.Nil(t, err)
+		loaded, err := login.LoadDeviceCodeToConfirm(s.Ctx, s.Application, code.UserCode, confirmation)
+		require.Nil(t, err)
+		assert.Equal(t, code.ID, loaded.ID)
+
+		_, err = login.ConfirmDeviceCode(s.Ctx, s.Application, code.UserCode, confirmation, true)
+		require.Nil(t, err)
+		_, err = login.ConfirmDeviceCode(s.Ctx, s.Application, code.UserCode, confirmation, true)
+		require.IsType(t, errors.BadParameterError{}, err)
+		_, err = login.AuthenticateDeviceCode(s.Ctx, s.Application, code.UserCode, identity.ID, tokenJSON, "https://openshift.io/device")
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	s.T().Run("denied", func(t *testing.T) {
+		code := s.createDeviceCode()
+		confirmation, err := login.AuthenticateDeviceCode(s.Ctx, s.Application, code.UserCode, identity.ID, tokenJSON, "https://openshift.io/device")
+		require.Nil(t, err)
+
+		_, err = login.ConfirmDeviceCode(s.Ctx, s.Application, code.UserCode, confirmation, false)
+		require.Nil(t, err)
+		_, err = login.ExchangeDeviceCode(s.Ctx, s.Application, code.DeviceCode, code.ClientID)
+		require.IsType(t, errors.UnauthorizedError{}, err)
+	})
+}
+
+func (s *deviceBlackBoxTest) createDeviceCode() *auth.DeviceCode {
+	code, err := login.NewDeviceCode("fabric8-cli", "", s.Configuration)
+	require.Nil(s.T(), err)
+	require.Nil(s.T(), s.Application.DeviceCodes().Create(s.Ctx, code))
+	return code
+}
+
+func (s *deviceBlackBoxTest) assertPollingError(expectedCode string, err error) {
+	require.NotNil(s.T(), err)
+	errResp, ok := err.(*goa.ErrorResponse)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), expectedCode, errResp.Code)
+	assert.Equal(s.T(), 400, errResp.Status)
+}
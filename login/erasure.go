@@ -0,0 +1,350 @@
+package login
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/wit"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// erasedEmailDomain is the domain of the anonymized emails. The ".invalid" TLD is reserved and never resolves.
+const erasedEmailDomain = "erased.invalid"
+
+// UserErasureConfiguration represents the configuration needed to deactivate and erase the users
+type UserErasureConfiguration interface {
+	KeycloakAdminConfiguration
+	GetWITURL(*goa.RequestData) (string, error)
+	GetUserErasureGracePeriod() time.Duration
+}
+
+// UserErasureService deactivates the users and erases their data from auth, Keycloak and WIT once the grace period has elapsed.
+// The erasure is done step by step and the last completed step is recorded so a failed erasure is resumed where it stopped.
+// The resources owned by the user, such as the spaces, are kept and remain owned by the anonymized identity.
+type UserErasureService struct {
+	db               application.DB
+	profileService   UserProfileService
+	config           UserErasureConfiguration
+	RemoteWITService wit.RemoteWITService
+}
+
+// NewUserErasureService creates a new user erasure service
+func NewUserErasureService(db application.DB, profileService UserProfileService, config UserErasureConfiguration) *UserErasureService {
+	return &UserErasureService{
+		db:               db,
+		profileService:   profileService,
+		config:           config,
+		RemoteWITService: &wit.RemoteWITServiceCaller{},
+	}
+}
+
+// CheckUserActive returns UnauthorizedError if the user of the given identity has been deactivated.
+// Identities which don't exist in auth yet are considered active.
+func CheckUserActive(ctx context.Context, db application.DB, identityID uuid.UUID) error {
+	var identities []account.Identity
+	err := application.Transactional(db, func(appl application.Application) error {
+		var err error
+		identities, err = appl.Identities().Query(account.IdentityFilterByID(identityID), account.IdentityWithUser())
+		return err
+	})
+	if err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	if len(identities) > 0 && identities[0].User.DeactivatedAt != nil {
+		return autherrors.NewUnauthorizedError(fmt.Sprintf("user '%s' is deactivated", identities[0].Username))
+	}
+	return nil
+}
+
+// Deactivate disables the user of the given Keycloak identity in Keycloak and in auth and schedules the erasure of the user data.
+// Deactivating an already deactivated user returns the existing erasure.
+func (s *UserErasureService) Deactivate(ctx context.Context, req *goa.RequestData, identityID uuid.UUID, requestedBy string) (*account.UserErasure, error) {
+	var erasure *account.UserErasure
+	var identity *account.Identity
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		var err error
+		erasure, err = appl.UserErasures().LoadByIdentityID(ctx, identityID)
+		if err == nil {
+			return nil
+		}
+		if notFound, _ := autherrors.IsNotFoundError(err); !notFound {
+			return err
+		}
+		erasure = nil
+		identities, err := appl.Identities().Query(account.IdentityFilterByID(identityID), account.IdentityWithUser())
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		if len(identities) == 0 {
+			return autherrors.NewNotFoundError("identity", identityID.String())
+		}
+		identity = &identities[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if erasure != nil {
+		return erasure, nil
+	}
+	if identity.ProviderType != account.KeycloakIDP || identity.User.ID == uuid.Nil {
+		return nil, autherrors.NewBadParameterError("identity_id", identityID.String()).Expected("Keycloak identity linked to a user")
+	}
+
+	// Disable the user in Keycloak first so the user can't get new tokens once deactivated
+	err = s.withKeycloakUser(ctx, req, identityID, func(pat string, userURL string) error {
+		err := s.profileService.SetEnabled(ctx, false, pat, userURL)
+		if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+			// the user doesn't exist in Keycloak so can't log in anyway
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = application.Transactional(s.db, func(appl application.Application) error {
+		user := identity.User
+		user.DeactivatedAt = &now
+		if err := appl.Users().Save(ctx, &user); err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		erasure = &account.UserErasure{
+			IdentityID:  identityID,
+			UserID:      user.ID,
+			RequestedBy: requestedBy,
+			Status:      account.ErasureStatusPending,
+			EraseAfter:  now.Add(s.config.GetUserErasureGracePeriod()),
+		}
+		return appl.UserErasures().Create(ctx, erasure)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id":     identityID,
+		"user_erasure_id": erasure.ID,
+		"requested_by":    requestedBy,
+		"erase_after":     erasure.EraseAfter,
+	}, "user deactivated")
+	return erasure, nil
+}
+
+// Erase erases the data of the deactivated user. The erasure is resumed from the last completed step
+// so running it again after a failure, or after it has completed, is safe.
+// The erasure is not started before the end of the grace period unless force is true.
+func (s *UserErasureService) Erase(ctx context.Context, req *goa.RequestData, erasureID uuid.UUID, force bool) (*account.UserErasure, error) {
+	var erasure *account.UserErasure
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		var err error
+		erasure, err = appl.UserErasures().Load(ctx, erasureID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if erasure.Status == account.ErasureStatusCompleted {
+		return erasure, nil
+	}
+	if !force && time.Now().Before(erasure.EraseAfter) {
+		return nil, autherrors.NewBadParameterError("force", force).Expected(fmt.Sprintf("true to erase the user before the end of the grace period on %s", erasure.EraseAfter.Format(time.RFC3339)))
+	}
+
+	erasure.Attempts++
+	for step := erasure.NextStep(); step != ""; step = erasure.NextStep() {
+		err = s.eraseStep(ctx, req, erasure, step)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"user_erasure_id": erasure.ID,
+				"identity_id":     erasure.IdentityID,
+				"step":            step,
+				"err":             err,
+			}, "unable to erase the user")
+			erasure.Status = account.ErasureStatusFailed
+			erasure.LastError = fmt.Sprintf("%s: %s", step, err.Error())
+			if saveErr := s.saveErasure(ctx, erasure); saveErr != nil {
+				return nil, saveErr
+			}
+			return nil, err
+		}
+		erasure.Step = step
+		if err = s.saveErasure(ctx, erasure); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	erasure.Status = account.ErasureStatusCompleted
+	erasure.LastError = ""
+	erasure.CompletedAt = &now
+	if err = s.saveErasure(ctx, erasure); err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"user_erasure_id": erasure.ID,
+		"identity_id":     erasure.IdentityID,
+		"attempts":        erasure.Attempts,
+	}, "user erased")
+	return erasure, nil
+}
+
+func (s *UserErasureService) saveErasure(ctx context.Context, erasure *account.UserErasure) error {
+	return application.Transactional(s.db, func(appl application.Application) error {
+		return appl.UserErasures().Save(ctx, erasure)
+	})
+}
+
+// eraseStep executes a single step of the erasure. Every step can be executed again if it has partially failed.
+func (s *UserErasureService) eraseStep(ctx context.Context, req *goa.RequestData, erasure *account.UserErasure, step string) error {
+	switch step {
+	case account.ErasureStepExternalTokens:
+		return s.forEachIdentity(ctx, erasure.UserID, func(appl application.Application, identityID uuid.UUID) error {
+			return appl.ExternalTokens().DeleteByIdentityID(ctx, identityID)
+		})
+	case account.ErasureStepIdentityRoles:
+		return s.forEachIdentity(ctx, erasure.UserID, func(appl application.Application, identityID uuid.UUID) error {
+			if err := appl.Teams().RemoveMemberships(ctx, identityID); err != nil {
+				return err
+			}
+			return appl.IdentityRoles().DeleteByIdentityID(ctx, identityID)
+		})
+	case account.ErasureStepExports:
+		return s.forEachIdentity(ctx, erasure.UserID, func(appl application.Application, identityID uuid.UUID) error {
+			return appl.UserExports().DeleteByIdentityID(ctx, identityID)
+		})
+	case account.ErasureStepKeycloak:
+		return s.withKeycloakUser(ctx, req, erasure.IdentityID, func(pat string, userURL string) error {
+			return s.profileService.Delete(ctx, pat, userURL)
+		})
+	case account.ErasureStepWIT:
+		return s.anonymizeWITUser(ctx, req, erasure.IdentityID)
+	case account.ErasureStepProfile:
+		return s.anonymizeUser(ctx, erasure.UserID)
+	}
+	return autherrors.NewInternalErrorFromString(ctx, fmt.Sprintf("unknown erasure step '%s'", step))
+}
+
+// forEachIdentity calls the given function for every identity of the user in a single transaction.
+// The deleted identities are included because the steps after the profile step run once the identities are deleted.
+func (s *UserErasureService) forEachIdentity(ctx context.Context, userID uuid.UUID, f func(appl application.Application, identityID uuid.UUID) error) error {
+	return application.Transactional(s.db, func(appl application.Application) error {
+		identities, err := appl.Identities().Query(account.IdentityFilterByUserID(userID), account.IdentityWithDeleted())
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		for _, identity := range identities {
+			if err := f(appl, identity.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *UserErasureService) withKeycloakUser(ctx context.Context, req *goa.RequestData, identityID uuid.UUID, f func(pat string, userURL string) error) error {
+	adminEndpoint, err := s.config.GetKeycloakEndpointAdmin(req)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, errs.Wrap(err, "unable to get Keycloak admin endpoint URL"))
+	}
+	tokenEndpoint, err := s.config.GetKeycloakEndpointToken(req)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, errs.Wrap(err, "unable to get Keycloak token endpoint URL"))
+	}
+	pat, err := auth.GetProtectedAPIToken(ctx, tokenEndpoint, s.config.GetKeycloakClientID(), s.config.GetKeycloakSecret())
+	if err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	return f(pat, adminEndpoint+"/users/"+url.PathEscape(identityID.String()))
+}
+
+// anonymizeWITUser replaces the profile of the user in WIT with the anonymized values
+func (s *UserErasureService) anonymizeWITUser(ctx context.Context, req *goa.RequestData, identityID uuid.UUID) error {
+	witURL, err := s.config.GetWITURL(req)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	empty := ""
+	username := erasedUsername(identityID)
+	email := erasedEmail(identityID)
+	updateUserPayload := &app.UpdateUsersPayload{
+		Data: &app.UpdateUserData{
+			Attributes: &app.UpdateIdentityDataAttributes{
+				Bio:                &empty,
+				Company:            &empty,
+				Email:              &email,
+				FullName:           &empty,
+				ImageURL:           &empty,
+				URL:                &empty,
+				Username:           &username,
+				ContextInformation: map[string]interface{}{},
+			},
+			Type: "identities",
+		},
+	}
+	return s.RemoteWITService.UpdateWITUser(ctx, req, updateUserPayload, witURL, identityID.String())
+}
+
+// anonymizeUser replaces the personal data of the user and its identities with anonymized values and deletes them.
+// Nothing is done if the user has already been deleted.
+func (s *UserErasureService) anonymizeUser(ctx context.Context, userID uuid.UUID) error {
+	return application.Transactional(s.db, func(appl application.Application) error {
+		user, err := appl.Users().Load(ctx, userID)
+		if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+			return nil
+		}
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		identities, err := appl.Identities().Query(account.IdentityFilterByUserID(userID))
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		for _, identity := range identities {
+			identity.Username = erasedUsername(identity.ID)
+			identity.ProfileURL = nil
+			// the user is saved separately below
+			identity.User = account.User{}
+			if err := appl.Identities().Save(ctx, &identity); err != nil {
+				return autherrors.NewInternalError(ctx, err)
+			}
+			if err := appl.Identities().Delete(ctx, identity.ID); err != nil {
+				return autherrors.NewInternalError(ctx, err)
+			}
+		}
+		user.Email = erasedEmail(user.ID)
+		user.FullName = ""
+		user.ImageURL = ""
+		user.Bio = ""
+		user.URL = ""
+		user.Company = ""
+		user.ContextInformation = account.ContextInformation{}
+		if err := appl.Users().Save(ctx, user); err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		// the history holds the previous values of the erased profile
+		if err := appl.UserProfileHistory().DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		return appl.Users().Delete(ctx, user.ID)
+	})
+}
+
+func erasedUsername(id uuid.UUID) string {
+	return "erased-" + id.String()
+}
+
+func erasedEmail(id uuid.UUID) string {
+	return id.String() + "@" + erasedEmailDomain
+}
@@ -0,0 +1,386 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/application"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/goasupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token/provider"
+
+	"github.com/satori/go.uuid"
+)
+
+// pendingExportTimeout is the time after which a pending export is considered failed.
+// The job building the archive is lost if the service is restarted before the export is completed.
+const pendingExportTimeout = 30 * time.Minute
+
+// UserExportConfiguration represents the configuration needed to export the personal data of the users
+type UserExportConfiguration interface {
+	GetUserExportTTL() time.Duration
+}
+
+// UserExportArchive is the JSON document with the personal data of a user.
+// The tokens of the linked accounts are never exported, only their metadata.
+type UserExportArchive struct {
+	ExportedAt      time.Time                `json:"exported_at"`
+	User            ExportedUser             `json:"user"`
+	Identities      []ExportedIdentity       `json:"identities"`
+	LinkedAccounts  []ExportedLinkedAccount  `json:"linked_accounts"`
+	SpaceOwnerships []ExportedSpaceOwnership `json:"space_ownerships"`
+	RoleAssignments []ExportedRoleAssignment `json:"role_assignments"`
+	Audit           []ExportedAuditEntry     `json:"audit"`
+}
+
+// ExportedUser represents the user in the export archive
+type ExportedUser struct {
+	ID                 uuid.UUID              `json:"id"`
+	Email              string                 `json:"email"`
+	FullName           string                 `json:"full_name"`
+	ImageURL           string                 `json:"image_url"`
+	Bio                string                 `json:"bio"`
+	URL                string                 `json:"url"`
+	Company            string                 `json:"company"`
+	Cluster            string                 `json:"cluster"`
+	ContextInformation map[string]interface{} `json:"context_information"`
+	TermsAcceptedAt    *time.Time             `json:"terms_accepted_at,omitempty"`
+	DeactivatedAt      *time.Time             `json:"deactivated_at,omitempty"`
+	CreatedAt          time.Time              `json:"created_at"`
+	UpdatedAt          time.Time              `json:"updated_at"`
+}
+
+// ExportedIdentity represents an identity of the user in the export archive
+type ExportedIdentity struct {
+	ID                    uuid.UUID `json:"id"`
+	Username              string    `json:"username"`
+	ProviderType          string    `json:"provider_type"`
+	ProfileURL            *string   `json:"profile_url,omitempty"`
+	RegistrationCompleted bool      `json:"registration_completed"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// ExportedLinkedAccount represents the metadata of an account linked to an identity of the user
+type ExportedLinkedAccount struct {
+	IdentityID uuid.UUID `json:"identity_id"`
+	ProviderID uuid.UUID `json:"provider_id"`
+	Username   string    `json:"username"`
+	Scope      string    `json:"scope"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ExportedSpaceOwnership represents a space owned by an identity of the user
+type ExportedSpaceOwnership struct {
+	SpaceID    uuid.UUID `json:"space_id"`
+	ResourceID string    `json:"resource_id"`
+	OwnerID    uuid.UUID `json:"owner_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ExportedRoleAssignment represents a role assigned to an identity of the user
+type ExportedRoleAssignment struct {
+	IdentityID uuid.UUID `json:"identity_id"`
+	ResourceID string    `json:"resource_id"`
+	RoleID     uuid.UUID `json:"role_id"`
+	RoleName   string    `json:"role_name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ExportedAuditEntry represents a recorded request or decision about the user,
+// such as the approval of the registration, the deactivation or a previous export
+type ExportedAuditEntry struct {
+	Type      string     `json:"type"`
+	ID        uuid.UUID  `json:"id"`
+	Status    string     `json:"status"`
+	Actor     string     `json:"actor,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+// UserExportService builds the archives with the personal data of the users.
+// The archive is built in the background and can be downloaded once completed until it expires.
+type UserExportService struct {
+	db     application.DB
+	config UserExportConfiguration
+	// Run executes the job building the archive. The job is run in a new goroutine by default.
+	Run func(job func())
+}
+
+// NewUserExportService creates a new user export service
+func NewUserExportService(db application.DB, config UserExportConfiguration) *UserExportService {
+	return &UserExportService{
+		db:     db,
+		config: config,
+		Run: func(job func()) {
+			go job()
+		},
+	}
+}
+
+// Request starts the export of the personal data of the user of the given identity.
+// If an export is already in progress, or a completed export has not expired yet, then this export is returned instead.
+// An export pending for longer than pendingExportTimeout is marked as failed and a new export is started.
+// The archive is built with a context detached from the request so that the job is not canceled once the response is sent.
+func (s *UserExportService) Request(ctx context.Context, identityID uuid.UUID, requestedBy string) (*account.UserExport, error) {
+	var export *account.UserExport
+	var created bool
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		identities, err := appl.Identities().Query(account.IdentityFilterByID(identityID), account.IdentityWithUser())
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		if len(identities) == 0 || identities[0].User.ID == uuid.Nil {
+			return autherrors.NewNotFoundError("identity", identityID.String())
+		}
+		latest, err := appl.UserExports().LoadLatest(ctx, identityID)
+		if notFound, _ := autherrors.IsNotFoundError(err); err != nil && !notFound {
+			return err
+		}
+		if err == nil {
+			if latest.Available() || (latest.Status == account.ExportStatusPending && time.Since(latest.CreatedAt) < pendingExportTimeout) {
+				export = latest
+				return nil
+			}
+			if latest.Status == account.ExportStatusPending {
+				log.Warn(ctx, map[string]interface{}{
+					"user_export_id": latest.ID,
+					"identity_id":    identityID,
+				}, "the user export has been pending for too long; marking it as failed")
+				latest.Status = account.ExportStatusFailed
+				latest.LastError = "the export did not complete in time"
+				if err := appl.UserExports().Save(ctx, latest); err != nil {
+					return err
+				}
+			}
+		}
+		export = &account.UserExport{
+			IdentityID:  identityID,
+			RequestedBy: requestedBy,
+			Status:      account.ExportStatusPending,
+		}
+		created = true
+		return appl.UserExports().Create(ctx, export)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		exportID := export.ID
+		jobCtx := goasupport.DetachContext(ctx)
+		s.Run(func() {
+			s.export(jobCtx, exportID)
+		})
+	}
+	return export, nil
+}
+
+// export builds the archive of the given export and records the result
+func (s *UserExportService) export(ctx context.Context, exportID uuid.UUID) {
+	var export *account.UserExport
+	var archive *UserExportArchive
+	buildErr := application.Transactional(s.db, func(appl application.Application) error {
+		var err error
+		export, err = appl.UserExports().Load(ctx, exportID)
+		if err != nil {
+			return err
+		}
+		archive, err = s.buildArchive(ctx, appl, export.IdentityID)
+		return err
+	})
+	if export == nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_export_id": exportID,
+			"err":            buildErr,
+		}, "unable to load the user export")
+		return
+	}
+	if buildErr == nil {
+		var content []byte
+		content, buildErr = json.Marshal(archive)
+		export.Archive = string(content)
+	}
+	if buildErr != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_export_id": exportID,
+			"identity_id":    export.IdentityID,
+			"err":            buildErr,
+		}, "unable to export the user data")
+		export.Status = account.ExportStatusFailed
+		export.LastError = buildErr.Error()
+	} else {
+		now := time.Now()
+		expiresAt := now.Add(s.config.GetUserExportTTL())
+		export.Status = account.ExportStatusCompleted
+		export.CompletedAt = &now
+		export.ExpiresAt = &expiresAt
+	}
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		return appl.UserExports().Save(ctx, export)
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_export_id": exportID,
+			"err":            err,
+		}, "unable to record the result of the user export")
+		return
+	}
+	log.Info(ctx, map[string]interface{}{
+		"user_export_id": exportID,
+		"status":         export.Status,
+	}, "user data export done")
+}
+
+// buildArchive collects the personal data of the user of the given identity from all the identities of the user
+func (s *UserExportService) buildArchive(ctx context.Context, appl application.Application, identityID uuid.UUID) (*UserExportArchive, error) {
+	identities, err := appl.Identities().Query(account.IdentityFilterByID(identityID), account.IdentityWithUser())
+	if err != nil {
+		return nil, autherrors.NewInternalError(ctx, err)
+	}
+	if len(identities) == 0 {
+		return nil, autherrors.NewNotFoundError("identity", identityID.String())
+	}
+	user := identities[0].User
+	identities, err = appl.Identities().Query(account.IdentityFilterByUserID(user.ID))
+	if err != nil {
+		return nil, autherrors.NewInternalError(ctx, err)
+	}
+
+	archive := &UserExportArchive{
+		ExportedAt: time.Now(),
+		User: ExportedUser{
+			ID:                 user.ID,
+			Email:              user.Email,
+			FullName:           user.FullName,
+			ImageURL:           user.ImageURL,
+			Bio:                user.Bio,
+			URL:                user.URL,
+			Company:            user.Company,
+			Cluster:            user.Cluster,
+			ContextInformation: user.ContextInformation,
+			TermsAcceptedAt:    user.TermsAcceptedAt,
+			DeactivatedAt:      user.DeactivatedAt,
+			CreatedAt:          user.CreatedAt,
+			UpdatedAt:          user.UpdatedAt,
+		},
+		Identities:      []ExportedIdentity{},
+		LinkedAccounts:  []ExportedLinkedAccount{},
+		SpaceOwnerships: []ExportedSpaceOwnership{},
+		RoleAssignments: []ExportedRoleAssignment{},
+		Audit:           []ExportedAuditEntry{},
+	}
+	for _, identity := range identities {
+		archive.Identities = append(archive.Identities, ExportedIdentity{
+			ID:                    identity.ID,
+			Username:              identity.Username,
+			ProviderType:          identity.ProviderType,
+			ProfileURL:            identity.ProfileURL,
+			RegistrationCompleted: identity.RegistrationCompleted,
+			CreatedAt:             identity.CreatedAt,
+			UpdatedAt:             identity.UpdatedAt,
+		})
+
+		tokens, err := appl.ExternalTokens().Query(provider.ExternalTokenFilterByIdentityID(identity.ID))
+		if err != nil {
+			return nil, autherrors.NewInternalError(ctx, err)
+		}
+		for _, token := range tokens {
+			archive.LinkedAccounts = append(archive.LinkedAccounts, ExportedLinkedAccount{
+				IdentityID: identity.ID,
+				ProviderID: token.ProviderID,
+				Username:   token.Username,
+				Scope:      token.Scope,
+				CreatedAt:  token.CreatedAt,
+				UpdatedAt:  token.UpdatedAt,
+			})
+		}
+
+		resources, err := appl.SpaceResources().ListByOwner(ctx, identity.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			archive.SpaceOwnerships = append(archive.SpaceOwnerships, ExportedSpaceOwnership{
+				SpaceID:    resource.SpaceID,
+				ResourceID: resource.ResourceID,
+				OwnerID:    resource.OwnerID,
+				CreatedAt:  resource.CreatedAt,
+			})
+		}
+
+		identityRoles, err := appl.IdentityRoles().ListByIdentity(ctx, identity.ID)
+		if err != nil {
+			return nil, autherrors.NewInternalError(ctx, err)
+		}
+		for _, identityRole := range identityRoles {
+			archive.RoleAssignments = append(archive.RoleAssignments, ExportedRoleAssignment{
+				IdentityID: identity.ID,
+				ResourceID: identityRole.ResourceID,
+				RoleID:     identityRole.RoleID,
+				RoleName:   identityRole.Role.Name,
+				CreatedAt:  identityRole.CreatedAt,
+			})
+		}
+
+		audit, err := s.auditEntries(ctx, appl, identity)
+		if err != nil {
+			return nil, err
+		}
+		archive.Audit = append(archive.Audit, audit...)
+	}
+	return archive, nil
+}
+
+// auditEntries returns the approval of the registration, the erasure and the previous exports of the given identity
+func (s *UserExportService) auditEntries(ctx context.Context, appl application.Application, identity account.Identity) ([]ExportedAuditEntry, error) {
+	var entries []ExportedAuditEntry
+	if identity.ProviderType == account.KeycloakIDP {
+		approval, err := appl.PendingApprovals().LoadByKeycloakUserID(ctx, identity.ID)
+		if notFound, _ := autherrors.IsNotFoundError(err); err != nil && !notFound {
+			return nil, err
+		}
+		if err == nil {
+			entries = append(entries, ExportedAuditEntry{
+				Type:      "registration_approval",
+				ID:        approval.ID,
+				Status:    approval.Status,
+				Actor:     approval.DecidedBy,
+				Reason:    approval.Reason,
+				CreatedAt: approval.CreatedAt,
+				DecidedAt: approval.DecidedAt,
+			})
+		}
+	}
+	erasure, err := appl.UserErasures().LoadByIdentityID(ctx, identity.ID)
+	if notFound, _ := autherrors.IsNotFoundError(err); err != nil && !notFound {
+		return nil, err
+	}
+	if err == nil {
+		entries = append(entries, ExportedAuditEntry{
+			Type:      "deactivation",
+			ID:        erasure.ID,
+			Status:    erasure.Status,
+			Actor:     erasure.RequestedBy,
+			CreatedAt: erasure.CreatedAt,
+		})
+	}
+	exports, err := appl.UserExports().List(ctx, identity.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, export := range exports {
+		entries = append(entries, ExportedAuditEntry{
+			Type:      "export",
+			ID:        export.ID,
+			Status:    export.Status,
+			Actor:     export.RequestedBy,
+			CreatedAt: export.CreatedAt,
+		})
+	}
+	return entries, nil
+}
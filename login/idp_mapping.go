@@ -0,0 +1,55 @@
+package login
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+)
+
+const (
+	loginHintParam = "login_hint"
+	idpHintParam   = "kc_idp_hint"
+)
+
+// ResolveIdpHint returns the alias of the Keycloak identity provider mapped to the domain of the given login hint
+// or an empty string if there is no mapping for the domain (home-realm discovery).
+// The login hint is either an email or a domain. If there is no mapping for the domain
+// then the parent domains are checked, so "dev.example.com" falls back to the "example.com" mapping.
+func ResolveIdpHint(ctx context.Context, db application.DB, loginHint string) (string, error) {
+	domain := strings.ToLower(strings.TrimSpace(loginHint))
+	if i := strings.LastIndex(domain, "@"); i >= 0 {
+		domain = domain[i+1:]
+	}
+	var idpHint string
+	err := application.Transactional(db, func(appl application.Application) error {
+		for domain != "" {
+			mapping, err := appl.IdpMappings().LoadByDomain(ctx, domain)
+			if err == nil {
+				idpHint = mapping.IdpHint
+				return nil
+			}
+			if notFound, _ := autherrors.IsNotFoundError(err); !notFound {
+				return err
+			}
+			i := strings.Index(domain, ".")
+			if i < 0 {
+				return nil
+			}
+			domain = domain[i+1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if idpHint != "" {
+		log.Debug(ctx, map[string]interface{}{
+			"login_hint": loginHint,
+			"idp_hint":   idpHint,
+		}, "identity provider resolved for the login hint")
+	}
+	return idpHint, nil
+}
@@ -0,0 +1,213 @@
+package login
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/configuration"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token/link"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	// GitHubProvider is the name of the GitHub identity provider
+	GitHubProvider = "github"
+	// OpenShiftProviderPrefix is the prefix of the OSO cluster identity provider names.
+	// The provider name of a cluster is the prefix followed by the cluster name.
+	OpenShiftProviderPrefix = "openshift-"
+	// keycloakOpenShiftProvider is the alias of the Keycloak identity provider of the default OSO cluster
+	keycloakOpenShiftProvider = "openshift-v3"
+	// linkedParam is the prefix of the per-provider link status params added to the login redirect
+	linkedParam = "linked_"
+)
+
+// LinkStatusConfiguration represents the configuration of the identity providers which can be linked to the user account
+type LinkStatusConfiguration interface {
+	GetOSOClusters() map[string]configuration.OSOCluster
+	GetOpenShiftClientApiUrl() string
+}
+
+// ProviderLinkStatus represents the link status between the user account and an identity provider
+type ProviderLinkStatus struct {
+	// Provider is "github" or "openshift-<cluster name>"
+	Provider string
+	// ForResource is the resource URL to be used to link the provider via /api/token/link
+	ForResource string
+	Linked      bool
+	// Username is the username of the user in the identity provider. Can be empty even if linked.
+	Username string
+	// keycloakAlias is the alias of the Keycloak identity provider if the provider can be linked via Keycloak
+	keycloakAlias string
+}
+
+// LinkStatus returns the link status of GitHub and each configured OSO cluster.
+// A provider is linked if an external token is stored for the identity or if the account is linked to
+// the corresponding Keycloak identity provider (GitHub and the default OSO cluster only).
+func (keycloak *KeycloakOAuthProvider) LinkStatus(ctx context.Context, identityID uuid.UUID, keycloakToken string, brokerEndpoint string, config LinkStatusConfiguration) ([]ProviderLinkStatus, error) {
+	statuses := []ProviderLinkStatus{
+		{
+			Provider:      GitHubProvider,
+			ForResource:   "https://github.com",
+			keycloakAlias: GitHubProvider,
+		},
+	}
+	clusters := config.GetOSOClusters()
+	apiURLs := make([]string, 0, len(clusters))
+	for apiURL := range clusters {
+		apiURLs = append(apiURLs, apiURL)
+	}
+	sort.Strings(apiURLs)
+	for _, apiURL := range apiURLs {
+		status := ProviderLinkStatus{
+			Provider:    OpenShiftProviderPrefix + clusters[apiURL].Name,
+			ForResource: apiURL,
+		}
+		if strings.TrimSuffix(apiURL, "/") == strings.TrimSuffix(config.GetOpenShiftClientApiUrl(), "/") {
+			status.keycloakAlias = keycloakOpenShiftProvider
+		}
+		statuses = append(statuses, status)
+	}
+
+	err := application.Transactional(keycloak.db, func(appl application.Application) error {
+		for i, status := range statuses {
+			providerID, err := linkProviderID(status.Provider, clusters[status.ForResource])
+			if err != nil {
+				return err
+			}
+			tokens, err := appl.ExternalTokens().LoadByProviderIDAndIdentityID(ctx, providerID, identityID)
+			if err != nil {
+				return err
+			}
+			if len(tokens) > 0 {
+				statuses[i].Linked = true
+				statuses[i].Username = tokens[0].Username
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if keycloakToken != "" {
+		for i, status := range statuses {
+			if status.Linked || status.keycloakAlias == "" {
+				continue
+			}
+			linked, err := keycloak.checkFederatedIdentity(ctx, keycloakToken, brokerEndpoint, status.keycloakAlias)
+			if err != nil {
+				return nil, err
+			}
+			statuses[i].Linked = linked
+		}
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": identityID,
+		"statuses":    statuses,
+	}, "link status checked")
+	return statuses, nil
+}
+
+// UnlinkIdentity removes the identity of a linked account from the user of the given Keycloak identity
+// along with the token of the account. The Keycloak identity itself can't be unlinked.
+func UnlinkIdentity(ctx context.Context, db application.DB, config LinkStatusConfiguration, keycloakIdentityID uuid.UUID, identityID uuid.UUID) error {
+	return application.Transactional(db, func(appl application.Application) error {
+		keycloakIdentity, err := appl.Identities().Load(ctx, keycloakIdentityID)
+		if err != nil {
+			return autherrors.NewUnauthorizedError(err.Error())
+		}
+		identity, err := appl.Identities().Load(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		if !identity.UserID.Valid || identity.UserID != keycloakIdentity.UserID {
+			return autherrors.NewNotFoundError("identity", identityID.String())
+		}
+		if identity.ProviderType == account.KeycloakIDP {
+			return autherrors.NewBadParameterError("identityID", identityID.String()).Expected("identity of a linked account")
+		}
+		providerID, found, err := linkedIdentityProviderID(identity.ProviderType, config)
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		if found {
+			tokens, err := appl.ExternalTokens().LoadByProviderIDAndIdentityID(ctx, providerID, keycloakIdentityID)
+			if err != nil {
+				return autherrors.NewInternalError(ctx, err)
+			}
+			for _, token := range tokens {
+				if err := appl.ExternalTokens().Delete(ctx, token.ID); err != nil {
+					return autherrors.NewInternalError(ctx, err)
+				}
+			}
+		}
+		if err := appl.Identities().Delete(ctx, identity.ID); err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		log.Info(ctx, map[string]interface{}{
+			"identity_id":          keycloakIdentityID,
+			"unlinked_identity_id": identityID,
+			"provider_type":        identity.ProviderType,
+		}, "account unlinked from the user")
+		return nil
+	})
+}
+
+// linkedIdentityProviderID returns the ID of the provider of the identities with the given provider type.
+// Returns false if the provider type doesn't match GitHub nor any configured OSO cluster.
+func linkedIdentityProviderID(providerType string, config LinkStatusConfiguration) (uuid.UUID, bool, error) {
+	if providerType == account.GitHubIDP {
+		id, err := linkProviderID(GitHubProvider, configuration.OSOCluster{})
+		return id, err == nil, err
+	}
+	for _, cluster := range config.GetOSOClusters() {
+		if providerType == account.OpenShiftIDPPrefix+cluster.Name {
+			id, err := linkProviderID(providerType, cluster)
+			return id, err == nil, err
+		}
+	}
+	return uuid.Nil, false, nil
+}
+
+// linkProviderID returns the ID of the provider used in the external token table
+func linkProviderID(provider string, cluster configuration.OSOCluster) (uuid.UUID, error) {
+	if provider == GitHubProvider {
+		return uuid.FromString(link.GitHubProviderID)
+	}
+	return uuid.FromString(cluster.TokenProviderID)
+}
+
+// unlinkedKeycloakProviders returns the aliases of the Keycloak identity providers which are not linked yet
+func unlinkedKeycloakProviders(statuses []ProviderLinkStatus) []string {
+	var providers []string
+	for _, status := range statuses {
+		if !status.Linked && status.keycloakAlias != "" {
+			providers = append(providers, status.keycloakAlias)
+		}
+	}
+	return providers
+}
+
+// addLinkStatusParams adds the "linked" param and the "linked_<provider>" param of each provider to the given
+// redirect URL query. The "linked" param keeps its original meaning: true if the account is linked to GitHub and
+// the default OSO cluster, i.e. to all the providers which are linked during login. The status of the other
+// OSO clusters is only reported by their "linked_<provider>" params.
+func addLinkStatusParams(redirectURL string, statuses []ProviderLinkStatus) string {
+	linked := true
+	var params []string
+	for _, status := range statuses {
+		if status.keycloakAlias != "" {
+			linked = linked && status.Linked
+		}
+		params = append(params, url.QueryEscape(linkedParam+status.Provider)+"="+strconv.FormatBool(status.Linked))
+	}
+	return redirectURL + "&linked=" + strconv.FormatBool(linked) + "&" + strings.Join(params, "&")
+}
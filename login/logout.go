@@ -1,17 +1,56 @@
 package login
 
 import (
+	"context"
+	"net/http"
 	"net/url"
 	"regexp"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
 	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/dgrijalva/jwt-go"
 	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
 )
 
+// KeycloakAdminConfiguration represents configuration needed to call the Keycloak admin API
+type KeycloakAdminConfiguration interface {
+	GetKeycloakEndpointAdmin(*goa.RequestData) (string, error)
+	GetKeycloakEndpointToken(*goa.RequestData) (string, error)
+	GetKeycloakClientID() string
+	GetKeycloakSecret() string
+}
+
+// LogoutServiceConfiguration represents configuration needed to revoke the logged out sessions
+type LogoutServiceConfiguration interface {
+	KeycloakAdminConfiguration
+	GetBackChannelLogoutClients() map[string]string
+}
+
 // KeycloakLogoutService represents a keycloak logout service
 type KeycloakLogoutService struct {
+	db           application.DB
+	tokenManager token.Manager
+	config       LogoutServiceConfiguration
+}
+
+// NewKeycloakLogoutService creates a new logout service which also revokes the logged out session
+func NewKeycloakLogoutService(db application.DB, tokenManager token.Manager, config LogoutServiceConfiguration) *KeycloakLogoutService {
+	return &KeycloakLogoutService{
+		db:           db,
+		tokenManager: tokenManager,
+		config:       config,
+	}
 }
 
 // LogoutService represents logout service interface
@@ -69,6 +108,8 @@ func (s *KeycloakLogoutService) Logout(ctx *app.LogoutLogoutContext, logoutEndpo
 		}, "Failed to logout. Unable to parse logout url.")
 		return jsonapi.JSONErrorResponse(ctx, goa.ErrInternal(err.Error()))
 	}
+	s.revokeSession(ctx)
+
 	parameters := logoutURL.Query()
 	parameters.Add("redirect_uri", redirectURLStr)
 	logoutURL.RawQuery = parameters.Encode()
@@ -76,3 +117,132 @@ func (s *KeycloakLogoutService) Logout(ctx *app.LogoutLogoutContext, logoutEndpo
 	ctx.ResponseData.Header().Set("Location", logoutURL.String())
 	return ctx.TemporaryRedirect()
 }
+
+// revokeSession revokes the session of the token used to authorize the logout request (if any).
+// The session is recorded as revoked so tokens from that session are rejected by auth,
+// the refresh and offline tokens of the session are revoked in Keycloak and the registered clients
+// are notified via OpenID Connect Back-Channel Logout.
+// Failures are logged but do not prevent the user from being logged out.
+func (s *KeycloakLogoutService) revokeSession(ctx *app.LogoutLogoutContext) {
+	if s.db == nil {
+		return
+	}
+	jwtToken := goajwt.ContextJWT(ctx)
+	if jwtToken == nil || !jwtToken.Valid {
+		return
+	}
+	claims, ok := jwtToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return
+	}
+	sessionState, _ := claims["session_state"].(string)
+	sub, _ := claims["sub"].(string)
+	if sessionState == "" || sub == "" {
+		// Not a user token. Nothing to revoke.
+		return
+	}
+
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		session := &auth.RevokedSession{SessionState: sessionState}
+		if identityID, err := uuid.FromString(sub); err == nil && appl.Identities().IsValid(ctx, identityID) {
+			session.IdentityID = &identityID
+		}
+		return appl.RevokedSessions().Create(ctx, session)
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"session_state": sessionState,
+			"err":           err,
+		}, "unable to record the revoked session")
+	}
+
+	err = RevokeKeycloakSession(ctx, ctx.RequestData, s.config, sub, sessionState)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"session_state": sessionState,
+			"err":           err,
+		}, "unable to revoke the Keycloak session")
+	}
+
+	s.sendBackChannelLogout(ctx, ctx.RequestData, sub, sessionState)
+}
+
+// RevokeKeycloakSession removes the user session in Keycloak which invalidates its refresh tokens
+// and revokes the offline tokens issued to the auth client
+func RevokeKeycloakSession(ctx context.Context, req *goa.RequestData, config KeycloakAdminConfiguration, userID string, sessionState string) error {
+	adminEndpoint, err := config.GetKeycloakEndpointAdmin(req)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, errs.Wrap(err, "unable to get Keycloak admin endpoint URL"))
+	}
+	tokenEndpoint, err := config.GetKeycloakEndpointToken(req)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, errs.Wrap(err, "unable to get Keycloak token endpoint URL"))
+	}
+	pat, err := auth.GetProtectedAPIToken(ctx, tokenEndpoint, config.GetKeycloakClientID(), config.GetKeycloakSecret())
+	if err != nil {
+		return err
+	}
+	urls := []string{
+		adminEndpoint + "/sessions/" + url.PathEscape(sessionState),
+		adminEndpoint + "/users/" + url.PathEscape(userID) + "/consents/" + url.PathEscape(config.GetKeycloakClientID()),
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, u := range urls {
+		request, err := http.NewRequest("DELETE", u, nil)
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		request.Header.Add("Authorization", "Bearer "+pat)
+		res, err := client.Do(request)
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		rest.CloseResponse(res)
+		// Not found means there is nothing to revoke
+		if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+			return autherrors.NewInternalError(ctx, errs.Errorf("unexpected response %s when revoking Keycloak session: %s", res.Status, u))
+		}
+	}
+	log.Info(ctx, map[string]interface{}{
+		"session_state": sessionState,
+		"user_id":       userID,
+	}, "Keycloak session revoked")
+	return nil
+}
+
+// sendBackChannelLogout sends an OpenID Connect Back-Channel Logout token to all the registered clients
+func (s *KeycloakLogoutService) sendBackChannelLogout(ctx context.Context, req *goa.RequestData, identityID string, sessionState string) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	for clientID, callbackURL := range s.config.GetBackChannelLogoutClients() {
+		logoutToken, err := s.tokenManager.GenerateLogoutToken(req, clientID, identityID, sessionState)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"client_id": clientID,
+				"err":       err,
+			}, "unable to generate logout token")
+			continue
+		}
+		res, err := client.PostForm(callbackURL, url.Values{"logout_token": {logoutToken}})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"client_id":    clientID,
+				"callback_url": callbackURL,
+				"err":          err,
+			}, "unable to send back-channel logout token")
+			continue
+		}
+		rest.CloseResponse(res)
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+			log.Error(ctx, map[string]interface{}{
+				"client_id":       clientID,
+				"callback_url":    callbackURL,
+				"response_status": res.Status,
+			}, "back-channel logout rejected by client")
+			continue
+		}
+		log.Info(ctx, map[string]interface{}{
+			"client_id":     clientID,
+			"session_state": sessionState,
+		}, "back-channel logout sent")
+	}
+}
@@ -0,0 +1,212 @@
+package login
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/application"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token/provider"
+
+	"github.com/satori/go.uuid"
+)
+
+// UserMergeService merges duplicate users, for example a user who signed up twice with different Keycloak accounts.
+type UserMergeService struct {
+	db application.DB
+	// Notify emits the merge event once the merge is committed. The event is logged by default.
+	Notify func(ctx context.Context, merge account.UserMerge)
+}
+
+// NewUserMergeService creates a new user merge service
+func NewUserMergeService(db application.DB) *UserMergeService {
+	return &UserMergeService{
+		db:     db,
+		Notify: LogUserMergeEvent,
+	}
+}
+
+// LogUserMergeEvent emits the merge event in the logs
+func LogUserMergeEvent(ctx context.Context, merge account.UserMerge) {
+	log.Info(ctx, map[string]interface{}{
+		"event":              "user_merged",
+		"user_merge_id":      merge.ID,
+		"source_identity_id": merge.SourceIdentityID,
+		"source_user_id":     merge.SourceUserID,
+		"target_identity_id": merge.TargetIdentityID,
+		"target_user_id":     merge.TargetUserID,
+		"merged_by":          merge.MergedBy,
+	}, "user merged")
+}
+
+// Merge merges the user of the source Keycloak identity into the user of the target Keycloak identity.
+// The external tokens, the space resources, the resources and the role assignments of the source identity
+// are moved to the target identity and the other identities of the source user are moved to the target user.
+// The source identity and user are then deleted and the merge is recorded as their tombstone.
+// Everything is done in a single transaction.
+func (s *UserMergeService) Merge(ctx context.Context, sourceIdentityID uuid.UUID, targetIdentityID uuid.UUID, mergedBy string) (*account.UserMerge, error) {
+	if sourceIdentityID == targetIdentityID {
+		return nil, autherrors.NewBadParameterError("target_identity_id", targetIdentityID.String()).Expected("identity different from the source identity")
+	}
+	var merge *account.UserMerge
+	err := application.Transactional(s.db, func(appl application.Application) error {
+		source, err := loadMergedIdentity(ctx, appl, sourceIdentityID, "source_identity_id")
+		if err != nil {
+			return err
+		}
+		target, err := loadMergedIdentity(ctx, appl, targetIdentityID, "target_identity_id")
+		if err != nil {
+			return err
+		}
+		if source.User.ID == target.User.ID {
+			return autherrors.NewBadParameterError("target_identity_id", targetIdentityID.String()).Expected("identity of another user")
+		}
+
+		if err := mergeExternalTokens(ctx, appl, source.ID, target.ID); err != nil {
+			return err
+		}
+		if err := mergeIdentityRoles(ctx, appl, source.ID, target.ID); err != nil {
+			return err
+		}
+		if err := appl.Teams().ReassignMemberships(ctx, source.ID, target.ID); err != nil {
+			return err
+		}
+		if err := appl.Organizations().TransferOwnership(ctx, source.ID, target.ID); err != nil {
+			return err
+		}
+		if err := appl.SpaceResources().TransferOwnership(ctx, source.ID, target.ID); err != nil {
+			return err
+		}
+		if err := appl.Resources().TransferOwnership(ctx, source.ID, target.ID); err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+
+		identities, err := appl.Identities().Query(account.IdentityFilterByUserID(source.User.ID))
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		for _, identity := range identities {
+			if identity.ID == source.ID {
+				continue
+			}
+			identity.UserID = account.NullUUID{UUID: target.User.ID, Valid: true}
+			identity.User = account.User{}
+			if err := appl.Identities().Save(ctx, &identity); err != nil {
+				return autherrors.NewInternalError(ctx, err)
+			}
+		}
+		if err := appl.Identities().Delete(ctx, source.ID); err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		if err := appl.Users().Delete(ctx, source.User.ID); err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+
+		merge = &account.UserMerge{
+			SourceIdentityID: source.ID,
+			SourceUserID:     source.User.ID,
+			TargetIdentityID: target.ID,
+			TargetUserID:     target.User.ID,
+			MergedBy:         mergedBy,
+		}
+		return appl.UserMerges().Create(ctx, merge)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Notify(ctx, *merge)
+	return merge, nil
+}
+
+// loadMergedIdentity loads the active Keycloak identity with its user
+func loadMergedIdentity(ctx context.Context, appl application.Application, identityID uuid.UUID, param string) (*account.Identity, error) {
+	identities, err := appl.Identities().Query(account.IdentityFilterByID(identityID), account.IdentityWithUser())
+	if err != nil {
+		return nil, autherrors.NewInternalError(ctx, err)
+	}
+	if len(identities) == 0 {
+		return nil, autherrors.NewNotFoundError("identity", identityID.String())
+	}
+	identity := &identities[0]
+	if identity.ProviderType != account.KeycloakIDP || identity.User.ID == uuid.Nil {
+		return nil, autherrors.NewBadParameterError(param, identityID.String()).Expected("Keycloak identity linked to a user")
+	}
+	if identity.User.DeactivatedAt != nil {
+		return nil, autherrors.NewBadParameterError(param, identityID.String()).Expected("identity of an active user")
+	}
+	return identity, nil
+}
+
+// mergeExternalTokens moves the tokens of the source identity to the target identity.
+// The tokens of the providers already linked to the target identity are dropped so the target keeps a single account per provider.
+func mergeExternalTokens(ctx context.Context, appl application.Application, sourceID uuid.UUID, targetID uuid.UUID) error {
+	sourceTokens, err := appl.ExternalTokens().Query(provider.ExternalTokenFilterByIdentityID(sourceID))
+	if err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	for _, token := range sourceTokens {
+		targetTokens, err := appl.ExternalTokens().LoadByProviderIDAndIdentityID(ctx, token.ProviderID, targetID)
+		if err != nil {
+			return autherrors.NewInternalError(ctx, err)
+		}
+		if len(targetTokens) > 0 {
+			if err := appl.ExternalTokens().Delete(ctx, token.ID); err != nil {
+				return autherrors.NewInternalError(ctx, err)
+			}
+		}
+	}
+	if err := appl.ExternalTokens().ReassignIdentity(ctx, sourceID, targetID); err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// mergeIdentityRoles moves the role assignments of the source identity to the target identity.
+// The roles the target identity already has on the same resources are dropped.
+func mergeIdentityRoles(ctx context.Context, appl application.Application, sourceID uuid.UUID, targetID uuid.UUID) error {
+	sourceRoles, err := appl.IdentityRoles().ListByIdentity(ctx, sourceID)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	targetRoles, err := appl.IdentityRoles().ListByIdentity(ctx, targetID)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	for _, sourceRole := range sourceRoles {
+		for _, targetRole := range targetRoles {
+			if sourceRole.ResourceID == targetRole.ResourceID && sourceRole.RoleID == targetRole.RoleID {
+				if err := appl.IdentityRoles().Delete(ctx, sourceRole.IdentityRoleID); err != nil {
+					return autherrors.NewInternalError(ctx, err)
+				}
+				break
+			}
+		}
+	}
+	if err := appl.IdentityRoles().ReassignIdentity(ctx, sourceID, targetID); err != nil {
+		return autherrors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// checkNotMerged returns ForbiddenError if the given identity has been merged into another user
+func checkNotMerged(ctx context.Context, db application.DB, identityID uuid.UUID) error {
+	var merge *account.UserMerge
+	err := application.Transactional(db, func(appl application.Application) error {
+		var err error
+		merge, err = appl.UserMerges().LoadBySourceIdentityID(ctx, identityID)
+		return err
+	})
+	if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Warn(ctx, map[string]interface{}{
+		"identity_id":        identityID,
+		"target_identity_id": merge.TargetIdentityID,
+	}, "merged identity is trying to log in")
+	return autherrors.NewForbiddenError(fmt.Sprintf("identity '%s' has been merged into another account", identityID))
+}
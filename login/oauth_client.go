@@ -0,0 +1,134 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// AuthorizationCodeGrantType is the grant type used by clients which log in users via the login endpoint
+	AuthorizationCodeGrantType = "authorization_code"
+	// RefreshTokenGrantType is the grant type used by clients which refresh tokens
+	RefreshTokenGrantType = "refresh_token"
+)
+
+// LoadOauthClient loads the registered API client with the given client ID and checks if the client is allowed to use the grant type.
+// API clients which are not registered yet may still log in users and refresh tokens until they are registered:
+// such clients get no redirect URIs of their own, so their redirects are validated against the valid redirect URLs of auth.
+// Returns BadParameterError if an unregistered client requests any other grant type or the grant type is not allowed for the client.
+func LoadOauthClient(ctx context.Context, db application.DB, clientID string, grantType string) (*auth.OauthClient, error) {
+	var client *auth.OauthClient
+	err := application.Transactional(db, func(appl application.Application) error {
+		var err error
+		client, err = appl.OauthClients().LoadByClientID(ctx, clientID)
+		return err
+	})
+	if err != nil {
+		if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+			if grantType == AuthorizationCodeGrantType || grantType == RefreshTokenGrantType {
+				log.Warn(ctx, map[string]interface{}{
+					"client_id":  clientID,
+					"grant_type": grantType,
+				}, "unregistered API client, falling back to the valid redirect URLs")
+				return unregisteredOauthClient(clientID), nil
+			}
+			log.Error(ctx, map[string]interface{}{
+				"client_id": clientID,
+			}, "unknown API client")
+			return nil, autherrors.NewBadParameterError("api_client", clientID).Expected("registered API client")
+		}
+		return nil, err
+	}
+	if !client.IsGrantTypeAllowed(grantType) {
+		log.Error(ctx, map[string]interface{}{
+			"client_id":  clientID,
+			"grant_type": grantType,
+		}, "grant type not allowed for the API client")
+		return nil, autherrors.NewBadParameterError("api_client", clientID).Expected("API client allowed to use the " + grantType + " grant type")
+	}
+	return client, nil
+}
+
+// unregisteredOauthClient returns the settings applied to an API client which is not registered:
+// no redirect URIs, no token limits and no additional claims.
+func unregisteredOauthClient(clientID string) *auth.OauthClient {
+	return &auth.OauthClient{
+		ClientID:   clientID,
+		GrantTypes: auth.StringList{AuthorizationCodeGrantType, RefreshTokenGrantType},
+	}
+}
+
+// LimitTokenSet applies the settings of the API client to the token set returned to the client.
+// The access token is reissued with the ID of the client and with the lifetime and the claims registered for the client,
+// the lifetime of the refresh token is limited to the lifetime registered for the client
+// and the refresh token is removed if the client is not allowed to refresh tokens.
+func LimitTokenSet(ctx context.Context, tokenManager token.Manager, tokenSet *token.TokenSet, client *auth.OauthClient) error {
+	if tokenSet.AccessToken != nil {
+		accessToken, err := tokenManager.ReissueAccessToken(ctx, *tokenSet.AccessToken, client.AccessTokenLifetime, client.Claims, client.ClientID)
+		if err != nil {
+			return autherrors.NewInternalError(ctx, errs.Wrap(err, "unable to reissue the access token for the API client"))
+		}
+		tokenSet.AccessToken = &accessToken
+	}
+	if client.AccessTokenLifetime != nil && (tokenSet.ExpiresIn == nil || *tokenSet.ExpiresIn > int64(*client.AccessTokenLifetime)) {
+		expiresIn := int64(*client.AccessTokenLifetime)
+		tokenSet.ExpiresIn = &expiresIn
+	}
+	if client.RefreshTokenLifetime != nil && (tokenSet.RefreshExpiresIn == nil || *tokenSet.RefreshExpiresIn > int64(*client.RefreshTokenLifetime)) {
+		refreshExpiresIn := int64(*client.RefreshTokenLifetime)
+		tokenSet.RefreshExpiresIn = &refreshExpiresIn
+	}
+	if !client.IsGrantTypeAllowed(RefreshTokenGrantType) {
+		tokenSet.RefreshToken = nil
+		tokenSet.RefreshExpiresIn = nil
+	}
+	return nil
+}
+
+// IssueTokenSet applies the settings of the API client to the token set issued to the client after login
+// and records the refresh token with the client and the expiration of the token family,
+// so the settings of the client also apply when the token is refreshed.
+func IssueTokenSet(ctx context.Context, db application.DB, tokenManager token.Manager, tokenSet *token.TokenSet, client *auth.OauthClient) error {
+	if err := LimitTokenSet(ctx, tokenManager, tokenSet, client); err != nil {
+		return err
+	}
+	if tokenSet.RefreshToken == nil {
+		return nil
+	}
+	refreshToken := &auth.RefreshToken{
+		TokenHash: auth.HashRefreshToken(*tokenSet.RefreshToken),
+		ClientID:  client.ClientID,
+	}
+	if client.RefreshTokenLifetime != nil && tokenSet.RefreshExpiresIn != nil {
+		expiresAt := time.Now().Add(time.Duration(*tokenSet.RefreshExpiresIn) * time.Second)
+		refreshToken.ExpiresAt = &expiresAt
+	}
+	return application.Transactional(db, func(appl application.Application) error {
+		return appl.RefreshTokens().Create(ctx, refreshToken)
+	})
+}
+
+// issueTokenJSON applies the settings of the API client to the JSON encoded token set
+func issueTokenJSON(ctx context.Context, db application.DB, tokenManager token.Manager, tokenJSON string, client *auth.OauthClient) (string, error) {
+	tokenSet := token.TokenSet{}
+	if err := json.Unmarshal([]byte(tokenJSON), &tokenSet); err != nil {
+		return "", errs.WithStack(err)
+	}
+	if err := IssueTokenSet(ctx, db, tokenManager, &tokenSet, client); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(tokenSet)
+	if err != nil {
+		return "", errs.WithStack(err)
+	}
+	return string(b), nil
+}
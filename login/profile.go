@@ -31,6 +31,7 @@ type KeycloakUserProfile struct {
 	FirstName  *string                        `json:"firstName,omitempty"`
 	LastName   *string                        `json:"lastName,omitempty"`
 	Email      *string                        `json:"email,omitempty"`
+	Enabled    *bool                          `json:"enabled,omitempty"`
 	Attributes *KeycloakUserProfileAttributes `json:"attributes,omitempty"`
 }
 
@@ -84,6 +85,9 @@ type UserProfileService interface {
 	Update(ctx context.Context, conkeycloakUserProfile *KeycloakUserProfile, accessToken string, keycloakProfileURL string) error
 	Get(ctx context.Context, accessToken string, keycloakProfileURL string) (*KeycloakUserProfileResponse, error)
 	Create(ctx context.Context, keycloakUserRequest *KeytcloakUserRequest, protectedAccessToken string, keycloakAdminUserAPIURL string) (*string, error)
+	SetAttributes(ctx context.Context, attributes KeycloakUserProfileAttributes, protectedAccessToken string, keycloakAdminUserURL string) error
+	SetEnabled(ctx context.Context, enabled bool, protectedAccessToken string, keycloakAdminUserURL string) error
+	Delete(ctx context.Context, protectedAccessToken string, keycloakAdminUserURL string) error
 }
 
 // KeycloakUserProfileClient describes the interface between platform and Keycloak User profile service.
@@ -273,3 +277,143 @@ func (userProfileClient *KeycloakUserProfileClient) Get(ctx context.Context, acc
 	err = json.NewDecoder(resp.Body).Decode(&keycloakUserProfileResponse)
 	return &keycloakUserProfileResponse, err
 }
+
+// SetAttributes sets the given attributes of the user using the Keycloak admin REST API.
+// The other attributes of the user are preserved.
+func (userProfileClient *KeycloakUserProfileClient) SetAttributes(ctx context.Context, attributes KeycloakUserProfileAttributes, protectedAccessToken string, keycloakAdminUserURL string) error {
+	existing, err := userProfileClient.Get(ctx, protectedAccessToken, keycloakAdminUserURL)
+	if err != nil {
+		return err
+	}
+	merged := KeycloakUserProfileAttributes{}
+	if existing.Attributes != nil {
+		for name, value := range *existing.Attributes {
+			merged[name] = value
+		}
+	}
+	for name, value := range attributes {
+		merged[name] = value
+	}
+	body, err := json.Marshal(KeycloakUserProfile{Attributes: &merged})
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+
+	req, err := http.NewRequest("PUT", keycloakAdminUserURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	req.Header.Add("Authorization", "Bearer "+protectedAccessToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := userProfileClient.client.Do(req)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"keycloak_user_url": keycloakAdminUserURL,
+			"err":               err,
+		}, "Unable to update Keycloak user attributes")
+		return errors.NewInternalError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		bodyString := rest.ReadBody(resp.Body)
+		log.Error(ctx, map[string]interface{}{
+			"response_status":   resp.Status,
+			"response_body":     bodyString,
+			"keycloak_user_url": keycloakAdminUserURL,
+		}, "Unable to update Keycloak user attributes")
+		if resp.StatusCode == http.StatusNotFound {
+			return errors.NewNotFoundError("keycloak user", keycloakAdminUserURL)
+		}
+		return errors.NewInternalError(ctx, errs.Errorf("received a non-2xx response %s while updating keycloak user attributes %s", resp.Status, keycloakAdminUserURL))
+	}
+	log.Info(ctx, map[string]interface{}{
+		"keycloak_user_url": keycloakAdminUserURL,
+	}, "Successfully updated Keycloak user attributes")
+	return nil
+}
+
+// SetEnabled enables or disables the user using the Keycloak admin REST API.
+// Disabled users can't log in nor refresh their tokens.
+func (userProfileClient *KeycloakUserProfileClient) SetEnabled(ctx context.Context, enabled bool, protectedAccessToken string, keycloakAdminUserURL string) error {
+	body, err := json.Marshal(KeycloakUserProfile{Enabled: &enabled})
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+
+	req, err := http.NewRequest("PUT", keycloakAdminUserURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	req.Header.Add("Authorization", "Bearer "+protectedAccessToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := userProfileClient.client.Do(req)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"keycloak_user_url": keycloakAdminUserURL,
+			"err":               err,
+		}, "Unable to enable or disable Keycloak user")
+		return errors.NewInternalError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		bodyString := rest.ReadBody(resp.Body)
+		log.Error(ctx, map[string]interface{}{
+			"response_status":   resp.Status,
+			"response_body":     bodyString,
+			"keycloak_user_url": keycloakAdminUserURL,
+		}, "Unable to enable or disable Keycloak user")
+		if resp.StatusCode == http.StatusNotFound {
+			return errors.NewNotFoundError("keycloak user", keycloakAdminUserURL)
+		}
+		return errors.NewInternalError(ctx, errs.Errorf("received a non-2xx response %s while updating keycloak user %s", resp.Status, keycloakAdminUserURL))
+	}
+	log.Info(ctx, map[string]interface{}{
+		"keycloak_user_url": keycloakAdminUserURL,
+		"enabled":           enabled,
+	}, "Successfully enabled or disabled Keycloak user")
+	return nil
+}
+
+// Delete deletes the user using the Keycloak admin REST API.
+// Deleting a user which doesn't exist in Keycloak is a no-op.
+func (userProfileClient *KeycloakUserProfileClient) Delete(ctx context.Context, protectedAccessToken string, keycloakAdminUserURL string) error {
+	req, err := http.NewRequest("DELETE", keycloakAdminUserURL, nil)
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	req.Header.Add("Authorization", "Bearer "+protectedAccessToken)
+
+	resp, err := userProfileClient.client.Do(req)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"keycloak_user_url": keycloakAdminUserURL,
+			"err":               err,
+		}, "Unable to delete Keycloak user")
+		return errors.NewInternalError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	bodyString := rest.ReadBody(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		log.Info(ctx, map[string]interface{}{
+			"keycloak_user_url": keycloakAdminUserURL,
+		}, "Keycloak user already deleted")
+		return nil
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		log.Error(ctx, map[string]interface{}{
+			"response_status":   resp.Status,
+			"response_body":     bodyString,
+			"keycloak_user_url": keycloakAdminUserURL,
+		}, "Unable to delete Keycloak user")
+		return errors.NewInternalError(ctx, errs.Errorf("received a non-2xx response %s while deleting keycloak user %s", resp.Status, keycloakAdminUserURL))
+	}
+	log.Info(ctx, map[string]interface{}{
+		"keycloak_user_url": keycloakAdminUserURL,
+	}, "Successfully deleted Keycloak user")
+	return nil
+}
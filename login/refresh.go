@@ -0,0 +1,191 @@
+package login
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/token"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// RefreshTokenRotation rotates refresh tokens on every use and detects reuse of already rotated tokens.
+// All the refresh tokens issued for the same session belong to the same token family.
+// If a rotated refresh token is used again then the whole family is revoked.
+type RefreshTokenRotation struct {
+	db           application.DB
+	tokenManager token.Manager
+	config       KeycloakAdminConfiguration
+}
+
+// NewRefreshTokenRotation creates a new refresh token rotation
+func NewRefreshTokenRotation(db application.DB, tokenManager token.Manager, config KeycloakAdminConfiguration) *RefreshTokenRotation {
+	return &RefreshTokenRotation{
+		db:           db,
+		tokenManager: tokenManager,
+		config:       config,
+	}
+}
+
+// Check checks the refresh token before it's exchanged for a new token set.
+// The token is not marked as used yet so the client can retry with the same token if the exchange fails.
+// Returns UnauthorizedError if the token has been revoked or has already been used,
+// in which case the whole token family is revoked.
+func (r *RefreshTokenRotation) Check(ctx context.Context, req *goa.RequestData, refreshToken string) (*auth.RefreshToken, error) {
+	tokenHash := auth.HashRefreshToken(refreshToken)
+	stored, err := r.load(ctx, tokenHash)
+	if err != nil {
+		if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+			// The token has not been issued by auth before (for example the first refresh after login).
+			// It's recorded as used once it has been rotated.
+			return &auth.RefreshToken{TokenHash: tokenHash}, nil
+		}
+		return nil, err
+	}
+	if stored.RevokedAt != nil {
+		return nil, autherrors.NewUnauthorizedError("refresh token has been revoked")
+	}
+	if stored.ExpiresAt != nil && time.Now().After(*stored.ExpiresAt) {
+		return nil, autherrors.NewUnauthorizedError("refresh token has expired")
+	}
+	if stored.UsedAt != nil {
+		return nil, r.revokeFamily(ctx, req, stored)
+	}
+	return stored, nil
+}
+
+// Rotate marks the checked refresh token as used and records the token set issued in exchange of it, in the same transaction.
+// The new refresh token belongs to the same token family and can't outlive the expiration of the family.
+// Returns UnauthorizedError if the session of the new token set has been revoked
+// or if the checked token has been used concurrently by another request, in which case the whole token family is revoked.
+func (r *RefreshTokenRotation) Rotate(ctx context.Context, req *goa.RequestData, checked *auth.RefreshToken, tokenSet *token.TokenSet) error {
+	if tokenSet.AccessToken == nil || tokenSet.RefreshToken == nil {
+		return autherrors.NewInternalError(ctx, errs.New("access token or refresh token is missing in the token set"))
+	}
+	claims, err := r.tokenManager.ParseToken(ctx, *tokenSet.AccessToken)
+	if err != nil {
+		return autherrors.NewInternalError(ctx, errs.Wrap(err, "unable to parse the refreshed access token"))
+	}
+	var identityID *uuid.UUID
+	if id, err := uuid.FromString(claims.Subject); err == nil {
+		identityID = &id
+	}
+	unknown := checked.ID == uuid.Nil
+	reused := false
+	err = application.Transactional(r.db, func(appl application.Application) error {
+		revoked, err := appl.RevokedSessions().IsRevoked(ctx, claims.SessionState)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return autherrors.NewUnauthorizedError("session has been logged out")
+		}
+		if identityID != nil && !appl.Identities().IsValid(ctx, *identityID) {
+			identityID = nil
+		}
+		now := time.Now()
+		if unknown {
+			checked.SessionState = claims.SessionState
+			checked.IdentityID = identityID
+			checked.UsedAt = &now
+			if err := appl.RefreshTokens().Create(ctx, checked); err != nil {
+				return err
+			}
+		} else {
+			ok, err := appl.RefreshTokens().MarkUsed(ctx, checked.ID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				reused = true
+				return nil
+			}
+			if checked.SessionState == "" {
+				checked.SessionState = claims.SessionState
+				checked.IdentityID = identityID
+				checked.UsedAt = &now
+				if err := appl.RefreshTokens().Save(ctx, checked); err != nil {
+					return err
+				}
+			}
+		}
+		return appl.RefreshTokens().Create(ctx, &auth.RefreshToken{
+			TokenHash:    auth.HashRefreshToken(*tokenSet.RefreshToken),
+			SessionState: claims.SessionState,
+			IdentityID:   identityID,
+			ClientID:     checked.ClientID,
+			ExpiresAt:    checked.ExpiresAt,
+		})
+	})
+	if err != nil {
+		if unknown {
+			// The token may have been rotated concurrently by another request
+			if stored, loadErr := r.load(ctx, checked.TokenHash); loadErr == nil {
+				return r.revokeFamily(ctx, req, stored)
+			}
+		}
+		return err
+	}
+	if reused {
+		return r.revokeFamily(ctx, req, checked)
+	}
+	if checked.ExpiresAt != nil {
+		refreshExpiresIn := int64(checked.ExpiresAt.Sub(time.Now()).Seconds())
+		if tokenSet.RefreshExpiresIn == nil || *tokenSet.RefreshExpiresIn > refreshExpiresIn {
+			tokenSet.RefreshExpiresIn = &refreshExpiresIn
+		}
+	}
+	return nil
+}
+
+// revokeFamily revokes all the refresh tokens of the family of the reused token,
+// revokes the session and logs the security event
+func (r *RefreshTokenRotation) revokeFamily(ctx context.Context, req *goa.RequestData, reused *auth.RefreshToken) error {
+	log.Error(ctx, map[string]interface{}{
+		"security_event":   "refresh_token_reuse",
+		"refresh_token_id": reused.ID,
+		"session_state":    reused.SessionState,
+		"identity_id":      reused.IdentityID,
+	}, "refresh token reuse detected; revoking the token family")
+	if reused.SessionState == "" {
+		return autherrors.NewUnauthorizedError("refresh token has already been used")
+	}
+	err := application.Transactional(r.db, func(appl application.Application) error {
+		if err := appl.RefreshTokens().RevokeFamily(ctx, reused.SessionState); err != nil {
+			return err
+		}
+		return appl.RevokedSessions().Create(ctx, &auth.RevokedSession{
+			SessionState: reused.SessionState,
+			IdentityID:   reused.IdentityID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if reused.IdentityID != nil {
+		err = RevokeKeycloakSession(ctx, req, r.config, reused.IdentityID.String(), reused.SessionState)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"session_state": reused.SessionState,
+				"err":           err,
+			}, "unable to revoke the Keycloak session")
+		}
+	}
+	return autherrors.NewUnauthorizedError("refresh token has already been used")
+}
+
+func (r *RefreshTokenRotation) load(ctx context.Context, tokenHash string) (*auth.RefreshToken, error) {
+	var stored *auth.RefreshToken
+	err := application.Transactional(r.db, func(appl application.Application) error {
+		var err error
+		stored, err = appl.RefreshTokens().LoadByHash(ctx, tokenHash)
+		return err
+	})
+	return stored, err
+}
@@ -12,10 +12,12 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/auth"
 	autherrors "github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
 	"github.com/fabric8-services/fabric8-auth/log"
@@ -34,11 +36,13 @@ import (
 )
 
 type LoginServiceConfiguration interface {
+	account.UserPolicyConfiguration
 	GetKeycloakEndpointBroker(*goa.RequestData) (string, error)
 	GetValidRedirectURLs() string
 	GetNotApprovedRedirect() string
 	GetWITURL(*goa.RequestData) (string, error)
 	GetOpenShiftClientApiUrl() string
+	ClusterConfiguration
 }
 
 // NewKeycloakOAuthProvider creates a new login.Service capable of using keycloak for authorization
@@ -68,6 +72,7 @@ type KeycloakOAuthService interface {
 	Link(ctx *app.LinkLinkContext, brokerEndpoint string, clientID string, validRedirectURL string) error
 	LinkSession(ctx *app.SessionLinkContext, brokerEndpoint string, clientID string, validRedirectURL string) error
 	LinkCallback(ctx *app.CallbackLinkContext, brokerEndpoint string, clientID string) error
+	LinkStatus(ctx context.Context, identityID uuid.UUID, keycloakToken string, brokerEndpoint string, config LinkStatusConfiguration) ([]ProviderLinkStatus, error)
 }
 
 type linkInterface interface {
@@ -77,13 +82,15 @@ type linkInterface interface {
 	BadRequest(r *app.JSONAPIErrors) error
 }
 
-var allProvidersToLink = []string{"github", "openshift-v3"}
+var allProvidersToLink = []string{GitHubProvider, keycloakOpenShiftProvider}
 
 const (
 	initiateLinkingParam = "initlinking"
 	apiClientParam       = "api_client"
 	apiTokenParam        = "api_token"
 	tokenJSONParam       = "token_json"
+	maxAgeParam          = "max_age"
+	acrValuesParam       = "acr_values"
 )
 
 // Perform performs authentication
@@ -162,7 +169,17 @@ func (keycloak *KeycloakOAuthProvider) Perform(ctx *app.LoginLoginContext, confi
 			return redirectWithError(ctx, knownReferrer, err.Error())
 		}
 
-		apiClient := referrerURL.Query().Get(apiClientParam)
+		var apiClient *auth.OauthClient
+		if clientID := referrerURL.Query().Get(apiClientParam); clientID != "" {
+			grantType := AuthorizationCodeGrantType
+			if referrerURL.Query().Get(deviceUserCodeParam) != "" {
+				grantType = DeviceCodeGrantType
+			}
+			apiClient, err = LoadOauthClient(ctx, keycloak.db, clientID, grantType)
+			if err != nil {
+				return redirectWithError(ctx, knownReferrer, err.Error())
+			}
+		}
 
 		identity, newUser, err := keycloak.CreateOrUpdateIdentity(ctx, keycloakToken.AccessToken, serviceConfig)
 		if err != nil {
@@ -171,9 +188,9 @@ func (keycloak *KeycloakOAuthProvider) Perform(ctx *app.LoginLoginContext, confi
 			}, "failed to create a user and keycloak identity ")
 			switch err.(type) {
 			case autherrors.UnauthorizedError:
-				if apiClient != "" {
+				if apiClient != nil {
 					// Return the api token
-					err = encodeToken(ctx, referrerURL, keycloakToken, apiClient)
+					err = keycloak.encodeToken(ctx, referrerURL, keycloakToken, apiClient)
 					if err != nil {
 						log.Error(ctx, map[string]interface{}{
 							"err": err,
@@ -232,7 +249,29 @@ func (keycloak *KeycloakOAuthProvider) Perform(ctx *app.LoginLoginContext, confi
 			}
 		}
 
-		err = encodeToken(ctx, referrerURL, keycloakToken, apiClient)
+		if userCode := referrerURL.Query().Get(deviceUserCodeParam); userCode != "" {
+			// The login has been initiated from the verification page of the device authorization grant.
+			// The token is issued to the device and is not passed back to the browser.
+			// The user must confirm the request of the device before the device gets the token.
+			parameters := referrerURL.Query()
+			parameters.Del(deviceUserCodeParam)
+			referrerURL.RawQuery = parameters.Encode()
+			confirmation, err := keycloak.authenticateDeviceCode(ctx, userCode, identity, keycloakToken, referrerURL.String())
+			if err != nil {
+				log.Error(ctx, map[string]interface{}{
+					"user_code": userCode,
+					"err":       err,
+				}, "failed to store the token of the device code")
+				return redirectWithError(ctx, knownReferrer, err.Error())
+			}
+			confirmParameters := url.Values{}
+			confirmParameters.Add("user_code", userCode)
+			confirmParameters.Add("confirmation", confirmation)
+			ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, "/api/login/device/confirm")+"?"+confirmParameters.Encode())
+			return ctx.TemporaryRedirect()
+		}
+
+		err = keycloak.encodeToken(ctx, referrerURL, keycloakToken, apiClient)
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
 				"err": err,
@@ -257,43 +296,44 @@ func (keycloak *KeycloakOAuthProvider) Perform(ctx *app.LoginLoginContext, confi
 		}
 
 		// If the 'initlinking" param == true then initiate account linking if not already linked
-		linked, err := keycloak.checkAllFederatedIdentities(ctx, keycloakToken.AccessToken, brokerEndpoint)
+		statuses, err := keycloak.LinkStatus(ctx, identity.ID, keycloakToken.AccessToken, brokerEndpoint, serviceConfig)
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
 				"err": err,
 			}, "failed to check federated identities")
 			return jsonapi.JSONErrorResponse(ctx, goa.ErrInternal(err.Error()))
 		}
+		// Return linked=true param if account has been linked to GitHub and the default OSO cluster or linked=false if not
+		// and linked_<provider>=true/false for each IdP including the other OSO clusters.
+		referrerStr := addLinkStatusParams(referrerURL.String(), statuses)
+		unlinked := unlinkedKeycloakProviders(statuses)
 		log.Debug(ctx, map[string]interface{}{
 			"code":           code,
 			"state":          state,
 			"known_referrer": knownReferrer,
 			"user_name":      identity.Username,
-			"linked":         linked,
+			"unlinked":       unlinked,
 		}, "identities links checked")
 
-		// Return linked=true param if account has been linked to all IdPs or linked=false if not.
-		if linked {
-			referrerStr := referrerURL.String() + "&linked=true"
+		if len(unlinked) == 0 {
+			// Nothing left which can be linked during login
 			ctx.ResponseData.Header().Set("Location", referrerStr)
 			log.Info(ctx, map[string]interface{}{
 				"known_referrer": knownReferrer,
 				"user_name":      identity.Username,
-				"linked":         linked,
 				"api_client":     ctx.APIClient,
 			}, "all good; redirecting back to referrer")
 			return ctx.TemporaryRedirect()
 		}
 
-		referrerStr := referrerURL.String() + "&linked=false"
 		log.Debug(ctx, map[string]interface{}{
 			"code":           code,
 			"state":          state,
 			"known_referrer": knownReferrer,
 			"user_name":      identity.Username,
-			"linked":         linked,
+			"unlinked":       unlinked,
 		}, "linking identities...")
-		return keycloak.autoLinkProvidersDuringLogin(ctx, keycloakToken.AccessToken, referrerStr)
+		return keycloak.autoLinkProvidersDuringLogin(ctx, keycloakToken.AccessToken, referrerStr, unlinked)
 	}
 
 	// First time access, redirect to oauth provider
@@ -315,12 +355,12 @@ func (keycloak *KeycloakOAuthProvider) Perform(ctx *app.LoginLoginContext, confi
 
 	stateID := uuid.NewV4()
 
-	redirect, err = keycloak.saveParams(ctx, *redirect)
+	redirect, apiClient, err := keycloak.saveParams(ctx, *redirect)
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
 
-	err = keycloak.saveReferrer(ctx, stateID, *redirect, validRedirectURL)
+	err = keycloak.saveReferrer(ctx, stateID, *redirect, validRedirectURL, apiClient)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"state":    stateID,
@@ -331,19 +371,46 @@ func (keycloak *KeycloakOAuthProvider) Perform(ctx *app.LoginLoginContext, confi
 		return err
 	}
 
-	redirectURL := config.AuthCodeURL(stateID.String(), oauth2.AccessTypeOnline)
+	authCodeOptions := []oauth2.AuthCodeOption{oauth2.AccessTypeOnline}
+	// Step-up authentication requested by the client
+	if ctx.MaxAge != nil {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(maxAgeParam, strconv.Itoa(*ctx.MaxAge)))
+	}
+	if ctx.AcrValues != nil && *ctx.AcrValues != "" {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(acrValuesParam, *ctx.AcrValues))
+	}
+	if ctx.LoginHint != nil && *ctx.LoginHint != "" {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(loginHintParam, *ctx.LoginHint))
+		// Send the user straight to the identity provider mapped to the domain of the user if any
+		idpHint, err := ResolveIdpHint(ctx, keycloak.db, *ctx.LoginHint)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"login_hint": *ctx.LoginHint,
+				"err":        err,
+			}, "unable to resolve the identity provider for the login hint")
+		} else if idpHint != "" {
+			authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(idpHintParam, idpHint))
+		}
+	}
+	redirectURL := config.AuthCodeURL(stateID.String(), authCodeOptions...)
 
 	ctx.ResponseData.Header().Set("Location", redirectURL)
 	return ctx.TemporaryRedirect()
 }
 
-func encodeToken(ctx context.Context, referrer *url.URL, outhToken *oauth2.Token, apiClient string) error {
+// encodeToken adds the token to the referrer URL.
+// If the login has been initiated by a registered API client then the token is limited to the settings of the client.
+func (keycloak *KeycloakOAuthProvider) encodeToken(ctx context.Context, referrer *url.URL, outhToken *oauth2.Token, apiClient *auth.OauthClient) error {
 	tokenJson, err := TokenToJson(ctx, outhToken)
 	if err != nil {
 		return err
 	}
 	parameters := referrer.Query()
-	if apiClient != "" {
+	if apiClient != nil {
+		tokenJson, err = issueTokenJSON(ctx, keycloak.db, keycloak.TokenManager, tokenJson, apiClient)
+		if err != nil {
+			return err
+		}
 		parameters.Add(apiTokenParam, tokenJson)
 	} else {
 		parameters.Add(tokenJSONParam, tokenJson)
@@ -352,16 +419,30 @@ func encodeToken(ctx context.Context, referrer *url.URL, outhToken *oauth2.Token
 	return nil
 }
 
-func (keycloak *KeycloakOAuthProvider) saveParams(ctx *app.LoginLoginContext, redirect string) (*string, error) {
-	if ctx.APIClient != nil || (ctx.Link != nil && *ctx.Link) {
-		// We need to save the "link" and "api_client" params so we don't lose them when redirect to sso for auth and back to auth.
+// saveParams adds the "link", "api_client" and "user_code" params to the redirect URL.
+// If the api_client param is set then the client must be registered and allowed to use the corresponding grant type.
+func (keycloak *KeycloakOAuthProvider) saveParams(ctx *app.LoginLoginContext, redirect string) (*string, *auth.OauthClient, error) {
+	var apiClient *auth.OauthClient
+	if ctx.APIClient != nil {
+		grantType := AuthorizationCodeGrantType
+		if ctx.UserCode != nil {
+			grantType = DeviceCodeGrantType
+		}
+		var err error
+		apiClient, err = LoadOauthClient(ctx, keycloak.db, *ctx.APIClient, grantType)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if ctx.APIClient != nil || (ctx.Link != nil && *ctx.Link) || ctx.UserCode != nil {
+		// We need to save the "link", "api_client" and "user_code" params so we don't lose them when redirect to sso for auth and back to auth.
 		linkURL, err := url.Parse(redirect)
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
 				"redirect": redirect,
 				"err":      err,
 			}, "unable to parse redirect")
-			return nil, autherrors.NewBadParameterError("redirect", redirect).Expected("valid URL")
+			return nil, nil, autherrors.NewBadParameterError("redirect", redirect).Expected("valid URL")
 		}
 		parameters := linkURL.Query()
 		if ctx.Link != nil && *ctx.Link {
@@ -370,15 +451,18 @@ func (keycloak *KeycloakOAuthProvider) saveParams(ctx *app.LoginLoginContext, re
 		if ctx.APIClient != nil {
 			parameters.Add(apiClientParam, *ctx.APIClient)
 		}
+		if ctx.UserCode != nil {
+			parameters.Add(deviceUserCodeParam, *ctx.UserCode)
+		}
 		linkURL.RawQuery = parameters.Encode()
 		s := linkURL.String()
-		return &s, nil
+		return &s, apiClient, nil
 	}
-	return &redirect, nil
+	return &redirect, apiClient, nil
 }
 
-func (keycloak *KeycloakOAuthProvider) autoLinkProvidersDuringLogin(ctx *app.LoginLoginContext, token string, referrerURL string) error {
-	// Link all available Identity Providers
+func (keycloak *KeycloakOAuthProvider) autoLinkProvidersDuringLogin(ctx *app.LoginLoginContext, token string, referrerURL string, providers []string) error {
+	// Link the given Identity Providers
 	linkURL, err := url.Parse(rest.AbsoluteURL(ctx.RequestData, "/api/link/session"))
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, goa.ErrInternal(err.Error()))
@@ -393,25 +477,12 @@ func (keycloak *KeycloakOAuthProvider) autoLinkProvidersDuringLogin(ctx *app.Log
 	parameters := url.Values{}
 	parameters.Add("redirect", referrerURL)
 	parameters.Add("sessionState", fmt.Sprintf("%v", claims.SessionState))
+	parameters.Add("provider", strings.Join(providers, ","))
 	linkURL.RawQuery = parameters.Encode()
 	ctx.ResponseData.Header().Set("Location", linkURL.String())
 	return ctx.TemporaryRedirect()
 }
 
-// checkAllFederatedIdentities returns false if there is at least one federated identity not linked to the account
-func (keycloak *KeycloakOAuthProvider) checkAllFederatedIdentities(ctx context.Context, token string, brokerEndpoint string) (bool, error) {
-	for _, provider := range allProvidersToLink {
-		linked, err := keycloak.checkFederatedIdentity(ctx, token, brokerEndpoint, provider)
-		if err != nil {
-			return false, err
-		}
-		if !linked {
-			return false, nil
-		}
-	}
-	return true, nil
-}
-
 // checkFederatedIdentity returns true if the account is already linked to the identity provider
 func (keycloak *KeycloakOAuthProvider) checkFederatedIdentity(ctx context.Context, token string, brokerEndpoint string, provider string) (bool, error) {
 	req, err := http.NewRequest("GET", brokerEndpoint+"/"+provider+"/token", nil)
@@ -463,16 +534,26 @@ func (keycloak *KeycloakOAuthProvider) linkAccountToProviders(ctx linkInterface,
 	}
 
 	state := uuid.NewV4()
-	err := keycloak.saveReferrer(ctx, state, *rdr, validRedirectURL)
+	err := keycloak.saveReferrer(ctx, state, *rdr, validRedirectURL, nil)
 	if err != nil {
 		return err
 	}
 
+	providers := allProvidersToLink
 	if provider != nil {
-		return keycloak.linkProvider(ctx, req, res, state.String(), sessionState, *provider, nil, brokerEndpoint, clientID)
+		providers = strings.Split(*provider, ",")
 	}
+	current, next := splitProviders(providers)
+	return keycloak.linkProvider(ctx, req, res, state.String(), sessionState, current, next, brokerEndpoint, clientID)
+}
 
-	return keycloak.linkProvider(ctx, req, res, state.String(), sessionState, allProvidersToLink[0], &allProvidersToLink[1], brokerEndpoint, clientID)
+// splitProviders returns the first provider to link and the comma separated list of the remaining providers if any
+func splitProviders(providers []string) (string, *string) {
+	if len(providers) < 2 {
+		return providers[0], nil
+	}
+	next := strings.Join(providers[1:], ",")
+	return providers[0], &next
 }
 
 // LinkCallback redirects to original referrer when Identity Provider account are linked to the user account
@@ -497,7 +578,8 @@ func (keycloak *KeycloakOAuthProvider) LinkCallback(ctx *app.CallbackLinkContext
 			jerrors, _ := jsonapi.ErrorToJSONAPIErrors(ctx, goa.ErrBadRequest("session state is empty"))
 			return ctx.Unauthorized(jerrors)
 		}
-		providerURL, err := getProviderURL(ctx.RequestData, *state, *sessionState, *next, nextProvider(*next), brokerEndpoint, clientID)
+		current, remaining := splitProviders(strings.Split(*next, ","))
+		providerURL, err := getProviderURL(ctx.RequestData, *state, *sessionState, current, remaining, brokerEndpoint, clientID)
 		if err != nil {
 			return jsonapi.JSONErrorResponse(ctx, goa.ErrInternal(err.Error()))
 		}
@@ -520,18 +602,6 @@ func (keycloak *KeycloakOAuthProvider) LinkCallback(ctx *app.CallbackLinkContext
 	return ctx.TemporaryRedirect()
 }
 
-func nextProvider(currentProvider string) *string {
-	for i, provider := range allProvidersToLink {
-		if provider == currentProvider {
-			if i+1 < len(allProvidersToLink) {
-				return &allProvidersToLink[i+1]
-			}
-			return nil
-		}
-	}
-	return nil
-}
-
 func (keycloak *KeycloakOAuthProvider) linkProvider(ctx linkInterface, req *goa.RequestData, res *goa.ResponseData, state string, sessionState string, provider string, nextProvider *string, brokerEndpoint string, clientID string) error {
 	providerURL, err := getProviderURL(req, state, sessionState, provider, nextProvider, brokerEndpoint, clientID)
 	if err != nil {
@@ -541,8 +611,8 @@ func (keycloak *KeycloakOAuthProvider) linkProvider(ctx linkInterface, req *goa.
 	return ctx.TemporaryRedirect()
 }
 
-func (keycloak *KeycloakOAuthProvider) saveReferrer(ctx linkInterface, state uuid.UUID, referrer string, validReferrerURL string) error {
-	err := oauth.SaveReferrer(ctx, keycloak.db, state, referrer, validReferrerURL)
+func (keycloak *KeycloakOAuthProvider) saveReferrer(ctx linkInterface, state uuid.UUID, referrer string, validReferrerURL string, apiClient *auth.OauthClient) error {
+	err := oauth.SaveReferrer(ctx, keycloak.db, state, referrer, validReferrerURL, apiClient)
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
@@ -556,7 +626,7 @@ func (keycloak *KeycloakOAuthProvider) getReferrer(ctx context.Context, state st
 func getProviderURL(req *goa.RequestData, state string, sessionState string, provider string, nextProvider *string, brokerEndpoint string, clientID string) (string, error) {
 	var nextParam string
 	if nextProvider != nil {
-		nextParam = "&next=" + *nextProvider
+		nextParam = "&next=" + url.QueryEscape(*nextProvider)
 	}
 	callbackURL := rest.AbsoluteURL(req, "/api/link/callback?provider="+provider+nextParam+"&sessionState="+sessionState+"&state="+state)
 
@@ -606,6 +676,14 @@ func (keycloak *KeycloakOAuthProvider) CreateOrUpdateIdentity(ctx context.Contex
 	}
 
 	if !claims.Approved {
+		err = RecordPendingApproval(ctx, keycloak.db, claims)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"keycloak_user_id": claims.Subject,
+				"err":              err,
+			}, "unable to record the pending approval")
+			// let's carry on instead of erroring out
+		}
 		return nil, false, autherrors.NewUnauthorizedError(fmt.Sprintf("user '%s' is not approved", claims.Username))
 	}
 
@@ -634,24 +712,41 @@ func (keycloak *KeycloakOAuthProvider) CreateOrUpdateIdentity(ctx context.Contex
 
 	if len(identities) == 0 {
 		// No Identity found, create a new Identity and User
+		// unless the identity has been merged into another user
+		if err := checkNotMerged(ctx, keycloak.db, keycloakIdentityID); err != nil {
+			return nil, false, err
+		}
+
+		var policy *account.UserPolicy
+		policy, err = account.NewUserPolicy(configuration)
+		if err != nil {
+			return nil, false, autherrors.NewInternalError(ctx, err)
+		}
 
 		// Now that user/identity objects have been initialized, update it
 		// from the token claims info.
 
-		_, err = fillUser(claims, identity)
-		if identity.User.Cluster == "" {
-			identity.User.Cluster = configuration.GetOpenShiftClientApiUrl()
-		}
+		_, err = fillUser(claims, identity, policy)
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
 				"keycloak_identity_id": keycloakIdentityID,
 				"err": err,
 			}, "unable to create user/identity")
+			if isValidationErr, _ := autherrors.IsValidationError(err); isValidationErr {
+				return nil, false, err
+			}
 			return nil, false, errors.New("failed to update user/identity from claims" + err.Error())
 		}
 
 		err = application.Transactional(keycloak.db, func(appl application.Application) error {
 			user := &identity.User
+			if user.Cluster == "" {
+				cluster, err := NewClusterService(keycloak.db, configuration).Allocate(ctx, appl, "")
+				if err != nil {
+					return err
+				}
+				user.Cluster = cluster
+			}
 			err := appl.Users().Create(ctx, user)
 			if err != nil {
 				return err
@@ -687,9 +782,17 @@ func (keycloak *KeycloakOAuthProvider) CreateOrUpdateIdentity(ctx context.Contex
 			}, "Found Keycloak identity is not linked to any User")
 			return nil, false, errors.New("found Keycloak identity is not linked to any User")
 		}
+		if identity.User.DeactivatedAt != nil {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id": keycloakIdentityID,
+				"username":    identity.Username,
+			}, "deactivated user is trying to log in")
+			return nil, false, autherrors.NewForbiddenError(fmt.Sprintf("user '%s' is deactivated", identity.Username))
+		}
 		// let's update the existing user with the fullname, email and avatar from Keycloak,
 		// in case the user changed them since the last time he/she logged in
-		isChanged, err := fillUser(claims, identity)
+		// the policy is not enforced for the existing users so they are not locked out when the policy changes
+		isChanged, err := fillUser(claims, identity, nil)
 		user := &identity.User
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
@@ -774,7 +877,17 @@ func generateGravatarURL(email string) (string, error) {
 	return urlStr, nil
 }
 
-func fillUser(claims *token.TokenClaims, identity *account.Identity) (bool, error) {
+// fillUser updates the user and the identity from the token claims.
+// The username and the email are validated against the given policy unless the policy is nil.
+func fillUser(claims *token.TokenClaims, identity *account.Identity, policy *account.UserPolicy) (bool, error) {
+	if policy != nil {
+		if err := policy.ValidateUsername(claims.Username); err != nil {
+			return false, err
+		}
+		if err := policy.ValidateEmail(claims.Email); err != nil {
+			return false, err
+		}
+	}
 	isChanged := false
 	if identity.User.FullName != claims.Name || identity.User.Email != claims.Email || identity.User.Company != claims.Company || identity.Username != claims.Username || identity.User.ImageURL == "" {
 		isChanged = true
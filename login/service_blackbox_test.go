@@ -12,19 +12,24 @@ import (
 
 	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/auth"
 	config "github.com/fabric8-services/fabric8-auth/configuration"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	. "github.com/fabric8-services/fabric8-auth/login"
 	"github.com/fabric8-services/fabric8-auth/resource"
+	"github.com/fabric8-services/fabric8-auth/test"
 	testtoken "github.com/fabric8-services/fabric8-auth/test/token"
 	"github.com/fabric8-services/fabric8-auth/token"
+	"github.com/fabric8-services/fabric8-auth/token/link"
+	"github.com/fabric8-services/fabric8-auth/token/provider"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/goadesign/goa"
 	goajwt "github.com/goadesign/goa/middleware/security/jwt"
 	"github.com/goadesign/goa/uuid"
 	_ "github.com/lib/pq"
+	satoriuuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -125,6 +130,64 @@ func (s *serviceBlackBoxTest) TestKeycloakAuthorizationRedirect() {
 	assert.NotEqual(s.T(), rw.Header().Get("Location"), "")
 }
 
+func (s *serviceBlackBoxTest) TestKeycloakAuthorizationRedirectWithLoginHint() {
+	// given
+	domain := uuid.NewV4().String() + ".example.org"
+	err := s.Application.IdpMappings().Create(s.Ctx, &auth.IdpMapping{Domain: domain, IdpHint: "corporate-saml"})
+	require.Nil(s.T(), err)
+
+	s.T().Run("mapped domain", func(t *testing.T) {
+		rw, authorizeCtx := s.loginRequest("https://openshift.io/somepath", "")
+		loginHint := "john@dev." + domain
+		authorizeCtx.APIClient = nil
+		authorizeCtx.LoginHint = &loginHint
+		// when
+		err := s.loginService.Perform(authorizeCtx, s.oauth, s.Configuration)
+		// then
+		require.Nil(t, err)
+		assert.Equal(t, 307, rw.Code)
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.Nil(t, err)
+		assert.Equal(t, loginHint, location.Query().Get("login_hint"))
+		assert.Equal(t, "corporate-saml", location.Query().Get("kc_idp_hint"))
+	})
+
+	s.T().Run("unmapped domain", func(t *testing.T) {
+		rw, authorizeCtx := s.loginRequest("https://openshift.io/somepath", "")
+		loginHint := "john@unknown-" + domain
+		authorizeCtx.APIClient = nil
+		authorizeCtx.LoginHint = &loginHint
+		// when
+		err := s.loginService.Perform(authorizeCtx, s.oauth, s.Configuration)
+		// then
+		require.Nil(t, err)
+		assert.Equal(t, 307, rw.Code)
+		location, err := url.Parse(rw.Header().Get("Location"))
+		require.Nil(t, err)
+		assert.Equal(t, loginHint, location.Query().Get("login_hint"))
+		assert.Empty(t, location.Query().Get("kc_idp_hint"))
+	})
+}
+
+func (s *serviceBlackBoxTest) TestKeycloakAuthorizationRedirectWithStepUp() {
+	// given
+	rw, authorizeCtx := s.loginRequest("https://openshift.io/somepath", "")
+	authorizeCtx.APIClient = nil
+	maxAge := 300
+	acrValues := "1 2"
+	authorizeCtx.MaxAge = &maxAge
+	authorizeCtx.AcrValues = &acrValues
+	// when
+	err := s.loginService.Perform(authorizeCtx, s.oauth, s.Configuration)
+	// then
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 307, rw.Code)
+	location, err := url.Parse(rw.Header().Get("Location"))
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "300", location.Query().Get("max_age"))
+	assert.Equal(s.T(), "1 2", location.Query().Get("acr_values"))
+}
+
 func (s *serviceBlackBoxTest) TestApprovedUserCreatedAndUpdated() {
 	claims := make(map[string]interface{})
 	token, err := testtoken.GenerateTokenWithClaims(claims)
@@ -135,7 +198,7 @@ func (s *serviceBlackBoxTest) TestApprovedUserCreatedAndUpdated() {
 	require.NotNil(s.T(), identity)
 	assert.True(s.T(), ok)
 	s.checkIfTokenMatchesIdentity(token, *identity)
-	assert.Equal(s.T(), s.Configuration.GetOpenShiftClientApiUrl(), identity.User.Cluster)
+	assert.Contains(s.T(), s.Configuration.GetOSOClusters(), identity.User.Cluster)
 
 	updatedClaims := make(map[string]interface{})
 	updatedClaims["company"] = "Updated company"
@@ -154,6 +217,48 @@ func (s *serviceBlackBoxTest) TestApprovedUserCreatedAndUpdated() {
 	s.checkIfTokenMatchesIdentity(token, *identity)
 }
 
+func (s *serviceBlackBoxTest) TestDeactivatedUserForbidden() {
+	claims := make(map[string]interface{})
+	token, err := testtoken.GenerateTokenWithClaims(claims)
+	require.Nil(s.T(), err)
+	identity, _, err := s.loginService.CreateOrUpdateIdentity(context.Background(), token, s.Configuration)
+	require.Nil(s.T(), err)
+
+	// when the user is deactivated
+	now := time.Now()
+	identity.User.DeactivatedAt = &now
+	require.Nil(s.T(), s.Application.Users().Save(s.Ctx, &identity.User))
+
+	// then
+	_, _, err = s.loginService.CreateOrUpdateIdentity(context.Background(), token, s.Configuration)
+	require.NotNil(s.T(), err)
+	require.IsType(s.T(), errors.NewForbiddenError(""), err)
+	// and the user can't retrieve tokens anymore
+	err = CheckUserActive(s.Ctx, s.Application, identity.ID)
+	require.IsType(s.T(), errors.NewUnauthorizedError(""), err)
+}
+
+func (s *serviceBlackBoxTest) TestMergedUserForbidden() {
+	claims := make(map[string]interface{})
+	token, err := testtoken.GenerateTokenWithClaims(claims)
+	require.Nil(s.T(), err)
+	source, _, err := s.loginService.CreateOrUpdateIdentity(context.Background(), token, s.Configuration)
+	require.Nil(s.T(), err)
+	otherToken, err := testtoken.GenerateTokenWithClaims(make(map[string]interface{}))
+	require.Nil(s.T(), err)
+	target, _, err := s.loginService.CreateOrUpdateIdentity(context.Background(), otherToken, s.Configuration)
+	require.Nil(s.T(), err)
+
+	// when the user is merged into another user
+	_, err = NewUserMergeService(s.Application).Merge(s.Ctx, source.ID, target.ID, "fabric8-auth-admin")
+	require.Nil(s.T(), err)
+
+	// then the merged account can't log in anymore
+	_, _, err = s.loginService.CreateOrUpdateIdentity(context.Background(), token, s.Configuration)
+	require.NotNil(s.T(), err)
+	require.IsType(s.T(), errors.NewForbiddenError(""), err)
+}
+
 func (s *serviceBlackBoxTest) TestUnapprovedUserUnauthorized() {
 	claims := make(map[string]interface{})
 	claims["approved"] = false
@@ -163,6 +268,33 @@ func (s *serviceBlackBoxTest) TestUnapprovedUserUnauthorized() {
 	_, _, err = s.loginService.CreateOrUpdateIdentity(context.Background(), token, s.Configuration)
 	require.NotNil(s.T(), err)
 	require.IsType(s.T(), errors.NewUnauthorizedError(""), err)
+
+	// the user is waiting for approval
+	tokenClaims, err := testtoken.TokenManager.ParseToken(context.Background(), token)
+	require.Nil(s.T(), err)
+	approval := s.findPendingApprovals(tokenClaims.Subject)
+	require.Len(s.T(), approval, 1)
+	assert.Equal(s.T(), account.ApprovalStatusPending, approval[0].Status)
+	assert.Equal(s.T(), tokenClaims.Username, approval[0].Username)
+
+	// logging in again doesn't add the user twice
+	_, _, err = s.loginService.CreateOrUpdateIdentity(context.Background(), token, s.Configuration)
+	require.NotNil(s.T(), err)
+	count := len(s.findPendingApprovals(tokenClaims.Subject))
+	assert.Equal(s.T(), 1, count)
+}
+
+func (s *serviceBlackBoxTest) findPendingApprovals(keycloakUserID string) []account.PendingApproval {
+	status := account.ApprovalStatusPending
+	approvals, _, err := s.Application.PendingApprovals().List(s.Ctx, &status, 0, 1000)
+	require.Nil(s.T(), err)
+	var found []account.PendingApproval
+	for _, a := range approvals {
+		if a.KeycloakUserID.String() == keycloakUserID {
+			found = append(found, a)
+		}
+	}
+	return found
 }
 
 func (s *serviceBlackBoxTest) checkIfTokenMatchesIdentity(tokenString string, identity account.Identity) {
@@ -370,6 +502,38 @@ func keycloakLinkRedirect(s *serviceBlackBoxTest, provider string, redirect stri
 	assert.Contains(s.T(), redirectLocation, location)
 }
 
+func (s *serviceBlackBoxTest) TestLinkStatus() {
+	// given
+	identity, err := test.CreateTestIdentity(s.DB, "TestLinkStatus"+uuid.NewV4().String(), "kc")
+	require.Nil(s.T(), err)
+	githubProviderID, err := satoriuuid.FromString(link.GitHubProviderID)
+	require.Nil(s.T(), err)
+	err = s.Application.ExternalTokens().Create(s.Ctx, &provider.ExternalToken{
+		Token:      "1234",
+		Scope:      "user:email",
+		Username:   "github-user",
+		ProviderID: githubProviderID,
+		IdentityID: identity.ID,
+	})
+	require.Nil(s.T(), err)
+	// when
+	statuses, err := s.loginService.LinkStatus(s.Ctx, identity.ID, "", "", s.Configuration)
+	// then
+	require.Nil(s.T(), err)
+	require.Len(s.T(), statuses, len(s.Configuration.GetOSOClusters())+1)
+	assert.Equal(s.T(), GitHubProvider, statuses[0].Provider)
+	assert.Equal(s.T(), "https://github.com", statuses[0].ForResource)
+	assert.True(s.T(), statuses[0].Linked)
+	assert.Equal(s.T(), "github-user", statuses[0].Username)
+	for _, status := range statuses[1:] {
+		cluster, found := s.Configuration.GetOSOClusters()[status.ForResource]
+		require.True(s.T(), found)
+		assert.Equal(s.T(), OpenShiftProviderPrefix+cluster.Name, status.Provider)
+		assert.False(s.T(), status.Linked)
+		assert.Empty(s.T(), status.Username)
+	}
+}
+
 func keycloakLinkCallbackRedirect(s *serviceBlackBoxTest, next string) {
 	rw := httptest.NewRecorder()
 	p := "/api/link/callback"
@@ -550,6 +714,7 @@ func (s *serviceBlackBoxTest) TestUnapprovedUserLoginUnauthorized() {
 }
 
 func (s *serviceBlackBoxTest) TestAPIClientForApprovedUsersReturnOK() {
+	s.registerAPIClient("vscode")
 	extra := make(map[string]string)
 	extra["api_client"] = "vscode"
 	rw, authorizeCtx := s.loginCallback(extra)
@@ -567,6 +732,7 @@ func (s *serviceBlackBoxTest) TestAPIClientForApprovedUsersReturnOK() {
 }
 
 func (s *serviceBlackBoxTest) TestAPIClientForUnapprovedUsersReturnOK() {
+	s.registerAPIClient("vscode")
 	extra := make(map[string]string)
 	extra["api_client"] = "vscode"
 	rw, authorizeCtx := s.loginCallback(extra)
@@ -584,6 +750,123 @@ func (s *serviceBlackBoxTest) TestAPIClientForUnapprovedUsersReturnOK() {
 	s.checkLoginCallback(dummyOauth, rw, authorizeCtx, "api_token")
 }
 
+func (s *serviceBlackBoxTest) TestAPIClientTokenLimitedToClientSettings() {
+	lifetime := 60
+	client := &auth.OauthClient{
+		ClientID:            "limited-" + uuid.NewV4().String(),
+		RedirectURIs:        auth.StringList{"https://openshift.io/somepath"},
+		GrantTypes:          auth.StringList{AuthorizationCodeGrantType},
+		AccessTokenLifetime: &lifetime,
+		Claims:              auth.StringList{"email"},
+	}
+	require.Nil(s.T(), s.Application.OauthClients().Create(s.Ctx, client))
+	extra := make(map[string]string)
+	extra["api_client"] = client.ClientID
+	rw, authorizeCtx := s.loginCallback(extra)
+
+	claims := make(map[string]interface{})
+	accessToken, err := testtoken.GenerateTokenWithClaims(claims)
+	require.Nil(s.T(), err)
+	dummyOauth := &dummyOauth2Config{
+		Config:      oauth2.Config{},
+		accessToken: accessToken,
+	}
+
+	err = s.loginService.Perform(authorizeCtx, dummyOauth, s.Configuration)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 307, rw.Code)
+	locationURL, err := url.Parse(rw.HeaderMap["Location"][0])
+	require.Nil(s.T(), err)
+	tokenSet, err := token.ReadTokenSetFromJson(context.Background(), locationURL.Query().Get("api_token"))
+	require.Nil(s.T(), err)
+	// the access token is reissued with the lifetime and the claims of the client
+	reissued, err := testtoken.TokenManager.ParseTokenWithMapClaims(context.Background(), *tokenSet.AccessToken)
+	require.Nil(s.T(), err)
+	original, err := testtoken.TokenManager.ParseTokenWithMapClaims(context.Background(), accessToken)
+	require.Nil(s.T(), err)
+	exp, err := token.NumberToInt(reissued["exp"])
+	require.Nil(s.T(), err)
+	assert.True(s.T(), exp <= time.Now().Unix()+int64(lifetime))
+	assert.Equal(s.T(), original["sub"], reissued["sub"])
+	assert.Equal(s.T(), original["session_state"], reissued["session_state"])
+	assert.Equal(s.T(), original["email"], reissued["email"])
+	assert.NotContains(s.T(), reissued, "given_name")
+	assert.Equal(s.T(), client.ClientID, reissued[token.APIClientClaim])
+	// the client is not allowed to refresh tokens
+	assert.Nil(s.T(), tokenSet.RefreshToken)
+	require.NotNil(s.T(), tokenSet.ExpiresIn)
+	assert.Equal(s.T(), int64(lifetime), *tokenSet.ExpiresIn)
+}
+
+func (s *serviceBlackBoxTest) TestUnregisteredAPIClientRedirectsToValidRedirectURL() {
+	rw, authorizeCtx := s.loginRequest("https://openshift.io/otherpath", "unregistered-"+uuid.NewV4().String())
+
+	err := s.loginService.Perform(authorizeCtx, s.dummyOauth, s.Configuration)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 307, rw.Code)
+}
+
+func (s *serviceBlackBoxTest) TestUnregisteredAPIClientReturnOK() {
+	extra := make(map[string]string)
+	extra["api_client"] = "unregistered-" + uuid.NewV4().String()
+	rw, authorizeCtx := s.loginCallback(extra)
+
+	claims := make(map[string]interface{})
+	accessToken, err := testtoken.GenerateTokenWithClaims(claims)
+	require.Nil(s.T(), err)
+
+	dummyOauth := &dummyOauth2Config{
+		Config:      oauth2.Config{},
+		accessToken: accessToken,
+	}
+
+	s.checkLoginCallback(dummyOauth, rw, authorizeCtx, "api_token")
+}
+
+func (s *serviceBlackBoxTest) TestUnregisteredAPIClientDeviceCodeBadRequest() {
+	_, err := LoadOauthClient(s.Ctx, s.Application, "unregistered-"+uuid.NewV4().String(), DeviceCodeGrantType)
+	require.NotNil(s.T(), err)
+	assert.IsType(s.T(), errors.BadParameterError{}, err)
+}
+
+func (s *serviceBlackBoxTest) TestAPIClientNotRegisteredRedirectBadRequest() {
+	s.registerAPIClient("vscode")
+	rw, authorizeCtx := s.loginRequest("https://openshift.io/otherpath", "vscode")
+
+	s.loginService.Perform(authorizeCtx, s.dummyOauth, s.Configuration)
+	assert.Equal(s.T(), 400, rw.Code)
+	assert.Equal(s.T(), 0, len(rw.HeaderMap["Location"]))
+}
+
+// registerAPIClient registers the API client allowed to redirect to https://openshift.io/somepath
+func (s *serviceBlackBoxTest) registerAPIClient(clientID string) {
+	_, err := s.Application.OauthClients().LoadByClientID(s.Ctx, clientID)
+	if err == nil {
+		return
+	}
+	err = s.Application.OauthClients().Create(s.Ctx, &auth.OauthClient{
+		ClientID:     clientID,
+		Name:         clientID,
+		RedirectURIs: auth.StringList{"https://openshift.io/somepath"},
+		GrantTypes:   auth.StringList{AuthorizationCodeGrantType, RefreshTokenGrantType},
+	})
+	require.Nil(s.T(), err)
+}
+
+func (s *serviceBlackBoxTest) loginRequest(redirect string, apiClient string) (*httptest.ResponseRecorder, *app.LoginLoginContext) {
+	rw := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/login", nil)
+	require.Nil(s.T(), err)
+	prms := url.Values{
+		"redirect":   {redirect},
+		"api_client": {apiClient},
+	}
+	goaCtx := goa.NewContext(goa.WithAction(context.Background(), "LoginTest"), rw, req, prms)
+	authorizeCtx, err := app.NewLoginLoginContext(goaCtx, req, goa.New("LoginService"))
+	require.Nil(s.T(), err)
+	return rw, authorizeCtx
+}
+
 func (s *serviceBlackBoxTest) loginCallback(extraParams map[string]string) (*httptest.ResponseRecorder, *app.LoginLoginContext) {
 	// Setup request context
 	rw := httptest.NewRecorder()
@@ -663,7 +946,17 @@ func (s *serviceBlackBoxTest) checkLoginCallback(dummyOauth *dummyOauth2Config,
 
 	tokenSet, err := token.ReadTokenSetFromJson(context.Background(), tokenJson[0])
 	require.Nil(s.T(), err)
-	assert.Equal(s.T(), dummyOauth.accessToken, *tokenSet.AccessToken)
+	if tokenParam == "api_token" {
+		// the access token is reissued to the API client
+		reissued, err := testtoken.TokenManager.ParseTokenWithMapClaims(context.Background(), *tokenSet.AccessToken)
+		require.Nil(s.T(), err)
+		original, err := testtoken.TokenManager.ParseTokenWithMapClaims(context.Background(), dummyOauth.accessToken)
+		require.Nil(s.T(), err)
+		assert.Equal(s.T(), original["sub"], reissued["sub"])
+		assert.NotEmpty(s.T(), reissued[token.APIClientClaim])
+	} else {
+		assert.Equal(s.T(), dummyOauth.accessToken, *tokenSet.AccessToken)
+	}
 	assert.Equal(s.T(), "someRefreshToken", *tokenSet.RefreshToken)
 
 	assert.NotContains(s.T(), locationString, "https://keycloak-url.example.org/path-of-login")
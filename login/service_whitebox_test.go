@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/fabric8-services/fabric8-auth/account"
+	"github.com/fabric8-services/fabric8-auth/configuration"
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/resource"
 	"github.com/fabric8-services/fabric8-auth/token"
 	"golang.org/x/oauth2"
@@ -29,7 +31,7 @@ func TestFillUserDoesntOverwriteExistingImageURL(t *testing.T) {
 
 	identity := &account.Identity{Username: "vaysa", User: account.User{FullName: "Vasya Pupkin", Company: "Red Hat", Email: "vpupkin@mail.io", ImageURL: "http://vpupkin.io/image.jpg"}}
 	claims := &token.TokenClaims{Username: "new username", Name: "new name", Company: "new company", Email: "new email"}
-	isChanged, err := fillUser(claims, identity)
+	isChanged, err := fillUser(claims, identity, nil)
 	require.Nil(t, err)
 	require.True(t, isChanged)
 	assert.Equal(t, "new name", identity.User.FullName)
@@ -39,6 +41,28 @@ func TestFillUserDoesntOverwriteExistingImageURL(t *testing.T) {
 	assert.Equal(t, "http://vpupkin.io/image.jpg", identity.User.ImageURL)
 }
 
+func TestFillUserEnforcesUserPolicy(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	config, err := configuration.GetConfigurationData()
+	require.Nil(t, err)
+	policy, err := account.NewUserPolicy(config)
+	require.Nil(t, err)
+
+	identity := &account.Identity{}
+	claims := &token.TokenClaims{Username: "openshift-infra", Name: "new name", Email: "new@email.io"}
+	_, err = fillUser(claims, identity, policy)
+	require.NotNil(t, err)
+	isValidationErr, _ := errors.IsValidationError(err)
+	assert.True(t, isValidationErr)
+
+	claims.Username = "vpupkin"
+	isChanged, err := fillUser(claims, identity, policy)
+	require.Nil(t, err)
+	assert.True(t, isChanged)
+	assert.Equal(t, "vpupkin", identity.Username)
+}
+
 func TestEncodeTokenOK(t *testing.T) {
 	accessToken := "accessToken%@!/\\&?"
 	refreshToken := "refreshToken%@!/\\&?"
@@ -69,3 +93,27 @@ func TestEncodeTokenOK(t *testing.T) {
 	assert.Equal(t, int64(expiresIn), *tokenData.ExpiresIn)
 	assert.Equal(t, int64(refreshExpiresIn), *tokenData.RefreshExpiresIn)
 }
+
+func TestAddLinkStatusParams(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	statuses := []ProviderLinkStatus{
+		{Provider: GitHubProvider, Linked: true, keycloakAlias: GitHubProvider},
+		{Provider: "openshift-us-east-2", Linked: false, keycloakAlias: keycloakOpenShiftProvider},
+		{Provider: "openshift-us-east-2a", Linked: false},
+	}
+	redirect := addLinkStatusParams("https://openshift.io/home?api_token=abc", statuses)
+	assert.Equal(t, "https://openshift.io/home?api_token=abc&linked=false&linked_github=true&linked_openshift-us-east-2=false&linked_openshift-us-east-2a=false", redirect)
+	assert.Equal(t, []string{keycloakOpenShiftProvider}, unlinkedKeycloakProviders(statuses))
+
+	// the other clusters don't affect the "linked" param
+	statuses[1].Linked = true
+	redirect = addLinkStatusParams("https://openshift.io/home?api_token=abc", statuses)
+	assert.Equal(t, "https://openshift.io/home?api_token=abc&linked=true&linked_github=true&linked_openshift-us-east-2=true&linked_openshift-us-east-2a=false", redirect)
+	assert.Empty(t, unlinkedKeycloakProviders(statuses))
+
+	statuses[2].Linked = true
+	redirect = addLinkStatusParams("https://openshift.io/home?api_token=abc", statuses)
+	assert.Contains(t, redirect, "&linked=true&")
+}
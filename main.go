@@ -151,20 +151,45 @@ func main() {
 	jwtMiddlewareTokenContext := goamiddleware.TokenContext(tokenManager.PublicKeys(), nil, app.NewJWTSecurity())
 	service.Use(jwtMiddlewareTokenContext)
 
+	// Throttle the login and token endpoints
+	rateLimiter, err := goamiddleware.NewRateLimiter(config)
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+		}, "failed to create rate limiter")
+	}
+	service.Use(rateLimiter.Middleware())
+
+	// Require a recent authentication for the sensitive actions
+	stepUpPolicy, err := goamiddleware.NewStepUpPolicy(config)
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+		}, "failed to create step-up authentication policy")
+	}
+	service.Use(stepUpPolicy.Middleware())
+
+	// Check the username and email policy early as it's loaded again by every request creating or updating a user
+	if _, err := account.NewUserPolicy(config); err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+		}, "invalid username and email policy")
+	}
+
 	service.Use(login.InjectTokenManager(tokenManager))
 	service.Use(log.LogRequest(config.IsPostgresDeveloperModeEnabled()))
-	app.UseJWTMiddleware(service, jwt.New(tokenManager.PublicKeys(), nil, app.NewJWTSecurity()))
+	app.UseJWTMiddleware(service, jwt.New(tokenManager.PublicKeys(), goamiddleware.RevokedSessionValidation(appDB), app.NewJWTSecurity()))
 
 	spaceAuthzService := authz.NewAuthzService(config)
 	service.Use(authz.InjectAuthzService(spaceAuthzService))
 
 	// Mount "login" controller
 	loginService := login.NewKeycloakOAuthProvider(identityRepository, userRepository, tokenManager, appDB)
-	loginCtrl := controller.NewLoginController(service, loginService, tokenManager, config)
+	loginCtrl := controller.NewLoginController(service, appDB, loginService, tokenManager, config)
 	app.MountLoginController(service, loginCtrl)
 
 	// Mount "logout" controller
-	logoutCtrl := controller.NewLogoutController(service, &login.KeycloakLogoutService{}, config)
+	logoutCtrl := controller.NewLogoutController(service, login.NewKeycloakLogoutService(appDB, tokenManager, config), config)
 	app.MountLogoutController(service, logoutCtrl)
 
 	providerFactory := link.NewOauthProviderFactory(config)
@@ -173,6 +198,7 @@ func main() {
 	keycloakExternalTokenService := keycloak.NewKeycloakTokenServiceClient(config)
 	// Mount "token" controller
 	tokenCtrl := controller.NewTokenController(service, appDB, loginService, linkService, providerFactory, tokenManager, &keycloakExternalTokenService, config)
+	tokenCtrl.Lockout = rateLimiter
 	app.MountTokenController(service, tokenCtrl)
 
 	// Mount "link" controller
@@ -187,6 +213,14 @@ func main() {
 	spaceCtrl := controller.NewSpaceController(service, appDB, config, auth.NewKeycloakResourceManager(config))
 	app.MountSpaceController(service, spaceCtrl)
 
+	// Mount "oauth_client" controller
+	oauthClientCtrl := controller.NewOauthClientController(service, appDB, config)
+	app.MountOauthClientController(service, oauthClientCtrl)
+
+	// Mount "idp_mapping" controller
+	idpMappingCtrl := controller.NewIdpMappingController(service, appDB, config)
+	app.MountIdpMappingController(service, idpMappingCtrl)
+
 	// Mount "user" controller
 	userCtrl := controller.NewUserController(service, appDB, tokenManager, config)
 	if config.GetTenantServiceURL() != "" {
@@ -204,8 +238,48 @@ func main() {
 	keycloakLinkAPIService := keycloakLinkAPI.NewKeycloakIDPServiceClient()
 
 	usersCtrl := controller.NewUsersController(service, appDB, config, keycloakProfileService, keycloakLinkAPIService)
+	usersCtrl.StepUp = stepUpPolicy
 	app.MountUsersController(service, usersCtrl)
 
+	// Mount "registration" controller
+	registrationCtrl := controller.NewRegistrationController(service, appDB, config, keycloakProfileService)
+	app.MountRegistrationController(service, registrationCtrl)
+
+	// Mount "approval" controller
+	approvalCtrl := controller.NewApprovalController(service, appDB, config, login.NewKeycloakApprovalService(appDB, keycloakProfileService, config))
+	if config.GetTenantServiceURL() != "" {
+		approvalCtrl.InitTenant = account.NewInitTenant(config)
+	}
+	app.MountApprovalController(service, approvalCtrl)
+
+	// Mount "erasure" controller
+	erasureCtrl := controller.NewErasureController(service, appDB, config, login.NewUserErasureService(appDB, keycloakProfileService, config))
+	app.MountErasureController(service, erasureCtrl)
+
+	// Mount "export" controller
+	exportCtrl := controller.NewExportController(service, appDB, config, login.NewUserExportService(appDB, config))
+	app.MountExportController(service, exportCtrl)
+
+	// Mount "merge" controller
+	mergeCtrl := controller.NewMergeController(service, appDB, config, login.NewUserMergeService(appDB))
+	app.MountMergeController(service, mergeCtrl)
+
+	// Mount "organization" controller
+	organizationCtrl := controller.NewOrganizationController(service, appDB, config, auth.NewKeycloakPolicyManager(config))
+	app.MountOrganizationController(service, organizationCtrl)
+
+	// Mount "team" controller
+	teamCtrl := controller.NewTeamController(service, appDB, config, auth.NewKeycloakPolicyManager(config))
+	app.MountTeamController(service, teamCtrl)
+
+	// Mount "resource" controller
+	resourceCtrl := controller.NewResourceController(service, appDB, config)
+	app.MountResourceController(service, resourceCtrl)
+
+	// Mount "cluster" controller
+	clusterCtrl := controller.NewClusterController(service, appDB, config, login.NewClusterService(appDB, config))
+	app.MountClusterController(service, clusterCtrl)
+
 	// Mount "collaborators" controller
 	collaboratorsCtrl := controller.NewCollaboratorsController(service, appDB, config, auth.NewKeycloakPolicyManager(config))
 	app.MountCollaboratorsController(service, collaboratorsCtrl)
@@ -5,11 +5,16 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"sync"
 	"text/template"
 
+	"github.com/fabric8-services/fabric8-auth/configuration"
 	"github.com/fabric8-services/fabric8-auth/log"
 
 	"github.com/goadesign/goa"
@@ -35,6 +40,8 @@ var populateLocker = &sync.Mutex{}
 
 type MigrationConfiguration interface {
 	GetOpenShiftClientApiUrl() string
+	GetValidRedirectURLs() string
+	GetOSOClusters() map[string]configuration.OSOCluster
 }
 
 // Migrate executes the required migration of the database on startup.
@@ -143,6 +150,70 @@ func GetMigrations(configuration MigrationConfiguration) Migrations {
 	// version 11
 	m = append(m, steps{ExecuteSQLFile("011-add-username-to-external-token.sql")})
 
+	// version 12
+	m = append(m, steps{ExecuteSQLFile("012-device-codes.sql")})
+
+	// version 13
+	m = append(m, steps{ExecuteSQLFile("013-revoked-sessions.sql")})
+
+	// version 14
+	m = append(m, steps{ExecuteSQLFile("014-refresh-tokens.sql")})
+
+	// version 15
+	m = append(m, steps{ExecuteSQLFile("015-oauth-clients.sql")})
+
+	// version 16
+	m = append(m, steps{ExecuteSQLFile("016-pending-approvals.sql")})
+
+	// version 17
+	m = append(m, steps{ExecuteSQLFile("017-idp-mappings.sql")})
+
+	// version 18
+	m = append(m, steps{ExecuteSQLFile("018-username-reservations.sql")})
+
+	// version 19
+	m = append(m, steps{ExecuteSQLFile("019-user-erasures.sql")})
+
+	// version 20
+	m = append(m, steps{ExecuteSQLFile("020-identity-role-uuid.sql")})
+
+	// version 21
+	m = append(m, steps{ExecuteSQLFile("021-user-exports.sql")})
+
+	// version 22
+	m = append(m, steps{ExecuteSQLFile("022-user-merges.sql")})
+
+	// version 23
+	m = append(m, steps{ExecuteSQLFile("023-user-search-indexes.sql")})
+
+	// version 24
+	m = append(m, steps{ExecuteSQLFile("024-organizations-teams.sql")})
+
+	// version 25
+	m = append(m, steps{ExecuteSQLFile("025-linked-identities.sql")})
+
+	// version 26
+	m = append(m, steps{ExecuteSQLFile("026-user-profile-history.sql")})
+
+	// version 27
+	m = append(m, steps{ExecuteSQLFile("027-user-versions.sql")})
+
+	// version 28
+	m = append(m, steps{ExecuteSQLFile("028-device-code-confirmations.sql")})
+
+	// version 29
+	existingClientRedirectURIPatterns, err := json.Marshal([]string{configuration.GetValidRedirectURLs()})
+	if err != nil {
+		panic(err)
+	}
+	m = append(m, steps{ExecuteSQLFile("029-oauth-client-limits.sql", strings.Replace(string(existingClientRedirectURIPatterns), "'", "''", -1))})
+
+	// version 30
+	m = append(m, steps{ExecuteSQLFile("030-linked-identities-backfill.sql", linkedIdentityProviders(configuration))})
+
+	// version 31
+	m = append(m, steps{ExecuteSQLFile("031-linked-identities-unique-index.sql")})
+
 	// Version N
 	//
 	// In order to add an upgrade, simply append an array of MigrationFunc to the
@@ -170,6 +241,21 @@ func GetMigrations(configuration MigrationConfiguration) Migrations {
 	return m
 }
 
+// linkedIdentityProviders returns the SQL values mapping the provider IDs of the external tokens
+// to the provider types of the identities of the linked accounts: "github" and "openshift-<cluster name>"
+func linkedIdentityProviders(config MigrationConfiguration) string {
+	// the ID of the GitHub provider in the external token table, see link.GitHubProviderID
+	values := []string{"('2f6b7176-8f4b-4204-962d-606033275397', 'github')"}
+	var clusters []string
+	for _, cluster := range config.GetOSOClusters() {
+		clusters = append(clusters, fmt.Sprintf("('%s', '%s')",
+			strings.Replace(strings.ToLower(cluster.TokenProviderID), "'", "''", -1),
+			strings.Replace("openshift-"+cluster.Name, "'", "''", -1)))
+	}
+	sort.Strings(clusters)
+	return strings.Join(append(values, clusters...), ", ")
+}
+
 // ExecuteSQLFile loads the given filename from the packaged SQL files and
 // executes it on the given database. Golang text/template module is used
 // to handle all the optional arguments passed to the sql files
@@ -103,6 +103,26 @@ func TestMigrations(t *testing.T) {
 	t.Run("TestMigration09", testMigration09)
 	t.Run("TestMigration10", testMigration10)
 	t.Run("TestMigration11", testMigration11)
+	t.Run("TestMigration12", testMigration12)
+	t.Run("TestMigration13", testMigration13)
+	t.Run("TestMigration14", testMigration14)
+	t.Run("TestMigration15", testMigration15)
+	t.Run("TestMigration16", testMigration16)
+	t.Run("TestMigration17", testMigration17)
+	t.Run("TestMigration18", testMigration18)
+	t.Run("TestMigration19", testMigration19)
+	t.Run("TestMigration20", testMigration20)
+	t.Run("TestMigration21", testMigration21)
+	t.Run("TestMigration22", testMigration22)
+	t.Run("TestMigration23", testMigration23)
+	t.Run("TestMigration24", testMigration24)
+	t.Run("TestMigration25", testMigration25)
+	t.Run("TestMigration26", testMigration26)
+	t.Run("TestMigration27", testMigration27)
+	t.Run("TestMigration28", testMigration28)
+	t.Run("TestMigration29", testMigration29)
+	t.Run("TestMigration30", testMigration30)
+	t.Run("TestMigration31", testMigration31)
 
 	// Perform the migration
 	if err := migration.Migrate(sqlDB, databaseName, conf); err != nil {
@@ -176,6 +196,222 @@ func testMigration11(t *testing.T) {
 	assert.True(t, dialect.HasColumn("external_tokens", "username"))
 }
 
+func testMigration12(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(13)], (13))
+
+	assert.True(t, dialect.HasTable("device_codes"))
+	assert.True(t, dialect.HasColumn("device_codes", "device_code"))
+	assert.True(t, dialect.HasColumn("device_codes", "user_code"))
+	assert.True(t, dialect.HasColumn("device_codes", "token"))
+	assert.True(t, dialect.HasIndex("device_codes", "idx_device_codes_device_code"))
+	assert.True(t, dialect.HasIndex("device_codes", "idx_device_codes_user_code"))
+}
+
+func testMigration13(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(14)], (14))
+
+	assert.True(t, dialect.HasTable("revoked_sessions"))
+	assert.True(t, dialect.HasColumn("revoked_sessions", "session_state"))
+	assert.True(t, dialect.HasColumn("revoked_sessions", "identity_id"))
+	assert.True(t, dialect.HasIndex("revoked_sessions", "idx_revoked_sessions_session_state"))
+}
+
+func testMigration14(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(15)], (15))
+
+	assert.True(t, dialect.HasTable("refresh_tokens"))
+	assert.True(t, dialect.HasColumn("refresh_tokens", "token_hash"))
+	assert.True(t, dialect.HasColumn("refresh_tokens", "used_at"))
+	assert.True(t, dialect.HasColumn("refresh_tokens", "revoked_at"))
+	assert.True(t, dialect.HasIndex("refresh_tokens", "idx_refresh_tokens_token_hash"))
+	assert.True(t, dialect.HasIndex("refresh_tokens", "idx_refresh_tokens_session_state"))
+}
+
+func testMigration15(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(16)], (16))
+
+	assert.True(t, dialect.HasTable("oauth_clients"))
+	assert.True(t, dialect.HasColumn("oauth_clients", "client_id"))
+	assert.True(t, dialect.HasColumn("oauth_clients", "redirect_uris"))
+	assert.True(t, dialect.HasColumn("oauth_clients", "redirect_uri_patterns"))
+	assert.True(t, dialect.HasColumn("oauth_clients", "grant_types"))
+	assert.True(t, dialect.HasColumn("oauth_clients", "claims"))
+	assert.True(t, dialect.HasIndex("oauth_clients", "idx_oauth_clients_client_id"))
+}
+
+func testMigration16(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(17)], (17))
+
+	assert.True(t, dialect.HasTable("pending_approvals"))
+	assert.True(t, dialect.HasColumn("pending_approvals", "keycloak_user_id"))
+	assert.True(t, dialect.HasColumn("pending_approvals", "status"))
+	assert.True(t, dialect.HasColumn("pending_approvals", "reason"))
+	assert.True(t, dialect.HasColumn("pending_approvals", "init_tenant"))
+	assert.True(t, dialect.HasIndex("pending_approvals", "idx_pending_approvals_keycloak_user_id"))
+	assert.True(t, dialect.HasIndex("pending_approvals", "idx_pending_approvals_status"))
+}
+
+func testMigration17(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(18)], (18))
+
+	assert.True(t, dialect.HasTable("idp_mappings"))
+	assert.True(t, dialect.HasColumn("idp_mappings", "domain"))
+	assert.True(t, dialect.HasColumn("idp_mappings", "idp_hint"))
+	assert.True(t, dialect.HasIndex("idp_mappings", "idx_idp_mappings_domain"))
+}
+
+func testMigration18(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(19)], (19))
+
+	assert.True(t, dialect.HasTable("username_reservations"))
+	assert.True(t, dialect.HasColumn("username_reservations", "username"))
+	assert.True(t, dialect.HasColumn("username_reservations", "identity_id"))
+	assert.True(t, dialect.HasColumn("username_reservations", "expires_at"))
+	assert.True(t, dialect.HasIndex("username_reservations", "idx_username_reservations_username"))
+	assert.True(t, dialect.HasColumn("users", "terms_accepted_at"))
+}
+
+func testMigration19(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(20)], (20))
+
+	assert.True(t, dialect.HasColumn("users", "deactivated_at"))
+	assert.True(t, dialect.HasTable("user_erasures"))
+	assert.True(t, dialect.HasColumn("user_erasures", "identity_id"))
+	assert.True(t, dialect.HasColumn("user_erasures", "status"))
+	assert.True(t, dialect.HasColumn("user_erasures", "step"))
+	assert.True(t, dialect.HasColumn("user_erasures", "erase_after"))
+	assert.True(t, dialect.HasIndex("user_erasures", "idx_user_erasures_identity_id"))
+}
+
+func testMigration20(t *testing.T) {
+	require.Nil(t, runSQLscript(sqlDB, "020-insert-identity-roles.sql"))
+	migrateToVersion(sqlDB, migrations[:(21)], (21))
+
+	var dataType string
+	err := sqlDB.QueryRow("SELECT data_type FROM information_schema.columns WHERE table_name = 'identity_role' AND column_name = 'identity_role_id'").Scan(&dataType)
+	require.Nil(t, err)
+	assert.Equal(t, "uuid", dataType)
+	// the existing role assignments keep their IDs
+	var identityRoleID string
+	err = sqlDB.QueryRow("SELECT identity_role_id FROM identity_role WHERE identity_id = '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0201'").Scan(&identityRoleID)
+	require.Nil(t, err)
+	assert.Equal(t, "00000000-0000-0000-0000-00000000002a", identityRoleID)
+}
+
+func testMigration21(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(22)], (22))
+
+	assert.True(t, dialect.HasTable("user_exports"))
+	assert.True(t, dialect.HasColumn("user_exports", "identity_id"))
+	assert.True(t, dialect.HasColumn("user_exports", "archive"))
+	assert.True(t, dialect.HasColumn("user_exports", "expires_at"))
+	assert.True(t, dialect.HasIndex("user_exports", "idx_user_exports_identity_id"))
+}
+
+func testMigration22(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(23)], (23))
+
+	assert.True(t, dialect.HasTable("user_merges"))
+	assert.True(t, dialect.HasColumn("user_merges", "source_identity_id"))
+	assert.True(t, dialect.HasColumn("user_merges", "target_identity_id"))
+	assert.True(t, dialect.HasColumn("user_merges", "merged_by"))
+	assert.True(t, dialect.HasIndex("user_merges", "idx_user_merges_source_identity_id"))
+}
+
+func testMigration23(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(24)], (24))
+
+	assert.True(t, dialect.HasIndex("users", "idx_users_full_name_trgm"))
+	assert.True(t, dialect.HasIndex("users", "idx_users_email_trgm"))
+	assert.True(t, dialect.HasIndex("identities", "idx_identities_username_trgm"))
+}
+
+func testMigration24(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(25)], (25))
+
+	assert.True(t, dialect.HasTable("organizations"))
+	assert.True(t, dialect.HasColumn("organizations", "owner_id"))
+	assert.True(t, dialect.HasTable("teams"))
+	assert.True(t, dialect.HasColumn("teams", "organization_id"))
+	assert.True(t, dialect.HasTable("team_members"))
+	assert.True(t, dialect.HasIndex("team_members", "idx_team_members_identity_id"))
+	assert.True(t, dialect.HasTable("team_role"))
+	assert.True(t, dialect.HasIndex("team_role", "idx_team_role_resource_id"))
+}
+
+func testMigration25(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(26)], (26))
+
+	assert.True(t, dialect.HasIndex("identities", "idx_identities_provider_type_username"))
+}
+
+func testMigration26(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(27)], (27))
+
+	assert.True(t, dialect.HasTable("user_profile_history"))
+	assert.True(t, dialect.HasIndex("user_profile_history", "idx_user_profile_history_user_id"))
+}
+
+func testMigration27(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(28)], (28))
+
+	assert.True(t, dialect.HasColumn("users", "version"))
+	assert.True(t, dialect.HasColumn("identities", "version"))
+}
+
+func testMigration28(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(29)], (29))
+
+	assert.True(t, dialect.HasColumn("device_codes", "confirmation_code"))
+	assert.True(t, dialect.HasColumn("device_codes", "redirect"))
+	assert.True(t, dialect.HasColumn("device_codes", "approved_at"))
+}
+
+func testMigration29(t *testing.T) {
+	migrateToVersion(sqlDB, migrations[:(30)], (30))
+
+	assert.True(t, dialect.HasColumn("refresh_tokens", "client_id"))
+	assert.True(t, dialect.HasColumn("refresh_tokens", "expires_at"))
+
+	var patterns string
+	err := sqlDB.QueryRow("SELECT redirect_uri_patterns FROM oauth_clients WHERE client_id = 'vscode'").Scan(&patterns)
+	require.Nil(t, err)
+	assert.Contains(t, patterns, conf.GetValidRedirectURLs())
+}
+
+func testMigration30(t *testing.T) {
+	require.Nil(t, runSQLscript(sqlDB, "030-insert-external-tokens.sql"))
+	migrateToVersion(sqlDB, migrations[:(31)], (31))
+
+	// the GitHub account is recorded as an identity of the user who linked it last
+	var userID string
+	var username string
+	err := sqlDB.QueryRow("SELECT user_id, username FROM identities WHERE provider_type = 'github' AND lower(username) = 'linked-before-github'").Scan(&userID, &username)
+	require.Nil(t, err)
+	assert.Equal(t, "8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3002", userID)
+	assert.Equal(t, "Linked-Before-GitHub", username)
+
+	var count int
+	err = sqlDB.QueryRow("SELECT count(*) FROM identities WHERE lower(username) LIKE 'linked-before-%' AND provider_type <> 'kc'").Scan(&count)
+	require.Nil(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func testMigration31(t *testing.T) {
+	require.Nil(t, runSQLscript(sqlDB, "031-insert-duplicate-linked-identities.sql"))
+	migrateToVersion(sqlDB, migrations[:(32)], (32))
+
+	// the OpenShift account is kept as an identity of the user who recorded it first
+	var userID string
+	err := sqlDB.QueryRow("SELECT user_id FROM identities WHERE provider_type = 'openshift-starter-us-east-2' AND lower(username) = 'linked-twice-openshift' AND deleted_at IS NULL").Scan(&userID)
+	require.Nil(t, err)
+	assert.Equal(t, "8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3001", userID)
+
+	// the account can't be recorded for another user anymore
+	_, err = sqlDB.Exec("INSERT INTO identities(created_at, updated_at, id, username, provider_type, user_id) VALUES (now(), now(), '5e1f4d1a-7d8e-4b0a-8f3b-6c7d8e9f3103', 'LINKED-TWICE-OPENSHIFT', 'openshift-starter-us-east-2', '8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3002')")
+	require.NotNil(t, err)
+}
+
 // runSQLscript loads the given filename from the packaged SQL test files and
 // executes it on the given database. Golang text/template module is used
 // to handle all the optional arguments passed to the sql test files
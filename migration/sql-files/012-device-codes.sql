@@ -0,0 +1,19 @@
+-- Create device code table for the OAuth 2.0 device authorization grant (RFC 8628)
+CREATE TABLE device_codes (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    device_code text NOT NULL,
+    user_code text NOT NULL,
+    client_id text,
+    scope text,
+    expires_at timestamp with time zone NOT NULL,
+    polling_interval integer NOT NULL,
+    last_polled_at timestamp with time zone,
+    identity_id uuid REFERENCES identities(id) ON DELETE CASCADE,
+    token text
+);
+
+CREATE UNIQUE INDEX idx_device_codes_device_code ON device_codes (device_code);
+CREATE UNIQUE INDEX idx_device_codes_user_code ON device_codes (user_code);
@@ -0,0 +1,12 @@
+-- Create table for the Keycloak sessions which have been logged out.
+-- Tokens issued for these sessions must be rejected.
+CREATE TABLE revoked_sessions (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    session_state text NOT NULL,
+    identity_id uuid REFERENCES identities(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_revoked_sessions_session_state ON revoked_sessions (session_state);
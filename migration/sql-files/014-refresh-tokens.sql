@@ -0,0 +1,16 @@
+-- Create table for the refresh tokens issued by auth.
+-- All the refresh tokens issued for the same session (session_state) belong to the same token family.
+CREATE TABLE refresh_tokens (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    token_hash text NOT NULL,
+    session_state text,
+    identity_id uuid REFERENCES identities(id) ON DELETE CASCADE,
+    used_at timestamp with time zone,
+    revoked_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX idx_refresh_tokens_session_state ON refresh_tokens (session_state);
@@ -0,0 +1,19 @@
+-- Create table for the registered API clients.
+-- Redirect URIs, redirect URI patterns, grant types and claims are stored as JSON arrays.
+-- Token lifetimes are in seconds. NULL means the default lifetime is used.
+CREATE TABLE oauth_clients (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    client_id text NOT NULL,
+    name text,
+    redirect_uris jsonb,
+    redirect_uri_patterns jsonb,
+    grant_types jsonb,
+    access_token_lifetime integer,
+    refresh_token_lifetime integer,
+    claims jsonb
+);
+
+CREATE UNIQUE INDEX idx_oauth_clients_client_id ON oauth_clients (client_id) WHERE deleted_at IS NULL;
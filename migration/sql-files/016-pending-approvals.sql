@@ -0,0 +1,20 @@
+-- Create table for the users waiting for approval.
+-- Unapproved users don't have an identity in auth yet so the entries reference the Keycloak user ID.
+CREATE TABLE pending_approvals (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    keycloak_user_id uuid NOT NULL,
+    username text,
+    email text,
+    full_name text,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reason text,
+    decided_by text,
+    decided_at timestamp with time zone,
+    init_tenant boolean NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX idx_pending_approvals_keycloak_user_id ON pending_approvals (keycloak_user_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_pending_approvals_status ON pending_approvals (status);
@@ -0,0 +1,13 @@
+-- Create table for the home-realm discovery mappings.
+-- The email domain of the user (or the login_hint) is mapped to the alias of the Keycloak
+-- identity provider which is passed as the kc_idp_hint param to Keycloak.
+CREATE TABLE idp_mappings (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    domain text NOT NULL,
+    idp_hint text NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_idp_mappings_domain ON idp_mappings (lower(domain)) WHERE deleted_at IS NULL;
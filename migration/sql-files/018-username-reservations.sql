@@ -0,0 +1,15 @@
+-- Create table for the usernames held for the users who are completing the registration.
+-- The user may not have an identity in auth yet so the entries reference the Keycloak user ID which is the identity ID.
+CREATE TABLE username_reservations (
+    created_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    username text NOT NULL,
+    identity_id uuid NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_username_reservations_username ON username_reservations (lower(username));
+CREATE INDEX idx_username_reservations_identity_id ON username_reservations (identity_id);
+
+-- The date the user accepted the terms of service during the registration
+ALTER TABLE users ADD COLUMN terms_accepted_at timestamp with time zone;
@@ -0,0 +1,23 @@
+-- The date the user has been deactivated. Deactivated users can't log in nor retrieve tokens.
+ALTER TABLE users ADD COLUMN deactivated_at timestamp with time zone;
+
+-- Create table for the erasure jobs of the deactivated users.
+-- The step is the last step of the erasure completed successfully so a failed job can be resumed from there.
+CREATE TABLE user_erasures (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    identity_id uuid NOT NULL REFERENCES identities (id),
+    user_id uuid NOT NULL,
+    requested_by text,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed')),
+    step text,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text,
+    erase_after timestamp with time zone NOT NULL,
+    completed_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX idx_user_erasures_identity_id ON user_erasures (identity_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_user_erasures_status_erase_after ON user_erasures (status, erase_after);
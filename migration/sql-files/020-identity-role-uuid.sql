@@ -0,0 +1,6 @@
+-- The identity role IDs are UUIDs like the IDs of the other authorization tables.
+-- The existing serial IDs are kept as the last digits of the UUIDs.
+ALTER TABLE identity_role ALTER COLUMN identity_role_id DROP DEFAULT;
+ALTER TABLE identity_role ALTER COLUMN identity_role_id SET DATA TYPE uuid USING lpad(to_hex(identity_role_id), 32, '0')::uuid;
+ALTER TABLE identity_role ALTER COLUMN identity_role_id SET DEFAULT uuid_generate_v4();
+DROP SEQUENCE IF EXISTS identity_role_identity_role_id_seq;
@@ -0,0 +1,17 @@
+-- Create table for the personal data exports of the users.
+-- The archive is the JSON document built by the export job. It can be downloaded until the expiry date.
+CREATE TABLE user_exports (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    identity_id uuid NOT NULL REFERENCES identities (id),
+    requested_by text,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed')),
+    archive text,
+    last_error text,
+    completed_at timestamp with time zone,
+    expires_at timestamp with time zone
+);
+
+CREATE INDEX idx_user_exports_identity_id ON user_exports (identity_id, created_at);
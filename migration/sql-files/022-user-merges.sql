@@ -0,0 +1,16 @@
+-- Create table for the merges of duplicate users.
+-- The merged identity and user are kept as deleted rows and the merge is the tombstone redirecting their IDs to the target.
+CREATE TABLE user_merges (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    source_identity_id uuid NOT NULL REFERENCES identities (id),
+    source_user_id uuid NOT NULL REFERENCES users (id),
+    target_identity_id uuid NOT NULL REFERENCES identities (id),
+    target_user_id uuid NOT NULL REFERENCES users (id),
+    merged_by text
+);
+
+CREATE UNIQUE INDEX idx_user_merges_source_identity_id ON user_merges (source_identity_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_user_merges_target_identity_id ON user_merges (target_identity_id);
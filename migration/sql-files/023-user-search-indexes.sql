@@ -0,0 +1,6 @@
+-- Trigram indexes used by the user search. They support both the LIKE '%term%' matching and the similarity ranking.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_users_full_name_trgm ON users USING gin (lower(full_name) gin_trgm_ops);
+CREATE INDEX idx_users_email_trgm ON users USING gin (lower(email) gin_trgm_ops);
+CREATE INDEX idx_identities_username_trgm ON identities USING gin (lower(username) gin_trgm_ops);
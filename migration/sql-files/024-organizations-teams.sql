@@ -0,0 +1,49 @@
+-- Create tables for the organizations and their teams of users.
+CREATE TABLE organizations (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    name text NOT NULL,
+    description text,
+    owner_id uuid NOT NULL REFERENCES identities (id)
+);
+
+CREATE UNIQUE INDEX idx_organizations_name ON organizations (lower(name)) WHERE deleted_at IS NULL;
+
+CREATE TABLE teams (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    organization_id uuid NOT NULL REFERENCES organizations (id),
+    name text NOT NULL,
+    description text
+);
+
+CREATE UNIQUE INDEX idx_teams_organization_name ON teams (organization_id, lower(name)) WHERE deleted_at IS NULL;
+
+-- The members are hard deleted when they leave the team so their access through the team ends straight away
+CREATE TABLE team_members (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    team_id uuid NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    identity_id uuid NOT NULL REFERENCES identities (id),
+    PRIMARY KEY (team_id, identity_id)
+);
+
+CREATE INDEX idx_team_members_identity_id ON team_members (identity_id);
+
+-- The roles of the teams on the resources. The members of the team hold the roles as long as they belong to the team.
+CREATE TABLE team_role (
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone,
+    team_role_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    team_id uuid NOT NULL REFERENCES teams (id),
+    resource_id uuid NOT NULL REFERENCES resource (resource_id),
+    role_id uuid NOT NULL REFERENCES role (role_id)
+);
+
+CREATE UNIQUE INDEX idx_team_role_team_resource_role ON team_role (team_id, resource_id, role_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_team_role_resource_id ON team_role (resource_id);
@@ -0,0 +1,2 @@
+-- Index used to look up the user who linked an external account (GitHub or OpenShift) by the username of the account
+CREATE INDEX idx_identities_provider_type_username ON identities (provider_type, lower(username)) WHERE deleted_at IS NULL;
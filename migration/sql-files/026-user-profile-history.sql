@@ -0,0 +1,15 @@
+-- Create the append-only history of the changes of the user profiles.
+-- The actor is the subject of the token of the request which made the change.
+CREATE TABLE user_profile_history (
+    created_at timestamp with time zone,
+    id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    user_id uuid NOT NULL REFERENCES users (id),
+    identity_id uuid REFERENCES identities (id),
+    field text NOT NULL,
+    old_value text,
+    new_value text,
+    actor text,
+    request_id text
+);
+
+CREATE INDEX idx_user_profile_history_user_id ON user_profile_history (user_id, created_at);
@@ -0,0 +1,3 @@
+-- Add the versions used to detect the concurrent updates of the users and identities.
+ALTER TABLE users ADD COLUMN version integer DEFAULT 0 NOT NULL;
+ALTER TABLE identities ADD COLUMN version integer DEFAULT 0 NOT NULL;
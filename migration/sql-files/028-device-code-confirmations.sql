@@ -0,0 +1,5 @@
+-- The user code of the device authorization grant must be explicitly approved by the logged in user.
+-- confirmation_code is the hash of the code sent back by the confirmation page.
+ALTER TABLE device_codes ADD COLUMN confirmation_code text;
+ALTER TABLE device_codes ADD COLUMN redirect text;
+ALTER TABLE device_codes ADD COLUMN approved_at timestamp with time zone;
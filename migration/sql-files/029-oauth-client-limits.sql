@@ -0,0 +1,10 @@
+-- The refresh tokens issued to a registered API client keep the client and the expiration of the token family
+-- so the settings of the client still apply when the tokens are refreshed.
+ALTER TABLE refresh_tokens ADD COLUMN client_id text;
+ALTER TABLE refresh_tokens ADD COLUMN expires_at timestamp with time zone;
+
+-- Register the API clients which were used before the registry was added.
+-- They keep being allowed to redirect to the valid redirect URLs of auth.
+INSERT INTO oauth_clients (created_at, updated_at, client_id, name, redirect_uri_patterns, grant_types)
+SELECT now(), now(), 'vscode', 'Visual Studio Code', '{{ index . 0}}', '["authorization_code", "refresh_token"]'
+WHERE NOT EXISTS (SELECT 1 FROM oauth_clients WHERE client_id = 'vscode' AND deleted_at IS NULL);
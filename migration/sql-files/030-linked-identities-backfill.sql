@@ -0,0 +1,23 @@
+-- Record the accounts linked before the linked accounts were recorded as identities,
+-- so that the users can be found by the username of their GitHub and OpenShift accounts.
+-- An account linked by several users is recorded for the user who linked it last.
+-- The accounts and the users which already have an identity of the provider are skipped.
+WITH providers (provider_id, provider_type) AS (
+    VALUES {{ index . 0}}
+), linked AS (
+    SELECT DISTINCT ON (p.provider_type, lower(t.username)) p.provider_type, t.username, i.user_id, t.created_at, t.updated_at
+    FROM external_tokens t
+    JOIN providers p ON p.provider_id = lower(t.provider_id)
+    JOIN identities i ON i.id = t.identity_id AND i.deleted_at IS NULL AND i.user_id IS NOT NULL
+    WHERE t.username IS NOT NULL AND t.username <> ''
+    ORDER BY p.provider_type, lower(t.username), t.updated_at DESC
+)
+INSERT INTO identities (created_at, updated_at, username, provider_type, user_id)
+SELECT DISTINCT ON (l.user_id, l.provider_type) l.created_at, now(), l.username, l.provider_type, l.user_id
+FROM linked l
+WHERE NOT EXISTS (
+    SELECT 1 FROM identities e
+    WHERE e.deleted_at IS NULL AND e.provider_type = l.provider_type
+    AND (lower(e.username) = lower(l.username) OR e.user_id = l.user_id)
+)
+ORDER BY l.user_id, l.provider_type, l.updated_at DESC;
@@ -0,0 +1,14 @@
+-- An account linked via GitHub or OpenShift is recorded as the identity of a single user.
+-- The accounts recorded for several users are kept for the user who recorded them first.
+UPDATE identities SET deleted_at = now()
+WHERE provider_type <> 'kc' AND deleted_at IS NULL AND id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY provider_type, lower(username) ORDER BY created_at, id) AS position
+        FROM identities
+        WHERE provider_type <> 'kc' AND deleted_at IS NULL AND username IS NOT NULL
+    ) recorded
+    WHERE recorded.position > 1
+);
+
+DROP INDEX IF EXISTS idx_identities_provider_type_username;
+CREATE UNIQUE INDEX idx_identities_provider_type_username ON identities (provider_type, lower(username)) WHERE deleted_at IS NULL;
@@ -0,0 +1,36 @@
+-- a role assigned to an identity before the identity role IDs were UUIDs
+INSERT INTO
+   identities(created_at, updated_at, id, username, provider_type)
+VALUES
+   (
+      now(), now(), '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0201', 'migration-test-identity-role', 'kc'
+   )
+;
+INSERT INTO
+   resource_type(created_at, updated_at, resource_type_id, name)
+VALUES
+   (
+      now(), now(), '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0202', 'migration-test-identity-role'
+   )
+;
+INSERT INTO
+   resource(created_at, updated_at, resource_id, owner_id, resource_type_id, name)
+VALUES
+   (
+      now(), now(), '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0203', '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0201', '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0202', 'migration-test-identity-role'
+   )
+;
+INSERT INTO
+   role(created_at, updated_at, role_id, resource_type_id, name)
+VALUES
+   (
+      now(), now(), '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0204', '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0202', 'migration-test-identity-role'
+   )
+;
+INSERT INTO
+   identity_role(created_at, updated_at, identity_role_id, identity_id, resource_id, role_id)
+VALUES
+   (
+      now(), now(), 42, '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0201', '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0203', '3b2c1d0e-9f8a-4b7c-8d6e-5f4a3b2c0204'
+   )
+;
@@ -0,0 +1,36 @@
+-- users who linked their GitHub account before the linked accounts were recorded as identities
+INSERT INTO
+   users(created_at, updated_at, id, email, full_name)
+VALUES
+   (
+      now(), now(), '8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3001', 'linked-before-1@example.com', 'linked before 1'
+   ),
+   (
+      now(), now(), '8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3002', 'linked-before-2@example.com', 'linked before 2'
+   )
+;
+INSERT INTO
+   identities(created_at, updated_at, id, username, provider_type, user_id)
+VALUES
+   (
+      now(), now(), '5e1f4d1a-7d8e-4b0a-8f3b-6c7d8e9f3001', 'linked-before-1', 'kc', '8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3001'
+   ),
+   (
+      now(), now(), '5e1f4d1a-7d8e-4b0a-8f3b-6c7d8e9f3002', 'linked-before-2', 'kc', '8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3002'
+   )
+;
+-- the same GitHub account linked by both users, the second user linked it last.
+-- The token of an unknown provider is not recorded as an identity.
+INSERT INTO
+   external_tokens(created_at, updated_at, id, provider_id, identity_id, token, scope, username)
+VALUES
+   (
+      now() - interval '2 days', now() - interval '2 days', '0d9c8b7a-6f5e-4d3c-2b1a-0f9e8d7c3001', '2f6b7176-8f4b-4204-962d-606033275397', '5e1f4d1a-7d8e-4b0a-8f3b-6c7d8e9f3001', 'token', 'user:full', 'linked-before-github'
+   ),
+   (
+      now() - interval '1 day', now() - interval '1 day', '0d9c8b7a-6f5e-4d3c-2b1a-0f9e8d7c3002', '2f6b7176-8f4b-4204-962d-606033275397', '5e1f4d1a-7d8e-4b0a-8f3b-6c7d8e9f3002', 'token', 'user:full', 'Linked-Before-GitHub'
+   ),
+   (
+      now(), now(), '0d9c8b7a-6f5e-4d3c-2b1a-0f9e8d7c3003', '9a3e1f0c-2b4d-4c6e-8f0a-1b3c5d7e3003', '5e1f4d1a-7d8e-4b0a-8f3b-6c7d8e9f3001', 'token', 'user:full', 'linked-before-unknown'
+   )
+;
@@ -0,0 +1,11 @@
+-- the same OpenShift account recorded as an identity of both users, the first user recorded it first
+INSERT INTO
+   identities(created_at, updated_at, id, username, provider_type, user_id)
+VALUES
+   (
+      now() - interval '2 days', now(), '5e1f4d1a-7d8e-4b0a-8f3b-6c7d8e9f3101', 'linked-twice-openshift', 'openshift-starter-us-east-2', '8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3001'
+   ),
+   (
+      now() - interval '1 day', now(), '5e1f4d1a-7d8e-4b0a-8f3b-6c7d8e9f3102', 'Linked-Twice-OpenShift', 'openshift-starter-us-east-2', '8c5b2ef1-3a2a-4a0c-9b5e-0b5e4d4d3002'
+   )
+;
@@ -61,6 +61,8 @@ type ResourceRepository interface {
 	Load(ctx context.Context, ID uuid.UUID) (*Resource, error)
 	Delete(ctx context.Context, ID uuid.UUID) error
 	LoadBySpace(ctx context.Context, spaceID *uuid.UUID) (*Resource, error)
+	ListByOwner(ctx context.Context, ownerID uuid.UUID) ([]Resource, error)
+	TransferOwnership(ctx context.Context, fromOwnerID uuid.UUID, toOwnerID uuid.UUID) error
 }
 
 // NewResourceRepository creates a new space resource repo
@@ -195,3 +197,29 @@ func (r *GormResourceRepository) LoadBySpace(ctx context.Context, spaceID *uuid.
 	}
 	return &res, nil
 }
+
+// ListByOwner returns the space resources owned by the given identity
+// returns InternalError
+func (r *GormResourceRepository) ListByOwner(ctx context.Context, ownerID uuid.UUID) ([]Resource, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "spaceresource", "listByOwner"}, time.Now())
+	var resources []Resource
+	if err := r.db.Where("space_resources.owner_id=?", ownerID).Order("created_at").Find(&resources).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return resources, nil
+}
+
+// TransferOwnership makes the given identity the owner of all the space resources owned by another identity
+// returns InternalError
+func (r *GormResourceRepository) TransferOwnership(ctx context.Context, fromOwnerID uuid.UUID, toOwnerID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "spaceresource", "transferOwnership"}, time.Now())
+	if err := r.db.Model(&Resource{}).Where("owner_id = ?", fromOwnerID).Update("owner_id", toOwnerID).Error; err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_owner_id": fromOwnerID,
+			"to_owner_id":   toOwnerID,
+			"err":           err,
+		}, "unable to transfer the ownership of the space resources")
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
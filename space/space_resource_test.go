@@ -124,6 +124,21 @@ func (test *resourceRepoBBTest) TestLoadByDifferentSpaceFails() {
 	assert.NotNil(test.T(), err)
 }
 
+func (test *resourceRepoBBTest) TestListByOwner() {
+	// given
+	ownerID := uuid.NewV4()
+	res, _ := expectResource(test.create(testResourceID, testPolicyID, testPermissionID), test.requireOk)
+	res.OwnerID = ownerID
+	expectResource(test.save(*res), test.requireOk)
+	test.create(testResource2ID, testPolicyID2, testPermissionID2)()
+	// when
+	resources, err := test.repo.ListByOwner(context.Background(), ownerID)
+	// then
+	require.Nil(test.T(), err)
+	require.Len(test.T(), resources, 1)
+	assert.Equal(test.T(), res.ID, resources[0].ID)
+}
+
 type resourceExpectation func(p *space.Resource, err error)
 
 func expectResource(f func() (*space.Resource, error), e resourceExpectation) (*space.Resource, error) {
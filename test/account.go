@@ -7,6 +7,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/log"
 	"github.com/fabric8-services/fabric8-auth/models"
 
+	"github.com/goadesign/goa"
 	"github.com/jinzhu/gorm"
 	uuid "github.com/satori/go.uuid"
 )
@@ -82,3 +83,42 @@ func CreateTestIdentityForAccountIdentity(db *gorm.DB, identity *account.Identit
 	}
 	return err
 }
+
+// CreateTestIdentityAndUser creates the given user and a Keycloak identity of the user in the database.
+// The username of the identity and the missing email, full name and cluster of the user are generated
+// from the given prefix so that each call creates a unique identity. For testing purpose only.
+func CreateTestIdentityAndUser(db *gorm.DB, prefix string, user account.User) (account.Identity, error) {
+	if user.Email == "" {
+		user.Email = prefix + uuid.NewV4().String() + "@example.com"
+	}
+	if user.FullName == "" {
+		user.FullName = prefix
+	}
+	if user.Cluster == "" {
+		user.Cluster = prefix + " cluster"
+	}
+	identity := account.Identity{
+		Username:     prefix + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+	}
+	err := models.Transactional(db, func(tx *gorm.DB) error {
+		if err := account.NewUserRepository(tx).Create(context.Background(), &user); err != nil {
+			return err
+		}
+		identity.User = user
+		identity.UserID = account.NullUUID{UUID: user.ID, Valid: true}
+		return account.NewIdentityRepository(tx).Create(context.Background(), &identity)
+	})
+	if err != nil {
+		log.Error(nil, map[string]interface{}{
+			"err":      err,
+			"identity": identity,
+		}, "unable to create identity and user")
+	}
+	return identity, err
+}
+
+// ServiceAsAdmin creates a new service and fills the context with the token of the default admin service account
+func ServiceAsAdmin(serviceName string) *goa.Service {
+	return ServiceAsServiceAccountUser(serviceName, account.Identity{Username: "fabric8-auth-admin"})
+}
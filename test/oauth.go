@@ -65,7 +65,9 @@ func (provider *DummyProvider) Profile(ctx context.Context, token oauth2.Token)
 	if provider.factory.LoadProfileFail {
 		return nil, errors.New("unable to load profile")
 	}
+	username := token.AccessToken + "testuser"
 	return &oauth.UserProfile{
-		Username: token.AccessToken + "testuser",
+		Username:   username,
+		ProfileURL: provider.url + "/" + username,
 	}, nil
 }
@@ -22,7 +22,8 @@ type GitHubIdentityProvider struct {
 }
 
 type gitHubUser struct {
-	Login string `json:"login"`
+	Login   string `json:"login"`
+	HTMLURL string `json:"html_url"`
 }
 
 func NewGitHubIdentityProvider(clientID string, clientSecret string, scopes string, authURL string) *GitHubIdentityProvider {
@@ -59,7 +60,11 @@ func (provider *GitHubIdentityProvider) Profile(ctx context.Context, token oauth
 	var u gitHubUser
 	err = json.Unmarshal(body, &u)
 	userProfile := &oauth.UserProfile{
-		Username: u.Login,
+		Username:   u.Login,
+		ProfileURL: u.HTMLURL,
+	}
+	if userProfile.ProfileURL == "" && u.Login != "" {
+		userProfile.ProfileURL = "https://github.com/" + u.Login
 	}
 	return userProfile, nil
 }
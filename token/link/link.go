@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/fabric8-services/fabric8-auth/account"
 	"github.com/fabric8-services/fabric8-auth/application"
 	"github.com/fabric8-services/fabric8-auth/configuration"
 	errs "github.com/fabric8-services/fabric8-auth/errors"
@@ -114,7 +115,7 @@ func (service *LinkService) ProviderLocation(ctx context.Context, req *goa.Reque
 		return "", err
 	}
 	stateID := uuid.NewV4()
-	err = oauth.SaveReferrer(ctx, service.db, stateID, redirectURL, service.config.GetValidRedirectURLs())
+	err = oauth.SaveReferrer(ctx, service.db, stateID, redirectURL, service.config.GetValidRedirectURLs(), nil)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"redirect_url": redirectURL,
@@ -201,6 +202,9 @@ func (service *LinkService) Callback(ctx context.Context, req *goa.RequestData,
 		return "", err
 	}
 	err = application.Transactional(service.db, func(appl application.Application) error {
+		if err := service.linkIdentity(ctx, appl, identityUUID, oauthProvider, *userProfile); err != nil {
+			return err
+		}
 		tokens, err := appl.ExternalTokens().LoadByProviderIDAndIdentityID(ctx, oauthProvider.ID(), identityUUID)
 		if err != nil {
 			return err
@@ -255,6 +259,93 @@ func (service *LinkService) Callback(ctx context.Context, req *goa.RequestData,
 	return knownReferrer, nil
 }
 
+// linkIdentity records the linked account as an identity of the user of the given Keycloak identity.
+// The user keeps a single identity per provider and an account is recorded as the identity of a single user.
+// If the account is already recorded for another user then the account is still linked, as it was before
+// the linked accounts were recorded, but it is not recorded as an identity of this user.
+// Returns VersionConflictError if the account is recorded for another user in the meantime.
+func (service *LinkService) linkIdentity(ctx context.Context, appl application.Application, identityID uuid.UUID, oauthProvider ProviderConfig, userProfile oauth.UserProfile) error {
+	providerType := service.identityProviderType(oauthProvider)
+	if providerType == "" || userProfile.Username == "" {
+		return nil
+	}
+	identity, err := appl.Identities().Load(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	if !identity.UserID.Valid {
+		return nil
+	}
+	userID := identity.UserID.UUID
+	owner, err := appl.Identities().Lookup(ctx, providerType, userProfile.Username)
+	if err == nil && owner.UserID.UUID != userID {
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id":   identityID,
+			"provider_type": providerType,
+			"username":      userProfile.Username,
+		}, "the account is already recorded as an identity of another user; not recording it for this user")
+		return nil
+	}
+	if notFound, _ := errs.IsNotFoundError(err); err != nil && !notFound {
+		return err
+	}
+	linked, err := appl.Identities().Query(account.IdentityFilterByUserID(userID), account.IdentityFilterByProviderType(providerType))
+	if err != nil {
+		return errs.NewInternalError(ctx, err)
+	}
+	linkedIdentity := account.Identity{
+		ProviderType: providerType,
+		UserID:       account.NullUUID{UUID: userID, Valid: true},
+	}
+	if len(linked) > 0 {
+		linkedIdentity = linked[0]
+	}
+	linkedIdentity.Username = userProfile.Username
+	linkedIdentity.ProfileURL = nil
+	if userProfile.ProfileURL != "" {
+		linkedIdentity.ProfileURL = &userProfile.ProfileURL
+	}
+	if linkedIdentity.ID == uuid.Nil {
+		err = appl.Identities().Create(ctx, &linkedIdentity)
+	} else {
+		err = appl.Identities().Save(ctx, &linkedIdentity)
+	}
+	if conflict, _ := errs.IsVersionConflictError(err); conflict {
+		// the account has been recorded for another user concurrently, the link can be retried
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id":   identityID,
+			"provider_type": providerType,
+			"username":      userProfile.Username,
+			"err":           err,
+		}, "unable to record the account as an identity of the user")
+		return err
+	}
+	if err != nil {
+		return errs.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id":        identityID,
+		"linked_identity_id": linkedIdentity.ID,
+		"provider_type":      providerType,
+	}, "account linked to the user")
+	return nil
+}
+
+// identityProviderType returns the provider type of the identities of the accounts linked via the given provider:
+// "github" or "openshift-<cluster name>". Returns an empty string for an unknown provider.
+func (service *LinkService) identityProviderType(oauthProvider ProviderConfig) string {
+	providerID := oauthProvider.ID().String()
+	if providerID == GitHubProviderID {
+		return account.GitHubIDP
+	}
+	for _, cluster := range service.config.GetOSOClusters() {
+		if cluster.TokenProviderID == providerID {
+			return account.OpenShiftIDPPrefix + cluster.Name
+		}
+	}
+	return ""
+}
+
 // NewOauthProvider creates a new oauth provider for the given resource URL
 func (service *OauthProviderFactoryService) NewOauthProvider(ctx context.Context, req *goa.RequestData, forResource string) (ProviderConfig, error) {
 	authURL := rest.AbsoluteURL(req, "")
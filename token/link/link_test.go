@@ -136,6 +136,68 @@ func (s *LinkTestSuite) TestProviderSavesTokenOK() {
 	s.checkToken(GitHubProviderID, token)
 }
 
+func (s *LinkTestSuite) TestProviderLinksIdentityOfUser() {
+	// given a Keycloak identity of a user
+	identity := s.createUserIdentity("TestProviderLinksIdentityOfUser")
+	token := uuid.NewV4().String()
+
+	// when
+	s.linkGitHub(identity, token)
+
+	// then the GitHub account is recorded as an identity of the user
+	linked, err := s.Application.Identities().Lookup(context.Background(), account.GitHubIDP, token+"testuser")
+	require.Nil(s.T(), err)
+	require.Equal(s.T(), identity.User.ID, linked.User.ID)
+	require.NotNil(s.T(), linked.ProfileURL)
+	require.Equal(s.T(), "https://github.com/org/repo/"+token+"testuser", *linked.ProfileURL)
+
+	// re-linking keeps a single GitHub identity
+	s.linkGitHub(identity, token)
+	identities, err := s.Application.Identities().Query(account.IdentityFilterByUserID(identity.User.ID), account.IdentityFilterByProviderType(account.GitHubIDP))
+	require.Nil(s.T(), err)
+	require.Len(s.T(), identities, 1)
+
+	// the same account can still be linked by another user but it stays the identity of the first user
+	other := s.createUserIdentity("TestProviderLinksIdentityOfUser2")
+	s.linkGitHub(other, token)
+	githubProviderID, err := uuid.FromString(GitHubProviderID)
+	require.Nil(s.T(), err)
+	tokens, err := s.Application.ExternalTokens().LoadByProviderIDAndIdentityID(context.Background(), githubProviderID, other.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), tokens, 1)
+	linked, err = s.Application.Identities().Lookup(context.Background(), account.GitHubIDP, token+"testuser")
+	require.Nil(s.T(), err)
+	require.Equal(s.T(), identity.User.ID, linked.User.ID)
+	identities, err = s.Application.Identities().Query(account.IdentityFilterByUserID(other.User.ID), account.IdentityFilterByProviderType(account.GitHubIDP))
+	require.Nil(s.T(), err)
+	require.Empty(s.T(), identities)
+}
+
+func (s *LinkTestSuite) createUserIdentity(name string) account.Identity {
+	user := account.User{
+		Email:    name + uuid.NewV4().String() + "@example.com",
+		FullName: name,
+		Cluster:  "https://api.starter-us-east-2.openshift.com",
+	}
+	require.Nil(s.T(), s.Application.Users().Create(context.Background(), &user))
+	identity := account.Identity{
+		Username:     name + uuid.NewV4().String(),
+		ProviderType: account.KeycloakIDP,
+		User:         user,
+		UserID:       account.NullUUID{UUID: user.ID, Valid: true},
+	}
+	require.Nil(s.T(), test.CreateTestIdentityForAccountIdentity(s.DB, &identity))
+	return identity
+}
+
+func (s *LinkTestSuite) linkGitHub(identity account.Identity, token string) {
+	location, err := s.linkService.ProviderLocation(context.Background(), s.requestData, identity.ID.String(), "https://github.com/org/repo", "https://openshift.io/home")
+	require.Nil(s.T(), err)
+	linkServiceWithDummyProviderFactory := NewLinkServiceWithFactory(s.Configuration, gormapplication.NewGormDB(s.DB), &test.DummyProviderFactory{Token: token, Config: s.Configuration})
+	_, err = linkServiceWithDummyProviderFactory.Callback(context.Background(), s.requestData, s.stateParam(location), uuid.NewV4().String())
+	require.Nil(s.T(), err)
+}
+
 func (s *LinkTestSuite) TestProviderSavesTokenWithUnavailableProfileFails() {
 	location, err := s.linkService.ProviderLocation(context.Background(), s.requestData, s.testIdentity.ID.String(), "https://github.com/org/repo", "https://openshift.io/home")
 	require.Nil(s.T(), err)
@@ -71,5 +71,8 @@ func (provider *OpenShiftIdentityProvider) Profile(ctx context.Context, token oa
 	userProfile := &oauth.UserProfile{
 		Username: u.Metadata.Name,
 	}
+	if u.Metadata.Name != "" {
+		userProfile.ProfileURL = fmt.Sprintf("%s/oapi/v1/users/%s", provider.Cluster.URL, u.Metadata.Name)
+	}
 	return userProfile, nil
 }
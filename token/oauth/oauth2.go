@@ -39,6 +39,8 @@ type OauthIdentityProvider struct {
 // UserProfile represents a user profile fetched from Identity Provider
 type UserProfile struct {
 	Username string
+	// ProfileURL is the URL of the user profile in the Identity Provider
+	ProfileURL string
 }
 
 // UserProfilePayload fetches user profile payload from Identity Provider
@@ -80,13 +82,22 @@ func (provider *OauthIdentityProvider) UserProfilePayload(ctx context.Context, t
 	return body, nil
 }
 
-// SaveReferrer validates referrer and saves it in DB
-func SaveReferrer(ctx context.Context, db application.DB, state uuid.UUID, referrer string, validReferrerURL string) error {
-	matched, err := regexp.MatchString(validReferrerURL, referrer)
+// SaveReferrer validates referrer and saves it in DB.
+// If the request is done by an API client with registered redirect URIs then the referrer is validated against the redirect URIs of the client
+// instead of the global whitelist regex.
+func SaveReferrer(ctx context.Context, db application.DB, state uuid.UUID, referrer string, validReferrerURL string, apiClient *auth.OauthClient) error {
+	var matched bool
+	var err error
+	if apiClient != nil && apiClient.HasRedirectURIs() {
+		matched, err = apiClient.IsRedirectURIAllowed(referrer)
+	} else {
+		matched, err = regexp.MatchString(validReferrerURL, referrer)
+	}
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"referrer":           referrer,
 			"valid_referrer_url": validReferrerURL,
+			"api_client":         apiClientID(apiClient),
 			"err":                err,
 		}, "Can't match referrer and whitelist regex")
 		return err
@@ -95,6 +106,7 @@ func SaveReferrer(ctx context.Context, db application.DB, state uuid.UUID, refer
 		log.Error(ctx, map[string]interface{}{
 			"referrer":           referrer,
 			"valid_referrer_url": validReferrerURL,
+			"api_client":         apiClientID(apiClient),
 		}, "Referrer not valid")
 		return errors.NewBadParameterError("redirect", "not valid redirect URL")
 	}
@@ -119,6 +131,13 @@ func SaveReferrer(ctx context.Context, db application.DB, state uuid.UUID, refer
 	return nil
 }
 
+func apiClientID(apiClient *auth.OauthClient) string {
+	if apiClient == nil {
+		return ""
+	}
+	return apiClient.ClientID
+}
+
 // LoadReferrer loads referrer from DB
 func LoadReferrer(ctx context.Context, db application.DB, state string) (string, error) {
 	var referrer string
@@ -64,6 +64,8 @@ type ExternalTokenRepository interface {
 	Create(ctx context.Context, ExternalToken *ExternalToken) error
 	Save(ctx context.Context, ExternalToken *ExternalToken) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteByIdentityID(ctx context.Context, identityID uuid.UUID) error
+	ReassignIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error
 	LoadByProviderIDAndIdentityID(ctx context.Context, providerID uuid.UUID, identityID uuid.UUID) ([]ExternalToken, error)
 	Query(funcs ...func(*gorm.DB) *gorm.DB) ([]ExternalToken, error)
 }
@@ -163,6 +165,50 @@ func (m *GormExternalTokenRepository) Delete(ctx context.Context, id uuid.UUID)
 	return nil
 }
 
+// DeleteByIdentityID removes all the tokens of the given identity. This is a hard delete!
+func (m *GormExternalTokenRepository) DeleteByIdentityID(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "ExternalToken", "deleteByIdentityID"}, time.Now())
+
+	db := m.db.Where("identity_id = ?", identityID).Delete(&ExternalToken{})
+	if db.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         db.Error,
+		}, "unable to delete the external_tokens")
+		return errs.WithStack(db.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": identityID,
+		"deleted":     db.RowsAffected,
+	}, "external_tokens deleted!")
+
+	return nil
+}
+
+// ReassignIdentity moves all the tokens of the given identity to another identity
+func (m *GormExternalTokenRepository) ReassignIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "ExternalToken", "reassignIdentity"}, time.Now())
+
+	db := m.db.Model(&ExternalToken{}).Where("identity_id = ?", fromIdentityID).Update("identity_id", toIdentityID)
+	if db.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_identity_id": fromIdentityID,
+			"to_identity_id":   toIdentityID,
+			"err":              db.Error,
+		}, "unable to reassign the external_tokens")
+		return errs.WithStack(db.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"from_identity_id": fromIdentityID,
+		"to_identity_id":   toIdentityID,
+		"reassigned":       db.RowsAffected,
+	}, "external_tokens reassigned!")
+
+	return nil
+}
+
 // Query expose an open ended Query model
 func (m *GormExternalTokenRepository) Query(funcs ...func(*gorm.DB) *gorm.DB) ([]ExternalToken, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "ExternalToken", "query"}, time.Now())
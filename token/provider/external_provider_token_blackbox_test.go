@@ -62,6 +62,22 @@ func (s *externalTokenBlackboxTest) TestTokenIsHardDeleted() {
 	require.Equal(s.T(), gorm.ErrRecordNotFound, err)
 }
 
+func (s *externalTokenBlackboxTest) TestOKToDeleteByIdentityID() {
+	// given
+	externalToken := createAndLoadExternalToken(s)
+	otherToken := createAndLoadExternalToken(s)
+	// when
+	err := s.repo.DeleteByIdentityID(s.Ctx, externalToken.IdentityID)
+	// then
+	require.Nil(s.T(), err)
+	tokens, err := s.repo.Query(provider.ExternalTokenFilterByIdentityID(externalToken.IdentityID))
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), tokens)
+	// the tokens of the other identities are not deleted
+	_, err = s.repo.Load(s.Ctx, otherToken.ID)
+	assert.Nil(s.T(), err)
+}
+
 func (s *externalTokenBlackboxTest) TestExternalProviderOKToLoad() {
 	createAndLoadExternalToken(s)
 }
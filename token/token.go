@@ -83,6 +83,8 @@ type Manager interface {
 	AuthServiceAccountToken(req *goa.RequestData) (string, error)
 	GenerateServiceAccountToken(req *goa.RequestData, saID string, saName string) (string, error)
 	GenerateUnsignedServiceAccountToken(req *goa.RequestData, saID string, saName string) *jwt.Token
+	GenerateLogoutToken(req *goa.RequestData, audience string, identityID string, sessionState string) (string, error)
+	ReissueAccessToken(ctx context.Context, accessToken string, lifetime *int, claims []string, clientID string) (string, error)
 }
 
 // PrivateKey represents an RSA private key with a Key ID
@@ -439,6 +441,80 @@ func (mgm *tokenManager) GenerateUnsignedServiceAccountToken(req *goa.RequestDat
 	return token
 }
 
+// BackChannelLogoutEvent is the event type of the OpenID Connect Back-Channel Logout token
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// GenerateLogoutToken generates and signs a new OpenID Connect Back-Channel Logout token
+// for the given client (audience), user (identityID) and session (sessionState)
+func (mgm *tokenManager) GenerateLogoutToken(req *goa.RequestData, audience string, identityID string, sessionState string) (string, error) {
+	token := jwt.New(jwt.SigningMethodRS256)
+	token.Header["kid"] = mgm.serviceAccountPrivateKey.KeyID
+	token.Header["typ"] = "logout+jwt"
+	claims := token.Claims.(jwt.MapClaims)
+	claims["iss"] = rest.AbsoluteURL(req, "")
+	claims["aud"] = audience
+	claims["iat"] = time.Now().Unix()
+	claims["jti"] = uuid.NewV4().String()
+	claims["sub"] = identityID
+	claims["sid"] = sessionState
+	claims["events"] = map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}}
+	tokenStr, err := token.SignedString(mgm.serviceAccountPrivateKey.Key)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return tokenStr, nil
+}
+
+// APIClientClaim is the claim of the reissued access tokens which holds the ID of the API client the token has been issued to
+const APIClientClaim = "api_client"
+
+// reissuedTokenClaims are the claims which identify the user and the session.
+// They are kept in the reissued access tokens whatever the claims allowed for the client.
+var reissuedTokenClaims = []string{"jti", "exp", "nbf", "iat", "iss", "aud", "sub", "typ", "azp", "session_state"}
+
+// ReissueAccessToken re-signs the given access token with the service account key.
+// The expiration of the new token is limited to the given lifetime in seconds (if not nil)
+// and only the given claims are kept (if not empty) in addition to the claims identifying the user and the session.
+// The ID of the API client the token is issued to is set in the "api_client" claim (if not empty).
+func (mgm *tokenManager) ReissueAccessToken(ctx context.Context, accessToken string, lifetime *int, claims []string, clientID string) (string, error) {
+	original, err := mgm.ParseTokenWithMapClaims(ctx, accessToken)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.New(jwt.SigningMethodRS256)
+	token.Header["kid"] = mgm.serviceAccountPrivateKey.KeyID
+	reissued := token.Claims.(jwt.MapClaims)
+	for name, value := range original {
+		if len(claims) == 0 || containsClaim(reissuedTokenClaims, name) || containsClaim(claims, name) {
+			reissued[name] = value
+		}
+	}
+	delete(reissued, APIClientClaim)
+	if clientID != "" {
+		reissued[APIClientClaim] = clientID
+	}
+	if lifetime != nil {
+		exp := time.Now().Unix() + int64(*lifetime)
+		if originalExp, err := NumberToInt(original["exp"]); err != nil || originalExp > exp {
+			reissued["exp"] = exp
+		}
+	}
+	tokenStr, err := token.SignedString(mgm.serviceAccountPrivateKey.Key)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return tokenStr, nil
+}
+
+func containsClaim(claims []string, name string) bool {
+	for _, claim := range claims {
+		if claim == name {
+			return true
+		}
+	}
+	return false
+}
+
 // IsSpecificServiceAccount checks if the request is done by a service account listed in the names param
 // based on the JWT Token provided in context
 func IsSpecificServiceAccount(ctx context.Context, names []string) bool {
@@ -454,6 +530,27 @@ func IsSpecificServiceAccount(ctx context.Context, names []string) bool {
 	return false
 }
 
+// ServiceAccountName returns the name of the service account which made the request
+// based on the JWT Token provided in context
+func ServiceAccountName(ctx context.Context) (string, bool) {
+	return extractServiceAccountName(ctx)
+}
+
+// ClientID returns the ID of the API client which made the request: the name of the service account
+// or the API client ("api_client" claim) the user token provided in context has been issued to.
+// User tokens which have not been issued to an API client have no client ID.
+func ClientID(ctx context.Context) (string, bool) {
+	if accountName, ok := extractServiceAccountName(ctx); ok {
+		return accountName, true
+	}
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return "", false
+	}
+	clientID, isString := token.Claims.(jwt.MapClaims)[APIClientClaim].(string)
+	return clientID, isString && clientID != ""
+}
+
 func extractServiceAccountName(ctx context.Context) (string, bool) {
 	token := goajwt.ContextJWT(ctx)
 	if token == nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rsa"
 	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/fabric8-services/fabric8-auth/account"
@@ -13,6 +14,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/token"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
 	goajwt "github.com/goadesign/goa/middleware/security/jwt"
 	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
@@ -76,6 +78,28 @@ func (s *TestTokenSuite) TestInvalidOAuthAccessToken() {
 	assert.NotNil(s.T(), err)
 }
 
+func (s *TestTokenSuite) TestGenerateLogoutToken() {
+	identityID := uuid.NewV4().String()
+	sessionState := uuid.NewV4().String()
+	req := &goa.RequestData{
+		Request: &http.Request{Host: "auth.openshift.io"},
+	}
+	logoutToken, err := s.tokenManager.GenerateLogoutToken(req, "fabric8-tenant", identityID, sessionState)
+	require.Nil(s.T(), err)
+
+	claims, err := s.tokenManager.ParseTokenWithMapClaims(context.Background(), logoutToken)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "fabric8-tenant", claims["aud"])
+	assert.Equal(s.T(), identityID, claims["sub"])
+	assert.Equal(s.T(), sessionState, claims["sid"])
+	assert.NotEmpty(s.T(), claims["jti"])
+	events, ok := claims["events"].(map[string]interface{})
+	require.True(s.T(), ok)
+	assert.Contains(s.T(), events, token.BackChannelLogoutEvent)
+	// logout tokens must not contain a nonce
+	assert.NotContains(s.T(), claims, "nonce")
+}
+
 func (s *TestTokenSuite) TestCheckClaimsOK() {
 	claims := &token.TokenClaims{
 		Email:    "somemail@domain.com",
@@ -118,6 +142,28 @@ func (s *TestTokenSuite) TestLocateTokenInContex() {
 	assert.Equal(s.T(), id, foundId, "ID in created context not equal")
 }
 
+func (s *TestTokenSuite) TestClientIDOfUserToken() {
+	// the authorized party of the Keycloak token is the same for all the clients
+	keycloakToken, err := testtoken.GenerateTokenWithClaims(map[string]interface{}{"azp": "fabric8-online-platform"})
+	require.Nil(s.T(), err)
+	claims, err := s.tokenManager.ParseTokenWithMapClaims(context.Background(), keycloakToken)
+	require.Nil(s.T(), err)
+	ctx := goajwt.WithJWT(context.Background(), &jwt.Token{Raw: keycloakToken, Claims: claims, Valid: true})
+	_, found := token.ClientID(ctx)
+	assert.False(s.T(), found)
+
+	// the token reissued to the API client
+	reissuedToken, err := s.tokenManager.ReissueAccessToken(context.Background(), keycloakToken, nil, nil, "vscode")
+	require.Nil(s.T(), err)
+	claims, err = s.tokenManager.ParseTokenWithMapClaims(context.Background(), reissuedToken)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "fabric8-online-platform", claims["azp"])
+	ctx = goajwt.WithJWT(context.Background(), &jwt.Token{Raw: reissuedToken, Claims: claims, Valid: true})
+	clientID, found := token.ClientID(ctx)
+	assert.True(s.T(), found)
+	assert.Equal(s.T(), "vscode", clientID)
+}
+
 func (s *TestTokenSuite) TestLocateMissingTokenInContext() {
 	ctx := context.Background()
 